@@ -2,7 +2,12 @@
 package args
 
 import (
+	"encoding/json"
 	"errors"
+	"fmt"
+	"strings"
+	"time"
+
 	sdkArgs "github.com/newrelic/infra-integrations-sdk/v3/args"
 	"github.com/newrelic/infra-integrations-sdk/v3/log"
 )
@@ -24,29 +29,178 @@ type ArgumentList struct {
 	Timeout                              string `default:"10" help:"Maximum wait for connection, in seconds. Set 0 for no timeout"`
 	CustomMetricsQuery                   string `default:"" help:"A SQL query to collect custom metrics. Must have the columns metric_name, metric_type, and metric_value. Additional columns are added as attributes"`
 	CustomMetricsConfig                  string `default:"" help:"YAML configuration with one or more custom SQL queries to collect"`
+	MetricCatalog                        string `default:"" help:"YAML metric catalog whose definitions are collected along with, or instead of, the built-in metrics of the instance, database, table and index samples"`
 	EnableSSL                            bool   `default:"false" help:"If true will use SSL encryption, false will not use encryption"`
 	TrustServerCertificate               bool   `default:"false" help:"If true server certificate is not verified for SSL. If false certificate will be verified against supplied certificate"`
 	Pgbouncer                            bool   `default:"false" help:"Collects metrics from PgBouncer instance. Assumes connection is through PgBouncer."`
-	CollectDbLockMetrics                 bool   `default:"false" help:"If true, enables collection of lock metrics for the specified database. (Note: requires that the 'tablefunc' extension is installed)"` //nolint: stylecheck
+	PgbouncerOnly                        bool   `default:"false" help:"Only collects metrics from the PgBouncer admin console, skipping all PostgreSQL collection"`
+	PgbouncerHostname                    string `default:"" help:"The PgBouncer hostname to connect to. Required by pgbouncer_only, and defaults to the PostgreSQL hostname otherwise"`
+	PgbouncerPort                        string `default:"" help:"The port to connect to the PgBouncer admin console. Required by pgbouncer_only, and defaults to the PostgreSQL port otherwise"`
+	PgbouncerUsername                    string `default:"" help:"The username for the PgBouncer admin console. Required by pgbouncer_only, and defaults to the PostgreSQL username otherwise"`
+	PgbouncerPassword                    string `default:"" help:"The password for the PgBouncer username. Required by pgbouncer_only, and defaults to the PostgreSQL password otherwise"`
+	Pgpool                               bool   `default:"false" help:"Collects metrics from Pgpool-II. Assumes connection is through Pgpool-II."`
+	CollectDbLockMetrics                 bool   `default:"false" help:"If true, enables collection of lock metrics for the specified database"` //nolint: stylecheck
 	CollectBloatMetrics                  bool   `default:"true" help:"Enable collecting bloat metrics which can be performance intensive"`
+	ConnectionBreakdownLimit             int    `default:"0" help:"Maximum number of user and application name combinations reported per database in PostgresqlConnectionSample. Set 0 to disable the breakdown"`
+	MaxConcurrentDatabases               int    `default:"10" help:"Maximum number of databases whose table and index metrics are collected concurrently, each using its own connection"`
+	StatementTimeout                     int    `default:"0" help:"Milliseconds after which PostgreSQL cancels a collection query, applied as statement_timeout on every connection. 0 keeps the server setting"`
+	CollectorStatementTimeouts           string `default:"{}" help:"A JSON object setting the milliseconds after which PostgreSQL cancels the queries of collectors, such as {\"bloat\": 60000}, instead of statement_timeout. 0 disables the timeout of a collector. See collector_intervals for the collector names"`
+	CollectionTimeout                    int    `default:"0" help:"Seconds after which the remaining collectors are skipped and the metrics collected so far are published. 0 disables the deadline"`
 	ShowVersion                          bool   `default:"false" help:"Print build information and exit"`
+	InstancesConfig                      string `default:"" help:"YAML configuration listing the PostgreSQL instances to collect in a single run. The other arguments are the defaults of every instance"`
+	MaxConcurrentInstances               int    `default:"5" help:"Maximum number of instances of the instances configuration collected concurrently"`
+	Daemon                               bool   `default:"false" help:"Keep running, collecting and publishing metrics every daemon_interval seconds, instead of collecting once and exiting"`
+	DaemonInterval                       int    `default:"15" help:"Seconds between the collection cycles of the daemon mode"`
+	DiscoveryInterval                    int    `default:"300" help:"Seconds after which the daemon mode refreshes the collection list, version and extensions of an instance"`
+	CollectorIntervals                   string `default:"{}" help:"A JSON object setting the seconds between the runs of collectors in the daemon mode, such as {\"bloat\": 3600}. Other collectors run every cycle. Collectors include instance, io, replication, replicationSlot, tablespace, database, connectionBreakdown, lock, table, bloat, index, customQuery, customMetricsConfig, pgpool, pgbouncer, inventory and queryMonitoring, with its waitEvents, blockingSessions, slowQueries, individualQueries, executionPlans and planChanges"`
 	EnableQueryMonitoring                bool   `default:"false" help:"Enable collection of detailed query performance metrics."`
 	QueryMonitoringResponseTimeThreshold int    `default:"1" help:"Threshold in milliseconds for query response time. If response time for the individual query exceeds this threshold, the individual query is reported in metrics"`
 	QueryMonitoringCountThreshold        int    `default:"20" help:"The number of records for each query performance metrics"`
+	QueryMonitoringPlanChangeThreshold   int    `default:"50" help:"Percentage by which the average execution time of a query must grow when its plan changes to report a PostgresPlanChangeEvent"`
 	IsRds                                bool   `default:"false" help:"If true, the integration will support on AWS RDS. This will enable RDS-specific metrics and configurations."`
 }
 
-// Validate validates PostgreSQl arguments
+// Validate validates PostgreSQl arguments. With an instances config, the arguments are only the defaults
+// of the instances, which are validated on their own.
 func (al ArgumentList) Validate() error {
-	if al.Username == "" || al.Password == "" {
+	if al.Daemon && al.DaemonInterval <= 0 {
+		return errors.New("invalid configuration: daemon_interval must be positive")
+	}
+	if al.InstancesConfig != "" {
+		return nil
+	}
+	if al.PgbouncerOnly {
+		if err := al.validatePgbouncerOnly(); err != nil {
+			return err
+		}
+	} else if al.Username == "" || al.Password == "" {
 		return errors.New("invalid configuration: must specify a username and password")
 	}
 	if err := al.validateSSL(); err != nil {
 		return err
 	}
+	if _, err := al.CollectorIntervalDurations(); err != nil {
+		return err
+	}
+	if _, err := al.CollectorStatementTimeoutDurations(); err != nil {
+		return err
+	}
 	return nil
 }
 
+// collectorNames are the collectors that collector_intervals and collector_statement_timeouts can be set for.
+// Collectors run by queryMonitoring default to its settings.
+var collectorNames = map[string]bool{
+	"version":             true,
+	"instance":            true,
+	"io":                  true,
+	"replication":         true,
+	"replicationSlot":     true,
+	"tablespace":          true,
+	"database":            true,
+	"connectionBreakdown": true,
+	"lock":                true,
+	"table":               true,
+	"bloat":               true,
+	"index":               true,
+	"customQuery":         true,
+	"customMetricsConfig": true,
+	"pgpool":              true,
+	"pgbouncer":           true,
+	"inventory":           true,
+	"queryMonitoring":     true,
+	"waitEvents":          true,
+	"blockingSessions":    true,
+	"slowQueries":         true,
+	"individualQueries":   true,
+	"executionPlans":      true,
+	"planChanges":         true,
+}
+
+// validatePgbouncerOnly checks that the PgBouncer only mode has its own connection settings, since it does not
+// connect to PostgreSQL
+func (al ArgumentList) validatePgbouncerOnly() error {
+	var missing []string
+	for _, setting := range []struct{ name, value string }{
+		{"pgbouncer_hostname", al.PgbouncerHostname},
+		{"pgbouncer_port", al.PgbouncerPort},
+		{"pgbouncer_username", al.PgbouncerUsername},
+		{"pgbouncer_password", al.PgbouncerPassword},
+	} {
+		if setting.value == "" {
+			missing = append(missing, setting.name)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("invalid configuration: pgbouncer_only requires %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// CollectorIntervalDurations returns the intervals of the collectors set by CollectorIntervals
+func (al ArgumentList) CollectorIntervalDurations() (map[string]time.Duration, error) {
+	var seconds map[string]int
+	if al.CollectorIntervals == "" {
+		return map[string]time.Duration{}, nil
+	}
+	if err := json.Unmarshal([]byte(al.CollectorIntervals), &seconds); err != nil {
+		return nil, fmt.Errorf("invalid configuration: collector_intervals must be a JSON object of seconds: %w", err)
+	}
+
+	intervals := make(map[string]time.Duration, len(seconds))
+	for name, s := range seconds {
+		if !collectorNames[name] {
+			return nil, fmt.Errorf("invalid configuration: unknown collector %s in collector_intervals", name)
+		}
+		// every collector depends on the version, which is cached for discovery_interval instead
+		if name == "version" {
+			return nil, errors.New("invalid configuration: the version collector cannot be given an interval")
+		}
+		if s <= 0 {
+			return nil, fmt.Errorf("invalid configuration: the interval of collector %s must be positive", name)
+		}
+		intervals[name] = time.Duration(s) * time.Second
+	}
+	return intervals, nil
+}
+
+// CollectorStatementTimeoutDurations returns the statement timeouts of the collectors set by CollectorStatementTimeouts
+func (al ArgumentList) CollectorStatementTimeoutDurations() (map[string]time.Duration, error) {
+	var milliseconds map[string]int
+	if al.CollectorStatementTimeouts == "" {
+		return map[string]time.Duration{}, nil
+	}
+	if err := json.Unmarshal([]byte(al.CollectorStatementTimeouts), &milliseconds); err != nil {
+		return nil, fmt.Errorf("invalid configuration: collector_statement_timeouts must be a JSON object of milliseconds: %w", err)
+	}
+
+	timeouts := make(map[string]time.Duration, len(milliseconds))
+	for name, ms := range milliseconds {
+		if !collectorNames[name] {
+			return nil, fmt.Errorf("invalid configuration: unknown collector %s in collector_statement_timeouts", name)
+		}
+		if ms < 0 {
+			return nil, fmt.Errorf("invalid configuration: the statement timeout of collector %s cannot be negative", name)
+		}
+		timeouts[name] = time.Duration(ms) * time.Millisecond
+	}
+	return timeouts, nil
+}
+
+// QueryMonitoringInterval returns the interval between the query monitoring runs of the daemon mode, or 0 when the
+// runs are scheduled by the agent
+func (al ArgumentList) QueryMonitoringInterval() time.Duration {
+	if !al.Daemon {
+		return 0
+	}
+	// the arguments are validated before they are collected
+	intervals, _ := al.CollectorIntervalDurations()
+	if interval, ok := intervals["queryMonitoring"]; ok {
+		return interval
+	}
+	return time.Duration(al.DaemonInterval) * time.Second
+}
+
 func (al ArgumentList) validateSSL() error {
 	if al.EnableSSL {
 		if !al.TrustServerCertificate && al.SSLRootCertLocation == "" {
@@ -60,3 +214,37 @@ func (al ArgumentList) validateSSL() error {
 
 	return nil
 }
+
+// HasPgbouncerConnectionSettings returns whether any of the PgBouncer admin console connection settings is set
+func (al ArgumentList) HasPgbouncerConnectionSettings() bool {
+	return al.PgbouncerHostname != "" || al.PgbouncerPort != "" || al.PgbouncerUsername != "" || al.PgbouncerPassword != ""
+}
+
+// PgbouncerHost returns the PgBouncer hostname, falling back to the PostgreSQL hostname unless in the PgBouncer only mode
+func (al ArgumentList) PgbouncerHost() string {
+	return al.pgbouncerSetting(al.PgbouncerHostname, al.Hostname)
+}
+
+// PgbouncerPortNumber returns the PgBouncer port, falling back to the PostgreSQL port unless in the PgBouncer only mode
+func (al ArgumentList) PgbouncerPortNumber() string {
+	return al.pgbouncerSetting(al.PgbouncerPort, al.Port)
+}
+
+// PgbouncerUser returns the PgBouncer username, falling back to the PostgreSQL username unless in the PgBouncer only mode
+func (al ArgumentList) PgbouncerUser() string {
+	return al.pgbouncerSetting(al.PgbouncerUsername, al.Username)
+}
+
+// PgbouncerPass returns the PgBouncer password, falling back to the PostgreSQL password unless in the PgBouncer only mode
+func (al ArgumentList) PgbouncerPass() string {
+	return al.pgbouncerSetting(al.PgbouncerPassword, al.Password)
+}
+
+// pgbouncerSetting returns value, or the PostgreSQL setting when value is not set and PgBouncer is collected along
+// with PostgreSQL
+func (al ArgumentList) pgbouncerSetting(value, postgresqlValue string) string {
+	if value == "" && !al.PgbouncerOnly {
+		return postgresqlValue
+	}
+	return value
+}
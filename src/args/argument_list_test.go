@@ -2,6 +2,7 @@ package args
 
 import (
 	"testing"
+	"time"
 )
 
 func TestValidate(t *testing.T) {
@@ -86,6 +87,117 @@ func TestValidate(t *testing.T) {
 			},
 			false,
 		},
+		{
+			"PgBouncer Only With PgBouncer Credentials",
+			&ArgumentList{
+				PgbouncerOnly:     true,
+				PgbouncerUsername: "pgbouncer",
+				PgbouncerPassword: "password",
+				PgbouncerHostname: "pgbouncer-host",
+				PgbouncerPort:     "6432",
+				CollectionList:    "{}",
+			},
+			false,
+		},
+		{
+			"PgBouncer Only Does Not Fall Back To PostgreSQL Settings",
+			&ArgumentList{
+				PgbouncerOnly:  true,
+				Username:       "user",
+				Password:       "password",
+				Hostname:       "localhost",
+				Port:           "6432",
+				CollectionList: "{}",
+			},
+			true,
+		},
+		{
+			"PgBouncer Only No Password",
+			&ArgumentList{
+				PgbouncerOnly:     true,
+				PgbouncerUsername: "pgbouncer",
+				PgbouncerHostname: "pgbouncer-host",
+				PgbouncerPort:     "6432",
+				Password:          "password",
+				CollectionList:    "{}",
+			},
+			true,
+		},
+		{
+			"Daemon Without Interval",
+			&ArgumentList{
+				Username:       "user",
+				Password:       "password",
+				Daemon:         true,
+				DaemonInterval: 0,
+			},
+			true,
+		},
+		{
+			"Collector Intervals",
+			&ArgumentList{
+				Username:           "user",
+				Password:           "password",
+				Daemon:             true,
+				DaemonInterval:     15,
+				CollectorIntervals: `{"bloat": 3600, "table": 60}`,
+			},
+			false,
+		},
+		{
+			"Invalid Collector Intervals",
+			&ArgumentList{
+				Username:           "user",
+				Password:           "password",
+				CollectorIntervals: `{"bloat": "hourly"}`,
+			},
+			true,
+		},
+		{
+			"Negative Collector Interval",
+			&ArgumentList{
+				Username:           "user",
+				Password:           "password",
+				CollectorIntervals: `{"bloat": -1}`,
+			},
+			true,
+		},
+		{
+			"Version Collector Interval",
+			&ArgumentList{
+				Username:           "user",
+				Password:           "password",
+				CollectorIntervals: `{"version": 60}`,
+			},
+			true,
+		},
+		{
+			"Unknown Collector Interval",
+			&ArgumentList{
+				Username:           "user",
+				Password:           "password",
+				CollectorIntervals: `{"tables": 60}`,
+			},
+			true,
+		},
+		{
+			"Unknown Collector Statement Timeout",
+			&ArgumentList{
+				Username:                   "user",
+				Password:                   "password",
+				CollectorStatementTimeouts: `{"blaot": 60000}`,
+			},
+			true,
+		},
+		{
+			"Negative Collector Statement Timeout",
+			&ArgumentList{
+				Username:                   "user",
+				Password:                   "password",
+				CollectorStatementTimeouts: `{"bloat": -1}`,
+			},
+			true,
+		},
 	}
 
 	for _, tc := range testCases {
@@ -97,3 +209,64 @@ func TestValidate(t *testing.T) {
 		}
 	}
 }
+
+func TestCollectorIntervalDurations(t *testing.T) {
+	al := ArgumentList{CollectorIntervals: `{"bloat": 3600, "connectionBreakdown": 15}`}
+	intervals, err := al.CollectorIntervalDurations()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(intervals) != 2 || intervals["bloat"] != time.Hour || intervals["connectionBreakdown"] != 15*time.Second {
+		t.Errorf("Unexpected intervals: %v", intervals)
+	}
+}
+
+func TestCollectorStatementTimeoutDurations(t *testing.T) {
+	al := ArgumentList{CollectorStatementTimeouts: `{"bloat": 60000, "queryMonitoring": 0}`}
+	timeouts, err := al.CollectorStatementTimeoutDurations()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(timeouts) != 2 || timeouts["bloat"] != time.Minute || timeouts["queryMonitoring"] != 0 {
+		t.Errorf("Unexpected statement timeouts: %v", timeouts)
+	}
+
+	al.CollectorStatementTimeouts = `["bloat"]`
+	if _, err := al.CollectorStatementTimeoutDurations(); err == nil {
+		t.Error("Expected error")
+	}
+}
+
+func TestQueryMonitoringInterval(t *testing.T) {
+	al := ArgumentList{DaemonInterval: 15, CollectorIntervals: `{"bloat": 3600}`}
+	if interval := al.QueryMonitoringInterval(); interval != 0 {
+		t.Errorf("Unexpected interval outside of the daemon mode: %s", interval)
+	}
+
+	al.Daemon = true
+	if interval := al.QueryMonitoringInterval(); interval != 15*time.Second {
+		t.Errorf("Unexpected interval: %s", interval)
+	}
+
+	al.CollectorIntervals = `{"queryMonitoring": 600}`
+	if interval := al.QueryMonitoringInterval(); interval != 10*time.Minute {
+		t.Errorf("Unexpected interval: %s", interval)
+	}
+}
+
+func TestPgbouncerSettings(t *testing.T) {
+	al := ArgumentList{Hostname: "postgres-host", Port: "5432", Username: "user", Password: "password", PgbouncerPort: "6432"}
+	if al.PgbouncerHost() != "postgres-host" || al.PgbouncerPortNumber() != "6432" || al.PgbouncerUser() != "user" || al.PgbouncerPass() != "password" {
+		t.Errorf("Unexpected PgBouncer settings: %s:%s %s", al.PgbouncerHost(), al.PgbouncerPortNumber(), al.PgbouncerUser())
+	}
+
+	// the PgBouncer only mode does not connect to PostgreSQL, so its settings are not used
+	al.PgbouncerOnly = true
+	if al.PgbouncerHost() != "" || al.PgbouncerUser() != "" || al.PgbouncerPass() != "" {
+		t.Errorf("Unexpected PgBouncer only settings: %s %s", al.PgbouncerHost(), al.PgbouncerUser())
+	}
+	err := al.Validate()
+	if err == nil || err.Error() != "invalid configuration: pgbouncer_only requires pgbouncer_hostname, pgbouncer_username, pgbouncer_password" {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,155 @@
+package args
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+
+	yaml "gopkg.in/yaml.v3"
+)
+
+// runArguments apply to the whole run and cannot be set per instance
+var runArguments = map[string]bool{
+	"instances_config":         true,
+	"max_concurrent_instances": true,
+	"collection_timeout":       true,
+	"show_version":             true,
+	"daemon":                   true,
+	"daemon_interval":          true,
+}
+
+type instancesConfig struct {
+	Instances []map[string]interface{} `yaml:"instances"`
+}
+
+// Instances returns the PostgreSQL instances to collect. Without an instances config, the argument list is the only
+// instance. Otherwise every instance of the config starts from the argument list and overrides the arguments it sets,
+// using the same names as the integration configuration (HOSTNAME, PORT, USERNAME, COLLECTION_LIST...).
+func (al ArgumentList) Instances() ([]ArgumentList, error) {
+	if al.InstancesConfig == "" {
+		return []ArgumentList{al}, nil
+	}
+
+	contents, err := os.ReadFile(al.InstancesConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read instances config: %w", err)
+	}
+
+	var config instancesConfig
+	if err := yaml.Unmarshal(contents, &config); err != nil {
+		return nil, fmt.Errorf("invalid instances config %s: %w", al.InstancesConfig, err)
+	}
+	if len(config.Instances) == 0 {
+		return nil, fmt.Errorf("instances config %s lists no instances", al.InstancesConfig)
+	}
+
+	instances := make([]ArgumentList, 0, len(config.Instances))
+	names := make(map[string]bool)
+	for i, overrides := range config.Instances {
+		instance := al
+		instance.InstancesConfig = ""
+		if err := instance.override(overrides); err != nil {
+			return nil, fmt.Errorf("instance %d: %w", i+1, err)
+		}
+
+		// entities are namespaced by the instance host and port, which must be unique within the payload
+		name := instance.InstanceName()
+		if names[name] {
+			return nil, fmt.Errorf("instance %s is listed more than once", name)
+		}
+		names[name] = true
+		instances = append(instances, instance)
+	}
+	return instances, nil
+}
+
+// InstanceName returns the name of the instance entity, which namespaces all the entities of the instance
+func (al ArgumentList) InstanceName() string {
+	if al.PgbouncerOnly {
+		return fmt.Sprintf("%s:%s", al.PgbouncerHost(), al.PgbouncerPortNumber())
+	}
+	return fmt.Sprintf("%s:%s", al.Hostname, al.Port)
+}
+
+// override sets the arguments named by the keys of overrides. Keys are case insensitive, and structured values are
+// encoded as JSON, so JSON arguments such as COLLECTION_LIST can be written as YAML.
+func (al *ArgumentList) override(overrides map[string]interface{}) error {
+	fields := make(map[string]reflect.Value)
+	v := reflect.ValueOf(al).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		if v.Type().Field(i).Anonymous {
+			continue
+		}
+		fields[argumentName(v.Type().Field(i).Name)] = v.Field(i)
+	}
+
+	for key, value := range overrides {
+		name := strings.ToLower(key)
+		if runArguments[name] {
+			return fmt.Errorf("%s applies to the whole run and cannot be set per instance", key)
+		}
+		field, ok := fields[name]
+		if !ok {
+			return fmt.Errorf("unknown argument %s", key)
+		}
+
+		s, err := argumentValue(value)
+		if err != nil {
+			return fmt.Errorf("invalid value for %s: %w", key, err)
+		}
+
+		switch field.Kind() {
+		case reflect.String:
+			field.SetString(s)
+		case reflect.Int:
+			n, err := strconv.Atoi(s)
+			if err != nil {
+				return fmt.Errorf("invalid value for %s: not an integer", key)
+			}
+			field.SetInt(int64(n))
+		case reflect.Bool:
+			b, err := strconv.ParseBool(s)
+			if err != nil {
+				return fmt.Errorf("invalid value for %s: not a boolean", key)
+			}
+			field.SetBool(b)
+		default:
+			return fmt.Errorf("%s cannot be set per instance", key)
+		}
+	}
+	return nil
+}
+
+func argumentValue(value interface{}) (string, error) {
+	switch value := value.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return value, nil
+	case []interface{}, map[string]interface{}:
+		encoded, err := json.Marshal(value)
+		return string(encoded), err
+	default:
+		return fmt.Sprint(value), nil
+	}
+}
+
+var camel = regexp.MustCompile("(^[^A-Z]*|[A-Z]*)([A-Z][^A-Z]+|$)")
+
+// argumentName returns the name of the argument of a field, as the SDK derives it for flags and environment variables
+func argumentName(fieldName string) string {
+	var parts []string
+	for _, sub := range camel.FindAllStringSubmatch(fieldName, -1) {
+		if sub[1] != "" {
+			parts = append(parts, sub[1])
+		}
+		if sub[2] != "" {
+			parts = append(parts, sub[2])
+		}
+	}
+	return strings.ToLower(strings.Join(parts, "_"))
+}
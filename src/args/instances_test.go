@@ -0,0 +1,127 @@
+package args
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeInstancesConfig(t *testing.T, contents string) string {
+	path := filepath.Join(t.TempDir(), "instances.yml")
+	require.NoError(t, os.WriteFile(path, []byte(contents), 0600))
+	return path
+}
+
+func TestInstances_WithoutConfig(t *testing.T) {
+	al := ArgumentList{Hostname: "localhost", Port: "5432"}
+
+	instances, err := al.Instances()
+	require.NoError(t, err)
+	assert.Equal(t, []ArgumentList{al}, instances)
+}
+
+func TestInstances(t *testing.T) {
+	defaults := ArgumentList{
+		Username:               "monitor",
+		Password:               "secret",
+		Hostname:               "localhost",
+		Port:                   "5432",
+		CollectionList:         "{}",
+		CollectBloatMetrics:    true,
+		MaxConcurrentDatabases: 10,
+		InstancesConfig: writeInstancesConfig(t, `instances:
+  - HOSTNAME: db1.example.com
+    COLLECTION_LIST: '["postgres"]'
+  - hostname: db2.example.com
+    port: 5433
+    password: other
+    collection_list:
+      app:
+        public: [users]
+    collect_bloat_metrics: false
+    max_concurrent_databases: 2
+    ENABLE_SSL: "true"
+`),
+	}
+
+	instances, err := defaults.Instances()
+	require.NoError(t, err)
+	require.Len(t, instances, 2)
+
+	assert.Equal(t, "db1.example.com:5432", instances[0].InstanceName())
+	assert.Equal(t, `["postgres"]`, instances[0].CollectionList)
+	assert.Equal(t, "secret", instances[0].Password)
+	assert.True(t, instances[0].CollectBloatMetrics)
+	assert.Empty(t, instances[0].InstancesConfig)
+
+	assert.Equal(t, "db2.example.com:5433", instances[1].InstanceName())
+	assert.Equal(t, `{"app":{"public":["users"]}}`, instances[1].CollectionList)
+	assert.Equal(t, "monitor", instances[1].Username)
+	assert.Equal(t, "other", instances[1].Password)
+	assert.False(t, instances[1].CollectBloatMetrics)
+	assert.Equal(t, 2, instances[1].MaxConcurrentDatabases)
+	assert.True(t, instances[1].EnableSSL)
+}
+
+func TestInstances_Errors(t *testing.T) {
+	testCases := []struct {
+		name     string
+		contents string
+		err      string
+	}{
+		{
+			name:     "no instances",
+			contents: "instances: []",
+			err:      "lists no instances",
+		},
+		{
+			name:     "unknown argument",
+			contents: "instances:\n  - HOST: db1",
+			err:      "instance 1: unknown argument HOST",
+		},
+		{
+			name:     "run argument",
+			contents: "instances:\n  - HOSTNAME: db1\n  - HOSTNAME: db2\n    COLLECTION_TIMEOUT: 10",
+			err:      "instance 2: COLLECTION_TIMEOUT applies to the whole run and cannot be set per instance",
+		},
+		{
+			name:     "invalid integer",
+			contents: "instances:\n  - PORT: 5432\n    MAX_CONCURRENT_DATABASES: many",
+			err:      "instance 1: invalid value for MAX_CONCURRENT_DATABASES: not an integer",
+		},
+		{
+			name:     "invalid boolean",
+			contents: "instances:\n  - ENABLE_SSL: maybe",
+			err:      "instance 1: invalid value for ENABLE_SSL: not a boolean",
+		},
+		{
+			name:     "default argument",
+			contents: "instances:\n  - VERBOSE: true",
+			err:      "instance 1: unknown argument VERBOSE",
+		},
+		{
+			name:     "duplicate instance",
+			contents: "instances:\n  - HOSTNAME: db1\n  - HOSTNAME: db1\n    DATABASE: app",
+			err:      "instance db1:5432 is listed more than once",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			al := ArgumentList{Hostname: "localhost", Port: "5432", InstancesConfig: writeInstancesConfig(t, tc.contents)}
+			_, err := al.Instances()
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tc.err)
+		})
+	}
+}
+
+func TestArgumentName(t *testing.T) {
+	assert.Equal(t, "collection_list", argumentName("CollectionList"))
+	assert.Equal(t, "enable_ssl", argumentName("EnableSSL"))
+	assert.Equal(t, "ssl_root_cert_location", argumentName("SSLRootCertLocation"))
+	assert.Equal(t, "query_monitoring_response_time_threshold", argumentName("QueryMonitoringResponseTimeThreshold"))
+}
@@ -2,6 +2,7 @@
 package collection
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"errors"
@@ -37,8 +38,8 @@ type ignoreList map[string]struct{}
 // BuildCollectionList unmarshals the collection_list from the args and builds the list of
 // objects to be collected. If collection_list is a JSON array, it collects every object in
 // each of the databases listed in the array. If it is a hash, it collects only the objects
-// listed
-func BuildCollectionList(al args.ArgumentList, ci connection.Info) (DatabaseList, error) {
+// listed. The queries building it run with ctx
+func BuildCollectionList(ctx context.Context, al args.ArgumentList, ci connection.Info) (DatabaseList, error) {
 	var dbList DatabaseList
 	var dbNames []string
 	var err error
@@ -55,7 +56,7 @@ func BuildCollectionList(al args.ArgumentList, ci connection.Info) (DatabaseList
 
 	switch {
 	case strings.ToLower(al.CollectionList) == "all":
-		if dbNames, err = getAllDatabaseNames(ci); err != nil {
+		if dbNames, err = getAllDatabaseNames(ctx, ci); err != nil {
 			return nil, fmt.Errorf("failed to get all databases names: %w", err)
 		}
 
@@ -70,7 +71,7 @@ func BuildCollectionList(al args.ArgumentList, ci connection.Info) (DatabaseList
 	}
 
 	if len(dbNames) != 0 {
-		if dbList, err = buildCollectionListFromDatabaseNames(dbNames, ignoreDBList, ignoreTableList, ci); err != nil {
+		if dbList, err = buildCollectionListFromDatabaseNames(ctx, dbNames, ignoreDBList, ignoreTableList, ci); err != nil {
 			return nil, err
 		}
 	}
@@ -97,12 +98,13 @@ func parseIgnoreList(list string) (ignoreList, error) {
 	return ignoreMap, nil
 }
 
-func getAllDatabaseNames(ci connection.Info) ([]string, error) {
+func getAllDatabaseNames(ctx context.Context, ci connection.Info) ([]string, error) {
 	con, err := ci.NewConnection(ci.DatabaseName())
 	if err != nil {
 		return nil, err
 	}
 	defer con.Close()
+	con = con.WithContext(ctx)
 
 	var dataModel []struct {
 		DatabaseName sql.NullString `db:"datname"`
@@ -122,7 +124,7 @@ func getAllDatabaseNames(ci connection.Info) ([]string, error) {
 	return databaseNames, nil
 }
 
-func buildCollectionListFromDatabaseNames(dbnames []string, ignoreDBList, ignoreTableList ignoreList, ci connection.Info) (DatabaseList, error) {
+func buildCollectionListFromDatabaseNames(ctx context.Context, dbnames []string, ignoreDBList, ignoreTableList ignoreList, ci connection.Info) (DatabaseList, error) {
 	databaseList := DatabaseList{}
 	for _, db := range dbnames {
 		if _, ok := ignoreDBList[db]; ok {
@@ -136,7 +138,7 @@ func buildCollectionListFromDatabaseNames(dbnames []string, ignoreDBList, ignore
 		}
 		defer con.Close()
 
-		schemaList, err := buildSchemaListForDatabase(con, ignoreTableList)
+		schemaList, err := buildSchemaListForDatabase(con.WithContext(ctx), ignoreTableList)
 		if err != nil {
 			log.Error("Failed to build schema list for database '%s': %s", db, err)
 			continue
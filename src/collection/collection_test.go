@@ -1,6 +1,7 @@
 package collection
 
 import (
+	"context"
 	"testing"
 
 	"github.com/newrelic/nri-postgresql/src/args"
@@ -107,7 +108,7 @@ func TestBuildCollectionList_DatabaseList(t *testing.T) {
 		},
 	}
 
-	dl, err := BuildCollectionList(al, &ci)
+	dl, err := BuildCollectionList(context.Background(), al, &ci)
 	assert.Nil(t, err)
 	assert.Equal(t, expected, dl)
 	assert.NoError(t, mock1.ExpectationsWereMet())
@@ -131,7 +132,7 @@ func TestBuildCollectionList_DetailedList(t *testing.T) {
 		},
 	}
 
-	dl, err := BuildCollectionList(al, nil)
+	dl, err := BuildCollectionList(context.Background(), al, nil)
 	assert.Nil(t, err)
 	assert.Equal(t, expected, dl)
 }
@@ -168,7 +169,7 @@ func TestBuildCollectionList_All(t *testing.T) {
 		},
 	}
 
-	dl, err := BuildCollectionList(al, &ci)
+	dl, err := BuildCollectionList(context.Background(), al, &ci)
 	assert.Nil(t, err)
 	assert.Equal(t, expected, dl)
 
@@ -208,7 +209,7 @@ func TestBuildCollectionList_IgnoreTables(t *testing.T) {
 		},
 	}
 
-	dl, err := BuildCollectionList(al, &ci)
+	dl, err := BuildCollectionList(context.Background(), al, &ci)
 	assert.Nil(t, err)
 	assert.Equal(t, expected, dl)
 	assert.NoError(t, mock1.ExpectationsWereMet())
@@ -0,0 +1,298 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/newrelic/infra-integrations-sdk/v3/integration"
+	"github.com/newrelic/infra-integrations-sdk/v3/log"
+	"github.com/newrelic/infra-integrations-sdk/v3/persist"
+	"github.com/newrelic/nri-postgresql/src/args"
+	"github.com/newrelic/nri-postgresql/src/collection"
+	"github.com/newrelic/nri-postgresql/src/connection"
+	"github.com/newrelic/nri-postgresql/src/inventory"
+	"github.com/newrelic/nri-postgresql/src/metrics"
+	queryperformancemonitoring "github.com/newrelic/nri-postgresql/src/query-performance-monitoring"
+)
+
+// statementSnapshotsTTL is how long the entries of the pg_stat_statements snapshot store are kept without being updated,
+// which must be at least the retention of the plan history kept in the same store
+const statementSnapshotsTTL = 7 * 24 * time.Hour
+
+// instanceCollector collects an instance. Its connections, collection list and capabilities are kept between
+// the cycles of the daemon mode, the collection list and capabilities being refreshed every discovery interval.
+type instanceCollector struct {
+	args           args.ArgumentList
+	connectionInfo connection.Info
+	// PgBouncer admin console collected along with PostgreSQL, or nil
+	pgbouncerConnectionInfo connection.Info
+	catalog                 *metrics.Catalog
+	schedule                *metrics.Schedule
+	statementTimeouts       map[string]time.Duration
+	capabilities            *metrics.Capabilities
+	discoveryInterval       time.Duration
+	// pg_stat_statements counters of the previous run, kept on disk so that slow queries are computed between runs
+	statementSnapshots persist.Storer
+
+	collectionList collection.DatabaseList
+	discoveredAt   time.Time
+	// telemetry of the last cycle, populated once every instance is collected
+	telemetry *metrics.CollectionTelemetry
+}
+
+// newInstanceCollectors returns the collectors of the instances. An instance with an invalid configuration
+// is logged and does not stop the others.
+func newInstanceCollectors(instances []args.ArgumentList) []*instanceCollector {
+	collectors := make([]*instanceCollector, 0, len(instances))
+	for _, instance := range instances {
+		c, err := newInstanceCollector(instance)
+		if err != nil {
+			log.Error("Configuration error for instance %s: %s", instance.InstanceName(), err.Error())
+			continue
+		}
+		collectors = append(collectors, c)
+	}
+	return collectors
+}
+
+func newInstanceCollector(args args.ArgumentList) (*instanceCollector, error) {
+	if err := args.Validate(); err != nil {
+		return nil, err
+	}
+
+	statementTimeouts, err := args.CollectorStatementTimeoutDurations()
+	if err != nil {
+		return nil, err
+	}
+	c := &instanceCollector{
+		args:              args,
+		discoveryInterval: time.Duration(args.DiscoveryInterval) * time.Second,
+		statementTimeouts: statementTimeouts,
+	}
+	if args.Daemon {
+		intervals, err := args.CollectorIntervalDurations()
+		if err != nil {
+			return nil, err
+		}
+		c.schedule = metrics.NewSchedule(time.Duration(args.DaemonInterval)*time.Second, intervals)
+		c.capabilities = metrics.NewCapabilities(c.discoveryInterval)
+	}
+
+	// PgBouncer only mode talks exclusively to the PgBouncer admin console
+	if args.PgbouncerOnly {
+		c.connectionInfo = connection.PgBouncerConnectionInfo(&args)
+		return c, nil
+	}
+
+	if args.EnableQueryMonitoring {
+		c.statementSnapshots = newStatementSnapshots(args)
+	}
+
+	c.connectionInfo = connection.DefaultConnectionInfo(&args)
+	// Without its own connection settings, PgBouncer is reached through the PostgreSQL connection settings
+	if args.Pgbouncer {
+		c.pgbouncerConnectionInfo = c.connectionInfo
+		if args.HasPgbouncerConnectionSettings() {
+			c.pgbouncerConnectionInfo = connection.PgBouncerConnectionInfo(&args)
+		}
+	}
+	if args.HasMetrics() {
+		// Without a valid catalog only the built-in definitions are collected
+		catalog, err := metrics.LoadCatalog(args.MetricCatalog)
+		if err != nil {
+			log.Error("Failed to load metric catalog, collecting built-in metrics only: %s", err.Error())
+		}
+		c.catalog = catalog
+	}
+	return c, nil
+}
+
+// newStatementSnapshots returns the store of the pg_stat_statements snapshots of the instance. Entries are kept long
+// after the snapshots expire, so that expired snapshots are told apart from missing ones, and without a usable
+// temporary directory they are only kept while the process runs.
+func newStatementSnapshots(args args.ArgumentList) persist.Storer {
+	logger := log.NewStdErr(args.Verbose)
+	instanceID := strings.NewReplacer(":", "-", "/", "-", "\\", "-").Replace(args.InstanceName())
+	storePath, err := persist.NewStorePath(integrationName+"-statements", instanceID, args.TempDir, logger, statementSnapshotsTTL)
+	if err == nil {
+		var store persist.Storer
+		if store, err = persist.NewFileStore(storePath.GetFilePath(), logger, statementSnapshotsTTL); err == nil {
+			return store
+		}
+	}
+	log.Warn("Failed to create the pg_stat_statements snapshot store of instance %s, keeping snapshots in memory: %s", args.InstanceName(), err.Error())
+	return persist.NewInMemoryStore()
+}
+
+// context returns a copy of ctx carrying the telemetry, schedule, capabilities and collector statement timeouts
+// of the instance
+func (c *instanceCollector) context(ctx context.Context) context.Context {
+	ctx = metrics.WithTelemetry(ctx, c.telemetry)
+	if len(c.statementTimeouts) > 0 {
+		ctx = metrics.WithStatementTimeouts(ctx, c.statementTimeouts)
+	}
+	if c.schedule != nil {
+		ctx = metrics.WithSchedule(ctx, c.schedule)
+	}
+	if c.capabilities != nil {
+		ctx = metrics.WithCapabilities(ctx, c.capabilities)
+	}
+	return ctx
+}
+
+// collect collects the metrics and inventory of the instance into pgIntegration for the cycle started at now
+func (c *instanceCollector) collect(ctx context.Context, pgIntegration *integration.Integration, now time.Time) error {
+	if c.schedule != nil {
+		c.schedule.StartCycle(now)
+	}
+
+	if c.args.PgbouncerOnly {
+		if c.args.HasMetrics() {
+			metrics.CollectPgBouncerMetrics(ctx, c.connectionInfo, pgIntegration)
+		}
+		return nil
+	}
+
+	// A collection list that cannot be refreshed is replaced once it can be built again
+	if c.discoveredAt.IsZero() || now.Sub(c.discoveredAt) >= c.discoveryInterval {
+		collectionList, err := collection.BuildCollectionList(ctx, c.args, c.connectionInfo)
+		switch {
+		case err == nil:
+			c.collectionList = collectionList
+			c.discoveredAt = now
+			// connections to the databases that left the collection list are not used anymore
+			databases := make([]string, 0, len(collectionList))
+			for database := range collectionList {
+				databases = append(databases, database)
+			}
+			c.connectionInfo.CloseExcept(databases)
+		case c.discoveredAt.IsZero():
+			return fmt.Errorf("error creating list of entities to collect: %w", err)
+		default:
+			log.Warn("Failed to refresh the list of entities to collect, collecting the previous one: %s", err.Error())
+		}
+	}
+
+	instance, err := pgIntegration.Entity(c.args.InstanceName(), "pg-instance")
+	if err != nil {
+		return fmt.Errorf("error creating instance entity: %w", err)
+	}
+
+	// Every collector reports how it went in PostgresqlIntegrationSample
+	c.telemetry = metrics.NewCollectionTelemetry()
+	ctx = c.context(ctx)
+
+	if c.args.HasMetrics() {
+		metrics.PopulateMetrics(ctx, c.connectionInfo, c.collectionList, instance, pgIntegration, c.pgbouncerConnectionInfo, c.args.Pgpool, c.args.CollectDbLockMetrics, c.args.CollectBloatMetrics, c.args.ConnectionBreakdownLimit, c.args.MaxConcurrentDatabases, c.args.CustomMetricsQuery, c.catalog)
+		if c.args.CustomMetricsConfig != "" {
+			metrics.RunCollector(ctx, "customMetricsConfig", func(ctx context.Context) {
+				metrics.PopulateCustomMetricsFromFile(ctx, c.connectionInfo, c.args.CustomMetricsConfig, pgIntegration)
+			})
+		}
+	}
+
+	if c.args.HasInventory() {
+		metrics.RunCollector(ctx, "inventory", func(ctx context.Context) {
+			con, err := c.connectionInfo.NewConnection(c.connectionInfo.DatabaseName())
+			if err != nil {
+				log.Error("Inventory collection failed: error creating connection to PostgreSQL: %s", err.Error())
+				return
+			}
+			defer con.Close()
+			inventory.PopulateInventory(instance, con.WithContext(ctx))
+		})
+	}
+
+	return nil
+}
+
+// monitorsQueries returns whether the query performance of the instance is monitored in the current cycle
+func (c *instanceCollector) monitorsQueries() bool {
+	return c.args.EnableQueryMonitoring && !c.args.PgbouncerOnly && c.telemetry != nil
+}
+
+func (c *instanceCollector) close() {
+	c.connectionInfo.Close()
+	if c.pgbouncerConnectionInfo != nil && c.pgbouncerConnectionInfo != c.connectionInfo {
+		c.pgbouncerConnectionInfo.Close()
+	}
+}
+
+// collectCycle collects every instance into pgIntegration, with at most maxConcurrentInstances instances collected
+// concurrently, and publishes them. An instance that fails is logged and does not stop the others. It returns false,
+// without publishing, when no instance could be collected.
+func collectCycle(ctx context.Context, collectors []*instanceCollector, maxConcurrentInstances int, pgIntegration *integration.Integration) bool {
+	if maxConcurrentInstances < 1 {
+		maxConcurrentInstances = 1
+	}
+
+	now := time.Now()
+	collected := make([]*instanceCollector, len(collectors))
+	sem := make(chan struct{}, maxConcurrentInstances)
+	wg := sync.WaitGroup{}
+	for i, c := range collectors {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(i int, c *instanceCollector) {
+			defer wg.Done()
+			defer func() {
+				<-sem
+			}()
+
+			c.telemetry = nil
+			if err := c.collect(ctx, pgIntegration, now); err != nil {
+				log.Error("Failed to collect instance %s: %s", c.args.InstanceName(), err.Error())
+				return
+			}
+			collected[i] = c
+		}(i, c)
+	}
+	wg.Wait()
+
+	ok := false
+	for _, c := range collected {
+		ok = ok || c != nil
+	}
+	if !ok {
+		return false
+	}
+
+	// Query performance metrics are published in batches, which clears the entities of the integration,
+	// so the instances are monitored one after the other once the metrics of every instance are published
+	published := false
+	for _, c := range collected {
+		if c == nil || !c.monitorsQueries() {
+			continue
+		}
+		if !published {
+			publish(pgIntegration)
+			published = true
+		}
+		queryperformancemonitoring.QueryPerformanceMain(c.context(ctx), c.args, pgIntegration, c.collectionList, c.connectionInfo, c.statementSnapshots)
+	}
+
+	for _, c := range collected {
+		if c == nil || c.telemetry == nil {
+			continue
+		}
+		// Publishing clears the entities, so the instance entity is retrieved again for the telemetry
+		instance, err := pgIntegration.Entity(c.args.InstanceName(), "pg-instance")
+		if err != nil {
+			log.Error("Error creating instance entity: %s", err.Error())
+			continue
+		}
+		c.telemetry.Populate(instance)
+	}
+
+	publish(pgIntegration)
+	return true
+}
+
+func publish(pgIntegration *integration.Integration) {
+	if err := pgIntegration.Publish(); err != nil {
+		log.Error(err.Error())
+	}
+}
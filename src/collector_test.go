@@ -0,0 +1,249 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/newrelic/infra-integrations-sdk/v3/integration"
+	"github.com/newrelic/infra-integrations-sdk/v3/persist"
+	"github.com/newrelic/nri-postgresql/src/args"
+	"github.com/newrelic/nri-postgresql/src/connection"
+	commonparameters "github.com/newrelic/nri-postgresql/src/query-performance-monitoring/common-parameters"
+	"github.com/newrelic/nri-postgresql/src/query-performance-monitoring/datamodels"
+	performancemetrics "github.com/newrelic/nri-postgresql/src/query-performance-monitoring/performance-metrics"
+	"github.com/stretchr/testify/assert"
+	tmock "github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func testArgs() args.ArgumentList {
+	instanceArgs := args.ArgumentList{
+		Hostname:          "postgres-host",
+		Port:              "5432",
+		Username:          "user",
+		Password:          "password",
+		DiscoveryInterval: 300,
+	}
+	instanceArgs.Metrics = true
+	return instanceArgs
+}
+
+func TestNewInstanceCollectorPgBouncer(t *testing.T) {
+	// without PgBouncer settings, PgBouncer is reached through the PostgreSQL connection settings
+	instanceArgs := testArgs()
+	instanceArgs.Pgbouncer = true
+	c, err := newInstanceCollector(instanceArgs)
+	require.NoError(t, err)
+	assert.Same(t, c.connectionInfo, c.pgbouncerConnectionInfo)
+
+	instanceArgs.PgbouncerHostname = "pgbouncer-host"
+	instanceArgs.PgbouncerPort = "6432"
+	c, err = newInstanceCollector(instanceArgs)
+	require.NoError(t, err)
+	require.NotNil(t, c.pgbouncerConnectionInfo)
+	host, port := c.pgbouncerConnectionInfo.HostPort()
+	assert.Equal(t, "pgbouncer-host", host)
+	assert.Equal(t, "6432", port)
+	assert.Equal(t, connection.PgBouncerAdminDatabase, c.pgbouncerConnectionInfo.DatabaseName())
+	host, _ = c.connectionInfo.HostPort()
+	assert.Equal(t, "postgres-host", host)
+
+	instanceArgs.Pgbouncer = false
+	c, err = newInstanceCollector(instanceArgs)
+	require.NoError(t, err)
+	assert.Nil(t, c.pgbouncerConnectionInfo)
+}
+
+// mockCollector returns the collector of instanceArgs, whose queries all fail
+func mockCollector(t *testing.T, instanceArgs args.ArgumentList) *instanceCollector {
+	c, err := newInstanceCollector(instanceArgs)
+	require.NoError(t, err)
+
+	testConnection, _ := connection.CreateMockSQL(t)
+	ci := &connection.MockInfo{}
+	ci.On("NewConnection", tmock.Anything).Return(testConnection, nil)
+	c.connectionInfo = ci
+	return c
+}
+
+// collectorStatuses returns the status of every collector reported in the PostgresqlIntegrationSample of the
+// published payloads, by instance
+func collectorStatuses(t *testing.T, output *bytes.Buffer) map[string]map[string]string {
+	statuses := make(map[string]map[string]string)
+	decoder := json.NewDecoder(output)
+	for decoder.More() {
+		var payload struct {
+			Data []struct {
+				Metrics []map[string]interface{} `json:"metrics"`
+			} `json:"data"`
+		}
+		require.NoError(t, decoder.Decode(&payload))
+		for _, entity := range payload.Data {
+			for _, sample := range entity.Metrics {
+				if sample["event_type"] != "PostgresqlIntegrationSample" || sample["collector"] == "run" {
+					continue
+				}
+				instance := sample["displayName"].(string)
+				if statuses[instance] == nil {
+					statuses[instance] = make(map[string]string)
+				}
+				statuses[instance][sample["collector"].(string)] = sample["collector.status"].(string)
+			}
+		}
+	}
+	output.Reset()
+	return statuses
+}
+
+func TestNewInstanceCollectors(t *testing.T) {
+	valid := testArgs()
+	invalid := testArgs()
+	invalid.Hostname = "other-host"
+	invalid.Password = ""
+
+	collectors := newInstanceCollectors([]args.ArgumentList{valid, invalid})
+	require.Len(t, collectors, 1)
+	assert.Equal(t, "postgres-host:5432", collectors[0].args.InstanceName())
+
+	daemon := testArgs()
+	daemon.Daemon = true
+	daemon.DaemonInterval = 15
+	daemon.CollectorIntervals = `{"bloat": 3600}`
+	collectors = newInstanceCollectors([]args.ArgumentList{daemon})
+	require.Len(t, collectors, 1)
+	assert.NotNil(t, collectors[0].schedule)
+	assert.NotNil(t, collectors[0].capabilities)
+}
+
+func TestCollectCyclePartialFailure(t *testing.T) {
+	var output bytes.Buffer
+	pgIntegration, err := integration.New("test", "test", integration.Writer(&output))
+	require.NoError(t, err)
+
+	healthy := testArgs()
+	healthy.CollectionList = "{}"
+	broken := testArgs()
+	broken.Hostname = "broken-host"
+	broken.CollectionList = "not a collection list"
+
+	collectors := []*instanceCollector{mockCollector(t, broken), mockCollector(t, healthy)}
+	assert.True(t, collectCycle(context.Background(), collectors, 2, pgIntegration))
+	// the instance that fails is not reported, and does not stop the other
+	assert.Equal(t, map[string]map[string]string{
+		"postgres-host:5432": {"version": "failed"},
+	}, collectorStatuses(t, &output))
+
+	assert.False(t, collectCycle(context.Background(), collectors[:1], 2, pgIntegration))
+	assert.Zero(t, output.Len())
+}
+
+func TestCollectCycleSchedule(t *testing.T) {
+	var output bytes.Buffer
+	pgIntegration, err := integration.New("test", "test", integration.Writer(&output))
+	require.NoError(t, err)
+
+	instanceArgs := testArgs()
+	instanceArgs.CollectionList = "{}"
+	instanceArgs.Inventory = true
+	instanceArgs.Daemon = true
+	instanceArgs.DaemonInterval = 15
+	instanceArgs.CollectorIntervals = `{"inventory": 3600}`
+	collectors := []*instanceCollector{mockCollector(t, instanceArgs)}
+
+	// a collector skipped by the collection deadline runs in the next cycle, and then waits for its interval
+	expired, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.True(t, collectCycle(expired, collectors, 1, pgIntegration))
+	assert.Equal(t, "skipped", collectorStatuses(t, &output)["postgres-host:5432"]["inventory"])
+
+	assert.True(t, collectCycle(context.Background(), collectors, 1, pgIntegration))
+	assert.Contains(t, collectorStatuses(t, &output)["postgres-host:5432"], "inventory")
+
+	assert.True(t, collectCycle(context.Background(), collectors, 1, pgIntegration))
+	assert.NotContains(t, collectorStatuses(t, &output)["postgres-host:5432"], "inventory")
+}
+
+func TestRunCycles(t *testing.T) {
+	var output bytes.Buffer
+	pgIntegration, err := integration.New("test", "test", integration.Writer(&output))
+	require.NoError(t, err)
+
+	instanceArgs := testArgs()
+	instanceArgs.CollectionList = "{}"
+	collectors := []*instanceCollector{mockCollector(t, instanceArgs)}
+
+	// the first cycle runs right away, even when the daemon is already stopping
+	stopped, cancel := context.WithCancel(context.Background())
+	cancel()
+	runCycles(stopped, collectors, time.Hour, 0, 1, pgIntegration)
+	assert.Equal(t, 1, publishedPayloads(t, &output))
+
+	// cycles then run every interval until the context is done
+	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+	defer cancel()
+	runCycles(ctx, collectors, 100*time.Millisecond, 0, 1, pgIntegration)
+	assert.GreaterOrEqual(t, publishedPayloads(t, &output), 2)
+}
+
+func publishedPayloads(t *testing.T, output *bytes.Buffer) int {
+	payloads := 0
+	for decoder := json.NewDecoder(output); decoder.More(); payloads++ {
+		var payload map[string]interface{}
+		require.NoError(t, decoder.Decode(&payload))
+	}
+	output.Reset()
+	return payloads
+}
+
+func TestNewStatementSnapshots(t *testing.T) {
+	instanceArgs := testArgs()
+	instanceArgs.TempDir = t.TempDir()
+	instanceArgs.CacheTTL = time.Minute
+
+	// snapshots are kept beyond the cache TTL, to be compared with the query monitoring interval instead
+	persist.SetNow(func() time.Time { return time.Now().Add(-10 * time.Minute) })
+	store := newStatementSnapshots(instanceArgs)
+	store.Set("pg_stat_statements", map[string]int{"1": 1})
+	persist.SetNow(time.Now)
+	require.NoError(t, store.Save())
+
+	var snapshot map[string]int
+	_, err := newStatementSnapshots(instanceArgs).Get("pg_stat_statements", &snapshot)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int{"1": 1}, snapshot)
+}
+
+func TestPlanHistoryOutlivesCacheTTL(t *testing.T) {
+	require.GreaterOrEqual(t, statementSnapshotsTTL, performancemetrics.PlanHistoryRetention)
+
+	instanceArgs := testArgs()
+	instanceArgs.TempDir = t.TempDir()
+	instanceArgs.CacheTTL = time.Minute
+	pgIntegration, err := integration.New("test", "test", integration.Writer(&bytes.Buffer{}))
+	require.NoError(t, err)
+	cp := commonparameters.SetCommonParameters(instanceArgs, 13, []string{"testdb"})
+	sample := func(planID string, execTimeMs float64) []datamodels.IndividualQueryMetrics {
+		queryID, database := "1", "testdb"
+		return []datamodels.IndividualQueryMetrics{{QueryID: &queryID, DatabaseName: &database, PlanID: &planID, ExecTimeInMs: &execTimeMs}}
+	}
+
+	// the plan seen hours ago is still known to the runs that follow, even those without individual queries
+	persist.SetNow(func() time.Time { return time.Now().Add(-2 * time.Hour) })
+	cp.StatementSnapshots = newStatementSnapshots(instanceArgs)
+	performancemetrics.PopulatePlanChangeEvents(sample("100", 2), pgIntegration, cp)
+	persist.SetNow(time.Now)
+
+	cp.StatementSnapshots = newStatementSnapshots(instanceArgs)
+	performancemetrics.PopulatePlanChangeEvents(nil, pgIntegration, cp)
+
+	cp.StatementSnapshots = newStatementSnapshots(instanceArgs)
+	var history map[string]struct {
+		CurrentPlanID string `json:"current_plan_id"`
+	}
+	_, err = cp.StatementSnapshots.Get("plans", &history)
+	require.NoError(t, err)
+	assert.Equal(t, "100", history["testdb:1"].CurrentPlanID)
+}
@@ -2,8 +2,13 @@
 package connection
 
 import (
+	"context"
 	"fmt"
 	"net/url"
+	"reflect"
+	"strconv"
+	"sync"
+	"time"
 
 	"github.com/jmoiron/sqlx"
 	// pq is required for postgreSQL driver but isn't used in code
@@ -13,6 +18,15 @@ import (
 )
 
 const (
+	// PgBouncerAdminDatabase is the pseudo-database exposing the PgBouncer admin console
+	PgBouncerAdminDatabase = "pgbouncer"
+
+	// maxConnectionsPerDatabase is the number of backend connections kept open to each database
+	maxConnectionsPerDatabase = 1
+	// connectionMaxIdleTime closes the connections left unused, such as those of the collectors that the daemon
+	// mode runs less often than every cycle
+	connectionMaxIdleTime = 5 * time.Minute
+
 	extensionsQuery = `
     SELECT -- EXTENSIONS_LIST
            n.nspname AS schema,
@@ -24,6 +38,13 @@ const (
 // PGSQLConnection represents a wrapper around a PostgreSQL connection
 type PGSQLConnection struct {
 	connection *sqlx.DB
+	// shared connections are owned by the Info that handed them out, which closes them
+	shared bool
+	// ctx bounds the queries run without an explicit context, see WithContext
+	ctx context.Context
+	// session tracks the statement_timeout set on the connection of the pool, or is nil if the statement
+	// timeouts of collectors are not overridden
+	session *session
 }
 
 // Info holds all the information needed from the user to create a new connection
@@ -31,6 +52,9 @@ type Info interface {
 	NewConnection(database string) (*PGSQLConnection, error)
 	HostPort() (string, string)
 	DatabaseName() string
+	// CloseExcept closes the connections to the databases other than databases and the default database
+	CloseExcept(databases []string)
+	Close()
 }
 
 type connectionInfo struct {
@@ -45,16 +69,44 @@ type connectionInfo struct {
 	SSLRootCertLocation    string
 	SSLKeyLocation         string
 	TrustServerCertificate bool
+	StatementTimeout       int
+	// OverrideStatementTimeouts is set when collectors may run their queries with their own statement_timeout
+	OverrideStatementTimeouts bool
+
+	connections     map[string]*PGSQLConnection
+	connectionsLock sync.Mutex
 }
 
 // DefaultConnectionInfo takes an argument list and constructs a default connection out of it
 func DefaultConnectionInfo(al *args.ArgumentList) Info {
+	// the arguments are validated before any connection is created
+	statementTimeouts, _ := al.CollectorStatementTimeoutDurations()
+	return &connectionInfo{
+		Database:                  al.Database,
+		Username:                  al.Username,
+		Password:                  al.Password,
+		Host:                      al.Hostname,
+		Port:                      al.Port,
+		Timeout:                   al.Timeout,
+		EnableSSL:                 al.EnableSSL,
+		SSLCertLocation:           al.SSLCertLocation,
+		SSLRootCertLocation:       al.SSLRootCertLocation,
+		SSLKeyLocation:            al.SSLKeyLocation,
+		TrustServerCertificate:    al.TrustServerCertificate,
+		StatementTimeout:          al.StatementTimeout,
+		OverrideStatementTimeouts: len(statementTimeouts) > 0,
+	}
+}
+
+// PgBouncerConnectionInfo constructs a connection to the PgBouncer admin console out of an argument list.
+// The PgBouncer host, port and credentials are used when set, and the PostgreSQL ones otherwise.
+func PgBouncerConnectionInfo(al *args.ArgumentList) Info {
 	return &connectionInfo{
-		Database:               al.Database,
-		Username:               al.Username,
-		Password:               al.Password,
-		Host:                   al.Hostname,
-		Port:                   al.Port,
+		Database:               PgBouncerAdminDatabase,
+		Username:               al.PgbouncerUser(),
+		Password:               al.PgbouncerPass(),
+		Host:                   al.PgbouncerHost(),
+		Port:                   al.PgbouncerPortNumber(),
 		Timeout:                al.Timeout,
 		EnableSSL:              al.EnableSSL,
 		SSLCertLocation:        al.SSLCertLocation,
@@ -64,16 +116,68 @@ func DefaultConnectionInfo(al *args.ArgumentList) Info {
 	}
 }
 
-// NewConnection creates a new PGSQLConnection from args
+// NewConnection returns the PGSQLConnection to database, creating it from args on first use.
+// Connections are pooled and shared by every caller for the lifetime of the Info, so closing
+// them has no effect until Info.Close is called.
 func (ci *connectionInfo) NewConnection(database string) (*PGSQLConnection, error) {
+	ci.connectionsLock.Lock()
+	defer ci.connectionsLock.Unlock()
+
+	if con, ok := ci.connections[database]; ok {
+		return con, nil
+	}
+
 	db, err := sqlx.Open("postgres", createConnectionURL(ci, database))
 	if err != nil {
 		return nil, err
 	}
+	db.SetMaxOpenConns(maxConnectionsPerDatabase)
+	db.SetMaxIdleConns(maxConnectionsPerDatabase)
+	db.SetConnMaxIdleTime(connectionMaxIdleTime)
 
-	return &PGSQLConnection{
+	con := &PGSQLConnection{
 		connection: db,
-	}, nil
+		shared:     true,
+	}
+	// SET is not supported by the PgBouncer admin console
+	if ci.OverrideStatementTimeouts && database != PgBouncerAdminDatabase {
+		con.session = &session{}
+	}
+	if ci.connections == nil {
+		ci.connections = make(map[string]*PGSQLConnection)
+	}
+	ci.connections[database] = con
+	return con, nil
+}
+
+// Close closes every connection handed out by NewConnection
+func (ci *connectionInfo) Close() {
+	ci.connectionsLock.Lock()
+	defer ci.connectionsLock.Unlock()
+
+	for database, con := range ci.connections {
+		con.closeConnection()
+		delete(ci.connections, database)
+	}
+}
+
+// CloseExcept closes the connections to the databases other than databases and the default database, such as
+// the databases dropped from the collection list
+func (ci *connectionInfo) CloseExcept(databases []string) {
+	keep := map[string]bool{ci.Database: true}
+	for _, database := range databases {
+		keep[database] = true
+	}
+
+	ci.connectionsLock.Lock()
+	defer ci.connectionsLock.Unlock()
+
+	for database, con := range ci.connections {
+		if !keep[database] {
+			con.closeConnection()
+			delete(ci.connections, database)
+		}
+	}
 }
 
 func (ci *connectionInfo) HostPort() (string, string) {
@@ -85,27 +189,238 @@ func (ci *connectionInfo) DatabaseName() string {
 }
 
 // Close closes the PosgreSQL connection. If an error occurs
-// it is logged as a warning.
+// it is logged as a warning. Shared connections are left open
+// until the Info that created them is closed.
 func (p PGSQLConnection) Close() {
+	if p.shared {
+		return
+	}
+	p.closeConnection()
+}
+
+func (p PGSQLConnection) closeConnection() {
 	if err := p.connection.Close(); err != nil {
 		log.Warn("Unable to close PostgreSQL Connection: %s", err.Error())
 	}
 }
 
-// Query runs a query and loads results into v
-func (p PGSQLConnection) Query(v interface{}, query string) error {
-	return p.connection.Select(v, query)
+// QueryObserver is notified of the outcome of every query run with a context carrying it
+type QueryObserver interface {
+	// ObserveQuery receives the number of rows loaded by the query, or read from its set of rows once
+	// they are closed, and its error if it failed
+	ObserveQuery(rows int, err error)
+}
+
+type queryObserverKey struct{}
+
+// WithQueryObserver returns a copy of ctx carrying observer, which is notified of the queries run with it
+func WithQueryObserver(ctx context.Context, observer QueryObserver) context.Context {
+	return context.WithValue(ctx, queryObserverKey{}, observer)
+}
+
+// QueryObserverFromContext returns the QueryObserver carried by ctx, or nil if there is none
+func QueryObserverFromContext(ctx context.Context) QueryObserver {
+	observer, _ := ctx.Value(queryObserverKey{}).(QueryObserver)
+	return observer
+}
+
+func observeQuery(ctx context.Context, v interface{}, err error) {
+	observer := QueryObserverFromContext(ctx)
+	if observer == nil {
+		return
+	}
+
+	rows := 0
+	if rv := reflect.Indirect(reflect.ValueOf(v)); err == nil && rv.Kind() == reflect.Slice {
+		rows = rv.Len()
+	}
+	observer.ObserveQuery(rows, err)
+}
+
+// Context returns the context the connection is bound to, see WithContext
+func (p PGSQLConnection) Context() context.Context {
+	return p.context()
+}
+
+// WithContext returns a copy of the connection whose Query, QueryUnsafe and Queryx run with ctx,
+// so that running queries are cancelled and no more are sent once ctx is done
+func (p PGSQLConnection) WithContext(ctx context.Context) *PGSQLConnection {
+	p.ctx = ctx
+	return &p
+}
+
+func (p PGSQLConnection) context() context.Context {
+	if p.ctx == nil {
+		return context.Background()
+	}
+	return p.ctx
+}
+
+// Query runs a query with the given bind parameters and loads results into v
+func (p PGSQLConnection) Query(v interface{}, query string, args ...interface{}) error {
+	return p.QueryContext(p.context(), v, query, args...)
+}
+
+// QueryContext runs a query bounded by ctx and loads results into v
+func (p PGSQLConnection) QueryContext(ctx context.Context, v interface{}, query string, args ...interface{}) error {
+	err := p.selectContext(ctx, p.connection, v, query, args...)
+	observeQuery(ctx, v, err)
+	return err
 }
 
 // QueryUnsafe runs a query and loads results into v, ignoring extra columns in the result set
 // This is useful for queries where the schema may vary (e.g., PgBouncer versions)
-func (p PGSQLConnection) QueryUnsafe(v interface{}, query string) error {
-	return p.connection.Unsafe().Select(v, query)
+func (p PGSQLConnection) QueryUnsafe(v interface{}, query string, args ...interface{}) error {
+	return p.QueryUnsafeContext(p.context(), v, query, args...)
+}
+
+// QueryUnsafeContext runs a query bounded by ctx and loads results into v, ignoring extra columns in the result set
+func (p PGSQLConnection) QueryUnsafeContext(ctx context.Context, v interface{}, query string, args ...interface{}) error {
+	err := p.selectContext(ctx, p.connection.Unsafe(), v, query, args...)
+	observeQuery(ctx, v, err)
+	return err
 }
 
-// Queryx runs a query and returns a set of rows
-func (p PGSQLConnection) Queryx(query string) (*sqlx.Rows, error) {
-	return p.connection.Queryx(query)
+// Queryx runs a query with the given bind parameters and returns a set of rows
+func (p PGSQLConnection) Queryx(query string, args ...interface{}) (*Rows, error) {
+	return p.QueryxContext(p.context(), query, args...)
+}
+
+// QueryxContext runs a query bounded by ctx and returns a set of rows
+func (p PGSQLConnection) QueryxContext(ctx context.Context, query string, args ...interface{}) (*Rows, error) {
+	q, release, err := p.queryer(ctx, p.connection)
+	if err != nil {
+		observeQuery(ctx, nil, err)
+		return nil, err
+	}
+
+	rows, err := q.QueryxContext(ctx, query, args...)
+	if err != nil {
+		release()
+		observeQuery(ctx, nil, err)
+		return nil, err
+	}
+	// closing a connection waits until the rows of its query are closed
+	go release()
+	return &Rows{Rows: rows, ctx: ctx}, nil
+}
+
+// Rows is the set of rows returned by Queryx. The rows read and the error met reading them are reported to the
+// QueryObserver of the query once the rows are closed, or all of them are read.
+type Rows struct {
+	*sqlx.Rows
+	ctx      context.Context
+	read     int
+	observed sync.Once
+}
+
+// Next prepares the next row for reading, see sql.Rows.Next
+func (r *Rows) Next() bool {
+	if r.Rows.Next() {
+		r.read++
+		return true
+	}
+	r.observe()
+	return false
+}
+
+// Close closes the rows, see sql.Rows.Close
+func (r *Rows) Close() error {
+	err := r.Rows.Close()
+	r.observe()
+	return err
+}
+
+func (r *Rows) observe() {
+	r.observed.Do(func() {
+		if observer := QueryObserverFromContext(r.ctx); observer != nil {
+			observer.ObserveQuery(r.read, r.Rows.Err())
+		}
+	})
+}
+
+func (p PGSQLConnection) selectContext(ctx context.Context, db *sqlx.DB, v interface{}, query string, args ...interface{}) error {
+	q, release, err := p.queryer(ctx, db)
+	if err != nil {
+		return err
+	}
+	defer release()
+	return sqlx.SelectContext(ctx, q, v, query, args...)
+}
+
+// queryer returns where the queries run with ctx are sent: the pool db itself, or, when the statement timeouts
+// of collectors are overridden, a connection of the pool whose statement_timeout is the one carried by ctx.
+// release returns the connection to the pool.
+func (p PGSQLConnection) queryer(ctx context.Context, db *sqlx.DB) (q sqlx.QueryerContext, release func(), err error) {
+	if p.session == nil {
+		return db, func() {}, nil
+	}
+
+	conn, err := db.Connx(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	timeout, ok := StatementTimeoutFromContext(ctx)
+	if err := p.session.setStatementTimeout(ctx, conn, timeout, ok); err != nil {
+		_ = conn.Close()
+		return nil, nil, err
+	}
+	return conn, func() { _ = conn.Close() }, nil
+}
+
+type statementTimeoutKey struct{}
+
+// WithStatementTimeout returns a copy of ctx whose queries run with timeout as statement_timeout, instead of the
+// statement timeout the connection was opened with. It only applies to connections of an Info overriding the
+// statement timeouts of collectors, and a zero timeout disables statement_timeout.
+func WithStatementTimeout(ctx context.Context, timeout time.Duration) context.Context {
+	return context.WithValue(ctx, statementTimeoutKey{}, timeout)
+}
+
+// StatementTimeoutFromContext returns the statement timeout carried by ctx, and whether it carries one
+func StatementTimeoutFromContext(ctx context.Context) (time.Duration, bool) {
+	timeout, ok := ctx.Value(statementTimeoutKey{}).(time.Duration)
+	return timeout, ok
+}
+
+// session tracks the statement_timeout set on the connection of a pool. As a pool keeps a single connection,
+// only the last one used is tracked, and the others have the statement timeout they were opened with.
+type session struct {
+	lock sync.Mutex
+	// driverConn is the connection whose statement_timeout was set to statementTimeout, if overridden
+	driverConn       interface{}
+	overridden       bool
+	statementTimeout time.Duration
+}
+
+// setStatementTimeout sets the statement_timeout of conn to timeout, or resets it to the one it was opened with
+// if override is false. Nothing is sent when conn already has that statement timeout.
+func (s *session) setStatementTimeout(ctx context.Context, conn *sqlx.Conn, timeout time.Duration, override bool) error {
+	var driverConn interface{}
+	if err := conn.Raw(func(dc interface{}) error {
+		driverConn = dc
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	overridden := s.overridden && s.driverConn == driverConn
+	if overridden == override && (!override || s.statementTimeout == timeout) {
+		return nil
+	}
+
+	query := "RESET statement_timeout"
+	if override {
+		query = fmt.Sprintf("SET statement_timeout = %d", timeout.Milliseconds())
+	}
+	if _, err := conn.ExecContext(ctx, query); err != nil {
+		return fmt.Errorf("error setting the statement timeout: %w", err)
+	}
+	s.driverConn, s.overridden, s.statementTimeout = driverConn, override, timeout
+	return nil
 }
 
 type extensions map[string]map[string]bool
@@ -168,6 +483,11 @@ func createConnectionURL(ci *connectionInfo, database string) string {
 	query := url.Values{}
 	query.Add("connect_timeout", ci.Timeout)
 
+	// statement_timeout is sent as a run-time parameter, which the PgBouncer admin console rejects
+	if ci.StatementTimeout > 0 && database != PgBouncerAdminDatabase {
+		query.Add("statement_timeout", strconv.Itoa(ci.StatementTimeout))
+	}
+
 	// SSL settings
 	if ci.EnableSSL {
 		addSSLQueries(query, ci)
@@ -43,3 +43,9 @@ func (mi *MockInfo) NewConnection(database string) (*PGSQLConnection, error) {
 	args := mi.Called(database)
 	return args.Get(0).(*PGSQLConnection), args.Error(1)
 }
+
+// CloseExcept is a no-op, as mock connections are closed by the tests that create them
+func (mi *MockInfo) CloseExcept(databases []string) {}
+
+// Close is a no-op, as mock connections are closed by the tests that create them
+func (mi *MockInfo) Close() {}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/newrelic/infra-integrations-sdk/v3/integration"
+	"github.com/newrelic/infra-integrations-sdk/v3/log"
+)
+
+// runDaemon collects and publishes the instances every cycle until the process is interrupted or terminated.
+func runDaemon(collectors []*instanceCollector, cycle time.Duration, collectionTimeout, maxConcurrentInstances int, pgIntegration *integration.Integration) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	runCycles(ctx, collectors, cycle, collectionTimeout, maxConcurrentInstances, pgIntegration)
+}
+
+// runCycles collects and publishes the instances every cycle until ctx is done. Each cycle is bounded by
+// collectionTimeout, and a cycle outlasting the interval delays the next one.
+func runCycles(ctx context.Context, collectors []*instanceCollector, cycle time.Duration, collectionTimeout, maxConcurrentInstances int, pgIntegration *integration.Integration) {
+	ticker := time.NewTicker(cycle)
+	defer ticker.Stop()
+	for {
+		cycleCtx, cancel := collectionContext(ctx, collectionTimeout)
+		if !collectCycle(cycleCtx, collectors, maxConcurrentInstances, pgIntegration) {
+			log.Error("No instance could be collected, retrying in %s", cycle)
+		}
+		cancel()
+
+		select {
+		case <-ctx.Done():
+			log.Info("Stopping daemon: %s", ctx.Err().Error())
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -2,20 +2,16 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"runtime"
 	"strings"
-
-	queryperformancemonitoring "github.com/newrelic/nri-postgresql/src/query-performance-monitoring"
+	"time"
 
 	"github.com/newrelic/infra-integrations-sdk/v3/integration"
 	"github.com/newrelic/infra-integrations-sdk/v3/log"
 	"github.com/newrelic/nri-postgresql/src/args"
-	"github.com/newrelic/nri-postgresql/src/collection"
-	"github.com/newrelic/nri-postgresql/src/connection"
-	"github.com/newrelic/nri-postgresql/src/inventory"
-	"github.com/newrelic/nri-postgresql/src/metrics"
 )
 
 const (
@@ -59,40 +55,43 @@ func main() {
 		os.Exit(1)
 	}
 
-	connectionInfo := connection.DefaultConnectionInfo(&args)
-	collectionList, err := collection.BuildCollectionList(args, connectionInfo)
+	instances, err := args.Instances()
 	if err != nil {
-		log.Error("Error creating list of entities to collect: %s", err)
+		log.Error("Configuration error: %s", err.Error())
 		os.Exit(1)
 	}
-	instance, err := pgIntegration.Entity(fmt.Sprintf("%s:%s", args.Hostname, args.Port), "pg-instance")
-	if err != nil {
-		log.Error("Error creating instance entity: %s", err.Error())
+
+	collectors := newInstanceCollectors(instances)
+	if len(collectors) == 0 {
 		os.Exit(1)
 	}
-	if args.HasMetrics() {
-		metrics.PopulateMetrics(connectionInfo, collectionList, instance, pgIntegration, args.Pgbouncer, args.CollectDbLockMetrics, args.CollectBloatMetrics, args.CustomMetricsQuery)
-		if args.CustomMetricsConfig != "" {
-			metrics.PopulateCustomMetricsFromFile(connectionInfo, args.CustomMetricsConfig, pgIntegration)
+	// Connections are shared by all collectors and closed once the run completes
+	defer func() {
+		for _, c := range collectors {
+			c.close()
 		}
-	}
+	}()
 
-	if args.HasInventory() {
-		con, err := connectionInfo.NewConnection(connectionInfo.DatabaseName())
-		if err != nil {
-			log.Error("Inventory collection failed: error creating connection to PostgreSQL: %s", err.Error())
-		} else {
-			defer con.Close()
-			inventory.PopulateInventory(instance, con)
-		}
+	if args.Daemon {
+		runDaemon(collectors, time.Duration(args.DaemonInterval)*time.Second, args.CollectionTimeout, args.MaxConcurrentInstances, pgIntegration)
+		return
 	}
 
-	if err = pgIntegration.Publish(); err != nil {
-		log.Error(err.Error())
-	}
+	// Once the collection deadline is reached the remaining collectors are skipped and the
+	// metrics collected so far are published
+	ctx, cancel := collectionContext(context.Background(), args.CollectionTimeout)
+	defer cancel()
 
-	if args.EnableQueryMonitoring {
-		queryperformancemonitoring.QueryPerformanceMain(args, pgIntegration, collectionList)
+	if !collectCycle(ctx, collectors, args.MaxConcurrentInstances, pgIntegration) {
+		os.Exit(1)
 	}
+}
 
+// collectionContext returns the context bounding a collection run, which expires after timeout
+// seconds, or never when timeout is not positive
+func collectionContext(parent context.Context, timeout int) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(parent)
+	}
+	return context.WithTimeout(parent, time.Duration(timeout)*time.Second)
 }
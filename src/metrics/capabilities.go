@@ -0,0 +1,64 @@
+package metrics
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Capabilities caches what is known of an instance between the cycles of the daemon mode, such as its version
+// and extensions, so that it is only queried again once older than maxAge
+type Capabilities struct {
+	maxAge time.Duration
+
+	lock   sync.Mutex
+	values map[string]cachedCapability
+}
+
+type cachedCapability struct {
+	value     interface{}
+	fetchedAt time.Time
+}
+
+type capabilitiesKey struct{}
+
+// NewCapabilities returns an empty cache of capabilities refreshed once older than maxAge
+func NewCapabilities(maxAge time.Duration) *Capabilities {
+	return &Capabilities{
+		maxAge: maxAge,
+		values: make(map[string]cachedCapability),
+	}
+}
+
+// WithCapabilities returns a copy of ctx carrying capabilities, which caches the capabilities fetched with it
+func WithCapabilities(ctx context.Context, capabilities *Capabilities) context.Context {
+	return context.WithValue(ctx, capabilitiesKey{}, capabilities)
+}
+
+// CachedCapability returns the named capability cached by the capabilities carried by ctx, calling fetch when it
+// is not cached yet or has expired. Without capabilities, or when fetch fails, nothing is cached.
+func CachedCapability[T any](ctx context.Context, name string, fetch func() (T, error)) (T, error) {
+	capabilities, _ := ctx.Value(capabilitiesKey{}).(*Capabilities)
+	if capabilities == nil {
+		return fetch()
+	}
+
+	capabilities.lock.Lock()
+	cached, ok := capabilities.values[name]
+	capabilities.lock.Unlock()
+	if ok && time.Since(cached.fetchedAt) < capabilities.maxAge {
+		if value, ok := cached.value.(T); ok {
+			return value, nil
+		}
+	}
+
+	value, err := fetch()
+	if err != nil {
+		return value, err
+	}
+
+	capabilities.lock.Lock()
+	defer capabilities.lock.Unlock()
+	capabilities.values[name] = cachedCapability{value: value, fetchedAt: time.Now()}
+	return value, nil
+}
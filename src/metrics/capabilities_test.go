@@ -0,0 +1,55 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/newrelic/nri-postgresql/src/connection"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/DATA-DOG/go-sqlmock.v1"
+)
+
+func TestCachedCapability(t *testing.T) {
+	fetches := 0
+	fetch := func() (int, error) {
+		fetches++
+		return fetches, nil
+	}
+
+	// without capabilities nothing is cached
+	value, err := CachedCapability(context.Background(), "test", fetch)
+	require.NoError(t, err)
+	assert.Equal(t, 1, value)
+
+	ctx := WithCapabilities(context.Background(), NewCapabilities(time.Hour))
+	value, _ = CachedCapability(ctx, "test", fetch)
+	assert.Equal(t, 2, value)
+	value, _ = CachedCapability(ctx, "test", fetch)
+	assert.Equal(t, 2, value)
+
+	_, err = CachedCapability(ctx, "failing", func() (int, error) { return 0, errors.New("failed") })
+	assert.Error(t, err)
+	value, _ = CachedCapability(ctx, "failing", fetch)
+	assert.Equal(t, 3, value)
+
+	expired := WithCapabilities(context.Background(), NewCapabilities(0))
+	CachedCapability(expired, "test", fetch) //nolint: errcheck
+	value, _ = CachedCapability(expired, "test", fetch)
+	assert.Equal(t, 5, value)
+}
+
+func TestCollectVersion_Cached(t *testing.T) {
+	con, mock := connection.CreateMockSQL(t)
+	mock.ExpectQuery(versionQuery).WillReturnRows(sqlmock.NewRows([]string{"server_version"}).AddRow("14.5"))
+
+	ctx := WithCapabilities(context.Background(), NewCapabilities(time.Hour))
+	for i := 0; i < 2; i++ {
+		version, err := CollectVersion(con.WithContext(ctx))
+		require.NoError(t, err)
+		assert.Equal(t, "14.5.0", version.String())
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,345 @@
+package metrics
+
+import (
+	_ "embed" // embeds the default metric catalog
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/blang/semver/v4"
+	"github.com/newrelic/infra-integrations-sdk/v3/data/metric"
+	"github.com/newrelic/nri-postgresql/src/collection"
+	yaml "gopkg.in/yaml.v3"
+)
+
+// defaultCatalog is the metric catalog shipped with the integration, collected along with the built-in definitions
+//
+//go:embed catalog.yaml
+var defaultCatalog []byte
+
+// Entity levels a catalog definition can report on. Its metrics are added to the sample of the level
+// (PostgresqlInstanceSample, PostgresqlDatabaseSample, PostgresqlTableSample or PostgresqlIndexSample).
+const (
+	catalogLevelInstance = "instance"
+	catalogLevelDatabase = "database"
+	catalogLevelTable    = "table"
+	catalogLevelIndex    = "index"
+)
+
+// Placeholders of catalog queries, replaced by a bind parameter holding the collected objects as a text array
+const (
+	databasesPlaceholder          = "%DATABASES%"
+	schemaTablesPlaceholder       = "%SCHEMA_TABLES%"
+	schemaTableIndexesPlaceholder = "%SCHEMA_TABLE_INDEXES%"
+)
+
+var catalogPlaceholders = []string{databasesPlaceholder, schemaTablesPlaceholder, schemaTableIndexesPlaceholder}
+
+// catalogLevel describes the columns identifying the entity of each row of a level, and the placeholders it supports
+type catalogLevel struct {
+	identityColumns []string
+	placeholders    []string
+}
+
+var catalogLevels = map[string]catalogLevel{
+	catalogLevelInstance: {},
+	catalogLevelDatabase: {
+		identityColumns: []string{"database"},
+		placeholders:    []string{databasesPlaceholder},
+	},
+	catalogLevelTable: {
+		identityColumns: []string{"database", "schema_name", "table_name"},
+		placeholders:    []string{schemaTablesPlaceholder},
+	},
+	catalogLevelIndex: {
+		identityColumns: []string{"database", "schema_name", "table_name", "index_name"},
+		placeholders:    []string{schemaTablesPlaceholder, schemaTableIndexesPlaceholder},
+	},
+}
+
+// Catalog holds the metric definitions loaded from YAML. They are collected after the built-in definitions
+// of their level, and the built-in metrics they report are no longer reported by the built-in definitions.
+type Catalog struct {
+	definitions []*catalogDefinition
+}
+
+type catalogYAML struct {
+	Definitions []*catalogDefinition `yaml:"definitions"`
+}
+
+type catalogDefinition struct {
+	Name       string          `yaml:"name"`
+	Level      string          `yaml:"level"`
+	MinVersion string          `yaml:"min_version"`
+	MaxVersion string          `yaml:"max_version"`
+	Enabled    *bool           `yaml:"enabled"`
+	Query      string          `yaml:"query"`
+	Metrics    []catalogMetric `yaml:"metrics"`
+
+	minVersion   *semver.Version
+	maxVersion   *semver.Version
+	placeholders []string
+	definition   *QueryDefinition
+}
+
+type catalogMetric struct {
+	Column     string `yaml:"column"`
+	MetricName string `yaml:"metric_name"`
+	SourceType string `yaml:"source_type"`
+}
+
+// LoadCatalog loads the catalog embedded in the integration, merged with the catalog file at path if one is given.
+// Definitions of the file replace the embedded ones of the same name, and are dropped when they are disabled.
+func LoadCatalog(path string) (*Catalog, error) {
+	definitions, err := parseCatalog(defaultCatalog)
+	if err != nil {
+		return nil, fmt.Errorf("invalid embedded metric catalog: %w", err)
+	}
+
+	if path != "" {
+		contents, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read metric catalog: %w", err)
+		}
+
+		overrides, err := parseCatalog(contents)
+		if err != nil {
+			return nil, fmt.Errorf("invalid metric catalog %s: %w", path, err)
+		}
+		definitions = mergeCatalogDefinitions(definitions, overrides)
+	}
+
+	catalog := &Catalog{}
+	for _, definition := range definitions {
+		if definition.Enabled == nil || *definition.Enabled {
+			catalog.definitions = append(catalog.definitions, definition)
+		}
+	}
+	return catalog, nil
+}
+
+func parseCatalog(contents []byte) ([]*catalogDefinition, error) {
+	var catalogFile catalogYAML
+	if err := yaml.Unmarshal(contents, &catalogFile); err != nil {
+		return nil, err
+	}
+
+	names := make(map[string]bool)
+	for _, definition := range catalogFile.Definitions {
+		if definition.Name == "" {
+			return nil, errors.New("definition without a name")
+		}
+		if names[definition.Name] {
+			return nil, fmt.Errorf("definition %s is declared more than once", definition.Name)
+		}
+		names[definition.Name] = true
+
+		// disabled definitions only need a name, to turn off the definition they replace
+		if definition.Enabled != nil && !*definition.Enabled {
+			continue
+		}
+		if err := definition.build(); err != nil {
+			return nil, fmt.Errorf("definition %s: %w", definition.Name, err)
+		}
+	}
+
+	return catalogFile.Definitions, nil
+}
+
+// mergeCatalogDefinitions replaces the definitions having the name of an override, and appends the other overrides
+func mergeCatalogDefinitions(definitions, overrides []*catalogDefinition) []*catalogDefinition {
+	merged := make([]*catalogDefinition, 0, len(definitions)+len(overrides))
+	replaced := make(map[string]bool)
+	for _, definition := range definitions {
+		for _, override := range overrides {
+			if override.Name == definition.Name {
+				definition = override
+				replaced[override.Name] = true
+				break
+			}
+		}
+		merged = append(merged, definition)
+	}
+
+	for _, override := range overrides {
+		if !replaced[override.Name] {
+			merged = append(merged, override)
+		}
+	}
+	return merged
+}
+
+// build validates the definition and builds its QueryDefinition, whose data model is a struct
+// created at run time with a field for each identity column of its level and each metric
+func (d *catalogDefinition) build() error {
+	level, ok := catalogLevels[d.Level]
+	if !ok {
+		return fmt.Errorf("unknown level %q, must be one of instance, database, table or index", d.Level)
+	}
+	if d.Query == "" {
+		return errors.New("query is required")
+	}
+	if len(d.Metrics) == 0 {
+		return errors.New("at least one metric is required")
+	}
+
+	var err error
+	if d.minVersion, err = parseCatalogVersion(d.MinVersion); err != nil {
+		return fmt.Errorf("invalid min_version: %w", err)
+	}
+	if d.maxVersion, err = parseCatalogVersion(d.MaxVersion); err != nil {
+		return fmt.Errorf("invalid max_version: %w", err)
+	}
+
+	query := d.Query
+	for _, placeholder := range catalogPlaceholders {
+		if !strings.Contains(query, placeholder) {
+			continue
+		}
+		if !slices.Contains(level.placeholders, placeholder) {
+			return fmt.Errorf("placeholder %s is not supported at the %s level", placeholder, d.Level)
+		}
+		d.placeholders = append(d.placeholders, placeholder)
+		query = strings.ReplaceAll(query, placeholder, fmt.Sprintf("$%d", len(d.placeholders)))
+	}
+
+	fields := make([]reflect.StructField, 0, len(level.identityColumns)+len(d.Metrics))
+	columns := make(map[string]bool)
+	for _, column := range level.identityColumns {
+		fields = append(fields, reflect.StructField{
+			Name: fmt.Sprintf("Field%d", len(fields)),
+			Type: reflect.TypeOf((*string)(nil)),
+			Tag:  reflect.StructTag(fmt.Sprintf(`db:%q`, column)),
+		})
+		columns[column] = true
+	}
+
+	metricNames := make(map[string]bool)
+	for _, m := range d.Metrics {
+		if m.Column == "" || m.MetricName == "" {
+			return errors.New("metrics require a column and a metric_name")
+		}
+		if columns[m.Column] {
+			return fmt.Errorf("column %s is used more than once", m.Column)
+		}
+		if metricNames[m.MetricName] {
+			return fmt.Errorf("metric %s is reported more than once", m.MetricName)
+		}
+		columns[m.Column] = true
+		metricNames[m.MetricName] = true
+
+		if m.SourceType == "" {
+			m.SourceType = "gauge"
+		}
+		sourceType, err := metric.SourceTypeForName(m.SourceType)
+		if err != nil {
+			return err
+		}
+		fieldType := reflect.TypeOf((*float64)(nil))
+		if sourceType == metric.ATTRIBUTE {
+			fieldType = reflect.TypeOf((*string)(nil))
+		}
+
+		fields = append(fields, reflect.StructField{
+			Name: fmt.Sprintf("Field%d", len(fields)),
+			Type: fieldType,
+			Tag:  reflect.StructTag(fmt.Sprintf(`db:%q metric_name:%q source_type:%q`, m.Column, m.MetricName, strings.ToLower(m.SourceType))),
+		})
+	}
+
+	d.definition = &QueryDefinition{
+		query:      query,
+		dataModels: reflect.MakeSlice(reflect.SliceOf(reflect.StructOf(fields)), 0, 0).Interface(),
+	}
+	return nil
+}
+
+func parseCatalogVersion(version string) (*semver.Version, error) {
+	if version == "" {
+		return nil, nil
+	}
+	v, err := semver.ParseTolerant(version)
+	if err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
+
+// supports returns whether the definition applies to version, min_version being inclusive and max_version exclusive
+func (d *catalogDefinition) supports(version *semver.Version) bool {
+	if d.minVersion != nil && version.LT(*d.minVersion) {
+		return false
+	}
+	if d.maxVersion != nil && version.GTE(*d.maxVersion) {
+		return false
+	}
+	return true
+}
+
+// bind binds the collected objects to the placeholders of the definition, returning nil if there is nothing to collect
+func (d *catalogDefinition) bind(databases collection.DatabaseList, schemaList collection.SchemaList) *QueryDefinition {
+	definition := d.definition
+	for _, placeholder := range d.placeholders {
+		switch placeholder {
+		case databasesPlaceholder:
+			definition = definition.bindDatabaseNames(databases)
+		case schemaTablesPlaceholder:
+			definition = definition.bindSchemaTables(schemaList)
+		case schemaTableIndexesPlaceholder:
+			definition = definition.bindSchemaTableIndexes(schemaList)
+		}
+		if definition == nil {
+			return nil
+		}
+	}
+	return definition
+}
+
+// apply returns the built-in definitions of a level without the metrics the catalog reports, followed by
+// the catalog definitions of the level supported by version, bound to the databases or schemaList collected
+func (c *Catalog) apply(level string, version *semver.Version, builtins []*QueryDefinition, databases collection.DatabaseList, schemaList collection.SchemaList) []*QueryDefinition {
+	if c == nil {
+		return builtins
+	}
+
+	definitions := c.withoutOverridden(level, version, builtins)
+	for _, d := range c.definitions {
+		if d.Level != level || !d.supports(version) {
+			continue
+		}
+		if definition := d.bind(databases, schemaList); definition != nil {
+			definitions = append(definitions, definition)
+		}
+	}
+	return definitions
+}
+
+// withoutOverridden returns the built-in definitions of a level without the metrics reported by the catalog
+// definitions of the level supported by version, for built-ins collected apart from the catalog definitions
+func (c *Catalog) withoutOverridden(level string, version *semver.Version, builtins []*QueryDefinition) []*QueryDefinition {
+	if c == nil {
+		return builtins
+	}
+
+	overridden := make(map[string]bool)
+	for _, d := range c.definitions {
+		if d.Level != level || !d.supports(version) {
+			continue
+		}
+		for _, m := range d.Metrics {
+			overridden[m.MetricName] = true
+		}
+	}
+	if len(overridden) == 0 {
+		return builtins
+	}
+
+	definitions := make([]*QueryDefinition, 0, len(builtins))
+	for _, builtin := range builtins {
+		definitions = append(definitions, builtin.withoutMetrics(overridden))
+	}
+	return definitions
+}
@@ -0,0 +1,256 @@
+package metrics
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/blang/semver/v4"
+	"github.com/lib/pq"
+	"github.com/newrelic/infra-integrations-sdk/v3/integration"
+	"github.com/newrelic/nri-postgresql/src/collection"
+	"github.com/newrelic/nri-postgresql/src/connection"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/DATA-DOG/go-sqlmock.v1"
+)
+
+func testCatalog(t *testing.T, contents string) *Catalog {
+	definitions, err := parseCatalog([]byte(contents))
+	require.NoError(t, err)
+	return &Catalog{definitions: definitions}
+}
+
+func TestParseCatalog_Errors(t *testing.T) {
+	testCases := []struct {
+		name     string
+		contents string
+		err      string
+	}{
+		{
+			name:     "missing name",
+			contents: "definitions:\n- level: instance\n  query: SELECT 1 AS one\n  metrics: [{column: one, metric_name: one}]",
+			err:      "definition without a name",
+		},
+		{
+			name: "duplicate name",
+			contents: `definitions:
+- {name: a, enabled: false}
+- {name: a, enabled: false}`,
+			err: "definition a is declared more than once",
+		},
+		{
+			name:     "unknown level",
+			contents: "definitions:\n- name: a\n  level: cluster\n  query: SELECT 1 AS one\n  metrics: [{column: one, metric_name: one}]",
+			err:      `definition a: unknown level "cluster", must be one of instance, database, table or index`,
+		},
+		{
+			name:     "missing query",
+			contents: "definitions:\n- name: a\n  level: instance\n  metrics: [{column: one, metric_name: one}]",
+			err:      "definition a: query is required",
+		},
+		{
+			name:     "missing metrics",
+			contents: "definitions:\n- name: a\n  level: instance\n  query: SELECT 1 AS one",
+			err:      "definition a: at least one metric is required",
+		},
+		{
+			name:     "invalid version",
+			contents: "definitions:\n- name: a\n  level: instance\n  min_version: x.y\n  query: SELECT 1 AS one\n  metrics: [{column: one, metric_name: one}]",
+			err:      "definition a: invalid min_version: Invalid character(s) found in major number \"x\"",
+		},
+		{
+			name:     "unsupported placeholder",
+			contents: "definitions:\n- name: a\n  level: database\n  query: SELECT 1 AS one WHERE relname = ANY(%SCHEMA_TABLES%)\n  metrics: [{column: one, metric_name: one}]",
+			err:      "definition a: placeholder %SCHEMA_TABLES% is not supported at the database level",
+		},
+		{
+			name:     "identity column as metric",
+			contents: "definitions:\n- name: a\n  level: database\n  query: SELECT datname AS database\n  metrics: [{column: database, metric_name: one}]",
+			err:      "definition a: column database is used more than once",
+		},
+		{
+			name:     "duplicate metric name",
+			contents: "definitions:\n- name: a\n  level: instance\n  query: SELECT 1 AS one, 2 AS two\n  metrics: [{column: one, metric_name: one}, {column: two, metric_name: one}]",
+			err:      "definition a: metric one is reported more than once",
+		},
+		{
+			name:     "unknown source type",
+			contents: "definitions:\n- name: a\n  level: instance\n  query: SELECT 1 AS one\n  metrics: [{column: one, metric_name: one, source_type: counter}]",
+			err:      "definition a: metric: Unknown source_type counter",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := parseCatalog([]byte(tc.contents))
+			assert.EqualError(t, err, tc.err)
+		})
+	}
+}
+
+func TestLoadCatalog(t *testing.T) {
+	catalog, err := LoadCatalog("")
+	require.NoError(t, err)
+	assert.Empty(t, catalog.definitions)
+
+	path := filepath.Join(t.TempDir(), "catalog.yml")
+	require.NoError(t, os.WriteFile(path, []byte(`definitions:
+- name: wal
+  level: instance
+  min_version: "10"
+  query: SELECT pg_current_wal_lsn() - '0/0' AS wal_bytes
+  metrics:
+  - {column: wal_bytes, metric_name: wal.bytesPerSecond, source_type: rate}
+- name: disabled
+  enabled: false
+`), 0600))
+
+	catalog, err = LoadCatalog(path)
+	require.NoError(t, err)
+	require.Len(t, catalog.definitions, 1)
+	assert.Equal(t, "wal", catalog.definitions[0].Name)
+
+	_, err = LoadCatalog(filepath.Join(t.TempDir(), "missing.yml"))
+	assert.Error(t, err)
+}
+
+func TestMergeCatalogDefinitions(t *testing.T) {
+	defaults := []*catalogDefinition{{Name: "a", Level: catalogLevelInstance}, {Name: "b", Level: catalogLevelInstance}}
+	overrides := []*catalogDefinition{{Name: "c", Level: catalogLevelDatabase}, {Name: "a", Level: catalogLevelDatabase}}
+
+	merged := mergeCatalogDefinitions(defaults, overrides)
+	require.Len(t, merged, 3)
+	assert.Equal(t, "a", merged[0].Name)
+	assert.Equal(t, catalogLevelDatabase, merged[0].Level)
+	assert.Equal(t, "b", merged[1].Name)
+	assert.Equal(t, "c", merged[2].Name)
+}
+
+func TestCatalog_Apply(t *testing.T) {
+	catalog := testCatalog(t, `definitions:
+- name: sizes
+  level: database
+  min_version: "9.6"
+  max_version: "17"
+  query: SELECT datname AS database, pg_database_size(datname) AS size FROM pg_database WHERE datname = ANY(%DATABASES%)
+  metrics:
+  - {column: size, metric_name: db.sizeInBytes, source_type: gauge}
+`)
+	databases := collection.DatabaseList{"db2": nil, "db1": nil}
+
+	builtins := []*QueryDefinition{databaseDefinitionOver91}
+
+	version := semver.MustParse("9.5.0")
+	assert.Equal(t, builtins, catalog.apply(catalogLevelDatabase, &version, builtins, databases, nil))
+
+	version = semver.MustParse("10.0.0")
+	definitions := catalog.apply(catalogLevelDatabase, &version, builtins, databases, nil)
+	require.Len(t, definitions, 2)
+
+	custom := definitions[1]
+	assert.Equal(t, "SELECT datname AS database, pg_database_size(datname) AS size FROM pg_database WHERE datname = ANY($1)", custom.GetQuery())
+	assert.Equal(t, []interface{}{pq.Array([]string{"db1", "db2"})}, custom.GetArgs())
+
+	// the built-in definition no longer reports the metric, but still reads its column
+	builtinType := reflect.TypeOf(definitions[0].GetDataModels()).Elem().Elem()
+	for i := 0; i < builtinType.NumField(); i++ {
+		assert.NotEqual(t, "db.sizeInBytes", builtinType.Field(i).Tag.Get("metric_name"))
+	}
+	field, ok := builtinType.FieldByNameFunc(func(name string) bool {
+		f, _ := builtinType.FieldByName(name)
+		return f.Tag.Get("db") == "database_size"
+	})
+	require.True(t, ok)
+	assert.Equal(t, `db:"database_size"`, string(field.Tag))
+
+	// nothing to bind, the catalog definition is not collected
+	assert.Len(t, catalog.apply(catalogLevelDatabase, &version, nil, collection.DatabaseList{}, nil), 0)
+
+	var nilCatalog *Catalog
+	assert.Equal(t, builtins, nilCatalog.apply(catalogLevelDatabase, &version, builtins, databases, nil))
+}
+
+func TestPopulateInstanceMetrics_Catalog(t *testing.T) {
+	testIntegration, _ := integration.New("test", "test")
+	testEntity, _ := testIntegration.Entity("testInstance", "instance")
+
+	version := semver.MustParse("9.0.0")
+	catalog := testCatalog(t, `definitions:
+- name: connections
+  level: instance
+  query: SELECT count(*) AS connections, sum(xact_commit) AS commits FROM pg_stat_database
+  metrics:
+  - {column: connections, metric_name: custom.connections, source_type: gauge}
+  - {column: commits, metric_name: bgwriter.buffersAllocatedPerSecond, source_type: gauge}
+`)
+
+	testConnection, mock := connection.CreateMockSQL(t)
+	mock.ExpectQuery(".*scheduled_checkpoints_performed.*").
+		WillReturnRows(sqlmock.NewRows([]string{
+			"scheduled_checkpoints_performed",
+			"requested_checkpoints_performed",
+			"buffers_written_during_checkpoint",
+			"buffers_written_by_background_writer",
+			"background_writer_stops",
+			"buffers_written_by_backend",
+			"buffers_allocated",
+		}).AddRow(1, 2, 3, 4, 5, 6, 7))
+	mock.ExpectQuery(".*pg_stat_database.*").
+		WillReturnRows(sqlmock.NewRows([]string{"connections", "commits"}).AddRow(12, 34))
+
+	PopulateInstanceMetrics(testEntity, &version, testConnection, catalog)
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	metrics := testEntity.Metrics[0].Metrics
+	assert.Equal(t, float64(12), metrics["custom.connections"])
+	assert.Equal(t, float64(34), metrics["bgwriter.buffersAllocatedPerSecond"])
+	assert.Equal(t, float64(0), metrics["bgwriter.checkpointsScheduledPerSecond"])
+}
+
+func TestPopulateIndexMetricsForDatabase_Catalog(t *testing.T) {
+	version := semver.MustParse("10.0.0")
+	testIntegration, _ := integration.New("test", "test")
+	schemaList := collection.SchemaList{
+		"schema1": collection.TableList{
+			"table1": []string{"index11"},
+		},
+	}
+	catalog := testCatalog(t, `definitions:
+- name: index_scans
+  level: index
+  query: >-
+    SELECT current_database() AS database, schemaname AS schema_name, relname AS table_name,
+    indexrelname AS index_name, idx_scan FROM pg_stat_user_indexes
+    WHERE schemaname || '.' || relname || '.' || indexrelname = ANY(%SCHEMA_TABLE_INDEXES%)
+  metrics:
+  - {column: idx_scan, metric_name: index.scans, source_type: gauge}
+`)
+
+	testConnection, mock := connection.CreateMockSQL(t)
+	mock.ExpectQuery(".*INDEXQUERY.*").
+		WillReturnRows(sqlmock.NewRows([]string{
+			"database", "schema_name", "table_name", "index_name", "index_size", "tuples_read", "tuples_fetched",
+		}).AddRow("db1", "schema1", "table1", "index11", 1, 2, 3))
+	mock.ExpectQuery(".*pg_stat_user_indexes.*").
+		WithArgs(pq.Array([]string{"schema1.table1.index11"})).
+		WillReturnRows(sqlmock.NewRows([]string{
+			"database", "schema_name", "table_name", "index_name", "idx_scan",
+		}).AddRow("db1", "schema1", "table1", "index11", 42))
+
+	populateIndexMetricsForDatabase(schemaList, &version, testConnection, testIntegration, &connection.MockInfo{}, catalog)
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	entity, err := testIntegration.Entity("index11", "pg-index",
+		integration.NewIDAttribute("pg-database", "db1"),
+		integration.NewIDAttribute("pg-schema", "schema1"),
+		integration.NewIDAttribute("host", "testhost"),
+		integration.NewIDAttribute("port", "1234"),
+		integration.NewIDAttribute("pg-table", "table1"))
+	require.NoError(t, err)
+	require.Len(t, entity.Metrics, 2)
+	assert.Equal(t, float64(1), entity.Metrics[0].Metrics["index.sizeInBytes"])
+	assert.Equal(t, float64(42), entity.Metrics[1].Metrics["index.scans"])
+	assert.Equal(t, "PostgresqlIndexSample", entity.Metrics[1].Metrics["event_type"])
+}
@@ -0,0 +1,139 @@
+package metrics
+
+import (
+	"github.com/blang/semver/v4"
+	"github.com/newrelic/nri-postgresql/src/collection"
+)
+
+func generateConnectionDefinitions(databases collection.DatabaseList, version *semver.Version) []*QueryDefinition {
+	queryDefinitions := make([]*QueryDefinition, 0, 1)
+	if len(databases) == 0 {
+		return queryDefinitions
+	}
+
+	v92 := semver.MustParse("9.2.0")
+	v96 := semver.MustParse("9.6.0")
+
+	if version.GE(v96) {
+		queryDefinitions = append(queryDefinitions, connectionStateDefinitionOver96.bindDatabaseNames(databases))
+	} else if version.GE(v92) {
+		queryDefinitions = append(queryDefinitions, connectionStateDefinitionOver92.bindDatabaseNames(databases))
+	}
+
+	return queryDefinitions
+}
+
+// generateConnectionBreakdownDefinitions returns the query reporting connections per user and application,
+// limited to the busiest limit combinations of each database. A limit of 0 disables the breakdown.
+func generateConnectionBreakdownDefinitions(databases collection.DatabaseList, version *semver.Version, limit int) []*QueryDefinition {
+	queryDefinitions := make([]*QueryDefinition, 0, 1)
+	if len(databases) == 0 || limit <= 0 {
+		return queryDefinitions
+	}
+
+	v92 := semver.MustParse("9.2.0")
+	if version.LT(v92) {
+		return queryDefinitions
+	}
+
+	def := connectionBreakdownDefinition.bindDatabaseNames(databases)
+	if def == nil {
+		return queryDefinitions
+	}
+
+	return append(queryDefinitions, def.withArgs(limit))
+}
+
+// connectionStateDefinitionOver96 is the query used to fetch connection states from Postgres 9.6 and above.
+// Backends waiting on a lock are identified by their wait_event_type, which replaced the waiting column.
+var connectionStateDefinitionOver96 = &QueryDefinition{
+	query: `SELECT -- CONNECTION_STATES
+		D.datname AS database,
+		COALESCE(SUM(CASE WHEN A.state = 'active' THEN 1 ELSE 0 END), 0) AS active,
+		COALESCE(SUM(CASE WHEN A.state = 'idle' THEN 1 ELSE 0 END), 0) AS idle,
+		COALESCE(SUM(CASE WHEN A.state = 'idle in transaction' THEN 1 ELSE 0 END), 0) AS idle_in_transaction,
+		COALESCE(SUM(CASE WHEN A.state = 'idle in transaction (aborted)' THEN 1 ELSE 0 END), 0) AS idle_in_transaction_aborted,
+		COALESCE(SUM(CASE WHEN A.state = 'fastpath function call' THEN 1 ELSE 0 END), 0) AS fastpath,
+		COALESCE(SUM(CASE WHEN A.wait_event_type = 'Lock' THEN 1 ELSE 0 END), 0) AS waiting,
+		COALESCE(MAX(extract(epoch from now() - A.xact_start)), 0)::float AS oldest_transaction_seconds,
+		COALESCE(MAX(CASE WHEN A.state = 'active' THEN extract(epoch from now() - A.query_start) END), 0)::float AS oldest_query_seconds,
+		COALESCE(MAX(CASE WHEN A.state LIKE 'idle in transaction%' THEN extract(epoch from now() - A.state_change) END), 0)::float AS oldest_idle_in_transaction_seconds
+		FROM pg_database D
+		LEFT JOIN pg_stat_activity A ON A.datname = D.datname AND A.pid <> pg_backend_pid()
+		WHERE D.datistemplate = FALSE
+			AND D.datname IS NOT NULL
+			AND D.datname = ANY($1)
+		GROUP BY D.datname;`,
+
+	dataModels: []connectionStateDataModel{},
+}
+
+// connectionStateDefinitionOver92 is the query used to fetch connection states from Postgres 9.2 to 9.5,
+// where the state column was introduced.
+var connectionStateDefinitionOver92 = &QueryDefinition{
+	query: `SELECT -- CONNECTION_STATES
+		D.datname AS database,
+		COALESCE(SUM(CASE WHEN A.state = 'active' THEN 1 ELSE 0 END), 0) AS active,
+		COALESCE(SUM(CASE WHEN A.state = 'idle' THEN 1 ELSE 0 END), 0) AS idle,
+		COALESCE(SUM(CASE WHEN A.state = 'idle in transaction' THEN 1 ELSE 0 END), 0) AS idle_in_transaction,
+		COALESCE(SUM(CASE WHEN A.state = 'idle in transaction (aborted)' THEN 1 ELSE 0 END), 0) AS idle_in_transaction_aborted,
+		COALESCE(SUM(CASE WHEN A.state = 'fastpath function call' THEN 1 ELSE 0 END), 0) AS fastpath,
+		COALESCE(SUM(CASE WHEN A.waiting THEN 1 ELSE 0 END), 0) AS waiting,
+		COALESCE(MAX(extract(epoch from now() - A.xact_start)), 0)::float AS oldest_transaction_seconds,
+		COALESCE(MAX(CASE WHEN A.state = 'active' THEN extract(epoch from now() - A.query_start) END), 0)::float AS oldest_query_seconds,
+		COALESCE(MAX(CASE WHEN A.state LIKE 'idle in transaction%' THEN extract(epoch from now() - A.state_change) END), 0)::float AS oldest_idle_in_transaction_seconds
+		FROM pg_database D
+		LEFT JOIN pg_stat_activity A ON A.datname = D.datname AND A.pid <> pg_backend_pid()
+		WHERE D.datistemplate = FALSE
+			AND D.datname IS NOT NULL
+			AND D.datname = ANY($1)
+		GROUP BY D.datname;`,
+
+	dataModels: []connectionStateDataModel{},
+}
+
+type connectionStateDataModel struct {
+	databaseBase
+	Active                         *int64   `db:"active"                             metric_name:"db.connections.active"                   source_type:"gauge"`
+	Idle                           *int64   `db:"idle"                               metric_name:"db.connections.idle"                     source_type:"gauge"`
+	IdleInTransaction              *int64   `db:"idle_in_transaction"                metric_name:"db.connections.idleInTransaction"        source_type:"gauge"`
+	IdleInTransactionAborted       *int64   `db:"idle_in_transaction_aborted"        metric_name:"db.connections.idleInTransactionAborted" source_type:"gauge"`
+	Fastpath                       *int64   `db:"fastpath"                           metric_name:"db.connections.fastpath"                 source_type:"gauge"`
+	Waiting                        *int64   `db:"waiting"                            metric_name:"db.connections.waiting"                  source_type:"gauge"`
+	OldestTransactionSeconds       *float64 `db:"oldest_transaction_seconds"         metric_name:"db.oldestTransactionInSeconds"           source_type:"gauge"`
+	OldestQuerySeconds             *float64 `db:"oldest_query_seconds"               metric_name:"db.oldestQueryInSeconds"                 source_type:"gauge"`
+	OldestIdleInTransactionSeconds *float64 `db:"oldest_idle_in_transaction_seconds" metric_name:"db.oldestIdleInTransactionInSeconds"     source_type:"gauge"`
+}
+
+// connectionBreakdownDefinition counts the connections of each database per user and application name.
+// Only the combinations with the most connections are returned for each database, up to the limit bound as $2, to bound cardinality.
+var connectionBreakdownDefinition = &QueryDefinition{
+	query: `SELECT -- CONNECTION_BREAKDOWN
+		database, user_name, application_name, active, idle, idle_in_transaction, total
+		FROM (
+			SELECT
+				A.datname AS database,
+				COALESCE(A.usename, '') AS user_name,
+				COALESCE(A.application_name, '') AS application_name,
+				SUM(CASE WHEN A.state = 'active' THEN 1 ELSE 0 END) AS active,
+				SUM(CASE WHEN A.state = 'idle' THEN 1 ELSE 0 END) AS idle,
+				SUM(CASE WHEN A.state LIKE 'idle in transaction%' THEN 1 ELSE 0 END) AS idle_in_transaction,
+				COUNT(*) AS total,
+				ROW_NUMBER() OVER (PARTITION BY A.datname ORDER BY COUNT(*) DESC) AS rank
+			FROM pg_stat_activity A
+			WHERE A.datname = ANY($1)
+				AND A.pid <> pg_backend_pid()
+			GROUP BY A.datname, A.usename, A.application_name
+		) B
+		WHERE rank <= $2;`,
+
+	dataModels: []struct {
+		databaseBase
+		User              *string `db:"user_name"           metric_name:"user"                             source_type:"attribute"`
+		ApplicationName   *string `db:"application_name"    metric_name:"applicationName"                  source_type:"attribute"`
+		Active            *int64  `db:"active"              metric_name:"db.connections.active"            source_type:"gauge"`
+		Idle              *int64  `db:"idle"                metric_name:"db.connections.idle"              source_type:"gauge"`
+		IdleInTransaction *int64  `db:"idle_in_transaction" metric_name:"db.connections.idleInTransaction" source_type:"gauge"`
+		Total             *int64  `db:"total"               metric_name:"db.connections.total"             source_type:"gauge"`
+	}{},
+}
@@ -0,0 +1,55 @@
+package metrics
+
+import (
+	"testing"
+
+	"github.com/blang/semver/v4"
+	"github.com/lib/pq"
+	"github.com/newrelic/nri-postgresql/src/collection"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_generateConnectionDefinitions(t *testing.T) {
+	databases := collection.DatabaseList{"test1": {}}
+
+	tests := []struct {
+		name          string
+		version       string
+		expectedTag   string
+		expectedCount int
+	}{
+		{name: "PostgreSQL 9.1", version: "9.1.0", expectedCount: 0},
+		{name: "PostgreSQL 9.2", version: "9.2.0", expectedCount: 1, expectedTag: "A.waiting"},
+		{name: "PostgreSQL 9.6", version: "9.6.0", expectedCount: 1, expectedTag: "wait_event_type = 'Lock'"},
+		{name: "PostgreSQL 17.2", version: "17.2.0", expectedCount: 1, expectedTag: "wait_event_type = 'Lock'"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			version := semver.MustParse(tt.version)
+			queryDefinitions := generateConnectionDefinitions(databases, &version)
+			assert.Len(t, queryDefinitions, tt.expectedCount)
+			if tt.expectedCount > 0 {
+				assert.Contains(t, queryDefinitions[0].GetQuery(), tt.expectedTag)
+				assert.Contains(t, queryDefinitions[0].GetQuery(), "D.datname = ANY($1)")
+				assert.Equal(t, []interface{}{pq.Array([]string{"test1"})}, queryDefinitions[0].GetArgs())
+			}
+		})
+	}
+}
+
+func Test_generateConnectionBreakdownDefinitions(t *testing.T) {
+	databases := collection.DatabaseList{"test1": {}}
+	version := semver.MustParse("12.0.0")
+
+	queryDefinitions := generateConnectionBreakdownDefinitions(databases, &version, 5)
+	assert.Len(t, queryDefinitions, 1)
+	assert.Contains(t, queryDefinitions[0].GetQuery(), "rank <= $2;")
+	assert.Equal(t, []interface{}{pq.Array([]string{"test1"}), 5}, queryDefinitions[0].GetArgs())
+	assert.Empty(t, connectionBreakdownDefinition.GetArgs())
+
+	assert.Empty(t, generateConnectionBreakdownDefinitions(databases, &version, 0))
+
+	oldVersion := semver.MustParse("9.1.0")
+	assert.Empty(t, generateConnectionBreakdownDefinitions(databases, &oldVersion, 5))
+}
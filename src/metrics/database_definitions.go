@@ -6,29 +6,35 @@ import (
 )
 
 func generateDatabaseDefinitions(databases collection.DatabaseList, version *semver.Version) []*QueryDefinition {
-	queryDefinitions := make([]*QueryDefinition, 0, 2)
+	queryDefinitions := make([]*QueryDefinition, 0, 3)
 	if len(databases) == 0 {
 		return queryDefinitions
 	}
 
 	v91 := semver.MustParse("9.1.0")
 	v92 := semver.MustParse("9.2.0")
+	v95 := semver.MustParse("9.5.0")
 
 	if version.LT(v91) {
-		queryDefinitions = append(queryDefinitions, databaseDefinitionUnder91.insertDatabaseNames(databases))
+		queryDefinitions = append(queryDefinitions, databaseDefinitionUnder91.bindDatabaseNames(databases))
 	} else {
-		queryDefinitions = append(queryDefinitions, databaseDefinitionOver91.insertDatabaseNames(databases))
+		queryDefinitions = append(queryDefinitions, databaseDefinitionOver91.bindDatabaseNames(databases))
 	}
 
 	if version.GE(v92) {
-		queryDefinitions = append(queryDefinitions, databaseDefinitionOver92.insertDatabaseNames(databases))
+		queryDefinitions = append(queryDefinitions, databaseDefinitionOver92.bindDatabaseNames(databases))
+	}
+
+	if version.GE(v95) {
+		queryDefinitions = append(queryDefinitions, databaseDefinitionOver95.bindDatabaseNames(databases))
 	}
 
 	return queryDefinitions
 }
 
 // databaseDefinitionUnder91 is the query used to fetch metrics from Postgres below version 9.1.
-// As a special case, max_connections is obtained from the pg_settings table rather than from pg_stat_database
+// As a special case, max_connections is obtained from the pg_settings table rather than from pg_stat_database.
+// The size is only read for databases the user can connect to, as pg_database_size raises an error otherwise
 var databaseDefinitionUnder91 = &QueryDefinition{
 	query: `SELECT -- UNDER91
 		D.datname AS database,
@@ -42,32 +48,43 @@ var databaseDefinitionUnder91 = &QueryDefinition{
 		SD.tup_fetched AS rows_fetched,
 		SD.tup_inserted AS rows_inserted,
 		SD.tup_updated AS rows_updated,
-		SD.tup_deleted AS rows_deleted
+		SD.tup_deleted AS rows_deleted,
+		age(D.datfrozenxid) AS xid_age,
+		age(D.datfrozenxid)::float * 100 / current_setting('autovacuum_freeze_max_age')::float AS xid_percent_towards_freeze_max_age,
+		age(D.datfrozenxid)::float * 100 / 2147483648 AS xid_percent_towards_wraparound,
+		CASE WHEN has_database_privilege(D.datname, 'CONNECT') THEN pg_database_size(D.datname) END AS database_size,
+		TS.spcname AS tablespace
 		FROM pg_stat_database SD 
 		INNER JOIN pg_database D ON D.datname = SD.datname 
 		LEFT JOIN pg_tablespace TS ON TS.oid = D.dattablespace 
 		WHERE D.datistemplate = FALSE 
 			AND D.datname IS NOT NULL
-			AND D.datname IN (%DATABASES%);`,
+			AND D.datname = ANY($1);`,
 
 	dataModels: []struct {
 		databaseBase
-		MaxConnections         *int64 `db:"max_connections"          metric_name:"db.maxconnections"        source_type:"gauge"`
-		ActiveConnections      *int64 `db:"active_connections"       metric_name:"db.connections"           source_type:"gauge"`
-		TransactionsCommitted  *int64 `db:"transactions_committed"   metric_name:"db.commitsPerSecond"      source_type:"rate"`
-		TransactionsRolledBack *int64 `db:"transactions_rolled_back" metric_name:"db.rollbacksPerSecond"    source_type:"rate"`
-		BlockReads             *int64 `db:"block_reads"              metric_name:"db.readsPerSecond"        source_type:"rate"`
-		BufferHits             *int64 `db:"buffer_hits"              metric_name:"db.bufferHitsPerSecond"   source_type:"rate"`
-		RowsReturned           *int64 `db:"rows_returned"            metric_name:"db.rowsReturnedPerSecond" source_type:"rate"`
-		RowsFetched            *int64 `db:"rows_fetched"             metric_name:"db.rowsFetchedPerSecond"  source_type:"rate"`
-		RowsInserted           *int64 `db:"rows_inserted"            metric_name:"db.rowsInsertedPerSecond" source_type:"rate"`
-		RowsUpdated            *int64 `db:"rows_updated"             metric_name:"db.rowsUpdatedPerSecond"  source_type:"rate"`
-		RowsDeleted            *int64 `db:"rows_deleted"             metric_name:"db.rowsDeletedPerSecond"  source_type:"rate"`
+		MaxConnections         *int64   `db:"max_connections"                    metric_name:"db.maxconnections"                           source_type:"gauge"`
+		ActiveConnections      *int64   `db:"active_connections"                 metric_name:"db.connections"                              source_type:"gauge"`
+		TransactionsCommitted  *int64   `db:"transactions_committed"             metric_name:"db.commitsPerSecond"                         source_type:"rate"`
+		TransactionsRolledBack *int64   `db:"transactions_rolled_back"           metric_name:"db.rollbacksPerSecond"                       source_type:"rate"`
+		BlockReads             *int64   `db:"block_reads"                        metric_name:"db.readsPerSecond"                           source_type:"rate"`
+		BufferHits             *int64   `db:"buffer_hits"                        metric_name:"db.bufferHitsPerSecond"                      source_type:"rate"`
+		RowsReturned           *int64   `db:"rows_returned"                      metric_name:"db.rowsReturnedPerSecond"                    source_type:"rate"`
+		RowsFetched            *int64   `db:"rows_fetched"                       metric_name:"db.rowsFetchedPerSecond"                     source_type:"rate"`
+		RowsInserted           *int64   `db:"rows_inserted"                      metric_name:"db.rowsInsertedPerSecond"                    source_type:"rate"`
+		RowsUpdated            *int64   `db:"rows_updated"                       metric_name:"db.rowsUpdatedPerSecond"                     source_type:"rate"`
+		RowsDeleted            *int64   `db:"rows_deleted"                       metric_name:"db.rowsDeletedPerSecond"                     source_type:"rate"`
+		XidAge                 *int64   `db:"xid_age"                            metric_name:"db.wraparound.xidAge"                        source_type:"gauge"`
+		XidPercentFreezeMaxAge *float64 `db:"xid_percent_towards_freeze_max_age" metric_name:"db.wraparound.xidPercentTowardsFreezeMaxAge" source_type:"gauge"`
+		XidPercentWraparound   *float64 `db:"xid_percent_towards_wraparound"     metric_name:"db.wraparound.xidPercentTowardsWraparound"   source_type:"gauge"`
+		DatabaseSize           *int64   `db:"database_size"                      metric_name:"db.sizeInBytes"                              source_type:"gauge"`
+		Tablespace             *string  `db:"tablespace"                         metric_name:"db.tablespace"                               source_type:"attribute"`
 	}{},
 }
 
 // databaseDefinitionOver91 is the query used to fetch metrics from Postgres version 9.1 and above.
-// As a special case, max_connections is obtained from the pg_settings table rather than from pg_stat_database
+// As a special case, max_connections is obtained from the pg_settings table rather than from pg_stat_database.
+// The size is only read for databases the user can connect to, as pg_database_size raises an error otherwise
 var databaseDefinitionOver91 = &QueryDefinition{
 	query: `SELECT 
 		D.datname AS database,
@@ -86,33 +103,45 @@ var databaseDefinitionOver91 = &QueryDefinition{
 		DBC.confl_lock AS queries_canceled_due_to_lock_timeouts,
 		DBC.confl_snapshot AS queries_canceled_due_to_old_snapshots,
 		DBC.confl_bufferpin AS queries_canceled_due_to_pinned_buffers,
-		DBC.confl_deadlock AS queries_canceled_due_to_deadlocks
+		DBC.confl_deadlock AS queries_canceled_due_to_deadlocks,
+		age(D.datfrozenxid) AS xid_age,
+		age(D.datfrozenxid)::float * 100 / current_setting('autovacuum_freeze_max_age')::float AS xid_percent_towards_freeze_max_age,
+		age(D.datfrozenxid)::float * 100 / 2147483648 AS xid_percent_towards_wraparound,
+		CASE WHEN has_database_privilege(D.datname, 'CONNECT') THEN pg_database_size(D.datname) END AS database_size,
+		TS.spcname AS tablespace,
+		COALESCE(extract(epoch from SD.stats_reset), 0)::float AS stats_reset
 		FROM pg_stat_database SD 
 		INNER JOIN pg_database D ON D.datname = SD.datname 
 		INNER JOIN pg_stat_database_conflicts DBC ON DBC.datname = D.datname 
 		LEFT JOIN pg_tablespace TS ON TS.oid = D.dattablespace 
 		WHERE D.datistemplate = FALSE 
 			AND D.datname IS NOT NULL
-			AND D.datname IN (%DATABASES%);`,
+			AND D.datname = ANY($1);`,
 
 	dataModels: []struct {
 		databaseBase
-		MaxConnections                    *int64 `db:"max_connections"                             metric_name:"db.maxconnections"                source_type:"gauge"`
-		ActiveConnections                 *int64 `db:"active_connections"                          metric_name:"db.connections"                   source_type:"gauge"`
-		TransactionsCommitted             *int64 `db:"transactions_committed"                      metric_name:"db.commitsPerSecond"              source_type:"rate"`
-		TransactionsRolledBack            *int64 `db:"transactions_rolled_back"                    metric_name:"db.rollbacksPerSecond"            source_type:"rate"`
-		BlockReads                        *int64 `db:"block_reads"                                 metric_name:"db.readsPerSecond"                source_type:"rate"`
-		BufferHits                        *int64 `db:"buffer_hits"                                 metric_name:"db.bufferHitsPerSecond"           source_type:"rate"`
-		RowsReturned                      *int64 `db:"rows_returned"                               metric_name:"db.rowsReturnedPerSecond"         source_type:"rate"`
-		RowsFetched                       *int64 `db:"rows_fetched"                                metric_name:"db.rowsFetchedPerSecond"          source_type:"rate"`
-		RowsInserted                      *int64 `db:"rows_inserted"                               metric_name:"db.rowsInsertedPerSecond"         source_type:"rate"`
-		RowsUpdated                       *int64 `db:"rows_updated"                                metric_name:"db.rowsUpdatedPerSecond"          source_type:"rate"`
-		RowsDeleted                       *int64 `db:"rows_deleted"                                metric_name:"db.rowsDeletedPerSecond"          source_type:"rate"`
-		CanceledQueriesDroppedTablespaces *int64 `db:"queries_canceled_due_to_dropped_tablespaces" metric_name:"db.conflicts.tablespacePerSecond" source_type:"rate"`
-		CanceledQueriesLockTimeouts       *int64 `db:"queries_canceled_due_to_lock_timeouts"       metric_name:"db.conflicts.locksPerSecond"      source_type:"rate"`
-		CanceledQueriesOldSnapshots       *int64 `db:"queries_canceled_due_to_old_snapshots"       metric_name:"db.conflicts.snapshotPerSecond"   source_type:"rate"`
-		CanceledQueriesPinnedBuffers      *int64 `db:"queries_canceled_due_to_pinned_buffers"      metric_name:"db.conflicts.bufferpinPerSecond"  source_type:"rate"`
-		CanceledQueriesDeadlocks          *int64 `db:"queries_canceled_due_to_deadlocks"           metric_name:"db.conflicts.deadlockPerSecond"   source_type:"rate"`
+		statsResetBase
+		MaxConnections                    *int64   `db:"max_connections"                             metric_name:"db.maxconnections"                           source_type:"gauge"`
+		ActiveConnections                 *int64   `db:"active_connections"                          metric_name:"db.connections"                              source_type:"gauge"`
+		TransactionsCommitted             *int64   `db:"transactions_committed"                      metric_name:"db.commitsPerSecond"                         source_type:"rate"`
+		TransactionsRolledBack            *int64   `db:"transactions_rolled_back"                    metric_name:"db.rollbacksPerSecond"                       source_type:"rate"`
+		BlockReads                        *int64   `db:"block_reads"                                 metric_name:"db.readsPerSecond"                           source_type:"rate"`
+		BufferHits                        *int64   `db:"buffer_hits"                                 metric_name:"db.bufferHitsPerSecond"                      source_type:"rate"`
+		RowsReturned                      *int64   `db:"rows_returned"                               metric_name:"db.rowsReturnedPerSecond"                    source_type:"rate"`
+		RowsFetched                       *int64   `db:"rows_fetched"                                metric_name:"db.rowsFetchedPerSecond"                     source_type:"rate"`
+		RowsInserted                      *int64   `db:"rows_inserted"                               metric_name:"db.rowsInsertedPerSecond"                    source_type:"rate"`
+		RowsUpdated                       *int64   `db:"rows_updated"                                metric_name:"db.rowsUpdatedPerSecond"                     source_type:"rate"`
+		RowsDeleted                       *int64   `db:"rows_deleted"                                metric_name:"db.rowsDeletedPerSecond"                     source_type:"rate"`
+		CanceledQueriesDroppedTablespaces *int64   `db:"queries_canceled_due_to_dropped_tablespaces" metric_name:"db.conflicts.tablespacePerSecond"            source_type:"rate"`
+		CanceledQueriesLockTimeouts       *int64   `db:"queries_canceled_due_to_lock_timeouts"       metric_name:"db.conflicts.locksPerSecond"                 source_type:"rate"`
+		CanceledQueriesOldSnapshots       *int64   `db:"queries_canceled_due_to_old_snapshots"       metric_name:"db.conflicts.snapshotPerSecond"              source_type:"rate"`
+		CanceledQueriesPinnedBuffers      *int64   `db:"queries_canceled_due_to_pinned_buffers"      metric_name:"db.conflicts.bufferpinPerSecond"             source_type:"rate"`
+		CanceledQueriesDeadlocks          *int64   `db:"queries_canceled_due_to_deadlocks"           metric_name:"db.conflicts.deadlockPerSecond"              source_type:"rate"`
+		XidAge                            *int64   `db:"xid_age"                                     metric_name:"db.wraparound.xidAge"                        source_type:"gauge"`
+		XidPercentFreezeMaxAge            *float64 `db:"xid_percent_towards_freeze_max_age"          metric_name:"db.wraparound.xidPercentTowardsFreezeMaxAge" source_type:"gauge"`
+		XidPercentWraparound              *float64 `db:"xid_percent_towards_wraparound"              metric_name:"db.wraparound.xidPercentTowardsWraparound"   source_type:"gauge"`
+		DatabaseSize                      *int64   `db:"database_size"                               metric_name:"db.sizeInBytes"                              source_type:"gauge"`
+		Tablespace                        *string  `db:"tablespace"                                  metric_name:"db.tablespace"                               source_type:"attribute"`
 	}{},
 }
 
@@ -124,17 +153,19 @@ var databaseDefinitionOver92 = &QueryDefinition{
 		SD.temp_bytes AS temporary_bytes_written,
 		SD.deadlocks AS deadlocks,
 		cast(SD.blk_read_time AS bigint) AS time_spent_reading_data,
-		cast(SD.blk_write_time AS bigint) AS time_spent_writing_data
+		cast(SD.blk_write_time AS bigint) AS time_spent_writing_data,
+		COALESCE(extract(epoch from SD.stats_reset), 0)::float AS stats_reset
 		FROM pg_stat_database SD 
 		INNER JOIN pg_database D ON D.datname = SD.datname 
 		INNER JOIN pg_stat_database_conflicts DBC ON DBC.datname = D.datname 
 		LEFT JOIN pg_tablespace TS ON TS.oid = D.dattablespace 
 		WHERE D.datistemplate = FALSE 
 			AND D.datname IS NOT NULL
-			AND D.datname IN (%DATABASES%);`,
+			AND D.datname = ANY($1);`,
 
 	dataModels: []struct {
 		databaseBase
+		statsResetBase
 		TempFilesCreated   *int64 `db:"temporary_files_created" metric_name:"db.tempFilesCreatedPerSecond"        source_type:"rate"`
 		TempWrittenInBytes *int64 `db:"temporary_bytes_written" metric_name:"db.tempWrittenInBytesPerSecond"      source_type:"rate"`
 		Deadlocks          *int64 `db:"deadlocks"               metric_name:"db.deadlocksPerSecond"               source_type:"rate"`
@@ -142,3 +173,24 @@ var databaseDefinitionOver92 = &QueryDefinition{
 		TimeSpentWriting   *int64 `db:"time_spent_writing_data" metric_name:"db.writeTimeInMillisecondsPerSecond" source_type:"rate"`
 	}{},
 }
+
+// databaseDefinitionOver95 is the query used to fetch multixact wraparound metrics from Postgres version 9.5 and above,
+// where mxid_age was introduced.
+var databaseDefinitionOver95 = &QueryDefinition{
+	query: `SELECT -- OVER95
+		D.datname AS database,
+		mxid_age(D.datminmxid) AS multixact_age,
+		mxid_age(D.datminmxid)::float * 100 / current_setting('autovacuum_multixact_freeze_max_age')::float AS multixact_percent_towards_freeze_max_age,
+		mxid_age(D.datminmxid)::float * 100 / 2147483648 AS multixact_percent_towards_wraparound
+		FROM pg_database D
+		WHERE D.datistemplate = FALSE
+			AND D.datname IS NOT NULL
+			AND D.datname = ANY($1);`,
+
+	dataModels: []struct {
+		databaseBase
+		MultixactAge                 *int64   `db:"multixact_age"                            metric_name:"db.wraparound.multixactAge"                        source_type:"gauge"`
+		MultixactPercentFreezeMaxAge *float64 `db:"multixact_percent_towards_freeze_max_age" metric_name:"db.wraparound.multixactPercentTowardsFreezeMaxAge" source_type:"gauge"`
+		MultixactPercentWraparound   *float64 `db:"multixact_percent_towards_wraparound"     metric_name:"db.wraparound.multixactPercentTowardsWraparound"   source_type:"gauge"`
+	}{},
+}
@@ -4,6 +4,7 @@ import (
 	"testing"
 
 	"github.com/blang/semver/v4"
+	"github.com/lib/pq"
 	"github.com/newrelic/nri-postgresql/src/collection"
 	"github.com/stretchr/testify/assert"
 )
@@ -36,23 +37,30 @@ func Test_generateDatabaseDefinitions_LengthV925(t *testing.T) {
 	assert.Equal(t, 2, len(queryDefinitions))
 }
 
-func Test_insertDatabaseNames(t *testing.T) {
+func Test_generateDatabaseDefinitions_LengthV95(t *testing.T) {
+	v95 := semver.MustParse("9.5.0")
+	databaseList := collection.DatabaseList{"test1": {}}
+
+	queryDefinitions := generateDatabaseDefinitions(databaseList, &v95)
+
+	assert.Equal(t, 3, len(queryDefinitions))
+}
+
+func Test_bindDatabaseNames(t *testing.T) {
 	t.Parallel()
 
 	testDefinition := &QueryDefinition{
-		query:      `SELECT * FROM test WHERE database IN (%DATABASES%);`,
+		query:      `SELECT * FROM test WHERE database = ANY($1);`,
 		dataModels: &[]struct{}{},
 	}
 
-	databaseList := collection.DatabaseList{"test1": {}, "test2": {}}
-	td := testDefinition.insertDatabaseNames(databaseList)
-
-	// The database names order is undetermined but the query is equivalent.
-	assert.Contains(t,
-		[]string{
-			`SELECT * FROM test WHERE database IN ('test1','test2');`,
-			`SELECT * FROM test WHERE database IN ('test2','test1');`,
-		},
-		td.query,
-	)
+	databaseList := collection.DatabaseList{"test2": {}, "test1": {}, "it's": {}}
+	td := testDefinition.bindDatabaseNames(databaseList)
+
+	// The query is left untouched and the names, quotes included, are bound in a stable order.
+	assert.Equal(t, testDefinition.query, td.query)
+	assert.Equal(t, []interface{}{pq.Array([]string{"it's", "test1", "test2"})}, td.GetArgs())
+	assert.Empty(t, testDefinition.GetArgs())
+
+	assert.Nil(t, testDefinition.bindDatabaseNames(collection.DatabaseList{}))
 }
@@ -6,7 +6,7 @@ import (
 
 func generateIndexDefinitions(schemaList collection.SchemaList) []*QueryDefinition {
 	queryDefinitions := make([]*QueryDefinition, 0)
-	if def := indexDefinition.insertSchemaTableIndexes(schemaList); def != nil {
+	if def := indexDefinition.bindSchemaTableIndexes(schemaList); def != nil {
 		queryDefinitions = append(queryDefinitions, def)
 	}
 
@@ -32,7 +32,7 @@ var indexDefinition = &QueryDefinition{
 					)
 					AS foo
 					ON t.tablename = foo.ctablename AND t.schemaname = foo.cschemaname
-			where indexname is not null and t.schemaname || '.' || t.tablename || '.' || indexname in (%SCHEMA_TABLE_INDEXES%)
+			where indexname is not null and t.schemaname || '.' || t.tablename || '.' || indexname = ANY($1)
 			ORDER BY 1,2;`,
 
 	dataModels: []struct {
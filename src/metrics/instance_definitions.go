@@ -10,12 +10,57 @@ type VersionDefinition struct {
 }
 
 var versionDefinitions = []VersionDefinition{
+	{
+		minVersion: semver.MustParse("18.0.0"),
+		queryDefinitions: []*QueryDefinition{
+			instanceDefinitionBase170,
+			instanceDefinition170,
+			instanceDefinitionInputOutput170,
+			instanceDefinitionWal180,
+			instanceDefinitionArchiver,
+			instanceDefinitionWalLsn100,
+		},
+	},
 	{
 		minVersion: semver.MustParse("17.0.0"),
 		queryDefinitions: []*QueryDefinition{
 			instanceDefinitionBase170,
 			instanceDefinition170,
 			instanceDefinitionInputOutput170,
+			instanceDefinitionWal140,
+			instanceDefinitionArchiver,
+			instanceDefinitionWalLsn100,
+		},
+	},
+	{
+		minVersion: semver.MustParse("14.0.0"),
+		queryDefinitions: []*QueryDefinition{
+			instanceDefinitionBase,
+			instanceDefinition91,
+			instanceDefinition92,
+			instanceDefinitionWal140,
+			instanceDefinitionArchiver,
+			instanceDefinitionWalLsn100,
+		},
+	},
+	{
+		minVersion: semver.MustParse("10.0.0"),
+		queryDefinitions: []*QueryDefinition{
+			instanceDefinitionBase,
+			instanceDefinition91,
+			instanceDefinition92,
+			instanceDefinitionArchiver,
+			instanceDefinitionWalLsn100,
+		},
+	},
+	{
+		minVersion: semver.MustParse("9.4.0"),
+		queryDefinitions: []*QueryDefinition{
+			instanceDefinitionBase,
+			instanceDefinition91,
+			instanceDefinition92,
+			instanceDefinitionArchiver,
+			instanceDefinitionWalLsn92,
 		},
 	},
 	{
@@ -24,6 +69,7 @@ var versionDefinitions = []VersionDefinition{
 			instanceDefinitionBase,
 			instanceDefinition91,
 			instanceDefinition92,
+			instanceDefinitionWalLsn92,
 		},
 	},
 	{
@@ -36,17 +82,52 @@ var versionDefinitions = []VersionDefinition{
 }
 
 func generateInstanceDefinitions(version *semver.Version) []*QueryDefinition {
-	// Find the first version definition that's applicable
-	for _, versionDef := range versionDefinitions {
+	if queryDefinitions := findVersionDefinitions(versionDefinitions, version); queryDefinitions != nil {
+		return queryDefinitions
+	}
+
+	return []*QueryDefinition{instanceDefinitionBaseUnder91}
+}
+
+// findVersionDefinitions returns the query definitions of the first version definition applicable
+// to version, or nil if none is. Version definitions must be sorted by descending minVersion.
+func findVersionDefinitions(versionDefs []VersionDefinition, version *semver.Version) []*QueryDefinition {
+	for _, versionDef := range versionDefs {
 		if version.GE(versionDef.minVersion) {
 			return versionDef.queryDefinitions
 		}
 	}
 
-	return []*QueryDefinition{instanceDefinitionBase}
+	return nil
 }
 
 var instanceDefinitionBase = &QueryDefinition{
+	query: `SELECT
+		BG.checkpoints_timed AS scheduled_checkpoints_performed,
+		BG.checkpoints_req AS requested_checkpoints_performed,
+		BG.buffers_checkpoint AS buffers_written_during_checkpoint,
+		BG.buffers_clean AS buffers_written_by_background_writer,
+		BG.maxwritten_clean AS background_writer_stops,
+		BG.buffers_backend AS buffers_written_by_backend,
+		BG.buffers_alloc AS buffers_allocated,
+		COALESCE(extract(epoch from BG.stats_reset), 0)::float AS stats_reset
+		FROM pg_stat_bgwriter BG;`,
+
+	dataModels: []struct {
+		statsResetBase
+		ScheduledCheckpointsPerformed    *int64 `db:"scheduled_checkpoints_performed"      metric_name:"bgwriter.checkpointsScheduledPerSecond"             source_type:"rate"`
+		RequestedCheckpointsPerformed    *int64 `db:"requested_checkpoints_performed"      metric_name:"bgwriter.checkpointsRequestedPerSecond"             source_type:"rate"`
+		BuffersWrittenDuringCheckpoint   *int64 `db:"buffers_written_during_checkpoint"    metric_name:"bgwriter.buffersWrittenForCheckpointsPerSecond"     source_type:"rate"`
+		BuffersWrittenByBackgroundWriter *int64 `db:"buffers_written_by_background_writer" metric_name:"bgwriter.buffersWrittenByBackgroundWriterPerSecond" source_type:"rate"`
+		BackgroundWriterStops            *int64 `db:"background_writer_stops"              metric_name:"bgwriter.backgroundWriterStopsPerSecond"            source_type:"rate"`
+		BuffersWrittenByBackend          *int64 `db:"buffers_written_by_backend"           metric_name:"bgwriter.buffersWrittenByBackendPerSecond"          source_type:"rate"`
+		BuffersAllocated                 *int64 `db:"buffers_allocated"                    metric_name:"bgwriter.buffersAllocatedPerSecond"                 source_type:"rate"`
+	}{},
+}
+
+// instanceDefinitionBaseUnder91 is the query used to fetch the background writer metrics from Postgres below
+// version 9.1, where pg_stat_bgwriter has no stats_reset column.
+var instanceDefinitionBaseUnder91 = &QueryDefinition{
 	query: `SELECT
 		BG.checkpoints_timed AS scheduled_checkpoints_performed,
 		BG.checkpoints_req AS requested_checkpoints_performed,
@@ -70,10 +151,12 @@ var instanceDefinitionBase = &QueryDefinition{
 
 var instanceDefinition91 = &QueryDefinition{
 	query: `SELECT 
-		BG.buffers_backend_fsync AS times_backend_executed_own_fsync
+		BG.buffers_backend_fsync AS times_backend_executed_own_fsync,
+		COALESCE(extract(epoch from BG.stats_reset), 0)::float AS stats_reset
 		FROM pg_stat_bgwriter BG;`,
 
 	dataModels: []struct {
+		statsResetBase
 		BackendExecutedOwnFsync *int64 `db:"times_backend_executed_own_fsync" metric_name:"bgwriter.backendFsyncCallsPerSecond" source_type:"rate"`
 	}{},
 }
@@ -81,10 +164,12 @@ var instanceDefinition91 = &QueryDefinition{
 var instanceDefinition92 = &QueryDefinition{
 	query: `SELECT 
 		cast(BG.checkpoint_write_time AS bigint) AS time_writing_checkpoint_files_to_disk,
-		cast(BG.checkpoint_sync_time AS bigint) AS time_synchronizing_checkpoint_files_to_disk
+		cast(BG.checkpoint_sync_time AS bigint) AS time_synchronizing_checkpoint_files_to_disk,
+		COALESCE(extract(epoch from BG.stats_reset), 0)::float AS stats_reset
 		FROM pg_stat_bgwriter BG;`,
 
 	dataModels: []struct {
+		statsResetBase
 		CheckpointWriteTime *int64 `db:"time_writing_checkpoint_files_to_disk"       metric_name:"bgwriter.checkpointWriteTimeInMillisecondsPerSecond" source_type:"rate"`
 		CheckpointSyncTime  *int64 `db:"time_synchronizing_checkpoint_files_to_disk" metric_name:"bgwriter.checkpointSyncTimeInMillisecondsPerSecond"  source_type:"rate"`
 	}{},
@@ -94,10 +179,12 @@ var instanceDefinitionBase170 = &QueryDefinition{
 	query: `SELECT
 		BG.buffers_clean AS buffers_written_by_background_writer,
 		BG.maxwritten_clean AS background_writer_stops,
-		BG.buffers_alloc AS buffers_allocated
+		BG.buffers_alloc AS buffers_allocated,
+		COALESCE(extract(epoch from BG.stats_reset), 0)::float AS stats_reset
 		FROM pg_stat_bgwriter BG;`,
 
 	dataModels: []struct {
+		statsResetBase
 		BuffersWrittenByBackgroundWriter *int64 `db:"buffers_written_by_background_writer" metric_name:"bgwriter.buffersWrittenByBackgroundWriterPerSecond" source_type:"rate"`
 		BackgroundWriterStops            *int64 `db:"background_writer_stops"              metric_name:"bgwriter.backgroundWriterStopsPerSecond"            source_type:"rate"`
 		BuffersAllocated                 *int64 `db:"buffers_allocated"                    metric_name:"bgwriter.buffersAllocatedPerSecond"                 source_type:"rate"`
@@ -110,10 +197,12 @@ var instanceDefinition170 = &QueryDefinition{
 		CP.num_requested AS requested_checkpoints_performed,
 		CP.buffers_written AS buffers_written_during_checkpoint,
 		cast(CP.write_time AS bigint) AS time_writing_checkpoint_files_to_disk,
-		cast(CP.sync_time AS bigint) AS time_synchronizing_checkpoint_files_to_disk
+		cast(CP.sync_time AS bigint) AS time_synchronizing_checkpoint_files_to_disk,
+		COALESCE(extract(epoch from CP.stats_reset), 0)::float AS stats_reset
 		FROM pg_stat_checkpointer CP;`,
 
 	dataModels: []struct {
+		statsResetBase
 		ScheduledCheckpointsPerformed  *int64 `db:"scheduled_checkpoints_performed"             metric_name:"checkpointer.checkpointsScheduledPerSecond"                  source_type:"rate"`
 		RequestedCheckpointsPerformed  *int64 `db:"requested_checkpoints_performed"             metric_name:"checkpointer.checkpointsRequestedPerSecond"              source_type:"rate"`
 		BuffersWrittenDuringCheckpoint *int64 `db:"buffers_written_during_checkpoint"           metric_name:"checkpointer.buffersWrittenForCheckpointsPerSecond"      source_type:"rate"`
@@ -125,11 +214,107 @@ var instanceDefinition170 = &QueryDefinition{
 var instanceDefinitionInputOutput170 = &QueryDefinition{
 	query: `SELECT 
 		SUM(IO.writes) AS buffers_written_by_backend, 
-		SUM(IO.fsyncs) AS times_backend_executed_own_fsync 
+		SUM(IO.fsyncs) AS times_backend_executed_own_fsync,
+		COALESCE(extract(epoch from MAX(IO.stats_reset)), 0)::float AS stats_reset
 		FROM pg_stat_io IO;`,
 
 	dataModels: []struct {
+		statsResetBase
 		BuffersWrittenByBackend *int64 `db:"buffers_written_by_backend"       metric_name:"io.buffersWrittenByBackendPerSecond"  source_type:"rate"`
 		BackendExecutedOwnFsync *int64 `db:"times_backend_executed_own_fsync" metric_name:"io.backendFsyncCallsPerSecond"        source_type:"rate"`
 	}{},
 }
+
+// instanceDefinitionWal180 reports WAL generation from pg_stat_wal on PostgreSQL 18 and above.
+// The write and sync counters moved to pg_stat_io in that version.
+var instanceDefinitionWal180 = &QueryDefinition{
+	query: `SELECT -- WAL_STATS
+		W.wal_records AS wal_records,
+		W.wal_fpi AS wal_full_page_images,
+		W.wal_bytes AS wal_bytes,
+		W.wal_buffers_full AS wal_buffers_full,
+		COALESCE(extract(epoch from W.stats_reset), 0)::float AS stats_reset
+		FROM pg_stat_wal W;`,
+
+	dataModels: []struct {
+		statsResetBase
+		WalRecords        *int64   `db:"wal_records"          metric_name:"wal.recordsPerSecond"        source_type:"rate"`
+		WalFullPageImages *int64   `db:"wal_full_page_images" metric_name:"wal.fullPageImagesPerSecond" source_type:"rate"`
+		WalBytes          *float64 `db:"wal_bytes"            metric_name:"wal.bytesPerSecond"          source_type:"rate"`
+		WalBuffersFull    *int64   `db:"wal_buffers_full"     metric_name:"wal.buffersFullPerSecond"    source_type:"rate"`
+	}{},
+}
+
+// instanceDefinitionWal140 reports WAL generation from pg_stat_wal on PostgreSQL 14 to 17.
+// The timing columns are only populated when track_wal_io_timing is enabled.
+var instanceDefinitionWal140 = &QueryDefinition{
+	query: `SELECT -- WAL_STATS
+		W.wal_records AS wal_records,
+		W.wal_fpi AS wal_full_page_images,
+		W.wal_bytes AS wal_bytes,
+		W.wal_buffers_full AS wal_buffers_full,
+		W.wal_write AS wal_writes,
+		W.wal_sync AS wal_syncs,
+		W.wal_write_time AS wal_write_time,
+		W.wal_sync_time AS wal_sync_time,
+		COALESCE(extract(epoch from W.stats_reset), 0)::float AS stats_reset
+		FROM pg_stat_wal W;`,
+
+	dataModels: []struct {
+		statsResetBase
+		WalRecords        *int64   `db:"wal_records"          metric_name:"wal.recordsPerSecond"                 source_type:"rate"`
+		WalFullPageImages *int64   `db:"wal_full_page_images" metric_name:"wal.fullPageImagesPerSecond"          source_type:"rate"`
+		WalBytes          *float64 `db:"wal_bytes"            metric_name:"wal.bytesPerSecond"                   source_type:"rate"`
+		WalBuffersFull    *int64   `db:"wal_buffers_full"     metric_name:"wal.buffersFullPerSecond"             source_type:"rate"`
+		WalWrites         *int64   `db:"wal_writes"           metric_name:"wal.writesPerSecond"                  source_type:"rate"`
+		WalSyncs          *int64   `db:"wal_syncs"            metric_name:"wal.syncsPerSecond"                   source_type:"rate"`
+		WalWriteTime      *float64 `db:"wal_write_time"       metric_name:"wal.writeTimeInMillisecondsPerSecond" source_type:"rate"`
+		WalSyncTime       *float64 `db:"wal_sync_time"        metric_name:"wal.syncTimeInMillisecondsPerSecond"  source_type:"rate"`
+	}{},
+}
+
+// instanceDefinitionArchiver reports the WAL archiver status on PostgreSQL 9.4 and above.
+// A failing archive_command shows up as failures with no recent successful archive.
+var instanceDefinitionArchiver = &QueryDefinition{
+	query: `SELECT -- ARCHIVER_STATS
+		A.archived_count AS archived_count,
+		A.failed_count AS failed_count,
+		extract(epoch from now() - A.last_archived_time)::float AS seconds_since_last_archive,
+		extract(epoch from now() - A.last_failed_time)::float AS seconds_since_last_failure,
+		A.last_archived_wal AS last_archived_wal,
+		A.last_failed_wal AS last_failed_wal,
+		COALESCE(extract(epoch from A.stats_reset), 0)::float AS stats_reset
+		FROM pg_stat_archiver A;`,
+
+	dataModels: []struct {
+		statsResetBase
+		ArchivedCount           *int64   `db:"archived_count"             metric_name:"archiver.archivedPerSecond"       source_type:"rate"`
+		FailedCount             *int64   `db:"failed_count"               metric_name:"archiver.failedPerSecond"         source_type:"rate"`
+		SecondsSinceLastArchive *float64 `db:"seconds_since_last_archive" metric_name:"archiver.secondsSinceLastArchive" source_type:"gauge"`
+		SecondsSinceLastFailure *float64 `db:"seconds_since_last_failure" metric_name:"archiver.secondsSinceLastFailure" source_type:"gauge"`
+		LastArchivedWal         *string  `db:"last_archived_wal"          metric_name:"archiver.lastArchivedWal"         source_type:"attribute"`
+		LastFailedWal           *string  `db:"last_failed_wal"            metric_name:"archiver.lastFailedWal"           source_type:"attribute"`
+	}{},
+}
+
+// instanceDefinitionWalLsn100 reports the current WAL position as a byte offset so its rate gives the WAL
+// generated per second. Standbys report the last replayed position, as the insert position is not available.
+var instanceDefinitionWalLsn100 = &QueryDefinition{
+	query: `SELECT -- WAL_LSN
+		pg_wal_lsn_diff(CASE WHEN pg_is_in_recovery() THEN pg_last_wal_replay_lsn() ELSE pg_current_wal_lsn() END, '0/0') AS wal_lsn_bytes;`,
+
+	dataModels: []struct {
+		WalLsnBytes *float64 `db:"wal_lsn_bytes" metric_name:"wal.lsnInBytesPerSecond" source_type:"rate"`
+	}{},
+}
+
+// instanceDefinitionWalLsn92 is the PostgreSQL 9.2 to 9.6 equivalent of instanceDefinitionWalLsn100,
+// using the xlog function names.
+var instanceDefinitionWalLsn92 = &QueryDefinition{
+	query: `SELECT -- WAL_LSN
+		pg_xlog_location_diff(CASE WHEN pg_is_in_recovery() THEN pg_last_xlog_replay_location() ELSE pg_current_xlog_location() END, '0/0') AS wal_lsn_bytes;`,
+
+	dataModels: []struct {
+		WalLsnBytes *float64 `db:"wal_lsn_bytes" metric_name:"wal.lsnInBytesPerSecond" source_type:"rate"`
+	}{},
+}
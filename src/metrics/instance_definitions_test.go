@@ -16,7 +16,7 @@ func Test_generateInstanceDefinitions(t *testing.T) {
 		{
 			name:            "PostgreSQL 9.0",
 			version:         "9.0.0",
-			expectedQueries: []*QueryDefinition{instanceDefinitionBase},
+			expectedQueries: []*QueryDefinition{instanceDefinitionBaseUnder91},
 		},
 		{
 			name:            "PostgreSQL 9.1",
@@ -26,22 +26,32 @@ func Test_generateInstanceDefinitions(t *testing.T) {
 		{
 			name:            "PostgreSQL 9.2",
 			version:         "9.2.0",
-			expectedQueries: []*QueryDefinition{instanceDefinitionBase, instanceDefinition91, instanceDefinition92},
+			expectedQueries: []*QueryDefinition{instanceDefinitionBase, instanceDefinition91, instanceDefinition92, instanceDefinitionWalLsn92},
+		},
+		{
+			name:            "PostgreSQL 9.4",
+			version:         "9.4.0",
+			expectedQueries: []*QueryDefinition{instanceDefinitionBase, instanceDefinition91, instanceDefinition92, instanceDefinitionArchiver, instanceDefinitionWalLsn92},
 		},
 		{
 			name:            "PostgreSQL 10.2",
 			version:         "10.2.0",
-			expectedQueries: []*QueryDefinition{instanceDefinitionBase, instanceDefinition91, instanceDefinition92},
+			expectedQueries: []*QueryDefinition{instanceDefinitionBase, instanceDefinition91, instanceDefinition92, instanceDefinitionArchiver, instanceDefinitionWalLsn100},
 		},
 		{
 			name:            "PostgreSQL 16.4",
 			version:         "16.4.2",
-			expectedQueries: []*QueryDefinition{instanceDefinitionBase, instanceDefinition91, instanceDefinition92},
+			expectedQueries: []*QueryDefinition{instanceDefinitionBase, instanceDefinition91, instanceDefinition92, instanceDefinitionWal140, instanceDefinitionArchiver, instanceDefinitionWalLsn100},
 		},
 		{
 			name:            "PostgreSQL 17.0",
 			version:         "17.0.0",
-			expectedQueries: []*QueryDefinition{instanceDefinitionBase170, instanceDefinition170, instanceDefinitionInputOutput170},
+			expectedQueries: []*QueryDefinition{instanceDefinitionBase170, instanceDefinition170, instanceDefinitionInputOutput170, instanceDefinitionWal140, instanceDefinitionArchiver, instanceDefinitionWalLsn100},
+		},
+		{
+			name:            "PostgreSQL 18.0",
+			version:         "18.0.0",
+			expectedQueries: []*QueryDefinition{instanceDefinitionBase170, instanceDefinition170, instanceDefinitionInputOutput170, instanceDefinitionWal180, instanceDefinitionArchiver, instanceDefinitionWalLsn100},
 		},
 	}
 
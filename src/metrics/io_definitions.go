@@ -0,0 +1,104 @@
+package metrics
+
+import (
+	"github.com/blang/semver/v4"
+)
+
+var ioVersionDefinitions = []VersionDefinition{
+	{
+		minVersion: semver.MustParse("18.0.0"),
+		queryDefinitions: []*QueryDefinition{
+			ioDefinition180,
+		},
+	},
+	{
+		minVersion: semver.MustParse("16.0.0"),
+		queryDefinitions: []*QueryDefinition{
+			ioDefinition160,
+		},
+	},
+}
+
+func generateIODefinitions(version *semver.Version) []*QueryDefinition {
+	if queryDefinitions := findVersionDefinitions(ioVersionDefinitions, version); queryDefinitions != nil {
+		return queryDefinitions
+	}
+
+	return []*QueryDefinition{}
+}
+
+// ioDefinition180 reports one row per backend type, object and context from pg_stat_io on PostgreSQL 18 and above,
+// where the byte counts are reported directly instead of the op_bytes multiplier.
+var ioDefinition180 = &QueryDefinition{
+	query: `SELECT -- IO_STATS
+		IO.backend_type AS backend_type,
+		IO.object AS object,
+		IO.context AS context,
+		IO.reads AS reads,
+		IO.read_bytes AS read_bytes,
+		IO.read_time AS read_time,
+		IO.writes AS writes,
+		IO.write_bytes AS write_bytes,
+		IO.write_time AS write_time,
+		IO.writebacks AS writebacks,
+		IO.writeback_time AS writeback_time,
+		IO.extends AS extends,
+		IO.extend_bytes AS extend_bytes,
+		IO.extend_time AS extend_time,
+		IO.hits AS hits,
+		IO.evictions AS evictions,
+		IO.reuses AS reuses,
+		IO.fsyncs AS fsyncs,
+		IO.fsync_time AS fsync_time
+		FROM pg_stat_io IO;`,
+
+	dataModels: []ioDataModel{},
+}
+
+// ioDefinition160 is the PostgreSQL 16 and 17 equivalent of ioDefinition180. Byte counts are derived
+// from op_bytes, which is the size of a single read, write or extend operation.
+var ioDefinition160 = &QueryDefinition{
+	query: `SELECT -- IO_STATS
+		IO.backend_type AS backend_type,
+		IO.object AS object,
+		IO.context AS context,
+		IO.reads AS reads,
+		IO.reads * IO.op_bytes AS read_bytes,
+		IO.read_time AS read_time,
+		IO.writes AS writes,
+		IO.writes * IO.op_bytes AS write_bytes,
+		IO.write_time AS write_time,
+		IO.writebacks AS writebacks,
+		IO.writeback_time AS writeback_time,
+		IO.extends AS extends,
+		IO.extends * IO.op_bytes AS extend_bytes,
+		IO.extend_time AS extend_time,
+		IO.hits AS hits,
+		IO.evictions AS evictions,
+		IO.reuses AS reuses,
+		IO.fsyncs AS fsyncs,
+		IO.fsync_time AS fsync_time
+		FROM pg_stat_io IO;`,
+
+	dataModels: []ioDataModel{},
+}
+
+type ioDataModel struct {
+	ioBase
+	Reads         *int64   `db:"reads"          metric_name:"io.readsPerSecond"                       source_type:"rate"`
+	ReadBytes     *int64   `db:"read_bytes"     metric_name:"io.readBytesPerSecond"                   source_type:"rate"`
+	ReadTime      *float64 `db:"read_time"      metric_name:"io.readTimeInMillisecondsPerSecond"      source_type:"rate"`
+	Writes        *int64   `db:"writes"         metric_name:"io.writesPerSecond"                      source_type:"rate"`
+	WriteBytes    *int64   `db:"write_bytes"    metric_name:"io.writeBytesPerSecond"                  source_type:"rate"`
+	WriteTime     *float64 `db:"write_time"     metric_name:"io.writeTimeInMillisecondsPerSecond"     source_type:"rate"`
+	Writebacks    *int64   `db:"writebacks"     metric_name:"io.writebacksPerSecond"                  source_type:"rate"`
+	WritebackTime *float64 `db:"writeback_time" metric_name:"io.writebackTimeInMillisecondsPerSecond" source_type:"rate"`
+	Extends       *int64   `db:"extends"        metric_name:"io.extendsPerSecond"                     source_type:"rate"`
+	ExtendBytes   *int64   `db:"extend_bytes"   metric_name:"io.extendBytesPerSecond"                 source_type:"rate"`
+	ExtendTime    *float64 `db:"extend_time"    metric_name:"io.extendTimeInMillisecondsPerSecond"    source_type:"rate"`
+	Hits          *int64   `db:"hits"           metric_name:"io.hitsPerSecond"                        source_type:"rate"`
+	Evictions     *int64   `db:"evictions"      metric_name:"io.evictionsPerSecond"                   source_type:"rate"`
+	Reuses        *int64   `db:"reuses"         metric_name:"io.reusesPerSecond"                      source_type:"rate"`
+	Fsyncs        *int64   `db:"fsyncs"         metric_name:"io.fsyncsPerSecond"                      source_type:"rate"`
+	FsyncTime     *float64 `db:"fsync_time"     metric_name:"io.fsyncTimeInMillisecondsPerSecond"     source_type:"rate"`
+}
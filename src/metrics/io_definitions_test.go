@@ -0,0 +1,45 @@
+package metrics
+
+import (
+	"testing"
+
+	"github.com/blang/semver/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_generateIODefinitions(t *testing.T) {
+	tests := []struct {
+		name            string
+		version         string
+		expectedQueries []*QueryDefinition
+	}{
+		{
+			name:            "PostgreSQL 15",
+			version:         "15.4.0",
+			expectedQueries: []*QueryDefinition{},
+		},
+		{
+			name:            "PostgreSQL 16",
+			version:         "16.0.0",
+			expectedQueries: []*QueryDefinition{ioDefinition160},
+		},
+		{
+			name:            "PostgreSQL 17",
+			version:         "17.2.0",
+			expectedQueries: []*QueryDefinition{ioDefinition160},
+		},
+		{
+			name:            "PostgreSQL 18",
+			version:         "18.0.0",
+			expectedQueries: []*QueryDefinition{ioDefinition180},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			version := semver.MustParse(tt.version)
+			queryDefinitions := generateIODefinitions(&version)
+			assert.Equal(t, tt.expectedQueries, queryDefinitions)
+		})
+	}
+}
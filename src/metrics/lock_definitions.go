@@ -1,6 +1,7 @@
 package metrics
 
 import (
+	"github.com/newrelic/infra-integrations-sdk/v3/log"
 	"github.com/newrelic/nri-postgresql/src/collection"
 )
 
@@ -10,59 +11,108 @@ func generateLockDefinitions(databases collection.DatabaseList) []*QueryDefiniti
 		return queryDefinitions
 	}
 
-	queryDefinitions = append(queryDefinitions, lockDefinitions.insertDatabaseNames(databases))
+	queryDefinitions = append(queryDefinitions, lockDefinitions.bindDatabaseNames(databases))
 
 	return queryDefinitions
 }
 
+// lockDefinitions counts the locks of each database by type, mode and whether they are granted.
+// The counts are pivoted into one sample per database by pivotLockCounts.
 var lockDefinitions = &QueryDefinition{
 	query: `SELECT -- LOCKS_DEFINITION
-                 database,
-                 COALESCE(access_exclusive_lock, 0) AS access_exclusive_lock,
-                 COALESCE(access_share_lock, 0) AS access_share_lock,
-                 COALESCE(exclusive_lock, 0) AS exclusive_lock,
-                 COALESCE(row_exclusive_lock, 0) AS row_exclusive_lock,
-                 COALESCE(row_share_lock, 0) AS row_share_lock,
-                 COALESCE(share_lock, 0) AS share_lock,
-                 COALESCE(share_row_exclusive_lock, 0) AS share_row_exclusive_lock,
-                 COALESCE(share_update_exclusive_lock, 0) AS share_update_exclusive_lock
-            FROM public.crosstab(
-                  $$SELECT psa.datname AS database,
-                           lock.mode,
-                           count(lock.mode)
-                     FROM pg_locks AS lock
-                LEFT JOIN pg_stat_activity AS psa ON lock.pid = psa.pid
-                    WHERE psa.datname IN (%DATABASES%)
-                 GROUP BY lock.database, lock.mode, psa.datname
-                 ORDER BY database,mode$$,
-                 $$VALUES ('AccessExclusiveLock'::text),
-                          ('AccessShareLock'::text),
-                          ('ExclusiveLock'::text),
-                          ('RowExclusiveLock'::text),
-                          ('RowShareLock'::text),
-                          ('ShareLock'::text),
-                          ('ShareRowExclusiveLock'::text),
-                          ('ShareUpdateExclusiveLock'::text) $$
-           ) AS data (
-                 database text,
-                 access_exclusive_lock numeric,
-                 access_share_lock numeric,
-                 exclusive_lock numeric,
-                 row_exclusive_lock numeric,
-                 row_share_lock numeric,
-                 share_lock numeric,
-                 share_row_exclusive_lock numeric,
-                 share_update_exclusive_lock numeric
-          );`,
-	dataModels: []struct {
-		databaseBase
-		AccessExclusiveLock      *int64 `db:"access_exclusive_lock" metric_name:"db.locks.accessExclusiveLock" source_type:"gauge"`
-		AccessShareLock          *int64 `db:"access_share_lock" metric_name:"db.locks.accessShareLock" source_type:"gauge"`
-		ExclusiveLock            *int64 `db:"exclusive_lock" metric_name:"db.locks.exclusiveLock" source_type:"gauge"`
-		RowExclusiveLock         *int64 `db:"row_exclusive_lock" metric_name:"db.locks.rowExclusiveLock" source_type:"gauge"`
-		RowShareLock             *int64 `db:"row_share_lock" metric_name:"db.locks.rowShareLock" source_type:"gauge"`
-		ShareLock                *int64 `db:"share_lock" metric_name:"db.locks.shareLock" source_type:"gauge"`
-		ShareRowExclusiveLock    *int64 `db:"share_row_exclusive_lock" metric_name:"db.locks.shareRowExclusiveLock" source_type:"gauge"`
-		ShareUpdateExclusiveLock *int64 `db:"share_update_exclusive_lock" metric_name:"db.locks.shareUpdateExclusiveLock" source_type:"gauge"`
-	}{},
+		psa.datname AS database,
+		lock.locktype AS locktype,
+		lock.mode AS mode,
+		lock.granted AS granted,
+		count(*) AS lock_count
+		FROM pg_locks AS lock
+		INNER JOIN pg_stat_activity AS psa ON lock.pid = psa.pid
+		WHERE psa.datname = ANY($1)
+		GROUP BY psa.datname, lock.locktype, lock.mode, lock.granted;`,
+
+	dataModels: []lockCountDataModel{},
+}
+
+type lockCountDataModel struct {
+	databaseBase
+	LockType *string `db:"locktype"`
+	Mode     *string `db:"mode"`
+	Granted  *bool   `db:"granted"`
+	Count    *int64  `db:"lock_count"`
+}
+
+// lockModeMetrics maps the table lock modes to the name of the metric counting them.
+// Waiters are counted in the same name suffixed with Waiting.
+var lockModeMetrics = map[string]string{
+	"AccessExclusiveLock":      "db.locks.accessExclusiveLock",
+	"AccessShareLock":          "db.locks.accessShareLock",
+	"ExclusiveLock":            "db.locks.exclusiveLock",
+	"RowExclusiveLock":         "db.locks.rowExclusiveLock",
+	"RowShareLock":             "db.locks.rowShareLock",
+	"ShareLock":                "db.locks.shareLock",
+	"ShareRowExclusiveLock":    "db.locks.shareRowExclusiveLock",
+	"ShareUpdateExclusiveLock": "db.locks.shareUpdateExclusiveLock",
+}
+
+// lockTypeMetrics maps the pg_locks lock types reported on their own to the name of the metric counting them
+var lockTypeMetrics = map[string]string{
+	"advisory": "db.locks.advisory",
+	"extend":   "db.locks.relationExtension",
+}
+
+const waitingLocksMetric = "db.locks.waiting"
+
+// pivotLockCounts turns the lock counts into one set of lock metrics per database. Every metric is present
+// for each database returned, so locks that are not held are reported as 0.
+func pivotLockCounts(rows []lockCountDataModel) map[string]map[string]int64 {
+	counts := make(map[string]map[string]int64)
+	for _, row := range rows {
+		name, err := row.GetDatabaseName()
+		if err != nil {
+			log.Error("Unable to get database name: %s", err.Error())
+			continue
+		}
+		if row.Count == nil {
+			continue
+		}
+
+		databaseCounts, ok := counts[name]
+		if !ok {
+			databaseCounts = newLockCounts()
+			counts[name] = databaseCounts
+		}
+
+		waiting := row.Granted != nil && !*row.Granted
+		if row.Mode != nil {
+			if metricName, ok := lockModeMetrics[*row.Mode]; ok {
+				databaseCounts[metricName] += *row.Count
+				if waiting {
+					databaseCounts[metricName+"Waiting"] += *row.Count
+				}
+			}
+		}
+		if row.LockType != nil {
+			if metricName, ok := lockTypeMetrics[*row.LockType]; ok {
+				databaseCounts[metricName] += *row.Count
+			}
+		}
+		if waiting {
+			databaseCounts[waitingLocksMetric] += *row.Count
+		}
+	}
+
+	return counts
+}
+
+func newLockCounts() map[string]int64 {
+	lockCounts := map[string]int64{waitingLocksMetric: 0}
+	for _, metricName := range lockModeMetrics {
+		lockCounts[metricName] = 0
+		lockCounts[metricName+"Waiting"] = 0
+	}
+	for _, metricName := range lockTypeMetrics {
+		lockCounts[metricName] = 0
+	}
+
+	return lockCounts
 }
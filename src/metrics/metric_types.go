@@ -3,15 +3,17 @@ package metrics
 import (
 	"fmt"
 	"reflect"
-	"strings"
+	"sort"
 
+	"github.com/lib/pq"
 	"github.com/newrelic/nri-postgresql/src/collection"
 )
 
-// QueryDefinition holds the query and the unmarshall model
+// QueryDefinition holds the query, its bind parameters and the unmarshall model
 type QueryDefinition struct {
 	query      string
 	dataModels interface{}
+	args       []interface{}
 }
 
 // GetQuery returns the query of the QueryDefinition
@@ -19,37 +21,51 @@ func (qd QueryDefinition) GetQuery() string {
 	return qd.query
 }
 
+// GetArgs returns the bind parameters of the QueryDefinition, in the order of their $n placeholders
+func (qd QueryDefinition) GetArgs() []interface{} {
+	return qd.args
+}
+
 // GetDataModels returns the data models of the QueryDefinition
 func (qd QueryDefinition) GetDataModels() interface{} {
 	ptr := reflect.New(reflect.ValueOf(qd.dataModels).Type())
 	return ptr.Interface()
 }
 
-func (qd QueryDefinition) insertDatabaseNames(databases collection.DatabaseList) *QueryDefinition {
-	schemaDBs := make([]string, 0)
+// withArgs returns a copy of the QueryDefinition with args bound to its next placeholders
+func (qd QueryDefinition) withArgs(args ...interface{}) *QueryDefinition {
+	boundArgs := make([]interface{}, 0, len(qd.args)+len(args))
+	boundArgs = append(boundArgs, qd.args...)
+	boundArgs = append(boundArgs, args...)
+
+	return &QueryDefinition{
+		query:      qd.query,
+		dataModels: qd.dataModels,
+		args:       boundArgs,
+	}
+}
+
+// bindDatabaseNames binds the names of databases as a text array, compared with "= ANY($1)" in the query
+func (qd QueryDefinition) bindDatabaseNames(databases collection.DatabaseList) *QueryDefinition {
+	schemaDBs := make([]string, 0, len(databases))
 	for schemaDB := range databases {
-		schemaDBs = append(schemaDBs, fmt.Sprintf("'%s'", schemaDB))
+		schemaDBs = append(schemaDBs, schemaDB)
 	}
 
 	if len(schemaDBs) == 0 {
 		return nil
 	}
 
-	schemaDBString := strings.Join(schemaDBs, ",")
-
-	newDBDef := &QueryDefinition{
-		dataModels: qd.dataModels,
-		query:      strings.Replace(qd.query, `%DATABASES%`, schemaDBString, 1),
-	}
-
-	return newDBDef
+	sort.Strings(schemaDBs)
+	return qd.withArgs(pq.Array(schemaDBs))
 }
 
-func (qd QueryDefinition) insertSchemaTables(schemaList collection.SchemaList) *QueryDefinition {
+// bindSchemaTables binds the "schema.table" names of schemaList as a text array, compared with "= ANY($1)" in the query
+func (qd QueryDefinition) bindSchemaTables(schemaList collection.SchemaList) *QueryDefinition {
 	schemaTables := make([]string, 0)
 	for schema, tableList := range schemaList {
 		for table := range tableList {
-			schemaTables = append(schemaTables, fmt.Sprintf("'%s.%s'", schema, table))
+			schemaTables = append(schemaTables, schema+"."+table)
 		}
 	}
 
@@ -57,22 +73,18 @@ func (qd QueryDefinition) insertSchemaTables(schemaList collection.SchemaList) *
 		return nil
 	}
 
-	schemaTablesString := strings.Join(schemaTables, ",")
-
-	newTableDef := &QueryDefinition{
-		dataModels: qd.dataModels,
-		query:      strings.Replace(qd.query, `%SCHEMA_TABLES%`, schemaTablesString, 1),
-	}
-
-	return newTableDef
+	sort.Strings(schemaTables)
+	return qd.withArgs(pq.Array(schemaTables))
 }
 
-func (qd QueryDefinition) insertSchemaTableIndexes(schemaList collection.SchemaList) *QueryDefinition {
+// bindSchemaTableIndexes binds the "schema.table.index" names of schemaList as a text array,
+// compared with "= ANY($1)" in the query
+func (qd QueryDefinition) bindSchemaTableIndexes(schemaList collection.SchemaList) *QueryDefinition {
 	schemaTableIndexes := make([]string, 0)
 	for schema, tableList := range schemaList {
 		for table, indexList := range tableList {
 			for _, index := range indexList {
-				schemaTableIndexes = append(schemaTableIndexes, fmt.Sprintf("'%s.%s.%s'", schema, table, index))
+				schemaTableIndexes = append(schemaTableIndexes, schema+"."+table+"."+index)
 			}
 		}
 	}
@@ -81,12 +93,50 @@ func (qd QueryDefinition) insertSchemaTableIndexes(schemaList collection.SchemaL
 		return nil
 	}
 
-	schemaTableIndexString := strings.Join(schemaTableIndexes, ",")
+	sort.Strings(schemaTableIndexes)
+	return qd.withArgs(pq.Array(schemaTableIndexes))
+}
 
-	newIndexDef := &QueryDefinition{
-		dataModels: qd.dataModels,
-		query:      strings.Replace(qd.query, `%SCHEMA_TABLE_INDEXES%`, schemaTableIndexString, 1),
+// withoutMetrics returns a copy of the QueryDefinition whose data models do not report the given metrics, which is
+// how metrics overridden by the catalog are left to it. The data model is rebuilt at run time with the fields of
+// embedded structs flattened, so its rows are identified by their columns rather than by modeler methods.
+func (qd QueryDefinition) withoutMetrics(metricNames map[string]bool) *QueryDefinition {
+	rowType := reflect.TypeOf(qd.dataModels).Elem()
+	fields, removed := flattenFields(rowType, metricNames, nil)
+	if !removed {
+		return &qd
+	}
+
+	return &QueryDefinition{
+		query:      qd.query,
+		dataModels: reflect.MakeSlice(reflect.SliceOf(reflect.StructOf(fields)), 0, 0).Interface(),
+		args:       qd.args,
 	}
+}
+
+// flattenFields appends the fields of t and of its embedded structs to fields, dropping the metric tags of the
+// fields reporting one of metricNames but keeping their db tag so that their columns are still scanned
+func flattenFields(t reflect.Type, metricNames map[string]bool, fields []reflect.StructField) ([]reflect.StructField, bool) {
+	removed := false
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.Anonymous && field.Type.Kind() == reflect.Struct {
+			var removedEmbedded bool
+			fields, removedEmbedded = flattenFields(field.Type, metricNames, fields)
+			removed = removed || removedEmbedded
+			continue
+		}
 
-	return newIndexDef
+		tag := field.Tag
+		if metricNames[tag.Get("metric_name")] {
+			tag = reflect.StructTag(fmt.Sprintf(`db:%q`, tag.Get("db")))
+			removed = true
+		}
+		fields = append(fields, reflect.StructField{
+			Name: fmt.Sprintf("Field%d", len(fields)),
+			Type: field.Type,
+			Tag:  tag,
+		})
+	}
+	return fields, removed
 }
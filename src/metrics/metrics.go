@@ -1,10 +1,14 @@
 package metrics
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"reflect"
 	"regexp"
+	"strconv"
+	"strings"
 	"sync"
 
 	"github.com/blang/semver/v4"
@@ -21,14 +25,20 @@ const (
 	versionQuery = `SHOW server_version`
 )
 
-// PopulateMetrics collects metrics for each type
+// PopulateMetrics collects metrics for each type. Once ctx is done, running queries are cancelled
+// and the remaining collectors are skipped, leaving the metrics collected so far to be published.
+// PgBouncer metrics are collected from the admin console described by pgbouncerInfo, unless it is nil.
 func PopulateMetrics(
+	ctx context.Context,
 	ci connection.Info,
 	databaseList collection.DatabaseList,
 	instance *integration.Entity,
 	i *integration.Integration,
-	collectPgBouncer, collectDbLocks, collectBloat bool,
-	customMetricsQuery string) {
+	pgbouncerInfo connection.Info,
+	collectPgpool, collectDbLocks, collectBloat bool,
+	connectionBreakdownLimit, maxConcurrentDatabases int,
+	customMetricsQuery string,
+	catalog *Catalog) {
 
 	con, err := ci.NewConnection(ci.DatabaseName())
 	if err != nil {
@@ -36,37 +46,83 @@ func PopulateMetrics(
 		return
 	}
 	defer con.Close()
+	con = con.WithContext(ctx)
 
-	version, err := CollectVersion(con)
+	var version *semver.Version
+	RunCollector(ctx, "version", func(ctx context.Context) {
+		version, err = CollectVersion(con.WithContext(ctx))
+	})
 	if err != nil {
 		log.Error("Metrics collection failed: error collecting version number: %s", err.Error())
 		return
 	}
+	if version == nil {
+		return
+	}
 
-	PopulateInstanceMetrics(instance, version, con)
-	PopulateDatabaseMetrics(databaseList, version, i, con, ci)
+	RunCollector(ctx, "instance", func(ctx context.Context) { PopulateInstanceMetrics(instance, version, con.WithContext(ctx), catalog) })
+	RunCollector(ctx, "io", func(ctx context.Context) { PopulateIOMetrics(instance, version, con.WithContext(ctx)) })
+	RunCollector(ctx, "replication", func(ctx context.Context) {
+		PopulateReplicationMetrics(instance, version, i, con.WithContext(ctx), ci)
+	})
+	RunCollector(ctx, "replicationSlot", func(ctx context.Context) {
+		PopulateReplicationSlotMetrics(instance, version, con.WithContext(ctx))
+	})
+	RunCollector(ctx, "tablespace", func(ctx context.Context) { PopulateTablespaceMetrics(version, i, con.WithContext(ctx), ci) })
+	RunCollector(ctx, "database", func(ctx context.Context) {
+		PopulateDatabaseMetrics(databaseList, version, i, con.WithContext(ctx), ci, catalog)
+	})
+	if connectionBreakdownLimit > 0 {
+		RunCollector(ctx, "connectionBreakdown", func(ctx context.Context) {
+			PopulateConnectionBreakdownMetrics(databaseList, version, connectionBreakdownLimit, i, con.WithContext(ctx), ci)
+		})
+	}
 	if collectDbLocks {
-		PopulateDatabaseLockMetrics(databaseList, version, i, con, ci)
+		RunCollector(ctx, "lock", func(ctx context.Context) {
+			PopulateDatabaseLockMetrics(databaseList, version, i, con.WithContext(ctx), ci)
+		})
+	}
+	RunCollector(ctx, "table", func(ctx context.Context) {
+		PopulateTableMetrics(ctx, databaseList, version, i, ci, maxConcurrentDatabases, catalog)
+	})
+	if collectBloat {
+		RunCollector(ctx, "bloat", func(ctx context.Context) {
+			PopulateTableBloatMetrics(ctx, databaseList, version, i, ci, maxConcurrentDatabases, catalog)
+		})
 	}
-	PopulateTableMetrics(databaseList, version, i, ci, collectBloat)
-	PopulateIndexMetrics(databaseList, i, ci)
+	RunCollector(ctx, "index", func(ctx context.Context) {
+		PopulateIndexMetrics(ctx, databaseList, version, i, ci, maxConcurrentDatabases, catalog)
+	})
 	if customMetricsQuery != "" {
-		PopulateCustomMetrics(customMetricsQuery, i, con, ci, instance)
+		RunCollector(ctx, "customQuery", func(ctx context.Context) {
+			PopulateCustomMetrics(customMetricsQuery, i, con.WithContext(ctx), ci, instance)
+		})
+	}
+	if collectPgpool {
+		RunCollector(ctx, "pgpool", func(ctx context.Context) { PopulatePgpoolMetrics(i, con.WithContext(ctx), ci) })
 	}
 
-	if collectPgBouncer {
-		con, err = ci.NewConnection("pgbouncer")
-		if err != nil {
-			log.Error("Error creating connection to pgbouncer database: %s", err)
-		} else {
-			defer con.Close()
-			PopulatePgBouncerMetrics(i, con, ci)
-		}
+	if pgbouncerInfo != nil {
+		RunCollector(ctx, "pgbouncer", func(ctx context.Context) { CollectPgBouncerMetrics(ctx, pgbouncerInfo, i) })
+	}
+}
+
+// CollectPgBouncerMetrics connects to the PgBouncer admin console described by ci and populates its metrics.
+// It does not require access to PostgreSQL, so it is also used on its own to monitor standalone PgBouncer hosts.
+func CollectPgBouncerMetrics(ctx context.Context, ci connection.Info, i *integration.Integration) {
+	con, err := ci.NewConnection(connection.PgBouncerAdminDatabase)
+	if err != nil {
+		log.Error("Error creating connection to pgbouncer database: %s", err)
+		recordCollectorError(ctx, err)
+		return
 	}
+	defer con.Close()
+
+	PopulatePgBouncerMetrics(i, con.WithContext(ctx), ci)
 }
 
 // PopulateCustomMetricsFromFile collects metrics defined by a custom config file
-func PopulateCustomMetricsFromFile(ci connection.Info, configFile string, psqlIntegration *integration.Integration) {
+func PopulateCustomMetricsFromFile(ctx context.Context, ci connection.Info, configFile string, psqlIntegration *integration.Integration) {
 	contents, err := ioutil.ReadFile(configFile)
 	if err != nil {
 		log.Error("Failed to read custom config file: %s", err)
@@ -85,6 +141,11 @@ func PopulateCustomMetricsFromFile(ci connection.Info, configFile string, psqlIn
 	wg := sync.WaitGroup{}
 	for _, config := range customYAML.Queries {
 		sem <- struct{}{}
+		if err := ctx.Err(); err != nil {
+			log.Warn("Skipping remaining custom queries: %s", err.Error())
+			<-sem
+			break
+		}
 		wg.Add(1)
 		go func(cfg customMetricsConfig) {
 			defer wg.Done()
@@ -92,14 +153,14 @@ func PopulateCustomMetricsFromFile(ci connection.Info, configFile string, psqlIn
 				<-sem
 			}()
 
-			CollectCustomConfig(ci, cfg, psqlIntegration)
+			CollectCustomConfig(ctx, ci, cfg, psqlIntegration)
 		}(config)
 	}
 	wg.Wait()
 }
 
 // CollectCustomConfig collects metrics defined by a custom config
-func CollectCustomConfig(ci connection.Info, cfg customMetricsConfig, pgIntegration *integration.Integration) {
+func CollectCustomConfig(ctx context.Context, ci connection.Info, cfg customMetricsConfig, pgIntegration *integration.Integration) {
 	dbName := func() string {
 		if cfg.Database == "" {
 			return ci.DatabaseName()
@@ -110,11 +171,12 @@ func CollectCustomConfig(ci connection.Info, cfg customMetricsConfig, pgIntegrat
 	con, err := ci.NewConnection(dbName)
 	if err != nil {
 		log.Error("Custom query collection failed: error creating connection to PostgreSQL: %s", err.Error())
+		recordCollectorError(ctx, err)
 		return
 	}
 	defer con.Close()
 
-	rows, err := con.Queryx(cfg.Query)
+	rows, err := con.QueryxContext(ctx, cfg.Query)
 	if err != nil {
 		log.Error("Could not execute database query: %s", err.Error())
 		return
@@ -223,7 +285,14 @@ type serverVersionRow struct {
 	Version string `db:"server_version"`
 }
 
+// CollectVersion returns the version of the server, cached by the capabilities carried by the connection context
 func CollectVersion(connection *connection.PGSQLConnection) (*semver.Version, error) {
+	return CachedCapability(connection.Context(), "version", func() (*semver.Version, error) {
+		return collectVersion(connection)
+	})
+}
+
+func collectVersion(connection *connection.PGSQLConnection) (*semver.Version, error) {
 	var versionRows []*serverVersionRow
 	if err := connection.Query(&versionRows, versionQuery); err != nil {
 		return nil, err
@@ -258,16 +327,16 @@ func CollectVersion(connection *connection.PGSQLConnection) (*semver.Version, er
 //return &v, nil
 //}
 
-// PopulateInstanceMetrics populates the metrics for an instance
-func PopulateInstanceMetrics(instanceEntity *integration.Entity, version *semver.Version, connection *connection.PGSQLConnection) {
+// PopulateInstanceMetrics populates the metrics for an instance, including those of the instance level of catalog
+func PopulateInstanceMetrics(instanceEntity *integration.Entity, version *semver.Version, connection *connection.PGSQLConnection, catalog *Catalog) {
 	metricSet := instanceEntity.NewMetricSet("PostgresqlInstanceSample",
 		attribute.Attribute{Key: "displayName", Value: instanceEntity.Metadata.Name},
 		attribute.Attribute{Key: "entityName", Value: instanceEntity.Metadata.Namespace + ":" + instanceEntity.Metadata.Name},
 	)
 
-	for _, queryDef := range generateInstanceDefinitions(version) {
+	for _, queryDef := range catalog.apply(catalogLevelInstance, version, generateInstanceDefinitions(version), nil, nil) {
 		dataModels := queryDef.GetDataModels()
-		if err := connection.Query(dataModels, queryDef.GetQuery()); err != nil {
+		if err := connection.Query(dataModels, queryDef.GetQuery(), queryDef.GetArgs()...); err != nil {
 			log.Error("Could not execute instance query: %s", err.Error())
 			continue
 		}
@@ -281,40 +350,246 @@ func PopulateInstanceMetrics(instanceEntity *integration.Entity, version *semver
 		}
 
 		vpInterface := vp.Index(0).Interface()
-		err := metricSet.MarshalMetrics(vpInterface)
+		err := marshalCounterMetrics(metricSet, vpInterface)
 		if err != nil {
 			log.Error("Could not parse metrics from instance query result: %s", err.Error())
+			recordCollectorError(connection.Context(), err)
+		}
+	}
+}
+
+// PopulateIOMetrics populates the pg_stat_io metrics of the instance, one sample per backend type, object and context
+func PopulateIOMetrics(instanceEntity *integration.Entity, version *semver.Version, connection *connection.PGSQLConnection) {
+	ioDefinitions := generateIODefinitions(version)
+	if len(ioDefinitions) == 0 {
+		SkipCollector(connection.Context(), "pg_stat_io requires PostgreSQL 16 or later")
+		return
+	}
+
+	for _, queryDef := range ioDefinitions {
+		dataModels := queryDef.GetDataModels()
+		if err := connection.Query(dataModels, queryDef.GetQuery(), queryDef.GetArgs()...); err != nil {
+			log.Error("Could not execute io query: %s", err.Error())
+			continue
+		}
+
+		// for each row in the response
+		v := reflect.Indirect(reflect.ValueOf(dataModels))
+		for i := 0; i < v.Len(); i++ {
+			row := v.Index(i).Interface()
+			backendType, object, ioContext, err := GetIODimensions(row)
+			if err != nil {
+				log.Error("Unable to get io dimensions: %s", err.Error())
+				continue
+			}
+
+			// the dimensions are set as metric set attributes so rates are computed per combination
+			metricSet := instanceEntity.NewMetricSet("PostgresqlIoSample",
+				attribute.Attribute{Key: "displayName", Value: instanceEntity.Metadata.Name},
+				attribute.Attribute{Key: "entityName", Value: instanceEntity.Metadata.Namespace + ":" + instanceEntity.Metadata.Name},
+				attribute.Attribute{Key: "backendType", Value: backendType},
+				attribute.Attribute{Key: "object", Value: object},
+				attribute.Attribute{Key: "context", Value: ioContext},
+			)
+
+			if err := metricSet.MarshalMetrics(row); err != nil {
+				log.Error("Failed to populate io metrics: %s", err.Error())
+			}
+		}
+	}
+}
+
+// PopulateReplicationMetrics populates the streaming replication metrics. On a primary one entity is
+// reported per connected standby; on a standby the WAL receiver state is reported for the instance itself.
+func PopulateReplicationMetrics(instanceEntity *integration.Entity, version *semver.Version, pgIntegration *integration.Integration, connection *connection.PGSQLConnection, ci connection.Info) {
+	replicationDefinitions := generateReplicationDefinitions(version)
+	if len(replicationDefinitions) == 0 {
+		SkipCollector(connection.Context(), "replication statistics require PostgreSQL 9.2 or later")
+		return
+	}
+
+	for _, queryDef := range replicationDefinitions {
+		dataModels := queryDef.GetDataModels()
+		if err := connection.Query(dataModels, queryDef.GetQuery(), queryDef.GetArgs()...); err != nil {
+			log.Error("Could not execute replication query: %s", err.Error())
+			continue
+		}
+
+		// for each row in the response
+		v := reflect.Indirect(reflect.ValueOf(dataModels))
+		for i := 0; i < v.Len(); i++ {
+			row := v.Index(i).Interface()
+
+			// WAL receiver rows describe the monitored instance itself
+			role, standbyName := "standby", instanceEntity.Metadata.Name
+			if _, ok := row.(StandbyModeler); ok {
+				role = "primary"
+				name, err := GetStandbyName(row)
+				if err != nil {
+					log.Error("Unable to get standby name: %s", err.Error())
+					continue
+				}
+				standbyName = name
+			}
+
+			host, port := ci.HostPort()
+			hostIDAttribute := integration.NewIDAttribute("host", host)
+			portIDAttribute := integration.NewIDAttribute("port", port)
+			standbyEntity, err := pgIntegration.Entity(standbyName, "pg-replication", hostIDAttribute, portIDAttribute)
+			if err != nil {
+				log.Error("Failed to get replication entity for standby %s: %s", standbyName, err.Error())
+				continue
+			}
+			metricSet := standbyEntity.NewMetricSet("PostgresqlReplicationSample",
+				attribute.Attribute{Key: "displayName", Value: standbyEntity.Metadata.Name},
+				attribute.Attribute{Key: "entityName", Value: "replication:" + standbyEntity.Metadata.Name},
+				attribute.Attribute{Key: "instance", Value: instanceEntity.Metadata.Name},
+				attribute.Attribute{Key: "reportedBy", Value: role},
+			)
+
+			if err := metricSet.MarshalMetrics(row); err != nil {
+				log.Error("Failed to populate replication entity with metrics: %s", err.Error())
+			}
 		}
 	}
 }
 
-// PopulateDatabaseMetrics populates the metrics for a database
-func PopulateDatabaseMetrics(databases collection.DatabaseList, version *semver.Version, pgIntegration *integration.Integration, connection *connection.PGSQLConnection, ci connection.Info) {
+// PopulateTablespaceMetrics populates the size and location of each tablespace of the instance
+func PopulateTablespaceMetrics(version *semver.Version, pgIntegration *integration.Integration, connection *connection.PGSQLConnection, ci connection.Info) {
+	for _, queryDef := range generateTablespaceDefinitions(version) {
+		dataModels := queryDef.GetDataModels()
+		if err := connection.Query(dataModels, queryDef.GetQuery(), queryDef.GetArgs()...); err != nil {
+			log.Error("Could not execute tablespace query: %s", err.Error())
+			continue
+		}
+
+		// for each row in the response
+		v := reflect.Indirect(reflect.ValueOf(dataModels))
+		for i := 0; i < v.Len(); i++ {
+			row := v.Index(i).Interface()
+			tablespaceName, err := GetTablespaceName(row)
+			if err != nil {
+				log.Error("Unable to get tablespace name: %s", err.Error())
+				continue
+			}
+
+			host, port := ci.HostPort()
+			hostIDAttribute := integration.NewIDAttribute("host", host)
+			portIDAttribute := integration.NewIDAttribute("port", port)
+			tablespaceEntity, err := pgIntegration.Entity(tablespaceName, "pg-tablespace", hostIDAttribute, portIDAttribute)
+			if err != nil {
+				log.Error("Failed to get tablespace entity for %s: %s", tablespaceName, err.Error())
+				continue
+			}
+			metricSet := tablespaceEntity.NewMetricSet("PostgresqlTablespaceSample",
+				attribute.Attribute{Key: "displayName", Value: tablespaceEntity.Metadata.Name},
+				attribute.Attribute{Key: "entityName", Value: "tablespace:" + tablespaceEntity.Metadata.Name},
+			)
+
+			if err := metricSet.MarshalMetrics(row); err != nil {
+				log.Error("Failed to populate tablespace entity with metrics: %s", err.Error())
+			}
+		}
+	}
+}
+
+// PopulateReplicationSlotMetrics populates the metrics for each replication slot on the instance
+func PopulateReplicationSlotMetrics(instanceEntity *integration.Entity, version *semver.Version, connection *connection.PGSQLConnection) {
+	replicationSlotDefinitions := generateReplicationSlotDefinitions(version)
+	if len(replicationSlotDefinitions) == 0 {
+		SkipCollector(connection.Context(), "replication slots require PostgreSQL 9.4 or later")
+		return
+	}
+
+	for _, queryDef := range replicationSlotDefinitions {
+		dataModels := queryDef.GetDataModels()
+		if err := connection.Query(dataModels, queryDef.GetQuery(), queryDef.GetArgs()...); err != nil {
+			log.Error("Could not execute replication slot query: %s", err.Error())
+			continue
+		}
+
+		// for each row in the response
+		v := reflect.Indirect(reflect.ValueOf(dataModels))
+		for i := 0; i < v.Len(); i++ {
+			row := v.Index(i).Interface()
+			slotName, err := GetSlotName(row)
+			if err != nil {
+				log.Error("Unable to get replication slot name: %s", err.Error())
+				continue
+			}
+
+			// slotName is set as a metric set attribute so rates are computed per slot
+			metricSet := instanceEntity.NewMetricSet("PostgresqlReplicationSlotSample",
+				attribute.Attribute{Key: "displayName", Value: instanceEntity.Metadata.Name},
+				attribute.Attribute{Key: "entityName", Value: instanceEntity.Metadata.Namespace + ":" + instanceEntity.Metadata.Name},
+				attribute.Attribute{Key: "slotName", Value: slotName},
+			)
+
+			if err := metricSet.MarshalMetrics(row); err != nil {
+				log.Error("Failed to populate replication slot metrics: %s", err.Error())
+			}
+		}
+	}
+}
+
+// PopulateDatabaseMetrics populates the metrics for a database, including those of the database level of catalog
+func PopulateDatabaseMetrics(databases collection.DatabaseList, version *semver.Version, pgIntegration *integration.Integration, connection *connection.PGSQLConnection, ci connection.Info, catalog *Catalog) {
 	databaseDefinitions := generateDatabaseDefinitions(databases, version)
-	processDatabaseDefinitions(databaseDefinitions, pgIntegration, connection, ci)
+	databaseDefinitions = append(databaseDefinitions, generateConnectionDefinitions(databases, version)...)
+	databaseDefinitions = catalog.apply(catalogLevelDatabase, version, databaseDefinitions, databases, nil)
+	processDatabaseDefinitions(databaseDefinitions, "PostgresqlDatabaseSample", pgIntegration, connection, ci)
+}
+
+// PopulateConnectionBreakdownMetrics populates the connection counts of each database per user and application,
+// reporting at most limit combinations per database
+func PopulateConnectionBreakdownMetrics(databases collection.DatabaseList, version *semver.Version, limit int, pgIntegration *integration.Integration, connection *connection.PGSQLConnection, ci connection.Info) {
+	breakdownDefinitions := generateConnectionBreakdownDefinitions(databases, version, limit)
+	processDatabaseDefinitions(breakdownDefinitions, "PostgresqlConnectionSample", pgIntegration, connection, ci)
 }
 
 // PopulateDatabaseLockMetrics populates the lock metrics for a database
 func PopulateDatabaseLockMetrics(databases collection.DatabaseList, version *semver.Version, pgIntegration *integration.Integration, connection *connection.PGSQLConnection, ci connection.Info) {
-	if !connection.HaveExtensionInSchema("tablefunc", "public") {
-		log.Warn("Crosstab function not available; database lock metric gathering not possible.")
-		log.Warn("To enable database lock metrics, enable the 'tablefunc' extension on the public")
-		log.Warn("schema of your database. You can do so by:")
-		log.Warn("  1. Installing the postgresql contribs package for your OS; and")
-		log.Warn("  2. Run the query 'CREATE EXTENSION tablefunc;' against your database's public schema")
-		return
-	}
+	for _, queryDef := range generateLockDefinitions(databases) {
+		dataModels := queryDef.GetDataModels()
+		if err := connection.Query(dataModels, queryDef.GetQuery(), queryDef.GetArgs()...); err != nil {
+			log.Error("Could not execute lock query: %s", err.Error())
+			continue
+		}
 
-	lockDefinitions := generateLockDefinitions(databases)
+		rows, ok := dataModels.(*[]lockCountDataModel)
+		if !ok {
+			log.Error("Unexpected data model for lock query")
+			continue
+		}
 
-	processDatabaseDefinitions(lockDefinitions, pgIntegration, connection, ci)
+		for name, lockCounts := range pivotLockCounts(*rows) {
+			host, port := ci.HostPort()
+			hostIDAttribute := integration.NewIDAttribute("host", host)
+			portIDAttribute := integration.NewIDAttribute("port", port)
+			databaseEntity, err := pgIntegration.Entity(name, "pg-database", hostIDAttribute, portIDAttribute)
+			if err != nil {
+				log.Error("Failed to get database entity for name %s: %s", name, err.Error())
+				continue
+			}
+			metricSet := databaseEntity.NewMetricSet("PostgresqlDatabaseSample",
+				attribute.Attribute{Key: "displayName", Value: databaseEntity.Metadata.Name},
+				attribute.Attribute{Key: "entityName", Value: "database:" + databaseEntity.Metadata.Name},
+			)
+
+			for metricName, count := range lockCounts {
+				if err := metricSet.SetMetric(metricName, count, metric.GAUGE); err != nil {
+					log.Error("Failed to set lock metric %s: %s", metricName, err.Error())
+				}
+			}
+		}
+	}
 }
 
-func processDatabaseDefinitions(definitions []*QueryDefinition, pgIntegration *integration.Integration, connection *connection.PGSQLConnection, ci connection.Info) {
+func processDatabaseDefinitions(definitions []*QueryDefinition, sampleName string, pgIntegration *integration.Integration, connection *connection.PGSQLConnection, ci connection.Info) {
 	for _, queryDef := range definitions {
 		// collect into model
 		dataModels := queryDef.GetDataModels()
-		if err := connection.Query(dataModels, queryDef.GetQuery()); err != nil {
+		if err := connection.Query(dataModels, queryDef.GetQuery(), queryDef.GetArgs()...); err != nil {
 			log.Error("Could not execute database query: %s", err.Error())
 			continue
 		}
@@ -335,45 +610,105 @@ func processDatabaseDefinitions(definitions []*QueryDefinition, pgIntegration *i
 			if err != nil {
 				log.Error("Failed to get database entity for name %s: %s", name, err.Error())
 			}
-			metricSet := databaseEntity.NewMetricSet("PostgresqlDatabaseSample",
+			metricSet := databaseEntity.NewMetricSet(sampleName,
 				attribute.Attribute{Key: "displayName", Value: databaseEntity.Metadata.Name},
 				attribute.Attribute{Key: "entityName", Value: "database:" + databaseEntity.Metadata.Name},
 			)
 
-			if err := metricSet.MarshalMetrics(db); err != nil {
+			if err := marshalCounterMetrics(metricSet, db); err != nil {
 				log.Error("Failed to database entity with metrics: %s", err.Error())
+				recordCollectorError(connection.Context(), err)
 			}
 
 		}
 	}
 }
 
-// PopulateTableMetrics populates the metrics for a table
-func PopulateTableMetrics(databases collection.DatabaseList, version *semver.Version, pgIntegration *integration.Integration, ci connection.Info, collectBloat bool) {
+// PopulateTableMetrics populates the metrics for a table, including those of the table level of catalog,
+// collecting up to maxConcurrentDatabases databases at a time
+func PopulateTableMetrics(ctx context.Context, databases collection.DatabaseList, version *semver.Version, pgIntegration *integration.Integration, ci connection.Info, maxConcurrentDatabases int, catalog *Catalog) {
+	forEachDatabase(ctx, databasesWithTables(databases), maxConcurrentDatabases, ci, func(schemaList collection.SchemaList, con *connection.PGSQLConnection) {
+		populateTableMetricsForDatabase(schemaList, version, con, pgIntegration, ci, catalog)
+		populateTableProgressMetricsForDatabase(schemaList, version, con, pgIntegration, ci)
+	})
+}
+
+// PopulateTableBloatMetrics populates the bloat metrics for a table, which can be performance intensive,
+// collecting up to maxConcurrentDatabases databases at a time
+func PopulateTableBloatMetrics(ctx context.Context, databases collection.DatabaseList, version *semver.Version, pgIntegration *integration.Integration, ci connection.Info, maxConcurrentDatabases int, catalog *Catalog) {
+	forEachDatabase(ctx, databasesWithTables(databases), maxConcurrentDatabases, ci, func(schemaList collection.SchemaList, con *connection.PGSQLConnection) {
+		populateTableBloatMetricsForDatabase(schemaList, version, con, pgIntegration, ci, catalog)
+	})
+}
+
+func databasesWithTables(databases collection.DatabaseList) collection.DatabaseList {
+	withTables := make(collection.DatabaseList, len(databases))
 	for database, schemaList := range databases {
-		if len(schemaList) == 0 {
-			return
+		if len(schemaList) > 0 {
+			withTables[database] = schemaList
 		}
+	}
+	return withTables
+}
 
-		// Create a new connection to the database
-		con, err := ci.NewConnection(database)
-		if err != nil {
-			log.Error("Failed to connect to database %s: %s", database, err.Error())
-			continue
+// forEachDatabase gets a connection to every database of the list and runs collect on it, with at most
+// maxConcurrentDatabases databases collected concurrently. Each connection is released as soon as its database
+// is collected. Entity and metric set creation on the integration is synchronized by the SDK, and every
+// database reports on its own entities, so collect can safely run in parallel. Databases not started
+// when ctx is done are skipped.
+func forEachDatabase(ctx context.Context, databases collection.DatabaseList, maxConcurrentDatabases int, ci connection.Info, collect func(schemaList collection.SchemaList, con *connection.PGSQLConnection)) {
+	if maxConcurrentDatabases < 1 {
+		maxConcurrentDatabases = 1
+	}
+
+	sem := make(chan struct{}, maxConcurrentDatabases)
+	wg := sync.WaitGroup{}
+	for database, schemaList := range databases {
+		sem <- struct{}{}
+		if err := ctx.Err(); err != nil {
+			log.Warn("Skipping collection of remaining databases: %s", err.Error())
+			<-sem
+			break
 		}
-		defer con.Close()
-		populateTableMetricsForDatabase(schemaList, version, con, pgIntegration, ci, collectBloat)
+		wg.Add(1)
+		go func(database string, schemaList collection.SchemaList) {
+			defer wg.Done()
+			defer func() {
+				<-sem
+			}()
+
+			con, err := ci.NewConnection(database)
+			if err != nil {
+				log.Error("Failed to connect to database %s: %s", database, err.Error())
+				recordCollectorError(ctx, err)
+				return
+			}
+			defer con.Close()
+
+			collect(schemaList, con.WithContext(ctx))
+		}(database, schemaList)
 	}
+	wg.Wait()
+}
+
+func populateTableMetricsForDatabase(schemaList collection.SchemaList, version *semver.Version, con *connection.PGSQLConnection, pgIntegration *integration.Integration, ci connection.Info, catalog *Catalog) {
+	tableDefinitions := catalog.apply(catalogLevelTable, version, generateTableDefinitions(schemaList, version), nil, schemaList)
+	populateTableDefinitions(tableDefinitions, con, pgIntegration, ci)
 }
 
-func populateTableMetricsForDatabase(schemaList collection.SchemaList, version *semver.Version, con *connection.PGSQLConnection, pgIntegration *integration.Integration, ci connection.Info, collectBloat bool) {
-	tableDefinitions := generateTableDefinitions(schemaList, version, collectBloat)
+// populateTableBloatMetricsForDatabase collects the bloat definitions without the table metrics of catalog,
+// which are collected by the table collector
+func populateTableBloatMetricsForDatabase(schemaList collection.SchemaList, version *semver.Version, con *connection.PGSQLConnection, pgIntegration *integration.Integration, ci connection.Info, catalog *Catalog) {
+	bloatDefinitions := catalog.withoutOverridden(catalogLevelTable, version, generateTableBloatDefinitions(schemaList, version))
+	populateTableDefinitions(bloatDefinitions, con, pgIntegration, ci)
+}
 
+func populateTableDefinitions(tableDefinitions []*QueryDefinition, con *connection.PGSQLConnection, pgIntegration *integration.Integration, ci connection.Info) {
 	// collect into model
 	for _, definition := range tableDefinitions {
 
 		dataModels := definition.GetDataModels()
-		if err := con.Query(dataModels, definition.GetQuery()); err != nil {
+		if err := con.Query(dataModels, definition.GetQuery(), definition.GetArgs()...); err != nil {
 			log.Error("Could not execute table query: %s", err.Error())
 			return
 		}
@@ -395,14 +730,10 @@ func populateTableMetricsForDatabase(schemaList collection.SchemaList, version *
 				log.Error("Unable to get table name: %s", err.Error())
 			}
 
-			host, port := ci.HostPort()
-			hostIDAttribute := integration.NewIDAttribute("host", host)
-			portIDAttribute := integration.NewIDAttribute("port", port)
-			databaseIDAttribute := integration.NewIDAttribute("pg-database", dbName)
-			schemaIDAttribute := integration.NewIDAttribute("pg-schema", schemaName)
-			tableEntity, err := pgIntegration.Entity(tableName, "pg-table", hostIDAttribute, portIDAttribute, databaseIDAttribute, schemaIDAttribute)
+			tableEntity, err := getTableEntity(pgIntegration, ci, dbName, schemaName, tableName)
 			if err != nil {
 				log.Error("Failed to get table entity for table %s: %s", tableName, err.Error())
+				continue
 			}
 			metricSet := tableEntity.NewMetricSet("PostgresqlTableSample",
 				attribute.Attribute{Key: "displayName", Value: tableEntity.Metadata.Name},
@@ -413,33 +744,87 @@ func populateTableMetricsForDatabase(schemaList collection.SchemaList, version *
 
 			if err := metricSet.MarshalMetrics(row); err != nil {
 				log.Error("Failed to populate table entity with metrics: %s", err.Error())
+				recordCollectorError(con.Context(), err)
 			}
 
 		}
 	}
 }
 
-// PopulateIndexMetrics populates the metrics for an index
-func PopulateIndexMetrics(databases collection.DatabaseList, pgIntegration *integration.Integration, ci connection.Info) {
-	for database, schemaList := range databases {
-		con, err := ci.NewConnection(database)
-		if err != nil {
-			log.Error("Failed to create new connection to database %s: %s", database, err.Error())
+func populateTableProgressMetricsForDatabase(schemaList collection.SchemaList, version *semver.Version, con *connection.PGSQLConnection, pgIntegration *integration.Integration, ci connection.Info) {
+	progressDefinitions := generateProgressDefinitions(schemaList, version)
+
+	for _, definition := range progressDefinitions {
+
+		// collect into model
+		dataModels := definition.GetDataModels()
+		if err := con.Query(dataModels, definition.GetQuery(), definition.GetArgs()...); err != nil {
+			log.Error("Could not execute progress query: %s", err.Error())
 			continue
 		}
-		defer con.Close()
-		populateIndexMetricsForDatabase(schemaList, con, pgIntegration, ci)
+
+		// for each operation in progress
+		v := reflect.Indirect(reflect.ValueOf(dataModels))
+		for i := 0; i < v.Len(); i++ {
+			row := v.Index(i).Interface()
+			dbName, err := GetDatabaseName(row)
+			if err != nil {
+				log.Error("Unable to get database name: %s", err.Error())
+			}
+			schemaName, err := GetSchemaName(row)
+			if err != nil {
+				log.Error("Unable to get schema name: %s", err.Error())
+			}
+			tableName, err := GetTableName(row)
+			if err != nil {
+				log.Error("Unable to get table name: %s", err.Error())
+			}
+
+			tableEntity, err := getTableEntity(pgIntegration, ci, dbName, schemaName, tableName)
+			if err != nil {
+				log.Error("Failed to get table entity for table %s: %s", tableName, err.Error())
+				continue
+			}
+			metricSet := tableEntity.NewMetricSet("PostgresqlTableProgressSample",
+				attribute.Attribute{Key: "displayName", Value: tableEntity.Metadata.Name},
+				attribute.Attribute{Key: "entityName", Value: "table:" + tableEntity.Metadata.Name},
+				attribute.Attribute{Key: "database", Value: dbName},
+				attribute.Attribute{Key: "schema", Value: schemaName},
+			)
+
+			if err := metricSet.MarshalMetrics(row); err != nil {
+				log.Error("Failed to populate table entity with progress metrics: %s", err.Error())
+			}
+		}
 	}
 }
 
-func populateIndexMetricsForDatabase(schemaList collection.SchemaList, con *connection.PGSQLConnection, pgIntegration *integration.Integration, ci connection.Info) {
-	indexDefinitions := generateIndexDefinitions(schemaList)
+// getTableEntity returns the pg-table entity identified by its host, port, database and schema
+func getTableEntity(pgIntegration *integration.Integration, ci connection.Info, dbName, schemaName, tableName string) (*integration.Entity, error) {
+	host, port := ci.HostPort()
+	hostIDAttribute := integration.NewIDAttribute("host", host)
+	portIDAttribute := integration.NewIDAttribute("port", port)
+	databaseIDAttribute := integration.NewIDAttribute("pg-database", dbName)
+	schemaIDAttribute := integration.NewIDAttribute("pg-schema", schemaName)
+	return pgIntegration.Entity(tableName, "pg-table", hostIDAttribute, portIDAttribute, databaseIDAttribute, schemaIDAttribute)
+}
+
+// PopulateIndexMetrics populates the metrics for an index, including those of the index level of catalog,
+// collecting up to maxConcurrentDatabases databases at a time
+func PopulateIndexMetrics(ctx context.Context, databases collection.DatabaseList, version *semver.Version, pgIntegration *integration.Integration, ci connection.Info, maxConcurrentDatabases int, catalog *Catalog) {
+	forEachDatabase(ctx, databases, maxConcurrentDatabases, ci, func(schemaList collection.SchemaList, con *connection.PGSQLConnection) {
+		populateIndexMetricsForDatabase(schemaList, version, con, pgIntegration, ci, catalog)
+	})
+}
+
+func populateIndexMetricsForDatabase(schemaList collection.SchemaList, version *semver.Version, con *connection.PGSQLConnection, pgIntegration *integration.Integration, ci connection.Info, catalog *Catalog) {
+	indexDefinitions := catalog.apply(catalogLevelIndex, version, generateIndexDefinitions(schemaList), nil, schemaList)
 
 	for _, definition := range indexDefinitions {
 
 		// collect into model
 		dataModels := definition.GetDataModels()
-		if err := con.Query(dataModels, definition.GetQuery()); err != nil {
+		if err := con.Query(dataModels, definition.GetQuery(), definition.GetArgs()...); err != nil {
 			log.Error("Could not execute index query: %s", err.Error())
 			return
 		}
@@ -474,6 +859,7 @@ func populateIndexMetricsForDatabase(schemaList collection.SchemaList, con *conn
 			indexEntity, err := pgIntegration.Entity(indexName, "pg-index", hostIDAttribute, portIDAttribute, databaseIDAttribute, schemaIDAttribute, tableIDAttribute)
 			if err != nil {
 				log.Error("Failed to get table entity for index %s: %s", indexName, err.Error())
+				continue
 			}
 			metricSet := indexEntity.NewMetricSet("PostgresqlIndexSample",
 				attribute.Attribute{Key: "displayName", Value: indexEntity.Metadata.Name},
@@ -485,6 +871,7 @@ func populateIndexMetricsForDatabase(schemaList collection.SchemaList, con *conn
 
 			if err := metricSet.MarshalMetrics(row); err != nil {
 				log.Error("Failed to populate index entity with metrics: %s", err.Error())
+				recordCollectorError(con.Context(), err)
 			}
 
 		}
@@ -500,8 +887,8 @@ func PopulatePgBouncerMetrics(pgIntegration *integration.Integration, con *conne
 		dataModels := definition.GetDataModels()
 		// Use QueryUnsafe to support different PgBouncer versions with varying column sets
 		if err := con.QueryUnsafe(dataModels, definition.GetQuery()); err != nil {
-			log.Error("Could not execute index query: %s", err.Error())
-			return
+			log.Error("Could not execute pgbouncer query %s: %s", definition.GetQuery(), err.Error())
+			continue
 		}
 
 		// for each row in the response
@@ -514,26 +901,385 @@ func PopulatePgBouncerMetrics(pgIntegration *integration.Integration, con *conne
 				continue
 			}
 
-			host, port := ci.HostPort()
-			hostIDAttribute := integration.NewIDAttribute("host", host)
-			portIDAttribute := integration.NewIDAttribute("port", port)
-			pgEntity, err := pgIntegration.Entity(name, "pgbouncer", hostIDAttribute, portIDAttribute)
+			metricSet, err := newPgBouncerMetricSet(pgIntegration, ci, name)
 			if err != nil {
 				log.Error("Failed to get database entity for name %s: %s", name, err.Error())
+				continue
 			}
-			metricSet := pgEntity.NewMetricSet("PgBouncerSample",
-				attribute.Attribute{Key: "displayName", Value: name},
-				attribute.Attribute{Key: "entityName", Value: "pgbouncer:" + name},
-				attribute.Attribute{Key: "host", Value: host},
-			)
 
 			if err := metricSet.MarshalMetrics(db); err != nil {
 				log.Error("Failed to populate pgbouncer entity with metrics: %s", err.Error())
 			}
+			if pool, ok := db.(pgbouncerPoolDataModel); ok {
+				if maxWait, ok := pool.maxWaitInMicroseconds(); ok {
+					if err := metricSet.SetMetric("pgbouncer.pools.maxwaitInMicroseconds", maxWait, metric.GAUGE); err != nil {
+						log.Error("Failed to set pgbouncer metric pgbouncer.pools.maxwaitInMicroseconds: %s", err.Error())
+					}
+				}
+			}
+		}
+	}
+
+	populatePgBouncerConnectionStates(pgbouncerServersDefinition, "pgbouncer.servers", pgbouncerServerStates, pgIntegration, con, ci)
+	populatePgBouncerConnectionStates(pgbouncerClientsDefinition, "pgbouncer.clients", pgbouncerClientStates, pgIntegration, con, ci)
+	populatePgBouncerMemMetrics(pgIntegration, con, ci)
+	populatePgBouncerListMetrics(pgIntegration, con, ci)
+}
+
+// newPgBouncerMetricSet creates a PgBouncerSample on the pgbouncer entity of the named database
+func newPgBouncerMetricSet(pgIntegration *integration.Integration, ci connection.Info, name string) (*metric.Set, error) {
+	host, port := ci.HostPort()
+	hostIDAttribute := integration.NewIDAttribute("host", host)
+	portIDAttribute := integration.NewIDAttribute("port", port)
+	pgEntity, err := pgIntegration.Entity(name, "pgbouncer", hostIDAttribute, portIDAttribute)
+	if err != nil {
+		return nil, err
+	}
+
+	return pgEntity.NewMetricSet("PgBouncerSample",
+		attribute.Attribute{Key: "displayName", Value: name},
+		attribute.Attribute{Key: "entityName", Value: "pgbouncer:" + name},
+		attribute.Attribute{Key: "host", Value: host},
+	), nil
+}
+
+// populatePgBouncerConnectionStates counts the connections listed by SHOW SERVERS or SHOW CLIENTS per database
+// and state, reporting them as prefix.<state> along with prefix.total
+func populatePgBouncerConnectionStates(definition *QueryDefinition, prefix string, knownStates []string, pgIntegration *integration.Integration, con *connection.PGSQLConnection, ci connection.Info) {
+	dataModels := definition.GetDataModels()
+	if err := con.QueryUnsafe(dataModels, definition.GetQuery()); err != nil {
+		log.Error("Could not execute pgbouncer query %s: %s", definition.GetQuery(), err.Error())
+		return
+	}
+
+	rows, ok := dataModels.(*[]pgbouncerConnectionDataModel)
+	if !ok {
+		log.Error("Unexpected data model for pgbouncer query %s", definition.GetQuery())
+		return
+	}
+
+	counts := make(map[string]map[string]int64)
+	for _, row := range *rows {
+		name, err := row.GetDatabaseName()
+		if err != nil || row.State == nil {
+			continue
+		}
+
+		databaseCounts, ok := counts[name]
+		if !ok {
+			databaseCounts = map[string]int64{prefix + ".total": 0}
+			for _, state := range knownStates {
+				databaseCounts[prefix+"."+snakeToCamelCase(state)] = 0
+			}
+			counts[name] = databaseCounts
+		}
+		databaseCounts[prefix+"."+snakeToCamelCase(*row.State)]++
+		databaseCounts[prefix+".total"]++
+	}
+
+	for name, databaseCounts := range counts {
+		metricSet, err := newPgBouncerMetricSet(pgIntegration, ci, name)
+		if err != nil {
+			log.Error("Failed to get database entity for name %s: %s", name, err.Error())
+			continue
+		}
+		for metricName, count := range databaseCounts {
+			if err := metricSet.SetMetric(metricName, count, metric.GAUGE); err != nil {
+				log.Error("Failed to set pgbouncer metric %s: %s", metricName, err.Error())
+			}
 		}
 	}
 }
 
+// populatePgBouncerMemMetrics reports one sample per PgBouncer memory cache. SHOW MEM describes the whole
+// process, so it is reported on the entity of the pgbouncer admin database.
+func populatePgBouncerMemMetrics(pgIntegration *integration.Integration, con *connection.PGSQLConnection, ci connection.Info) {
+	dataModels := pgbouncerMemDefinition.GetDataModels()
+	if err := con.QueryUnsafe(dataModels, pgbouncerMemDefinition.GetQuery()); err != nil {
+		log.Error("Could not execute pgbouncer query %s: %s", pgbouncerMemDefinition.GetQuery(), err.Error())
+		return
+	}
+
+	v := reflect.Indirect(reflect.ValueOf(dataModels))
+	for i := 0; i < v.Len(); i++ {
+		metricSet, err := newPgBouncerMetricSet(pgIntegration, ci, connection.PgBouncerAdminDatabase)
+		if err != nil {
+			log.Error("Failed to get database entity for name %s: %s", connection.PgBouncerAdminDatabase, err.Error())
+			return
+		}
+		if err := metricSet.MarshalMetrics(v.Index(i).Interface()); err != nil {
+			log.Error("Failed to populate pgbouncer entity with metrics: %s", err.Error())
+		}
+	}
+}
+
+// populatePgBouncerListMetrics reports the item count of each PgBouncer internal list as pgbouncer.lists.<list>
+// on the entity of the pgbouncer admin database
+func populatePgBouncerListMetrics(pgIntegration *integration.Integration, con *connection.PGSQLConnection, ci connection.Info) {
+	dataModels := pgbouncerListsDefinition.GetDataModels()
+	if err := con.QueryUnsafe(dataModels, pgbouncerListsDefinition.GetQuery()); err != nil {
+		log.Error("Could not execute pgbouncer query %s: %s", pgbouncerListsDefinition.GetQuery(), err.Error())
+		return
+	}
+
+	rows, ok := dataModels.(*[]pgbouncerListDataModel)
+	if !ok || len(*rows) == 0 {
+		return
+	}
+
+	metricSet, err := newPgBouncerMetricSet(pgIntegration, ci, connection.PgBouncerAdminDatabase)
+	if err != nil {
+		log.Error("Failed to get database entity for name %s: %s", connection.PgBouncerAdminDatabase, err.Error())
+		return
+	}
+	for _, row := range *rows {
+		if row.List == nil || row.Items == nil {
+			continue
+		}
+		metricName := "pgbouncer.lists." + snakeToCamelCase(*row.List)
+		if err := metricSet.SetMetric(metricName, *row.Items, metric.GAUGE); err != nil {
+			log.Error("Failed to set pgbouncer metric %s: %s", metricName, err.Error())
+		}
+	}
+}
+
+// snakeToCamelCase converts PgBouncer state and list names such as active_cancel_req to activeCancelReq
+func snakeToCamelCase(name string) string {
+	parts := strings.Split(name, "_")
+	for i := 1; i < len(parts); i++ {
+		if parts[i] != "" {
+			parts[i] = strings.ToUpper(parts[i][:1]) + parts[i][1:]
+		}
+	}
+	return strings.Join(parts, "")
+}
+
+// pgpoolInstanceEntityName is the pgpool entity carrying the metrics of the Pgpool-II instance as a whole
+const pgpoolInstanceEntityName = "pgpool"
+
+// PopulatePgpoolMetrics populates Pgpool-II metrics. Backend node metrics are reported on a pgpool entity per node,
+// and process metrics on the pgpool entity of the Pgpool-II instance.
+func PopulatePgpoolMetrics(pgIntegration *integration.Integration, con *connection.PGSQLConnection, ci connection.Info) {
+	nodeNames := populatePgpoolNodeMetrics(pgIntegration, con, ci)
+	populatePgpoolPoolMetrics(nodeNames, pgIntegration, con, ci)
+	populatePgpoolProcessMetrics(pgIntegration, con, ci)
+
+	dataModels := pgpoolHealthCheckDefinition.GetDataModels()
+	// Use QueryUnsafe to support different Pgpool-II versions with varying column sets
+	if err := con.QueryUnsafe(dataModels, pgpoolHealthCheckDefinition.GetQuery()); err != nil {
+		log.Error("Could not execute pgpool query %s: %s", pgpoolHealthCheckDefinition.GetQuery(), err.Error())
+		return
+	}
+
+	v := reflect.Indirect(reflect.ValueOf(dataModels))
+	for i := 0; i < v.Len(); i++ {
+		row := v.Index(i).Interface()
+		name, err := GetPgpoolNodeName(row)
+		if err != nil {
+			log.Error("Unable to get pgpool node name: %s", err.Error())
+			continue
+		}
+
+		metricSet, err := newPgpoolMetricSet(pgIntegration, ci, name)
+		if err != nil {
+			log.Error("Failed to get pgpool entity for name %s: %s", name, err.Error())
+			continue
+		}
+
+		if err := metricSet.MarshalMetrics(row); err != nil {
+			log.Error("Failed to populate pgpool entity with metrics: %s", err.Error())
+		}
+	}
+}
+
+// newPgpoolMetricSet creates a PgpoolSample on the named pgpool entity
+func newPgpoolMetricSet(pgIntegration *integration.Integration, ci connection.Info, name string) (*metric.Set, error) {
+	host, port := ci.HostPort()
+	hostIDAttribute := integration.NewIDAttribute("host", host)
+	portIDAttribute := integration.NewIDAttribute("port", port)
+	pgEntity, err := pgIntegration.Entity(name, "pgpool", hostIDAttribute, portIDAttribute)
+	if err != nil {
+		return nil, err
+	}
+
+	return pgEntity.NewMetricSet("PgpoolSample",
+		attribute.Attribute{Key: "displayName", Value: name},
+		attribute.Attribute{Key: "entityName", Value: "pgpool:" + name},
+		attribute.Attribute{Key: "host", Value: host},
+	), nil
+}
+
+// populatePgpoolNodeMetrics reports SHOW POOL_NODES and returns the node names keyed by node id
+func populatePgpoolNodeMetrics(pgIntegration *integration.Integration, con *connection.PGSQLConnection, ci connection.Info) map[string]string {
+	dataModels := pgpoolNodesDefinition.GetDataModels()
+	if err := con.QueryUnsafe(dataModels, pgpoolNodesDefinition.GetQuery()); err != nil {
+		log.Error("Could not execute pgpool query %s: %s", pgpoolNodesDefinition.GetQuery(), err.Error())
+		return nil
+	}
+
+	rows, ok := dataModels.(*[]pgpoolNodeDataModel)
+	if !ok {
+		log.Error("Unexpected data model for pgpool query %s", pgpoolNodesDefinition.GetQuery())
+		return nil
+	}
+
+	nodeNames := make(map[string]string, len(*rows))
+	for _, row := range *rows {
+		name, err := row.GetNodeName()
+		if err != nil {
+			log.Error("Unable to get pgpool node name: %s", err.Error())
+			continue
+		}
+		if row.NodeID != nil {
+			nodeNames[*row.NodeID] = name
+		}
+
+		metricSet, err := newPgpoolMetricSet(pgIntegration, ci, name)
+		if err != nil {
+			log.Error("Failed to get pgpool entity for name %s: %s", name, err.Error())
+			continue
+		}
+
+		if err := metricSet.MarshalMetrics(row); err != nil {
+			log.Error("Failed to populate pgpool entity with metrics: %s", err.Error())
+		}
+
+		if row.ReplicationDelay == nil {
+			continue
+		}
+		metricName, delay, err := parsePgpoolReplicationDelay(*row.ReplicationDelay)
+		if err != nil {
+			log.Error("Unable to parse replication delay of pgpool node %s: %s", name, err.Error())
+			continue
+		}
+		if err := metricSet.SetMetric(metricName, delay, metric.GAUGE); err != nil {
+			log.Error("Failed to set pgpool metric %s: %s", metricName, err.Error())
+		}
+	}
+
+	return nodeNames
+}
+
+// parsePgpoolReplicationDelay returns the metric name and value of a SHOW POOL_NODES replication_delay,
+// which is a byte count, or a number of seconds followed by "second" when delay_threshold_by_time is set
+func parsePgpoolReplicationDelay(value string) (string, float64, error) {
+	fields := strings.Fields(value)
+	if len(fields) == 0 {
+		return "", 0, errors.New("empty replication delay")
+	}
+
+	delay, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return "", 0, err
+	}
+	if len(fields) > 1 && strings.HasPrefix(fields[1], "second") {
+		return "pgpool.node.replicationDelayInSeconds", delay, nil
+	}
+	return "pgpool.node.replicationDelayInBytes", delay, nil
+}
+
+// populatePgpoolPoolMetrics counts the pooled backend connections of SHOW POOL_POOLS per backend node
+func populatePgpoolPoolMetrics(nodeNames map[string]string, pgIntegration *integration.Integration, con *connection.PGSQLConnection, ci connection.Info) {
+	if len(nodeNames) == 0 {
+		return
+	}
+
+	dataModels := pgpoolPoolsDefinition.GetDataModels()
+	if err := con.QueryUnsafe(dataModels, pgpoolPoolsDefinition.GetQuery()); err != nil {
+		log.Error("Could not execute pgpool query %s: %s", pgpoolPoolsDefinition.GetQuery(), err.Error())
+		return
+	}
+
+	rows, ok := dataModels.(*[]pgpoolPoolDataModel)
+	if !ok {
+		log.Error("Unexpected data model for pgpool query %s", pgpoolPoolsDefinition.GetQuery())
+		return
+	}
+
+	counts := make(map[string]int64, len(nodeNames))
+	for id := range nodeNames {
+		counts[id] = 0
+	}
+	for _, row := range *rows {
+		if row.BackendID == nil || row.PoolConnected == nil || *row.PoolConnected == 0 {
+			continue
+		}
+		if _, ok := counts[*row.BackendID]; ok {
+			counts[*row.BackendID]++
+		}
+	}
+
+	for id, count := range counts {
+		name := nodeNames[id]
+		metricSet, err := newPgpoolMetricSet(pgIntegration, ci, name)
+		if err != nil {
+			log.Error("Failed to get pgpool entity for name %s: %s", name, err.Error())
+			continue
+		}
+		if err := metricSet.SetMetric("pgpool.node.pooledConnections", count, metric.GAUGE); err != nil {
+			log.Error("Failed to set pgpool metric pgpool.node.pooledConnections: %s", err.Error())
+		}
+	}
+}
+
+// populatePgpoolProcessMetrics counts the Pgpool-II child processes of SHOW POOL_PROCESSES, in total, connected to
+// a database, and per status as pgpool.processes.<status> when the status column is available
+func populatePgpoolProcessMetrics(pgIntegration *integration.Integration, con *connection.PGSQLConnection, ci connection.Info) {
+	dataModels := pgpoolProcessesDefinition.GetDataModels()
+	if err := con.QueryUnsafe(dataModels, pgpoolProcessesDefinition.GetQuery()); err != nil {
+		log.Error("Could not execute pgpool query %s: %s", pgpoolProcessesDefinition.GetQuery(), err.Error())
+		return
+	}
+
+	rows, ok := dataModels.(*[]pgpoolProcessDataModel)
+	if !ok || len(*rows) == 0 {
+		return
+	}
+
+	// Processes may be listed once per pool slot, so they are counted by pid
+	processes := make(map[string]struct{})
+	connected := make(map[string]struct{})
+	counts := make(map[string]int64)
+	for _, row := range *rows {
+		if row.PoolPid == nil {
+			continue
+		}
+		if _, seen := processes[*row.PoolPid]; seen {
+			continue
+		}
+		processes[*row.PoolPid] = struct{}{}
+		if row.Database != nil && *row.Database != "" {
+			connected[*row.PoolPid] = struct{}{}
+		}
+		if row.Status != nil {
+			if len(counts) == 0 {
+				for _, state := range pgpoolProcessStates {
+					counts[pgpoolProcessStateMetric(state)] = 0
+				}
+			}
+			counts[pgpoolProcessStateMetric(*row.Status)]++
+		}
+	}
+	counts["pgpool.processes.total"] = int64(len(processes))
+	counts["pgpool.processes.connected"] = int64(len(connected))
+
+	metricSet, err := newPgpoolMetricSet(pgIntegration, ci, pgpoolInstanceEntityName)
+	if err != nil {
+		log.Error("Failed to get pgpool entity for name %s: %s", pgpoolInstanceEntityName, err.Error())
+		return
+	}
+	for metricName, count := range counts {
+		if err := metricSet.SetMetric(metricName, count, metric.GAUGE); err != nil {
+			log.Error("Failed to set pgpool metric %s: %s", metricName, err.Error())
+		}
+	}
+}
+
+// pgpoolProcessStateMetric converts a Pgpool-II process status such as "Idle in transaction" to pgpool.processes.idleInTransaction
+func pgpoolProcessStateMetric(state string) string {
+	return "pgpool.processes." + snakeToCamelCase(strings.ToLower(strings.Join(strings.Fields(state), "_")))
+}
+
 // PopulateCustomMetrics collects metrics from a custom query
 func PopulateCustomMetrics(customMetricsQuery string, pgIntegration *integration.Integration, con *connection.PGSQLConnection, ci connection.Info, instance *integration.Entity) {
 	rows, err := con.Queryx(customMetricsQuery)
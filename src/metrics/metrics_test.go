@@ -1,16 +1,22 @@
 package metrics
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/blang/semver/v4"
+	"github.com/lib/pq"
 	"github.com/newrelic/infra-integrations-sdk/v3/integration"
 	"github.com/newrelic/nri-postgresql/src/collection"
 	"github.com/newrelic/nri-postgresql/src/connection"
 	"github.com/stretchr/testify/assert"
 	tmock "github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 	"gopkg.in/DATA-DOG/go-sqlmock.v1"
 )
 
@@ -34,7 +40,7 @@ func TestPopulateInstanceMetrics(t *testing.T) {
 	mock.ExpectQuery(".*scheduled_checkpoints_performed.*").
 		WillReturnRows(instanceRows)
 
-	PopulateInstanceMetrics(testEntity, &version, testConnection)
+	PopulateInstanceMetrics(testEntity, &version, testConnection, nil)
 
 	expected := map[string]interface{}{
 		"bgwriter.checkpointsScheduledPerSecond":             float64(0),
@@ -52,6 +58,56 @@ func TestPopulateInstanceMetrics(t *testing.T) {
 	assert.Equal(t, expected, testEntity.Metrics[0].Metrics)
 }
 
+func TestPopulateInstanceMetrics_WalAndArchiver(t *testing.T) {
+	testIntegration, _ := integration.New("test", "test")
+	testEntity, _ := testIntegration.Entity("testInstance", "instance")
+
+	version := semver.MustParse("14.0.0")
+
+	testConnection, mock := connection.CreateMockSQL(t)
+	mock.ExpectQuery(".*scheduled_checkpoints_performed.*").
+		WillReturnRows(sqlmock.NewRows([]string{"buffers_allocated"}).AddRow(7))
+	mock.ExpectQuery(".*times_backend_executed_own_fsync.*").
+		WillReturnRows(sqlmock.NewRows([]string{"times_backend_executed_own_fsync"}))
+	mock.ExpectQuery(".*time_writing_checkpoint_files_to_disk.*").
+		WillReturnRows(sqlmock.NewRows([]string{"time_writing_checkpoint_files_to_disk"}))
+	mock.ExpectQuery(".*WAL_STATS.*").
+		WillReturnRows(sqlmock.NewRows([]string{"wal_records", "wal_bytes", "wal_write_time"}).AddRow(10, 4096, 1.5))
+	mock.ExpectQuery(".*ARCHIVER_STATS.*").
+		WillReturnRows(sqlmock.NewRows([]string{
+			"archived_count",
+			"failed_count",
+			"seconds_since_last_archive",
+			"seconds_since_last_failure",
+			"last_archived_wal",
+			"last_failed_wal",
+		}).AddRow(5, 2, 3600.0, 30.0, "000000010000000000000003", "000000010000000000000004"))
+	mock.ExpectQuery(".*WAL_LSN.*pg_wal_lsn_diff.*").
+		WillReturnRows(sqlmock.NewRows([]string{"wal_lsn_bytes"}).AddRow(123456))
+
+	PopulateInstanceMetrics(testEntity, &version, testConnection, nil)
+
+	expected := map[string]interface{}{
+		"bgwriter.buffersAllocatedPerSecond":   float64(0),
+		"wal.recordsPerSecond":                 float64(0),
+		"wal.bytesPerSecond":                   float64(0),
+		"wal.writeTimeInMillisecondsPerSecond": float64(0),
+		"archiver.archivedPerSecond":           float64(0),
+		"archiver.failedPerSecond":             float64(0),
+		"archiver.secondsSinceLastArchive":     float64(3600),
+		"archiver.secondsSinceLastFailure":     float64(30),
+		"archiver.lastArchivedWal":             "000000010000000000000003",
+		"archiver.lastFailedWal":               "000000010000000000000004",
+		"wal.lsnInBytesPerSecond":              float64(0),
+		"displayName":                          "testInstance",
+		"entityName":                           "instance:testInstance",
+		"event_type":                           "PostgresqlInstanceSample",
+	}
+
+	assert.Equal(t, expected, testEntity.Metrics[0].Metrics)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
 func TestPopulateInstanceMetrics_NoRows(t *testing.T) {
 	testIntegration, _ := integration.New("test", "test")
 	testEntity, _ := testIntegration.Entity("testInstance", "instance")
@@ -72,7 +128,7 @@ func TestPopulateInstanceMetrics_NoRows(t *testing.T) {
 	mock.ExpectQuery(".*scheduled_checkpoints_performed.*").
 		WillReturnRows(instanceRows)
 
-	PopulateInstanceMetrics(testEntity, &version, testConnection)
+	PopulateInstanceMetrics(testEntity, &version, testConnection, nil)
 
 	expected := map[string]interface{}{
 		"displayName": "testInstance",
@@ -83,6 +139,261 @@ func TestPopulateInstanceMetrics_NoRows(t *testing.T) {
 	assert.Equal(t, expected, testEntity.Metrics[0].Metrics)
 }
 
+func TestPopulateIOMetrics(t *testing.T) {
+	testIntegration, _ := integration.New("test", "test")
+	testEntity, _ := testIntegration.Entity("testInstance", "pg-instance")
+
+	version := semver.MustParse("16.1.0")
+
+	testConnection, mock := connection.CreateMockSQL(t)
+	ioRows := sqlmock.NewRows([]string{
+		"backend_type",
+		"object",
+		"context",
+		"reads",
+		"read_bytes",
+		"read_time",
+		"writes",
+		"write_bytes",
+		"write_time",
+		"writebacks",
+		"writeback_time",
+		"extends",
+		"extend_bytes",
+		"extend_time",
+		"hits",
+		"evictions",
+		"reuses",
+		"fsyncs",
+		"fsync_time",
+	}).AddRow("autovacuum worker", "relation", "vacuum", 10, 81920, 1.5, 2, 16384, 0.5, 0, 0.0, 1, 8192, 0.1, 30, 0, 4, nil, nil).
+		AddRow("client backend", "relation", "normal", 5, 40960, 0.2, 1, 8192, 0.1, 0, 0.0, 0, 0, 0.0, 100, 3, nil, 1, 0.3)
+	mock.ExpectQuery(".*IO_STATS.*op_bytes.*").WillReturnRows(ioRows)
+
+	PopulateIOMetrics(testEntity, &version, testConnection)
+
+	assert.Len(t, testEntity.Metrics, 2)
+	expected := map[string]interface{}{
+		"io.readsPerSecond":                       float64(0),
+		"io.readBytesPerSecond":                   float64(0),
+		"io.readTimeInMillisecondsPerSecond":      float64(0),
+		"io.writesPerSecond":                      float64(0),
+		"io.writeBytesPerSecond":                  float64(0),
+		"io.writeTimeInMillisecondsPerSecond":     float64(0),
+		"io.writebacksPerSecond":                  float64(0),
+		"io.writebackTimeInMillisecondsPerSecond": float64(0),
+		"io.extendsPerSecond":                     float64(0),
+		"io.extendBytesPerSecond":                 float64(0),
+		"io.extendTimeInMillisecondsPerSecond":    float64(0),
+		"io.hitsPerSecond":                        float64(0),
+		"io.evictionsPerSecond":                   float64(0),
+		"io.reusesPerSecond":                      float64(0),
+		"backendType":                             "autovacuum worker",
+		"object":                                  "relation",
+		"context":                                 "vacuum",
+		"displayName":                             "testInstance",
+		"entityName":                              "pg-instance:testInstance",
+		"event_type":                              "PostgresqlIoSample",
+	}
+	assert.Equal(t, expected, testEntity.Metrics[0].Metrics)
+	assert.Equal(t, "client backend", testEntity.Metrics[1].Metrics["backendType"])
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPopulateReplicationMetrics(t *testing.T) {
+	testIntegration, _ := integration.New("test", "test")
+	testEntity, _ := testIntegration.Entity("testInstance", "pg-instance")
+
+	version := semver.MustParse("10.0.0")
+
+	testConnection, mock := connection.CreateMockSQL(t)
+	primaryRows := sqlmock.NewRows([]string{
+		"standby_name",
+		"application_name",
+		"client_address",
+		"state",
+		"sync_state",
+		"sent_lag_bytes",
+		"write_lag_bytes",
+		"flush_lag_bytes",
+		"replay_lag_bytes",
+		"write_lag_seconds",
+		"flush_lag_seconds",
+		"replay_lag_seconds",
+	}).AddRow("walreceiver@10.0.0.2:40612", "walreceiver", "10.0.0.2", "streaming", "async", 1, 2, 3, 4, 0.5, 0.6, 0.7).
+		AddRow("walreceiver@10.0.0.3:51220", "walreceiver", "10.0.0.3", "streaming", "async", 5, 6, 7, 8, 0.8, 0.9, 1.0)
+	mock.ExpectQuery(".*REPLICATION_PRIMARY.*").WillReturnRows(primaryRows)
+
+	standbyRows := sqlmock.NewRows([]string{"receiver_status", "slot_name", "replay_lag_bytes", "replay_lag_seconds", "last_message_lag_seconds"})
+	mock.ExpectQuery(".*REPLICATION_STANDBY.*").WillReturnRows(standbyRows)
+
+	ci := &connection.MockInfo{}
+	PopulateReplicationMetrics(testEntity, &version, testIntegration, testConnection, ci)
+
+	expected := map[string]interface{}{
+		"replication.applicationName":    "walreceiver",
+		"replication.clientAddress":      "10.0.0.2",
+		"replication.state":              "streaming",
+		"replication.syncState":          "async",
+		"replication.sentLagInBytes":     float64(1),
+		"replication.writeLagInBytes":    float64(2),
+		"replication.flushLagInBytes":    float64(3),
+		"replication.replayLagInBytes":   float64(4),
+		"replication.writeLagInSeconds":  float64(0.5),
+		"replication.flushLagInSeconds":  float64(0.6),
+		"replication.replayLagInSeconds": float64(0.7),
+		"displayName":                    "walreceiver@10.0.0.2:40612",
+		"entityName":                     "replication:walreceiver@10.0.0.2:40612",
+		"instance":                       "testInstance",
+		"reportedBy":                     "primary",
+		"event_type":                     "PostgresqlReplicationSample",
+	}
+
+	standbyEntity, err := testIntegration.Entity("walreceiver@10.0.0.2:40612", "pg-replication", integration.NewIDAttribute("host", "testhost"), integration.NewIDAttribute("port", "1234"))
+	assert.Nil(t, err)
+	assert.Equal(t, expected, standbyEntity.Metrics[0].Metrics)
+
+	// standbys sharing their application_name are reported on their own entities
+	otherEntity, err := testIntegration.Entity("walreceiver@10.0.0.3:51220", "pg-replication", integration.NewIDAttribute("host", "testhost"), integration.NewIDAttribute("port", "1234"))
+	assert.Nil(t, err)
+	assert.Len(t, standbyEntity.Metrics, 1)
+	assert.Len(t, otherEntity.Metrics, 1)
+	assert.Equal(t, float64(8), otherEntity.Metrics[0].Metrics["replication.replayLagInBytes"])
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPopulateReplicationMetricsCascadingStandby(t *testing.T) {
+	testIntegration, _ := integration.New("test", "test")
+	testEntity, _ := testIntegration.Entity("testInstance", "pg-instance")
+
+	version := semver.MustParse("16.0.0")
+
+	// a cascading standby reports both its downstream standbys and its own WAL receiver
+	testConnection, mock := connection.CreateMockSQL(t)
+	primaryRows := sqlmock.NewRows([]string{
+		"standby_name", "application_name", "client_address", "state", "sync_state", "sent_lag_bytes", "write_lag_bytes", "flush_lag_bytes",
+		"replay_lag_bytes", "write_lag_seconds", "flush_lag_seconds", "replay_lag_seconds",
+	}).AddRow("downstream1", "downstream1", "10.0.0.3", "streaming", "async", 0, 8, 16, 32, 0.1, 0.2, 0.3)
+	mock.ExpectQuery(`.*REPLICATION_PRIMARY.*CASE WHEN pg_is_in_recovery\(\) THEN pg_last_wal_receive_lsn\(\) ELSE pg_current_wal_lsn\(\) END.*`).
+		WillReturnRows(primaryRows)
+	standbyRows := sqlmock.NewRows([]string{"receiver_status", "slot_name", "replay_lag_bytes", "replay_lag_seconds", "last_message_lag_seconds"}).
+		AddRow("streaming", "cascade_slot", 64, 1.5, 0.05)
+	mock.ExpectQuery(".*REPLICATION_STANDBY.*").WillReturnRows(standbyRows)
+
+	ci := &connection.MockInfo{}
+	PopulateReplicationMetrics(testEntity, &version, testIntegration, testConnection, ci)
+
+	downstreamEntity, err := testIntegration.Entity("downstream1", "pg-replication", integration.NewIDAttribute("host", "testhost"), integration.NewIDAttribute("port", "1234"))
+	assert.Nil(t, err)
+	assert.Equal(t, float64(32), downstreamEntity.Metrics[0].Metrics["replication.replayLagInBytes"])
+	assert.Equal(t, "primary", downstreamEntity.Metrics[0].Metrics["reportedBy"])
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPopulateReplicationSlotMetrics(t *testing.T) {
+	testIntegration, _ := integration.New("test", "test")
+	testEntity, _ := testIntegration.Entity("testInstance", "pg-instance")
+
+	version := semver.MustParse("13.0.0")
+
+	testConnection, mock := connection.CreateMockSQL(t)
+	slotRows := sqlmock.NewRows([]string{
+		"slot_name",
+		"slot_type",
+		"plugin",
+		"database",
+		"active",
+		"retained_wal_bytes",
+		"wal_status",
+		"safe_wal_size",
+	}).AddRow("slot1", "logical", "pgoutput", "db1", 0, 2048, "extended", nil)
+	mock.ExpectQuery(".*REPLICATION_SLOTS.*").WillReturnRows(slotRows)
+
+	PopulateReplicationSlotMetrics(testEntity, &version, testConnection)
+
+	expected := map[string]interface{}{
+		"replicationSlot.slotType":           "logical",
+		"replicationSlot.plugin":             "pgoutput",
+		"replicationSlot.database":           "db1",
+		"replicationSlot.active":             float64(0),
+		"replicationSlot.retainedWalInBytes": float64(2048),
+		"replicationSlot.walStatus":          "extended",
+		"slotName":                           "slot1",
+		"displayName":                        "testInstance",
+		"entityName":                         "pg-instance:testInstance",
+		"event_type":                         "PostgresqlReplicationSlotSample",
+	}
+
+	assert.Equal(t, expected, testEntity.Metrics[0].Metrics)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPopulateReplicationSlotMetrics_Stats(t *testing.T) {
+	testIntegration, _ := integration.New("test", "test")
+	testEntity, _ := testIntegration.Entity("testInstance", "pg-instance")
+
+	version := semver.MustParse("16.1.0")
+
+	testConnection, mock := connection.CreateMockSQL(t)
+	slotRows := sqlmock.NewRows([]string{
+		"slot_name", "slot_type", "plugin", "database", "active", "retained_wal_bytes", "wal_status", "safe_wal_size",
+		"spill_txns", "spill_count", "spill_bytes", "stream_txns", "stream_count", "stream_bytes", "total_txns", "total_bytes",
+	}).
+		AddRow("slot1", "logical", "pgoutput", "db1", 1, 2048, "reserved", nil, 1, 2, 4096, 0, 0, 0, 10, 8192).
+		AddRow("standby1", "physical", nil, nil, 1, 1024, "reserved", nil, nil, nil, nil, nil, nil, nil, nil, nil)
+	mock.ExpectQuery(".*REPLICATION_SLOTS.*LEFT JOIN pg_stat_replication_slots.*").WillReturnRows(slotRows)
+
+	PopulateReplicationSlotMetrics(testEntity, &version, testConnection)
+
+	// the statistics of a slot are in the sample of the slot
+	require.Len(t, testEntity.Metrics, 2)
+	assert.Equal(t, "slot1", testEntity.Metrics[0].Metrics["slotName"])
+	assert.Equal(t, float64(2048), testEntity.Metrics[0].Metrics["replicationSlot.retainedWalInBytes"])
+	assert.Contains(t, testEntity.Metrics[0].Metrics, "replicationSlot.totalBytesPerSecond")
+	assert.Equal(t, "standby1", testEntity.Metrics[1].Metrics["slotName"])
+	assert.NotContains(t, testEntity.Metrics[1].Metrics, "replicationSlot.totalBytesPerSecond")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPopulateTablespaceMetrics(t *testing.T) {
+	testIntegration, _ := integration.New("test", "test")
+
+	version := semver.MustParse("12.0.0")
+
+	testConnection, mock := connection.CreateMockSQL(t)
+	tablespaceRows := sqlmock.NewRows([]string{
+		"tablespace_name",
+		"location",
+		"owner",
+		"size_in_bytes",
+		"database_count",
+	}).AddRow("fast_ssd", "/mnt/ssd/pg", "postgres", 4096, 2).
+		AddRow("pg_global", "", "postgres", nil, 0)
+	mock.ExpectQuery(".*TABLESPACES.*").WillReturnRows(tablespaceRows)
+
+	ci := &connection.MockInfo{}
+	PopulateTablespaceMetrics(&version, testIntegration, testConnection, ci)
+
+	expected := map[string]interface{}{
+		"tablespace.location":    "/mnt/ssd/pg",
+		"tablespace.owner":       "postgres",
+		"tablespace.sizeInBytes": float64(4096),
+		"tablespace.databases":   float64(2),
+		"displayName":            "fast_ssd",
+		"entityName":             "tablespace:fast_ssd",
+		"event_type":             "PostgresqlTablespaceSample",
+	}
+
+	tablespaceEntity, err := testIntegration.Entity("fast_ssd", "pg-tablespace", integration.NewIDAttribute("host", "testhost"), integration.NewIDAttribute("port", "1234"))
+	assert.Nil(t, err)
+	assert.Equal(t, expected, tablespaceEntity.Metrics[0].Metrics)
+
+	globalEntity, err := testIntegration.Entity("pg_global", "pg-tablespace", integration.NewIDAttribute("host", "testhost"), integration.NewIDAttribute("port", "1234"))
+	assert.Nil(t, err)
+	assert.NotContains(t, globalEntity.Metrics[0].Metrics, "tablespace.sizeInBytes")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
 func TestPopulateDatabaseMetrics(t *testing.T) {
 	testIntegration, _ := integration.New("test", "test")
 
@@ -102,13 +413,15 @@ func TestPopulateDatabaseMetrics(t *testing.T) {
 		"rows_inserted",
 		"rows_updated",
 		"rows_deleted",
-	}).AddRow("testDB", 1, 2, 3, 4, 5, 6, 7, 8, 9, 10)
+		"database_size",
+		"tablespace",
+	}).AddRow("testDB", 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 8192, "pg_default")
 
 	mock.ExpectQuery(".*UNDER91.*").
 		WillReturnRows(databaseRows)
 
 	ci := &connection.MockInfo{}
-	PopulateDatabaseMetrics(dbList, &version, testIntegration, testConnection, ci)
+	PopulateDatabaseMetrics(dbList, &version, testIntegration, testConnection, ci, nil)
 
 	expected := map[string]interface{}{
 
@@ -122,6 +435,8 @@ func TestPopulateDatabaseMetrics(t *testing.T) {
 		"db.rowsInsertedPerSecond": float64(0),
 		"db.rowsReturnedPerSecond": float64(0),
 		"db.rowsUpdatedPerSecond":  float64(0),
+		"db.sizeInBytes":           float64(8192),
+		"db.tablespace":            "pg_default",
 		"displayName":              "testDB",
 		"entityName":               "database:testDB",
 		"event_type":               "PostgresqlDatabaseSample",
@@ -132,65 +447,114 @@ func TestPopulateDatabaseMetrics(t *testing.T) {
 	assert.Equal(t, expected, dbEntity.Metrics[0].Metrics)
 }
 
-func TestPopulateDatabaseLockMetrics_WithTablefuncExtension(t *testing.T) {
+func TestPopulateConnectionBreakdownMetrics(t *testing.T) {
 	testIntegration, _ := integration.New("test", "test")
 
-	version := semver.MustParse("9.0.0")
+	version := semver.MustParse("12.0.0")
 	dbList := collection.DatabaseList{"test1": {}}
 
 	testConnection, mock := connection.CreateMockSQL(t)
+	breakdownRows := sqlmock.NewRows([]string{
+		"database",
+		"user_name",
+		"application_name",
+		"active",
+		"idle",
+		"idle_in_transaction",
+		"total",
+	}).AddRow("test1", "app_user", "billing", 2, 3, 1, 6)
+
+	mock.ExpectQuery(`.*CONNECTION_BREAKDOWN.*rank <= \$2.*`).
+		WithArgs(pq.Array([]string{"test1"}), 10).
+		WillReturnRows(breakdownRows)
+
+	ci := &connection.MockInfo{}
+	PopulateConnectionBreakdownMetrics(dbList, &version, 10, testIntegration, testConnection, ci)
+
+	expected := map[string]interface{}{
+		"db.connections.active":            float64(2),
+		"db.connections.idle":              float64(3),
+		"db.connections.idleInTransaction": float64(1),
+		"db.connections.total":             float64(6),
+		"user":                             "app_user",
+		"applicationName":                  "billing",
+		"displayName":                      "test1",
+		"entityName":                       "database:test1",
+		"event_type":                       "PostgresqlConnectionSample",
+	}
+
+	dbEntity, err := testIntegration.Entity("test1", "pg-database", integration.NewIDAttribute("host", "testhost"), integration.NewIDAttribute("port", "1234"))
+	assert.Nil(t, err)
+	assert.Equal(t, expected, dbEntity.Metrics[0].Metrics)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPopulateDatabaseLockMetrics(t *testing.T) {
+	testIntegration, _ := integration.New("test", "test")
+
+	version := semver.MustParse("9.0.0")
+	dbList := collection.DatabaseList{"test1": {}}
 
-	extensionRows := sqlmock.NewRows([]string{
-		"schema",
-		"extension",
-	}).AddRow("public", "tablefunc")
-	mock.ExpectQuery(".*EXTENSIONS_LIST.*").WillReturnRows(extensionRows)
+	testConnection, mock := connection.CreateMockSQL(t)
 
 	lockRows := sqlmock.NewRows([]string{
 		"database",
-		"access_exclusive_lock",
-		"access_share_lock",
-		"exclusive_lock",
-		"row_exclusive_lock",
-		"row_share_lock",
-		"share_lock",
-		"share_row_exclusive_lock",
-		"share_update_exclusive_lock",
-	}).AddRow("testDB", 1, 2, 3, 4, 5, 6, 7, 8)
+		"locktype",
+		"mode",
+		"granted",
+		"lock_count",
+	}).AddRow("testDB", "relation", "AccessExclusiveLock", true, 1).
+		AddRow("testDB", "relation", "AccessExclusiveLock", false, 2).
+		AddRow("testDB", "relation", "AccessShareLock", true, 3).
+		AddRow("testDB", "relation", "RowExclusiveLock", true, 4).
+		AddRow("testDB", "advisory", "ExclusiveLock", true, 5).
+		AddRow("testDB", "extend", "ExclusiveLock", false, 6)
 	mock.ExpectQuery(".*LOCKS_DEFINITION.*").WillReturnRows(lockRows)
 
 	ci := &connection.MockInfo{}
 	PopulateDatabaseLockMetrics(dbList, &version, testIntegration, testConnection, ci)
 
 	expected := map[string]interface{}{
-		"db.locks.accessExclusiveLock":      float64(1),
-		"db.locks.accessShareLock":          float64(2),
-		"db.locks.exclusiveLock":            float64(3),
-		"db.locks.rowExclusiveLock":         float64(4),
-		"db.locks.rowShareLock":             float64(5),
-		"db.locks.shareLock":                float64(6),
-		"db.locks.shareRowExclusiveLock":    float64(7),
-		"db.locks.shareUpdateExclusiveLock": float64(8),
-		"displayName":                       "testDB",
-		"entityName":                        "database:testDB",
-		"event_type":                        "PostgresqlDatabaseSample",
+		"db.locks.accessExclusiveLock":             float64(3),
+		"db.locks.accessExclusiveLockWaiting":      float64(2),
+		"db.locks.accessShareLock":                 float64(3),
+		"db.locks.accessShareLockWaiting":          float64(0),
+		"db.locks.exclusiveLock":                   float64(11),
+		"db.locks.exclusiveLockWaiting":            float64(6),
+		"db.locks.rowExclusiveLock":                float64(4),
+		"db.locks.rowExclusiveLockWaiting":         float64(0),
+		"db.locks.rowShareLock":                    float64(0),
+		"db.locks.rowShareLockWaiting":             float64(0),
+		"db.locks.shareLock":                       float64(0),
+		"db.locks.shareLockWaiting":                float64(0),
+		"db.locks.shareRowExclusiveLock":           float64(0),
+		"db.locks.shareRowExclusiveLockWaiting":    float64(0),
+		"db.locks.shareUpdateExclusiveLock":        float64(0),
+		"db.locks.shareUpdateExclusiveLockWaiting": float64(0),
+		"db.locks.advisory":                        float64(5),
+		"db.locks.relationExtension":               float64(6),
+		"db.locks.waiting":                         float64(8),
+		"displayName":                              "testDB",
+		"entityName":                               "database:testDB",
+		"event_type":                               "PostgresqlDatabaseSample",
 	}
 
 	dbEntity, err := testIntegration.Entity("testDB", "pg-database", integration.NewIDAttribute("host", "testhost"), integration.NewIDAttribute("port", "1234"))
 
 	assert.Nil(t, err)
 	assert.Equal(t, expected, dbEntity.Metrics[0].Metrics)
+	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
-func TestPopulateDatabaseLockMetrics_WithoutTablefuncExtension(t *testing.T) {
+func TestPopulateDatabaseLockMetrics_NoLocks(t *testing.T) {
 	testIntegration, _ := integration.New("test", "test")
 
 	version := semver.MustParse("9.0.0")
 	dbList := collection.DatabaseList{"test1": {}}
 
 	testConnection, mock := connection.CreateMockSQL(t)
-	extensionRows := sqlmock.NewRows([]string{"schema", "extension"})
-	mock.ExpectQuery(".*EXTENSIONS_LIST.*").WillReturnRows(extensionRows)
+	lockRows := sqlmock.NewRows([]string{"database", "locktype", "mode", "granted", "lock_count"})
+	mock.ExpectQuery(".*LOCKS_DEFINITION.*").WillReturnRows(lockRows)
 
 	ci := &connection.MockInfo{}
 	PopulateDatabaseLockMetrics(dbList, &version, testIntegration, testConnection, ci)
@@ -235,8 +599,90 @@ func Test_populateTableMetricsForDatabase(t *testing.T) {
 		"n_tup_del",
 		"n_live_tup",
 		"n_dead_tup",
-	}).AddRow("db1", "schema1", "table1", 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16, 17, 18, 19)
+		"xid_age",
+		"xid_percent_towards_freeze_max_age",
+		"xid_percent_towards_wraparound",
+	}).AddRow("db1", "schema1", "table1", 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16, 17, 18, 19, 100000000, 50.0, 4.66)
+
+	mock.ExpectQuery(`.*TABLEQUERY.*GREATEST\(age\(c.relfrozenxid\), age\(toast.relfrozenxid\)\).*ON toast.oid = c.reltoastrelid.*`).
+		WillReturnRows(tableRows)
+
+	multixactRows := sqlmock.NewRows([]string{
+		"database",
+		"schema_name",
+		"table_name",
+		"multixact_age",
+		"multixact_percent_towards_freeze_max_age",
+	}).AddRow("db1", "schema1", "table1", 20, 0.5)
 
+	mock.ExpectQuery(".*TABLEMULTIXACTQUERY.*").
+		WillReturnRows(multixactRows)
+
+	ci := &connection.MockInfo{}
+	version := semver.MustParse("12.0.0")
+	populateTableMetricsForDatabase(dbList["db1"], &version, testConnection, testIntegration, ci, nil)
+
+	expectedBase := map[string]interface{}{
+		"table.totalSizeInBytes":                         float64(1),
+		"table.indexSizeInBytes":                         float64(2),
+		"table.indexBlocksReadPerSecond":                 float64(0),
+		"table.indexBlocksHitPerSecond":                  float64(0),
+		"table.indexToastBlocksReadPerSecond":            float64(0),
+		"table.indexToastBlocksHitPerSecond":             float64(0),
+		"table.lastVacuum":                               float64(7),
+		"table.lastAutoVacuum":                           float64(8),
+		"table.lastAnalyze":                              float64(9),
+		"table.lastAutoAnalyze":                          float64(10),
+		"table.sequentialScansPerSecond":                 float64(0),
+		"table.sequentialScanRowsFetchedPerSecond":       float64(0),
+		"table.indexScansPerSecond":                      float64(0),
+		"table.indexScanRowsFetchedPerSecond":            float64(0),
+		"table.rowsInsertedPerSecond":                    float64(0),
+		"table.rowsUpdatedPerSecond":                     float64(0),
+		"table.rowsDeletedPerSecond":                     float64(0),
+		"table.liveRows":                                 float64(18),
+		"table.deadRows":                                 float64(19),
+		"table.wraparound.xidAge":                        float64(100000000),
+		"table.wraparound.xidPercentTowardsFreezeMaxAge": float64(50),
+		"table.wraparound.xidPercentTowardsWraparound":   float64(4.66),
+		"database":    "db1",
+		"schema":      "schema1",
+		"displayName": "table1",
+		"entityName":  "table:table1",
+		"event_type":  "PostgresqlTableSample",
+	}
+
+	expectedMultixact := map[string]interface{}{
+		"table.wraparound.multixactAge":                        float64(20),
+		"table.wraparound.multixactPercentTowardsFreezeMaxAge": float64(0.5),
+		"database":    "db1",
+		"schema":      "schema1",
+		"displayName": "table1",
+		"entityName":  "table:table1",
+		"event_type":  "PostgresqlTableSample",
+	}
+
+	id1 := integration.NewIDAttribute("pg-database", "db1")
+	id2 := integration.NewIDAttribute("pg-schema", "schema1")
+	id3 := integration.NewIDAttribute("host", "testhost")
+	id4 := integration.NewIDAttribute("port", "1234")
+	tableEntity, err := testIntegration.Entity("table1", "pg-table", id1, id2, id3, id4)
+	assert.Nil(t, err)
+	assert.Equal(t, expectedBase, tableEntity.Metrics[0].Metrics)
+	assert.Equal(t, expectedMultixact, tableEntity.Metrics[1].Metrics)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func Test_populateTableBloatMetricsForDatabase(t *testing.T) {
+	testIntegration, _ := integration.New("test", "test")
+
+	schemaList := collection.SchemaList{
+		"schema1": collection.TableList{
+			"table1": []string{},
+		},
+	}
+
+	testConnection, mock := connection.CreateMockSQL(t)
 	bloatRows := sqlmock.NewRows([]string{
 		"database",
 		"schema_name",
@@ -248,41 +694,12 @@ func Test_populateTableMetricsForDatabase(t *testing.T) {
 
 	mock.ExpectQuery(".*BLOATQUERY.*").
 		WillReturnRows(bloatRows)
-	mock.ExpectQuery(".*TABLEQUERY.*").
-		WillReturnRows(tableRows)
 
 	ci := &connection.MockInfo{}
 	version := semver.MustParse("12.0.0")
-	populateTableMetricsForDatabase(dbList["db1"], &version, testConnection, testIntegration, ci, true)
+	populateTableBloatMetricsForDatabase(schemaList, &version, testConnection, testIntegration, ci, nil)
 
-	expectedBase := map[string]interface{}{
-		"table.totalSizeInBytes":                   float64(1),
-		"table.indexSizeInBytes":                   float64(2),
-		"table.indexBlocksReadPerSecond":           float64(0),
-		"table.indexBlocksHitPerSecond":            float64(0),
-		"table.indexToastBlocksReadPerSecond":      float64(0),
-		"table.indexToastBlocksHitPerSecond":       float64(0),
-		"table.lastVacuum":                         float64(7),
-		"table.lastAutoVacuum":                     float64(8),
-		"table.lastAnalyze":                        float64(9),
-		"table.lastAutoAnalyze":                    float64(10),
-		"table.sequentialScansPerSecond":           float64(0),
-		"table.sequentialScanRowsFetchedPerSecond": float64(0),
-		"table.indexScansPerSecond":                float64(0),
-		"table.indexScanRowsFetchedPerSecond":      float64(0),
-		"table.rowsInsertedPerSecond":              float64(0),
-		"table.rowsUpdatedPerSecond":               float64(0),
-		"table.rowsDeletedPerSecond":               float64(0),
-		"table.liveRows":                           float64(18),
-		"table.deadRows":                           float64(19),
-		"database":                                 "db1",
-		"schema":                                   "schema1",
-		"displayName":                              "table1",
-		"entityName":                               "table:table1",
-		"event_type":                               "PostgresqlTableSample",
-	}
-
-	expectedBloat := map[string]interface{}{
+	expected := map[string]interface{}{
 		"table.bloatRatio":       float64(0.3),
 		"table.bloatSizeInBytes": float64(1.0),
 		"table.dataSizeInBytes":  float64(2.0),
@@ -299,8 +716,124 @@ func Test_populateTableMetricsForDatabase(t *testing.T) {
 	id4 := integration.NewIDAttribute("port", "1234")
 	tableEntity, err := testIntegration.Entity("table1", "pg-table", id1, id2, id3, id4)
 	assert.Nil(t, err)
-	assert.Equal(t, expectedBloat, tableEntity.Metrics[0].Metrics)
-	assert.Equal(t, expectedBase, tableEntity.Metrics[1].Metrics)
+	require.Len(t, tableEntity.Metrics, 1)
+	assert.Equal(t, expected, tableEntity.Metrics[0].Metrics)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func Test_populateTableProgressMetricsForDatabase(t *testing.T) {
+	testIntegration, _ := integration.New("test", "test")
+
+	schemaList := collection.SchemaList{
+		"schema1": collection.TableList{
+			"table1": []string{},
+		},
+	}
+
+	testConnection, mock := connection.CreateMockSQL(t)
+	vacuumRows := sqlmock.NewRows([]string{
+		"database",
+		"schema_name",
+		"table_name",
+		"command",
+		"phase",
+		"index_name",
+		"pid",
+		"is_autovacuum",
+		"blocks_total",
+		"blocks_done",
+		"percent_complete",
+		"duration_seconds",
+	}).AddRow("db1", "schema1", "table1", "VACUUM", "scanning heap", nil, "4242", 1, 200, 50, 25.0, 3600.5)
+
+	mock.ExpectQuery(".*VACUUMPROGRESSQUERY.*").
+		WillReturnRows(vacuumRows)
+
+	ci := &connection.MockInfo{}
+	version := semver.MustParse("9.6.0")
+	populateTableProgressMetricsForDatabase(schemaList, &version, testConnection, testIntegration, ci)
+
+	expected := map[string]interface{}{
+		"progress.command":           "VACUUM",
+		"progress.phase":             "scanning heap",
+		"progress.pid":               "4242",
+		"progress.isAutovacuum":      float64(1),
+		"progress.blocksTotal":       float64(200),
+		"progress.blocksDone":        float64(50),
+		"progress.percentComplete":   float64(25),
+		"progress.durationInSeconds": float64(3600.5),
+		"database":                   "db1",
+		"schema":                     "schema1",
+		"displayName":                "table1",
+		"entityName":                 "table:table1",
+		"event_type":                 "PostgresqlTableProgressSample",
+	}
+
+	id1 := integration.NewIDAttribute("pg-database", "db1")
+	id2 := integration.NewIDAttribute("pg-schema", "schema1")
+	id3 := integration.NewIDAttribute("host", "testhost")
+	id4 := integration.NewIDAttribute("port", "1234")
+	tableEntity, err := testIntegration.Entity("table1", "pg-table", id1, id2, id3, id4)
+	assert.Nil(t, err)
+	assert.Equal(t, expected, tableEntity.Metrics[0].Metrics)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func Test_populateTableProgressMetricsForDatabase_IndexPhases(t *testing.T) {
+	schemaList := collection.SchemaList{
+		"schema1": collection.TableList{
+			"table1": []string{},
+		},
+	}
+	columns := []string{
+		"database", "schema_name", "table_name", "command", "phase", "index_name", "pid", "is_autovacuum",
+		"blocks_total", "blocks_done", "indexes_total", "indexes_done", "percent_complete", "duration_seconds",
+	}
+	id1 := integration.NewIDAttribute("pg-database", "db1")
+	id2 := integration.NewIDAttribute("pg-schema", "schema1")
+	id3 := integration.NewIDAttribute("host", "testhost")
+	id4 := integration.NewIDAttribute("port", "1234")
+
+	// the index phases of a vacuum report the indexes processed from Postgres 17
+	testIntegration, _ := integration.New("test", "test")
+	testConnection, mock := connection.CreateMockSQL(t)
+	mock.ExpectQuery(`.*VACUUMPROGRESSQUERY.*p.indexes_processed as indexes_done.*WHEN p.phase = 'vacuuming heap' THEN 100.0 \* p.heap_blks_vacuumed.*`).
+		WillReturnRows(sqlmock.NewRows(columns).
+			AddRow("db1", "schema1", "table1", "VACUUM", "vacuuming indexes", nil, "4242", 1, 200, 200, 4, 1, 25.0, 7200.0))
+	mock.ExpectQuery(".*ANALYZEPROGRESSQUERY.*").WillReturnRows(sqlmock.NewRows(columns))
+	mock.ExpectQuery(".*CREATEINDEXPROGRESSQUERY.*").WillReturnRows(sqlmock.NewRows(columns))
+	mock.ExpectQuery(`.*CLUSTERPROGRESSQUERY.*WHEN 'rebuilding index' THEN.*p.index_rebuild_count / x.indexes.*`).
+		WillReturnRows(sqlmock.NewRows(columns))
+
+	version := semver.MustParse("17.0.0")
+	populateTableProgressMetricsForDatabase(schemaList, &version, testConnection, testIntegration, &connection.MockInfo{})
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	tableEntity, err := testIntegration.Entity("table1", "pg-table", id1, id2, id3, id4)
+	assert.Nil(t, err)
+	require.Len(t, tableEntity.Metrics, 1)
+	assert.Equal(t, "vacuuming indexes", tableEntity.Metrics[0].Metrics["progress.phase"])
+	assert.Equal(t, float64(4), tableEntity.Metrics[0].Metrics["progress.indexesTotal"])
+	assert.Equal(t, float64(1), tableEntity.Metrics[0].Metrics["progress.indexesDone"])
+	assert.Equal(t, float64(25), tableEntity.Metrics[0].Metrics["progress.percentComplete"])
+
+	// before Postgres 17 the index phases report no percent complete, rather than that of the heap scan
+	testIntegration, _ = integration.New("test", "test")
+	testConnection, mock = connection.CreateMockSQL(t)
+	mock.ExpectQuery(`.*VACUUMPROGRESSQUERY.*WHEN p.phase IN \('vacuuming indexes', 'cleaning up indexes'\) THEN NULL.*`).
+		WillReturnRows(sqlmock.NewRows(columns).
+			AddRow("db1", "schema1", "table1", "VACUUM", "vacuuming indexes", nil, "4242", 1, 200, 200, nil, nil, nil, 7200.0))
+
+	version = semver.MustParse("9.6.0")
+	populateTableProgressMetricsForDatabase(schemaList, &version, testConnection, testIntegration, &connection.MockInfo{})
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	tableEntity, err = testIntegration.Entity("table1", "pg-table", id1, id2, id3, id4)
+	assert.Nil(t, err)
+	require.Len(t, tableEntity.Metrics, 1)
+	assert.Equal(t, float64(200), tableEntity.Metrics[0].Metrics["progress.blocksDone"])
+	assert.NotContains(t, tableEntity.Metrics[0].Metrics, "progress.percentComplete")
+	assert.NotContains(t, tableEntity.Metrics[0].Metrics, "progress.indexesTotal")
 }
 
 func TestPopulateTableMetricsForDatabaseNoTables(t *testing.T) {
@@ -316,7 +849,7 @@ func TestPopulateTableMetricsForDatabaseNoTables(t *testing.T) {
 
 	ci := &connection.MockInfo{}
 	version := semver.MustParse("10.0.0")
-	populateTableMetricsForDatabase(dbList["db1"], &version, testConnection, testIntegration, ci, true)
+	populateTableMetricsForDatabase(dbList["db1"], &version, testConnection, testIntegration, ci, nil)
 
 	tableEntity, err := testIntegration.Entity("table1", "table")
 	assert.Nil(t, err)
@@ -324,6 +857,7 @@ func TestPopulateTableMetricsForDatabaseNoTables(t *testing.T) {
 }
 
 func TestPopulateIndexMetricsForDatabase(t *testing.T) {
+	version := semver.MustParse("10.0.0")
 	testIntegration, _ := integration.New("test", "test")
 
 	dbList := collection.DatabaseList{
@@ -369,8 +903,8 @@ func TestPopulateIndexMetricsForDatabase(t *testing.T) {
 		WillReturnRows(indexRows2)
 
 	ci := &connection.MockInfo{}
-	populateIndexMetricsForDatabase(dbList["db1"], testConnection, testIntegration, ci)
-	populateIndexMetricsForDatabase(dbList["db2"], testConnection, testIntegration, ci)
+	populateIndexMetricsForDatabase(dbList["db1"], &version, testConnection, testIntegration, ci, nil)
+	populateIndexMetricsForDatabase(dbList["db2"], &version, testConnection, testIntegration, ci, nil)
 
 	expected := map[string]interface{}{
 		"database":                   "db1",
@@ -413,7 +947,72 @@ func TestPopulateIndexMetricsForDatabase(t *testing.T) {
 	assert.Equal(t, expected2, indexEntity2.Metrics[0].Metrics)
 }
 
+func TestPopulateIndexMetrics_ConcurrentDatabases(t *testing.T) {
+	version := semver.MustParse("10.0.0")
+	testIntegration, _ := integration.New("test", "test")
+	ci := &connection.MockInfo{}
+
+	dbList := collection.DatabaseList{}
+	mocks := make(map[string]sqlmock.Sqlmock)
+	for i := 1; i <= 4; i++ {
+		dbName := fmt.Sprintf("db%d", i)
+		dbList[dbName] = collection.SchemaList{
+			"schema1": collection.TableList{
+				"table1": []string{"index1"},
+			},
+		}
+
+		testConnection, mock := connection.CreateMockSQL(t)
+		mock.ExpectQuery(".*INDEXQUERY.*").
+			WillReturnRows(sqlmock.NewRows([]string{"database", "schema_name", "table_name", "index_name", "index_size"}).
+				AddRow(dbName, "schema1", "table1", "index1", i))
+		mock.ExpectClose()
+		ci.On("NewConnection", dbName).Return(testConnection, nil)
+		mocks[dbName] = mock
+	}
+
+	PopulateIndexMetrics(context.Background(), dbList, &version, testIntegration, ci, 2, nil)
+
+	for i := 1; i <= 4; i++ {
+		dbName := fmt.Sprintf("db%d", i)
+		assert.NoError(t, mocks[dbName].ExpectationsWereMet(), dbName)
+
+		indexEntity, err := testIntegration.Entity("index1", "pg-index",
+			integration.NewIDAttribute("host", "testhost"),
+			integration.NewIDAttribute("port", "1234"),
+			integration.NewIDAttribute("pg-database", dbName),
+			integration.NewIDAttribute("pg-schema", "schema1"),
+			integration.NewIDAttribute("pg-table", "table1"),
+		)
+		assert.Nil(t, err)
+		assert.Len(t, indexEntity.Metrics, 1, dbName)
+		assert.Equal(t, float64(i), indexEntity.Metrics[0].Metrics["index.sizeInBytes"], dbName)
+	}
+}
+
+func TestPopulateIndexMetrics_DeadlineReached(t *testing.T) {
+	version := semver.MustParse("10.0.0")
+	testIntegration, _ := integration.New("test", "test")
+	ci := &connection.MockInfo{}
+
+	dbList := collection.DatabaseList{
+		"db1": collection.SchemaList{
+			"schema1": collection.TableList{
+				"table1": []string{"index1"},
+			},
+		},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	PopulateIndexMetrics(ctx, dbList, &version, testIntegration, ci, 2, nil)
+
+	ci.AssertNotCalled(t, "NewConnection", tmock.Anything)
+	assert.Empty(t, testIntegration.Entities)
+}
+
 func TestPopulateIndexMetricsForDatabaseNoIndexes(t *testing.T) {
+	version := semver.MustParse("10.0.0")
 	testIntegration, _ := integration.New("test", "test")
 
 	dbList := collection.DatabaseList{
@@ -427,7 +1026,7 @@ func TestPopulateIndexMetricsForDatabaseNoIndexes(t *testing.T) {
 	testConnection, _ := connection.CreateMockSQL(t)
 
 	ci := &connection.MockInfo{}
-	populateIndexMetricsForDatabase(dbList["db1"], testConnection, testIntegration, ci)
+	populateIndexMetricsForDatabase(dbList["db1"], &version, testConnection, testIntegration, ci, nil)
 
 	indexEntity, err := testIntegration.Entity("index1", "index")
 	assert.Nil(t, err)
@@ -470,6 +1069,8 @@ func TestPopulatePgBouncerMetrics(t *testing.T) {
 			"pgbouncer.stats.avgBytesIn":                                      float64(11),
 			"pgbouncer.stats.avgBytesOut":                                     float64(12),
 			"pgbouncer.stats.avgQueryDurationInMilliseconds":                  float64(13),
+			"pgbouncer.stats.totalWaitTimeInMicrosecondsPerSecond":            float64(0),
+			"pgbouncer.stats.avgWaitTimeInMicroseconds":                       float64(14),
 			"displayName": "testDB",
 			"entityName":  "pgbouncer:testDB",
 			"event_type":  "PgBouncerSample",
@@ -512,6 +1113,8 @@ func TestPopulatePgBouncerMetrics(t *testing.T) {
 				"pgbouncer.pools.serverConnectionsLogin":   float64(7),
 				"pgbouncer.pools.maxwaitInMilliseconds":    float64(8),
 				"pgbouncer.pools.user":                     "testUser",
+				"pgbouncer.pools.maxwaitInMicroseconds":    float64(8000009),
+				"pgbouncer.pools.poolMode":                 "testMode",
 				"displayName":                              "testDB",
 				"entityName":                               "pgbouncer:testDB",
 				"event_type":                               "PgBouncerSample",
@@ -552,6 +1155,8 @@ func TestPopulatePgBouncerMetrics(t *testing.T) {
 				"host":                                       "testhost",
 				"pgbouncer.pools.clientConnectionsCancelReq": float64(10),
 				"pgbouncer.pools.user":                       "testUser",
+				"pgbouncer.pools.maxwaitInMicroseconds":      float64(8000009),
+				"pgbouncer.pools.poolMode":                   "testMode",
 			},
 		},
 		{
@@ -594,6 +1199,8 @@ func TestPopulatePgBouncerMetrics(t *testing.T) {
 				"pgbouncer.pools.serverConnectionsActiveCancel":     float64(12),
 				"pgbouncer.pools.serverConnectionsBeingCancel":      float64(13),
 				"pgbouncer.pools.user":                              "testUser",
+				"pgbouncer.pools.maxwaitInMicroseconds":             float64(8000009),
+				"pgbouncer.pools.poolMode":                          "testMode",
 			},
 		},
 		{
@@ -653,6 +1260,8 @@ func TestPopulatePgBouncerMetrics(t *testing.T) {
 				"pgbouncer.pools.serverConnectionsActiveCancel":     float64(12),
 				"pgbouncer.pools.serverConnectionsBeingCancel":      float64(13),
 				"pgbouncer.pools.user":                              "testUser",
+				"pgbouncer.pools.maxwaitInMicroseconds":             float64(8000009),
+				"pgbouncer.pools.poolMode":                          "testMode",
 			},
 			expectedStats: map[string]interface{}{
 				"pgbouncer.stats.transactionsPerSecond":                           float64(0),
@@ -667,6 +1276,8 @@ func TestPopulatePgBouncerMetrics(t *testing.T) {
 				"pgbouncer.stats.avgBytesIn":                                      float64(11),
 				"pgbouncer.stats.avgBytesOut":                                     float64(12),
 				"pgbouncer.stats.avgQueryDurationInMilliseconds":                  float64(13),
+				"pgbouncer.stats.totalWaitTimeInMicrosecondsPerSecond":            float64(0),
+				"pgbouncer.stats.avgWaitTimeInMicroseconds":                       float64(14),
 				"pgbouncer.stats.totalServerAssignmentCount":                      float64(15),
 				"pgbouncer.stats.avgServerAssignmentCount":                        float64(16),
 				"displayName": "testDB",
@@ -706,6 +1317,248 @@ func TestPopulatePgBouncerMetrics(t *testing.T) {
 
 }
 
+func TestPopulatePgBouncerMetrics_AdminCommands(t *testing.T) {
+	testIntegration, _ := integration.New("test", "test")
+	testConnection, mock := connection.CreateMockSQL(t)
+
+	mock.ExpectQuery("SHOW STATS;").
+		WillReturnRows(sqlmock.NewRows([]string{"database", "total_xact_count"}).AddRow("testDB", 1))
+	mock.ExpectQuery("SHOW POOLS;").
+		WillReturnRows(sqlmock.NewRows([]string{"database", "user", "cl_active"}).AddRow("testDB", "testUser", 1))
+	mock.ExpectQuery("SHOW DATABASES;").
+		WillReturnRows(sqlmock.NewRows([]string{
+			"name",
+			"host",
+			"port",
+			"database",
+			"force_user",
+			"pool_size",
+			"min_pool_size",
+			"reserve_pool",
+			"pool_mode",
+			"max_connections",
+			"current_connections",
+			"paused",
+			"disabled",
+		}).AddRow("testDB", "db.example.com", 5432, "realDB", nil, 20, 0, 5, "transaction", 100, 18, 0, 0))
+	mock.ExpectQuery("SHOW SERVERS;").
+		WillReturnRows(sqlmock.NewRows([]string{"type", "user", "database", "state", "addr"}).
+			AddRow("S", "testUser", "testDB", "active", "10.0.0.1").
+			AddRow("S", "testUser", "testDB", "active", "10.0.0.1").
+			AddRow("S", "testUser", "testDB", "idle", "10.0.0.1"))
+	mock.ExpectQuery("SHOW CLIENTS;").
+		WillReturnRows(sqlmock.NewRows([]string{"type", "user", "database", "state", "addr"}).
+			AddRow("C", "testUser", "testDB", "waiting", "10.0.0.2").
+			AddRow("C", "testUser", "testDB", "active_cancel_req", "10.0.0.2"))
+	mock.ExpectQuery("SHOW MEM;").
+		WillReturnRows(sqlmock.NewRows([]string{"name", "size", "used", "free", "memtotal"}).
+			AddRow("user_cache", 512, 4, 46, 25600))
+	mock.ExpectQuery("SHOW LISTS;").
+		WillReturnRows(sqlmock.NewRows([]string{"list", "items"}).
+			AddRow("databases", 2).
+			AddRow("free_clients", 48))
+
+	ci := &connection.MockInfo{}
+	PopulatePgBouncerMetrics(testIntegration, testConnection, ci)
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	id3 := integration.NewIDAttribute("host", "testhost")
+	id4 := integration.NewIDAttribute("port", "1234")
+	pbEntity, err := testIntegration.Entity("testDB", "pgbouncer", id3, id4)
+	assert.Nil(t, err)
+	assert.Len(t, pbEntity.Metrics, 5)
+
+	assert.Equal(t, map[string]interface{}{
+		"pgbouncer.databases.host":               "db.example.com",
+		"pgbouncer.databases.database":           "realDB",
+		"pgbouncer.databases.poolMode":           "transaction",
+		"pgbouncer.databases.poolSize":           float64(20),
+		"pgbouncer.databases.minPoolSize":        float64(0),
+		"pgbouncer.databases.reservePool":        float64(5),
+		"pgbouncer.databases.maxConnections":     float64(100),
+		"pgbouncer.databases.currentConnections": float64(18),
+		"pgbouncer.databases.paused":             float64(0),
+		"pgbouncer.databases.disabled":           float64(0),
+		"displayName":                            "testDB",
+		"entityName":                             "pgbouncer:testDB",
+		"event_type":                             "PgBouncerSample",
+		"host":                                   "testhost",
+	}, pbEntity.Metrics[2].Metrics)
+
+	assert.Equal(t, float64(2), pbEntity.Metrics[3].Metrics["pgbouncer.servers.active"])
+	assert.Equal(t, float64(1), pbEntity.Metrics[3].Metrics["pgbouncer.servers.idle"])
+	assert.Equal(t, float64(0), pbEntity.Metrics[3].Metrics["pgbouncer.servers.beingCanceled"])
+	assert.Equal(t, float64(3), pbEntity.Metrics[3].Metrics["pgbouncer.servers.total"])
+	assert.Equal(t, float64(1), pbEntity.Metrics[4].Metrics["pgbouncer.clients.waiting"])
+	assert.Equal(t, float64(1), pbEntity.Metrics[4].Metrics["pgbouncer.clients.activeCancelReq"])
+	assert.Equal(t, float64(0), pbEntity.Metrics[4].Metrics["pgbouncer.clients.active"])
+	assert.Equal(t, float64(2), pbEntity.Metrics[4].Metrics["pgbouncer.clients.total"])
+
+	adminEntity, err := testIntegration.Entity("pgbouncer", "pgbouncer", id3, id4)
+	assert.Nil(t, err)
+	assert.Len(t, adminEntity.Metrics, 2)
+	assert.Equal(t, map[string]interface{}{
+		"pgbouncer.mem.cache":           "user_cache",
+		"pgbouncer.mem.itemSizeInBytes": float64(512),
+		"pgbouncer.mem.itemsUsed":       float64(4),
+		"pgbouncer.mem.itemsFree":       float64(46),
+		"pgbouncer.mem.totalInBytes":    float64(25600),
+		"displayName":                   "pgbouncer",
+		"entityName":                    "pgbouncer:pgbouncer",
+		"event_type":                    "PgBouncerSample",
+		"host":                          "testhost",
+	}, adminEntity.Metrics[0].Metrics)
+	assert.Equal(t, float64(2), adminEntity.Metrics[1].Metrics["pgbouncer.lists.databases"])
+	assert.Equal(t, float64(48), adminEntity.Metrics[1].Metrics["pgbouncer.lists.freeClients"])
+}
+
+func TestPopulatePgBouncerMetrics_FailedCommand(t *testing.T) {
+	testIntegration, _ := integration.New("test", "test")
+	testConnection, mock := connection.CreateMockSQL(t)
+
+	// a command missing from the PgBouncer version does not prevent the others from being collected
+	mock.ExpectQuery("SHOW STATS;").WillReturnError(errors.New("invalid command"))
+	mock.ExpectQuery("SHOW POOLS;").
+		WillReturnRows(sqlmock.NewRows([]string{"database", "user", "cl_active"}).AddRow("testDB", "testUser", 1))
+	mock.ExpectQuery("SHOW DATABASES;").WillReturnError(errors.New("invalid command"))
+	mock.ExpectQuery("SHOW SERVERS;").
+		WillReturnRows(sqlmock.NewRows([]string{"type", "user", "database", "state", "addr"}).
+			AddRow("S", "testUser", "testDB", "active", "10.0.0.1"))
+	mock.ExpectQuery("SHOW CLIENTS;").
+		WillReturnRows(sqlmock.NewRows([]string{"type", "user", "database", "state", "addr"}))
+	mock.ExpectQuery("SHOW MEM;").
+		WillReturnRows(sqlmock.NewRows([]string{"name", "size", "used", "free", "memtotal"}).
+			AddRow("user_cache", 512, 4, 46, 25600))
+	mock.ExpectQuery("SHOW LISTS;").
+		WillReturnRows(sqlmock.NewRows([]string{"list", "items"}).AddRow("databases", 2))
+
+	ci := &connection.MockInfo{}
+	PopulatePgBouncerMetrics(testIntegration, testConnection, ci)
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	id3 := integration.NewIDAttribute("host", "testhost")
+	id4 := integration.NewIDAttribute("port", "1234")
+	pbEntity, err := testIntegration.Entity("testDB", "pgbouncer", id3, id4)
+	assert.Nil(t, err)
+	require.Len(t, pbEntity.Metrics, 2)
+	assert.Equal(t, float64(1), pbEntity.Metrics[0].Metrics["pgbouncer.pools.clientConnectionsActive"])
+	assert.Equal(t, float64(1), pbEntity.Metrics[1].Metrics["pgbouncer.servers.active"])
+
+	adminEntity, err := testIntegration.Entity("pgbouncer", "pgbouncer", id3, id4)
+	assert.Nil(t, err)
+	assert.Len(t, adminEntity.Metrics, 2)
+}
+
+func TestPopulatePgpoolMetrics(t *testing.T) {
+	testIntegration, _ := integration.New("test", "test")
+	testConnection, mock := connection.CreateMockSQL(t)
+
+	nodeColumns := []string{
+		"node_id",
+		"hostname",
+		"port",
+		"status",
+		"pg_status",
+		"lb_weight",
+		"role",
+		"pg_role",
+		"select_cnt",
+		"load_balance_node",
+		"replication_delay",
+		"replication_state",
+		"replication_sync_state",
+		"last_status_change",
+	}
+	mock.ExpectQuery("SHOW POOL_NODES;").
+		WillReturnRows(sqlmock.NewRows(nodeColumns).
+			AddRow("0", "primary.example.com", "5432", "up", "up", "0.500000", "primary", "primary", "10", "true", "0", "", "", "2024-01-01 00:00:00").
+			AddRow("1", "standby.example.com", "5432", "up", "up", "0.500000", "standby", "standby", "5", "false", "1.5 second", "streaming", "async", "2024-01-01 00:00:00"))
+	mock.ExpectQuery("SHOW POOL_POOLS;").
+		WillReturnRows(sqlmock.NewRows([]string{"pool_pid", "pool_id", "backend_id", "database", "pool_connected"}).
+			AddRow("100", "0", "0", "postgres", "1").
+			AddRow("100", "0", "1", "postgres", "1").
+			AddRow("101", "0", "0", "", "0").
+			AddRow("101", "0", "1", "", "0"))
+	mock.ExpectQuery("SHOW POOL_PROCESSES;").
+		WillReturnRows(sqlmock.NewRows([]string{"pool_pid", "database", "username", "status"}).
+			AddRow("100", "postgres", "app", "Idle in transaction").
+			AddRow("101", "", "", "Wait for connection"))
+	mock.ExpectQuery("SHOW POOL_HEALTH_CHECK_STATS;").
+		WillReturnRows(sqlmock.NewRows([]string{"node_id", "hostname", "port", "total_count", "fail_count", "average_duration"}).
+			AddRow("0", "primary.example.com", "5432", "20", "1", "12.500000"))
+
+	ci := &connection.MockInfo{}
+	PopulatePgpoolMetrics(testIntegration, testConnection, ci)
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	id3 := integration.NewIDAttribute("host", "testhost")
+	id4 := integration.NewIDAttribute("port", "1234")
+	primaryEntity, err := testIntegration.Entity("primary.example.com:5432", "pgpool", id3, id4)
+	assert.Nil(t, err)
+	assert.Len(t, primaryEntity.Metrics, 3)
+	assert.Equal(t, map[string]interface{}{
+		"pgpool.node.id":                      "0",
+		"pgpool.node.status":                  "up",
+		"pgpool.node.pgStatus":                "up",
+		"pgpool.node.loadBalanceRatio":        float64(0.5),
+		"pgpool.node.role":                    "primary",
+		"pgpool.node.pgRole":                  "primary",
+		"pgpool.node.selectQueriesPerSecond":  float64(0),
+		"pgpool.node.loadBalanceNode":         "true",
+		"pgpool.node.replicationState":        "",
+		"pgpool.node.replicationSyncState":    "",
+		"pgpool.node.replicationDelayInBytes": float64(0),
+		"displayName":                         "primary.example.com:5432",
+		"entityName":                          "pgpool:primary.example.com:5432",
+		"event_type":                          "PgpoolSample",
+		"host":                                "testhost",
+	}, primaryEntity.Metrics[0].Metrics)
+	assert.Equal(t, float64(1), primaryEntity.Metrics[1].Metrics["pgpool.node.pooledConnections"])
+	assert.Equal(t, float64(0), primaryEntity.Metrics[2].Metrics["pgpool.healthCheck.checksPerSecond"])
+	assert.Equal(t, float64(12.5), primaryEntity.Metrics[2].Metrics["pgpool.healthCheck.averageDurationInMilliseconds"])
+
+	standbyEntity, err := testIntegration.Entity("standby.example.com:5432", "pgpool", id3, id4)
+	assert.Nil(t, err)
+	assert.Len(t, standbyEntity.Metrics, 2)
+	assert.Equal(t, float64(1.5), standbyEntity.Metrics[0].Metrics["pgpool.node.replicationDelayInSeconds"])
+	assert.Equal(t, "standby", standbyEntity.Metrics[0].Metrics["pgpool.node.role"])
+	assert.Equal(t, float64(1), standbyEntity.Metrics[1].Metrics["pgpool.node.pooledConnections"])
+
+	instanceEntity, err := testIntegration.Entity("pgpool", "pgpool", id3, id4)
+	assert.Nil(t, err)
+	assert.Len(t, instanceEntity.Metrics, 1)
+	assert.Equal(t, float64(2), instanceEntity.Metrics[0].Metrics["pgpool.processes.total"])
+	assert.Equal(t, float64(1), instanceEntity.Metrics[0].Metrics["pgpool.processes.connected"])
+	assert.Equal(t, float64(1), instanceEntity.Metrics[0].Metrics["pgpool.processes.idleInTransaction"])
+	assert.Equal(t, float64(1), instanceEntity.Metrics[0].Metrics["pgpool.processes.waitForConnection"])
+	assert.Equal(t, float64(0), instanceEntity.Metrics[0].Metrics["pgpool.processes.executeCommand"])
+}
+
+func TestParsePgpoolReplicationDelay(t *testing.T) {
+	testCases := []struct {
+		value      string
+		wantMetric string
+		wantDelay  float64
+		wantError  bool
+	}{
+		{"1024", "pgpool.node.replicationDelayInBytes", 1024, false},
+		{"0.250 second", "pgpool.node.replicationDelayInSeconds", 0.25, false},
+		{"", "", 0, true},
+		{"unknown", "", 0, true},
+	}
+
+	for _, tc := range testCases {
+		metricName, delay, err := parsePgpoolReplicationDelay(tc.value)
+		if tc.wantError {
+			assert.Error(t, err, tc.value)
+			continue
+		}
+		assert.NoError(t, err, tc.value)
+		assert.Equal(t, tc.wantMetric, metricName, tc.value)
+		assert.Equal(t, tc.wantDelay, delay, tc.value)
+	}
+}
+
 func TestPopulateMetrics(t *testing.T) {
 	testIntegration, _ := integration.New("test", "test")
 
@@ -729,7 +1582,35 @@ func TestPopulateMetrics(t *testing.T) {
 
 	instance, _ := testIntegration.Entity("testInstance", "instance")
 
-	PopulateMetrics(ci, dbList, instance, testIntegration, true, true, true, "")
+	PopulateMetrics(context.Background(), ci, dbList, instance, testIntegration, ci, false, true, true, 0, 1, "", nil)
+}
+
+func TestPopulateMetricsBloatSchedule(t *testing.T) {
+	testIntegration, _ := integration.New("test", "test")
+	dbList := collection.DatabaseList{"db1": collection.SchemaList{"schema1": collection.TableList{"table1": []string{}}}}
+
+	ci := &connection.MockInfo{}
+	testConnection, mock := connection.CreateMockSQL(t)
+	mock.MatchExpectationsInOrder(false)
+	mock.ExpectQuery(".*server_version.*").WillReturnRows(sqlmock.NewRows([]string{"server_version"}).AddRow("12.0"))
+	ci.On("NewConnection", tmock.Anything).Return(testConnection, nil)
+	instance, _ := testIntegration.Entity("testInstance", "instance")
+
+	// bloat is its own collector, which runs less often than the table collector
+	schedule := NewSchedule(15*time.Second, map[string]time.Duration{"bloat": time.Hour})
+	schedule.StartCycle(time.Now())
+	schedule.ran("bloat")
+	telemetry := NewCollectionTelemetry()
+	ctx := WithTelemetry(WithSchedule(context.Background(), schedule), telemetry)
+	PopulateMetrics(ctx, ci, dbList, instance, testIntegration, nil, false, false, true, 0, 1, "", nil)
+
+	collectors := make(map[string]bool)
+	for _, c := range telemetry.collectors {
+		collectors[c.name] = true
+	}
+	assert.True(t, collectors["table"])
+	assert.False(t, collectors["bloat"])
+	assert.False(t, schedule.due("bloat"))
 }
 
 func TestPopulateCustomMetricsFromFile(t *testing.T) {
@@ -760,7 +1641,7 @@ queries:
 `)
 	assert.NoError(t, os.WriteFile(filepath.Join(dir, "customQueryConfig.yaml"), customQueryCfg, 0600))
 
-	PopulateCustomMetricsFromFile(ci, filepath.Join(dir, "customQueryConfig.yaml"), testIntegration)
+	PopulateCustomMetricsFromFile(context.Background(), ci, filepath.Join(dir, "customQueryConfig.yaml"), testIntegration)
 
 	assert.Len(t, testIntegration.Entities, 1)
 	assert.Len(t, testIntegration.Entities[0].Metrics, 1)
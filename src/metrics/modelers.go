@@ -2,6 +2,7 @@ package metrics
 
 import (
 	"errors"
+	"fmt"
 	"reflect"
 )
 
@@ -27,7 +28,7 @@ func GetDatabaseName(dataModel interface{}) (string, error) {
 	v := reflect.ValueOf(dataModel)
 	modeler, ok := v.Interface().(DatabaseModeler)
 	if !ok {
-		return "", errors.New("data model does not implement DatabaseModeler interface")
+		return getColumnString(dataModel, "database")
 	}
 
 	name, err := modeler.GetDatabaseName()
@@ -60,7 +61,7 @@ func GetSchemaName(dataModel interface{}) (string, error) {
 	v := reflect.ValueOf(dataModel)
 	modeler, ok := v.Interface().(SchemaModeler)
 	if !ok {
-		return "", errors.New("data model does not implement SchemaModeler interface")
+		return getColumnString(dataModel, "schema_name")
 	}
 
 	name, err := modeler.GetSchemaName()
@@ -93,7 +94,7 @@ func GetTableName(dataModel interface{}) (string, error) {
 	v := reflect.ValueOf(dataModel)
 	modeler, ok := v.Interface().(TableModeler)
 	if !ok {
-		return "", errors.New("data model does not implement TableModeler interface")
+		return getColumnString(dataModel, "table_name")
 	}
 
 	name, err := modeler.GetTableName()
@@ -126,7 +127,7 @@ func GetIndexName(dataModel interface{}) (string, error) {
 	v := reflect.ValueOf(dataModel)
 	modeler, ok := v.Interface().(IndexModeler)
 	if !ok {
-		return "", errors.New("data model does not implement IndexModeler interface")
+		return getColumnString(dataModel, "index_name")
 	}
 
 	name, err := modeler.GetIndexName()
@@ -136,3 +137,212 @@ func GetIndexName(dataModel interface{}) (string, error) {
 
 	return name, nil
 }
+
+// StandbyModeler represents something with a replication standby field
+type StandbyModeler interface {
+	GetStandbyName() (string, error)
+}
+
+type standbyBase struct {
+	Standby *string `db:"standby_name"`
+}
+
+// GetStandbyName returns the standby name
+func (d standbyBase) GetStandbyName() (string, error) {
+	if d.Standby == nil {
+		return "", errors.New("standby name not returned")
+	}
+	return *d.Standby, nil
+}
+
+// GetStandbyName returns the standby name
+func GetStandbyName(dataModel interface{}) (string, error) {
+	v := reflect.ValueOf(dataModel)
+	modeler, ok := v.Interface().(StandbyModeler)
+	if !ok {
+		return "", errors.New("data model does not implement StandbyModeler interface")
+	}
+
+	name, err := modeler.GetStandbyName()
+	if err != nil {
+		return "", err
+	}
+
+	return name, nil
+}
+
+// SlotModeler represents something with a replication slot field
+type SlotModeler interface {
+	GetSlotName() (string, error)
+}
+
+type slotBase struct {
+	Slot *string `db:"slot_name"`
+}
+
+// GetSlotName returns the replication slot name
+func (d slotBase) GetSlotName() (string, error) {
+	if d.Slot == nil {
+		return "", errors.New("slot name not returned")
+	}
+	return *d.Slot, nil
+}
+
+// GetSlotName returns the replication slot name
+func GetSlotName(dataModel interface{}) (string, error) {
+	v := reflect.ValueOf(dataModel)
+	modeler, ok := v.Interface().(SlotModeler)
+	if !ok {
+		return "", errors.New("data model does not implement SlotModeler interface")
+	}
+
+	name, err := modeler.GetSlotName()
+	if err != nil {
+		return "", err
+	}
+
+	return name, nil
+}
+
+// TablespaceModeler represents something with a tablespace field
+type TablespaceModeler interface {
+	GetTablespaceName() (string, error)
+}
+
+type tablespaceBase struct {
+	Tablespace *string `db:"tablespace_name"`
+}
+
+// GetTablespaceName returns the tablespace name
+func (d tablespaceBase) GetTablespaceName() (string, error) {
+	if d.Tablespace == nil {
+		return "", errors.New("tablespace name not returned")
+	}
+	return *d.Tablespace, nil
+}
+
+// GetTablespaceName returns the tablespace name
+func GetTablespaceName(dataModel interface{}) (string, error) {
+	v := reflect.ValueOf(dataModel)
+	modeler, ok := v.Interface().(TablespaceModeler)
+	if !ok {
+		return "", errors.New("data model does not implement TablespaceModeler interface")
+	}
+
+	name, err := modeler.GetTablespaceName()
+	if err != nil {
+		return "", err
+	}
+
+	return name, nil
+}
+
+// IOModeler represents a pg_stat_io row identified by backend type, object and context
+type IOModeler interface {
+	GetIODimensions() (backendType, object, context string, err error)
+}
+
+type ioBase struct {
+	BackendType *string `db:"backend_type"`
+	Object      *string `db:"object"`
+	Context     *string `db:"context"`
+}
+
+// GetIODimensions returns the backend type, object and context of the row
+func (d ioBase) GetIODimensions() (string, string, string, error) {
+	if d.BackendType == nil || d.Object == nil || d.Context == nil {
+		return "", "", "", errors.New("io backend type, object or context not returned")
+	}
+	return *d.BackendType, *d.Object, *d.Context, nil
+}
+
+// GetIODimensions returns the backend type, object and context of a pg_stat_io row
+func GetIODimensions(dataModel interface{}) (string, string, string, error) {
+	v := reflect.ValueOf(dataModel)
+	modeler, ok := v.Interface().(IOModeler)
+	if !ok {
+		return "", "", "", errors.New("data model does not implement IOModeler interface")
+	}
+
+	return modeler.GetIODimensions()
+}
+
+// StatsResetModeler represents a row of counters reported along with the stats_reset time of their statistics view
+type StatsResetModeler interface {
+	GetStatsReset() (float64, bool)
+}
+
+type statsResetBase struct {
+	StatsReset *float64 `db:"stats_reset"`
+}
+
+// GetStatsReset returns the stats_reset time in seconds since the epoch, if it was returned
+func (d statsResetBase) GetStatsReset() (float64, bool) {
+	if d.StatsReset == nil {
+		return 0, false
+	}
+	return *d.StatsReset, true
+}
+
+// pgbouncerDatabaseBase implements DatabaseModeler for PgBouncer commands reporting the database name in a name column
+type pgbouncerDatabaseBase struct {
+	Name *string `db:"name"`
+}
+
+// GetDatabaseName returns the PgBouncer database name for the object
+func (d pgbouncerDatabaseBase) GetDatabaseName() (string, error) {
+	if d.Name == nil {
+		return "", errors.New("database name not returned")
+	}
+	return *d.Name, nil
+}
+
+// PgpoolNodeModeler is an interface to represent something which identifies a Pgpool-II backend node
+type PgpoolNodeModeler interface {
+	GetNodeName() (string, error)
+}
+
+// pgpoolNodeBase identifies a Pgpool-II backend node by its hostname and port columns
+type pgpoolNodeBase struct {
+	Hostname *string `db:"hostname"`
+	Port     *string `db:"port"`
+}
+
+// GetNodeName returns the backend node name as hostname:port
+func (n pgpoolNodeBase) GetNodeName() (string, error) {
+	if n.Hostname == nil || n.Port == nil {
+		return "", errors.New("node hostname or port not returned")
+	}
+	return *n.Hostname + ":" + *n.Port, nil
+}
+
+// GetPgpoolNodeName returns the Pgpool-II backend node name for the object
+func GetPgpoolNodeName(dataModel interface{}) (string, error) {
+	v := reflect.ValueOf(dataModel)
+	modeler, ok := v.Interface().(PgpoolNodeModeler)
+	if !ok {
+		return "", errors.New("data model does not implement PgpoolNodeModeler interface")
+	}
+
+	return modeler.GetNodeName()
+}
+
+// getColumnString returns the value of the string field of a data model scanned from column. Data models built at
+// run time, such as those of the metric catalog, have no modeler methods and are identified by their columns instead.
+func getColumnString(dataModel interface{}, column string) (string, error) {
+	v := reflect.Indirect(reflect.ValueOf(dataModel))
+	if v.Kind() == reflect.Struct {
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).Tag.Get("db") != column {
+				continue
+			}
+			if value, ok := v.Field(i).Interface().(*string); ok {
+				if value == nil {
+					return "", fmt.Errorf("%s not returned", column)
+				}
+				return *value, nil
+			}
+		}
+	}
+	return "", fmt.Errorf("data model has no %s column", column)
+}
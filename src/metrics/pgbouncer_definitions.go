@@ -1,9 +1,10 @@
 package metrics
 
 func generatePgBouncerDefinitions() []*QueryDefinition {
-	queryDefinitions := make([]*QueryDefinition, 2)
+	queryDefinitions := make([]*QueryDefinition, 3)
 	queryDefinitions[0] = pgbouncerStatsDefinition
 	queryDefinitions[1] = pgbouncerPoolsDefinition
+	queryDefinitions[2] = pgbouncerDatabasesDefinition
 
 	return queryDefinitions
 }
@@ -21,7 +22,7 @@ var pgbouncerStatsDefinition = &QueryDefinition{
 		TotalXactTime              *int64 `db:"total_xact_time"   metric_name:"pgbouncer.stats.totalTransactionDurationInMillisecondsPerSecond" source_type:"rate"`
 		TotalQueryTime             *int64 `db:"total_query_time"  metric_name:"pgbouncer.stats.totalQueryDurationInMillisecondsPerSecond"       source_type:"rate"`
 		TotalRequests              *int64 `db:"total_requests"    metric_name:"pgbouncer.stats.requestsPerSecond"                               source_type:"rate"`
-		TotalWaitTime              *int64 `db:"total_wait_time"`
+		TotalWaitTime              *int64 `db:"total_wait_time"   metric_name:"pgbouncer.stats.totalWaitTimeInMicrosecondsPerSecond"            source_type:"rate"`
 		AvgXactCount               *int64 `db:"avg_xact_count"    metric_name:"pgbouncer.stats.avgTransactionCount"                             source_type:"gauge"`
 		AvgXactTime                *int64 `db:"avg_xact_time"     metric_name:"pgbouncer.stats.avgTransactionDurationInMilliseconds"            source_type:"gauge"`
 		AvgQueryCount              *int64 `db:"avg_query_count"   metric_name:"pgbouncer.stats.avgQueryCount"                                   source_type:"gauge"`
@@ -31,30 +32,118 @@ var pgbouncerStatsDefinition = &QueryDefinition{
 		AvgReq                     *int64 `db:"avg_req"           metric_name:"pgbouncer.stats.avgRequestsPerSecond"                            source_type:"gauge"`
 		AvgQueryTime               *int64 `db:"avg_query_time"    metric_name:"pgbouncer.stats.avgQueryDurationInMilliseconds"                  source_type:"gauge"`
 		AvgQuery                   *int64 `db:"avg_query"         metric_name:"pgbouncer.stats.avgQueryDurationInMilliseconds"                  source_type:"gauge"`
-		AvgWaitTime                *int64 `db:"avg_wait_time"`
+		AvgWaitTime                *int64 `db:"avg_wait_time"     metric_name:"pgbouncer.stats.avgWaitTimeInMicroseconds"                       source_type:"gauge"`
 	}{},
 }
 
 var pgbouncerPoolsDefinition = &QueryDefinition{
 	query: `SHOW POOLS;`,
 
+	dataModels: []pgbouncerPoolDataModel{},
+}
+
+// pgbouncerPoolDataModel is a row of SHOW POOLS. The oldest wait is split in maxwait seconds and maxwait_us
+// microseconds, which are reported together as pgbouncer.pools.maxwaitInMicroseconds.
+type pgbouncerPoolDataModel struct {
+	databaseBase
+	User               *string `db:"user"                  metric_name:"pgbouncer.pools.user"                          source_type:"attribute"`
+	ClCancelReq        *int64  `db:"cl_cancel_req"         metric_name:"pgbouncer.pools.clientConnectionsCancelReq"        source_type:"gauge"` // removed in v1.18
+	ClActive           *int64  `db:"cl_active"             metric_name:"pgbouncer.pools.clientConnectionsActive"           source_type:"gauge"`
+	ClWaiting          *int64  `db:"cl_waiting"            metric_name:"pgbouncer.pools.clientConnectionsWaiting"          source_type:"gauge"`
+	ClWaitingCancelReq *int64  `db:"cl_waiting_cancel_req" metric_name:"pgbouncer.pools.clientConnectionsWaitingCancelReq" source_type:"gauge"` // added in v1.18
+	ClActiveCancelReq  *int64  `db:"cl_active_cancel_req"  metric_name:"pgbouncer.pools.clientConnectionsActiveCancelReq"  source_type:"gauge"` // added in v1.18
+	SvActiveCancel     *int64  `db:"sv_active_cancel"      metric_name:"pgbouncer.pools.serverConnectionsActiveCancel"     source_type:"gauge"` // added in v1.18
+	SvBeingCancel      *int64  `db:"sv_being_canceled"     metric_name:"pgbouncer.pools.serverConnectionsBeingCancel"      source_type:"gauge"` // added in v1.18
+	SvActive           *int64  `db:"sv_active"             metric_name:"pgbouncer.pools.serverConnectionsActive"           source_type:"gauge"`
+	SvIdle             *int64  `db:"sv_idle"               metric_name:"pgbouncer.pools.serverConnectionsIdle"             source_type:"gauge"`
+	SvUsed             *int64  `db:"sv_used"               metric_name:"pgbouncer.pools.serverConnectionsUsed"             source_type:"gauge"`
+	SvTested           *int64  `db:"sv_tested"             metric_name:"pgbouncer.pools.serverConnectionsTested"           source_type:"gauge"`
+	SvLogin            *int64  `db:"sv_login"              metric_name:"pgbouncer.pools.serverConnectionsLogin"            source_type:"gauge"`
+	MaxWait            *int64  `db:"maxwait"               metric_name:"pgbouncer.pools.maxwaitInMilliseconds"             source_type:"gauge"`
+	MaxWaitUs          *int64  `db:"maxwait_us"` // microsecond part of maxwait, added in v1.8
+	PoolMode           *string `db:"pool_mode"             metric_name:"pgbouncer.pools.poolMode"                          source_type:"attribute"`
+}
+
+// maxWaitInMicroseconds returns how long the oldest waiting client of the pool has waited
+func (p pgbouncerPoolDataModel) maxWaitInMicroseconds() (int64, bool) {
+	if p.MaxWait == nil {
+		return 0, false
+	}
+	maxWait := *p.MaxWait * 1000000
+	if p.MaxWaitUs != nil {
+		maxWait += *p.MaxWaitUs
+	}
+	return maxWait, true
+}
+
+// pgbouncerDatabasesDefinition reports the configuration and usage of each database pool.
+// The rows are keyed by the name column, which is the database name used by SHOW STATS and SHOW POOLS.
+var pgbouncerDatabasesDefinition = &QueryDefinition{
+	query: `SHOW DATABASES;`,
+
 	dataModels: []struct {
-		databaseBase
-		User               *string `db:"user"                  metric_name:"pgbouncer.pools.user"                          source_type:"attribute"`
-		ClCancelReq        *int64  `db:"cl_cancel_req"         metric_name:"pgbouncer.pools.clientConnectionsCancelReq"        source_type:"gauge"` // removed in v1.18
-		ClActive           *int64  `db:"cl_active"             metric_name:"pgbouncer.pools.clientConnectionsActive"           source_type:"gauge"`
-		ClWaiting          *int64  `db:"cl_waiting"            metric_name:"pgbouncer.pools.clientConnectionsWaiting"          source_type:"gauge"`
-		ClWaitingCancelReq *int64  `db:"cl_waiting_cancel_req" metric_name:"pgbouncer.pools.clientConnectionsWaitingCancelReq" source_type:"gauge"` // added in v1.18
-		ClActiveCancelReq  *int64  `db:"cl_active_cancel_req"  metric_name:"pgbouncer.pools.clientConnectionsActiveCancelReq"  source_type:"gauge"` // added in v1.18
-		SvActiveCancel     *int64  `db:"sv_active_cancel"      metric_name:"pgbouncer.pools.serverConnectionsActiveCancel"     source_type:"gauge"` // added in v1.18
-		SvBeingCancel      *int64  `db:"sv_being_canceled"     metric_name:"pgbouncer.pools.serverConnectionsBeingCancel"      source_type:"gauge"` // added in v1.18
-		SvActive           *int64  `db:"sv_active"             metric_name:"pgbouncer.pools.serverConnectionsActive"           source_type:"gauge"`
-		SvIdle             *int64  `db:"sv_idle"               metric_name:"pgbouncer.pools.serverConnectionsIdle"             source_type:"gauge"`
-		SvUsed             *int64  `db:"sv_used"               metric_name:"pgbouncer.pools.serverConnectionsUsed"             source_type:"gauge"`
-		SvTested           *int64  `db:"sv_tested"             metric_name:"pgbouncer.pools.serverConnectionsTested"           source_type:"gauge"`
-		SvLogin            *int64  `db:"sv_login"              metric_name:"pgbouncer.pools.serverConnectionsLogin"            source_type:"gauge"`
-		MaxWait            *int64  `db:"maxwait"               metric_name:"pgbouncer.pools.maxwaitInMilliseconds"             source_type:"gauge"`
-		MaxWaitUs          *int64  `db:"maxwait_us"`
-		PoolMode           *string `db:"pool_mode"`
+		pgbouncerDatabaseBase
+		Host               *string `db:"host"                metric_name:"pgbouncer.databases.host"               source_type:"attribute"`
+		Database           *string `db:"database"            metric_name:"pgbouncer.databases.database"           source_type:"attribute"`
+		ForceUser          *string `db:"force_user"          metric_name:"pgbouncer.databases.forceUser"          source_type:"attribute"`
+		PoolMode           *string `db:"pool_mode"           metric_name:"pgbouncer.databases.poolMode"           source_type:"attribute"`
+		PoolSize           *int64  `db:"pool_size"           metric_name:"pgbouncer.databases.poolSize"           source_type:"gauge"`
+		MinPoolSize        *int64  `db:"min_pool_size"       metric_name:"pgbouncer.databases.minPoolSize"        source_type:"gauge"`
+		ReservePool        *int64  `db:"reserve_pool"        metric_name:"pgbouncer.databases.reservePool"        source_type:"gauge"`
+		MaxConnections     *int64  `db:"max_connections"     metric_name:"pgbouncer.databases.maxConnections"     source_type:"gauge"`
+		CurrentConnections *int64  `db:"current_connections" metric_name:"pgbouncer.databases.currentConnections" source_type:"gauge"`
+		Paused             *int64  `db:"paused"              metric_name:"pgbouncer.databases.paused"             source_type:"gauge"`
+		Disabled           *int64  `db:"disabled"            metric_name:"pgbouncer.databases.disabled"           source_type:"gauge"`
 	}{},
 }
+
+// pgbouncerServersDefinition lists the server connections, which are counted per database and state
+var pgbouncerServersDefinition = &QueryDefinition{
+	query: `SHOW SERVERS;`,
+
+	dataModels: []pgbouncerConnectionDataModel{},
+}
+
+// pgbouncerClientsDefinition lists the client connections, which are counted per database and state
+var pgbouncerClientsDefinition = &QueryDefinition{
+	query: `SHOW CLIENTS;`,
+
+	dataModels: []pgbouncerConnectionDataModel{},
+}
+
+type pgbouncerConnectionDataModel struct {
+	databaseBase
+	State *string `db:"state"`
+}
+
+// pgbouncerServerStates and pgbouncerClientStates are the connection states always reported, so that
+// states without connections are reported as 0. States not listed are reported when they occur.
+var (
+	pgbouncerServerStates = []string{"active", "idle", "used", "tested", "new", "active_cancel", "being_canceled"}
+	pgbouncerClientStates = []string{"active", "waiting", "active_cancel_req", "waiting_cancel_req"}
+)
+
+// pgbouncerMemDefinition reports the internal memory caches of PgBouncer
+var pgbouncerMemDefinition = &QueryDefinition{
+	query: `SHOW MEM;`,
+
+	dataModels: []struct {
+		Name     *string `db:"name"     metric_name:"pgbouncer.mem.cache"           source_type:"attribute"`
+		Size     *int64  `db:"size"     metric_name:"pgbouncer.mem.itemSizeInBytes" source_type:"gauge"`
+		Used     *int64  `db:"used"     metric_name:"pgbouncer.mem.itemsUsed"       source_type:"gauge"`
+		Free     *int64  `db:"free"     metric_name:"pgbouncer.mem.itemsFree"       source_type:"gauge"`
+		MemTotal *int64  `db:"memtotal" metric_name:"pgbouncer.mem.totalInBytes"    source_type:"gauge"`
+	}{},
+}
+
+// pgbouncerListsDefinition reports the size of the internal lists of PgBouncer, one row per list
+var pgbouncerListsDefinition = &QueryDefinition{
+	query: `SHOW LISTS;`,
+
+	dataModels: []pgbouncerListDataModel{},
+}
+
+type pgbouncerListDataModel struct {
+	List  *string `db:"list"`
+	Items *int64  `db:"items"`
+}
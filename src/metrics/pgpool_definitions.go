@@ -0,0 +1,74 @@
+package metrics
+
+// Pgpool-II returns every column of its SHOW commands as text, which the driver converts into the field types.
+// Columns missing from older Pgpool-II versions are left unset.
+
+// pgpoolNodesDefinition reports the status, role, load balancing and replication of each backend node
+var pgpoolNodesDefinition = &QueryDefinition{
+	query: `SHOW POOL_NODES;`,
+
+	dataModels: []pgpoolNodeDataModel{},
+}
+
+type pgpoolNodeDataModel struct {
+	pgpoolNodeBase
+	NodeID               *string  `db:"node_id"                metric_name:"pgpool.node.id"                     source_type:"attribute"`
+	Status               *string  `db:"status"                 metric_name:"pgpool.node.status"                 source_type:"attribute"`
+	PgStatus             *string  `db:"pg_status"              metric_name:"pgpool.node.pgStatus"               source_type:"attribute"` // added in v4.3
+	LbWeight             *float64 `db:"lb_weight"              metric_name:"pgpool.node.loadBalanceRatio"       source_type:"gauge"`
+	Role                 *string  `db:"role"                   metric_name:"pgpool.node.role"                   source_type:"attribute"`
+	PgRole               *string  `db:"pg_role"                metric_name:"pgpool.node.pgRole"                 source_type:"attribute"` // added in v4.3
+	SelectCnt            *int64   `db:"select_cnt"             metric_name:"pgpool.node.selectQueriesPerSecond" source_type:"rate"`
+	LoadBalanceNode      *string  `db:"load_balance_node"      metric_name:"pgpool.node.loadBalanceNode"        source_type:"attribute"`
+	ReplicationState     *string  `db:"replication_state"      metric_name:"pgpool.node.replicationState"       source_type:"attribute"` // added in v4.1
+	ReplicationSyncState *string  `db:"replication_sync_state" metric_name:"pgpool.node.replicationSyncState"   source_type:"attribute"` // added in v4.1
+	// ReplicationDelay is in bytes, or in seconds with a "second" suffix when delay_threshold_by_time is set
+	ReplicationDelay *string `db:"replication_delay"`
+}
+
+// pgpoolProcessesDefinition lists the Pgpool-II child processes, which are counted for the whole Pgpool-II instance
+var pgpoolProcessesDefinition = &QueryDefinition{
+	query: `SHOW POOL_PROCESSES;`,
+
+	dataModels: []pgpoolProcessDataModel{},
+}
+
+type pgpoolProcessDataModel struct {
+	PoolPid  *string `db:"pool_pid"`
+	Database *string `db:"database"`
+	Status   *string `db:"status"` // added in v4.3
+}
+
+// pgpoolProcessStates are the process states always reported once Pgpool-II reports a status column
+var pgpoolProcessStates = []string{"Idle", "Idle in transaction", "Wait for connection", "Execute command"}
+
+// pgpoolPoolsDefinition lists the connection pool slots of every child process, one row per backend node
+var pgpoolPoolsDefinition = &QueryDefinition{
+	query: `SHOW POOL_POOLS;`,
+
+	dataModels: []pgpoolPoolDataModel{},
+}
+
+type pgpoolPoolDataModel struct {
+	BackendID     *string `db:"backend_id"`
+	PoolConnected *int64  `db:"pool_connected"`
+}
+
+// pgpoolHealthCheckDefinition reports the health check statistics of each backend node, added in v4.1
+var pgpoolHealthCheckDefinition = &QueryDefinition{
+	query: `SHOW POOL_HEALTH_CHECK_STATS;`,
+
+	dataModels: []struct {
+		pgpoolNodeBase
+		TotalCount        *int64   `db:"total_count"         metric_name:"pgpool.healthCheck.checksPerSecond"               source_type:"rate"`
+		SuccessCount      *int64   `db:"success_count"       metric_name:"pgpool.healthCheck.successesPerSecond"            source_type:"rate"`
+		FailCount         *int64   `db:"fail_count"          metric_name:"pgpool.healthCheck.failuresPerSecond"             source_type:"rate"`
+		SkipCount         *int64   `db:"skip_count"          metric_name:"pgpool.healthCheck.skipsPerSecond"                source_type:"rate"`
+		RetryCount        *int64   `db:"retry_count"         metric_name:"pgpool.healthCheck.retriesPerSecond"              source_type:"rate"`
+		AverageRetryCount *float64 `db:"average_retry_count" metric_name:"pgpool.healthCheck.averageRetries"                source_type:"gauge"`
+		MaxRetryCount     *int64   `db:"max_retry_count"     metric_name:"pgpool.healthCheck.maxRetries"                    source_type:"gauge"`
+		MaxDuration       *int64   `db:"max_duration"        metric_name:"pgpool.healthCheck.maxDurationInMilliseconds"     source_type:"gauge"`
+		MinDuration       *int64   `db:"min_duration"        metric_name:"pgpool.healthCheck.minDurationInMilliseconds"     source_type:"gauge"`
+		AverageDuration   *float64 `db:"average_duration"    metric_name:"pgpool.healthCheck.averageDurationInMilliseconds" source_type:"gauge"`
+	}{},
+}
@@ -0,0 +1,223 @@
+package metrics
+
+import (
+	"github.com/blang/semver/v4"
+	"github.com/newrelic/nri-postgresql/src/collection"
+)
+
+var progressVersionDefinitions = []VersionDefinition{
+	{
+		minVersion: semver.MustParse("17.0.0"),
+		queryDefinitions: []*QueryDefinition{
+			vacuumProgressDefinition170,
+			analyzeProgressDefinition,
+			createIndexProgressDefinition,
+			clusterProgressDefinition,
+		},
+	},
+	{
+		minVersion: semver.MustParse("13.0.0"),
+		queryDefinitions: []*QueryDefinition{
+			vacuumProgressDefinition,
+			analyzeProgressDefinition,
+			createIndexProgressDefinition,
+			clusterProgressDefinition,
+		},
+	},
+	{
+		minVersion: semver.MustParse("12.0.0"),
+		queryDefinitions: []*QueryDefinition{
+			vacuumProgressDefinition,
+			createIndexProgressDefinition,
+			clusterProgressDefinition,
+		},
+	},
+	{
+		minVersion: semver.MustParse("9.6.0"),
+		queryDefinitions: []*QueryDefinition{
+			vacuumProgressDefinition,
+		},
+	},
+}
+
+func generateProgressDefinitions(schemaList collection.SchemaList, version *semver.Version) []*QueryDefinition {
+	queryDefinitions := make([]*QueryDefinition, 0)
+
+	for _, progressDef := range findVersionDefinitions(progressVersionDefinitions, version) {
+		if def := progressDef.bindSchemaTables(schemaList); def != nil {
+			queryDefinitions = append(queryDefinitions, def)
+		}
+	}
+
+	return queryDefinitions
+}
+
+// progressDataModel is shared by all the pg_stat_progress_* queries, which are normalized
+// to a command, a phase and a number of blocks processed out of a total. The phases processing
+// the indexes of the table report the number of indexes processed out of a total instead.
+type progressDataModel struct {
+	databaseBase
+	schemaBase
+	tableBase
+	Command         *string  `db:"command"          metric_name:"progress.command"           source_type:"attribute"`
+	Phase           *string  `db:"phase"            metric_name:"progress.phase"             source_type:"attribute"`
+	IndexName       *string  `db:"index_name"       metric_name:"progress.indexName"         source_type:"attribute"`
+	Pid             *string  `db:"pid"              metric_name:"progress.pid"               source_type:"attribute"`
+	IsAutovacuum    *int64   `db:"is_autovacuum"    metric_name:"progress.isAutovacuum"      source_type:"gauge"`
+	BlocksTotal     *int64   `db:"blocks_total"     metric_name:"progress.blocksTotal"       source_type:"gauge"`
+	BlocksDone      *int64   `db:"blocks_done"      metric_name:"progress.blocksDone"        source_type:"gauge"`
+	IndexesTotal    *int64   `db:"indexes_total"    metric_name:"progress.indexesTotal"      source_type:"gauge"`
+	IndexesDone     *int64   `db:"indexes_done"     metric_name:"progress.indexesDone"       source_type:"gauge"`
+	PercentComplete *float64 `db:"percent_complete" metric_name:"progress.percentComplete"   source_type:"gauge"`
+	DurationSeconds *float64 `db:"duration_seconds" metric_name:"progress.durationInSeconds" source_type:"gauge"`
+}
+
+// vacuumProgressDefinition reports running VACUUM and autovacuum operations, available from Postgres 9.6.
+// The progress views report every database, so rows are restricted to the connected one so relations can be resolved.
+// The heap is scanned, then the dead tuples are removed from the indexes and from the heap, possibly several times, so
+// progress is that of the phase running. Index progress is not reported before Postgres 17, so the index phases
+// report no percent complete.
+var vacuumProgressDefinition = &QueryDefinition{
+	query: `SELECT -- VACUUMPROGRESSQUERY
+			current_database() as database,
+			n.nspname as schema_name,
+			c.relname as table_name,
+			'VACUUM' as command,
+			p.phase,
+			NULL::text as index_name,
+			p.pid::text as pid,
+			(a.query LIKE 'autovacuum:%')::int as is_autovacuum,
+			p.heap_blks_total as blocks_total,
+			CASE WHEN p.phase = 'vacuuming heap' THEN p.heap_blks_vacuumed ELSE p.heap_blks_scanned END as blocks_done,
+			CASE
+				WHEN p.phase IN ('vacuuming indexes', 'cleaning up indexes') THEN NULL
+				WHEN p.heap_blks_total = 0 THEN 0
+				WHEN p.phase = 'vacuuming heap' THEN 100.0 * p.heap_blks_vacuumed / p.heap_blks_total
+				ELSE 100.0 * p.heap_blks_scanned / p.heap_blks_total
+			END as percent_complete,
+			extract(epoch from now() - a.xact_start)::float as duration_seconds
+		FROM pg_stat_progress_vacuum p
+		JOIN pg_class c ON c.oid = p.relid
+		JOIN pg_namespace n ON n.oid = c.relnamespace
+		LEFT JOIN pg_stat_activity a ON a.pid = p.pid
+		WHERE p.datname = current_database() AND n.nspname::text || '.' || c.relname::text = ANY($1)`,
+
+	dataModels: []progressDataModel{},
+}
+
+// vacuumProgressDefinition170 reports running VACUUM and autovacuum operations from Postgres 17, which added the
+// number of indexes processed, so the index phases report the indexes vacuumed or cleaned up out of their total.
+var vacuumProgressDefinition170 = &QueryDefinition{
+	query: `SELECT -- VACUUMPROGRESSQUERY
+			current_database() as database,
+			n.nspname as schema_name,
+			c.relname as table_name,
+			'VACUUM' as command,
+			p.phase,
+			NULL::text as index_name,
+			p.pid::text as pid,
+			(a.query LIKE 'autovacuum:%')::int as is_autovacuum,
+			p.heap_blks_total as blocks_total,
+			CASE WHEN p.phase = 'vacuuming heap' THEN p.heap_blks_vacuumed ELSE p.heap_blks_scanned END as blocks_done,
+			p.indexes_total,
+			p.indexes_processed as indexes_done,
+			CASE
+				WHEN p.phase IN ('vacuuming indexes', 'cleaning up indexes') THEN
+					CASE WHEN p.indexes_total > 0 THEN 100.0 * p.indexes_processed / p.indexes_total ELSE 0 END
+				WHEN p.heap_blks_total = 0 THEN 0
+				WHEN p.phase = 'vacuuming heap' THEN 100.0 * p.heap_blks_vacuumed / p.heap_blks_total
+				ELSE 100.0 * p.heap_blks_scanned / p.heap_blks_total
+			END as percent_complete,
+			extract(epoch from now() - a.xact_start)::float as duration_seconds
+		FROM pg_stat_progress_vacuum p
+		JOIN pg_class c ON c.oid = p.relid
+		JOIN pg_namespace n ON n.oid = c.relnamespace
+		LEFT JOIN pg_stat_activity a ON a.pid = p.pid
+		WHERE p.datname = current_database() AND n.nspname::text || '.' || c.relname::text = ANY($1)`,
+
+	dataModels: []progressDataModel{},
+}
+
+// analyzeProgressDefinition reports running ANALYZE operations, available from Postgres 13.
+var analyzeProgressDefinition = &QueryDefinition{
+	query: `SELECT -- ANALYZEPROGRESSQUERY
+			current_database() as database,
+			n.nspname as schema_name,
+			c.relname as table_name,
+			'ANALYZE' as command,
+			p.phase,
+			NULL::text as index_name,
+			p.pid::text as pid,
+			(a.query LIKE 'autovacuum:%')::int as is_autovacuum,
+			p.sample_blks_total as blocks_total,
+			p.sample_blks_scanned as blocks_done,
+			CASE WHEN p.sample_blks_total > 0 THEN 100.0 * p.sample_blks_scanned / p.sample_blks_total ELSE 0 END as percent_complete,
+			extract(epoch from now() - a.xact_start)::float as duration_seconds
+		FROM pg_stat_progress_analyze p
+		JOIN pg_class c ON c.oid = p.relid
+		JOIN pg_namespace n ON n.oid = c.relnamespace
+		LEFT JOIN pg_stat_activity a ON a.pid = p.pid
+		WHERE p.datname = current_database() AND n.nspname::text || '.' || c.relname::text = ANY($1)`,
+
+	dataModels: []progressDataModel{},
+}
+
+// createIndexProgressDefinition reports running CREATE INDEX and REINDEX operations, available from Postgres 12.
+var createIndexProgressDefinition = &QueryDefinition{
+	query: `SELECT -- CREATEINDEXPROGRESSQUERY
+			current_database() as database,
+			n.nspname as schema_name,
+			c.relname as table_name,
+			p.command,
+			p.phase,
+			i.relname as index_name,
+			p.pid::text as pid,
+			0 as is_autovacuum,
+			p.blocks_total,
+			p.blocks_done,
+			CASE WHEN p.blocks_total > 0 THEN 100.0 * p.blocks_done / p.blocks_total ELSE 0 END as percent_complete,
+			extract(epoch from now() - a.xact_start)::float as duration_seconds
+		FROM pg_stat_progress_create_index p
+		JOIN pg_class c ON c.oid = p.relid
+		JOIN pg_namespace n ON n.oid = c.relnamespace
+		LEFT JOIN pg_class i ON i.oid = p.index_relid
+		LEFT JOIN pg_stat_activity a ON a.pid = p.pid
+		WHERE p.datname = current_database() AND n.nspname::text || '.' || c.relname::text = ANY($1)`,
+
+	dataModels: []progressDataModel{},
+}
+
+// clusterProgressDefinition reports running CLUSTER and VACUUM FULL operations, available from Postgres 12.
+// Blocks are only counted while the heap is scanned sequentially, and indexes while they are rebuilt, so the
+// other phases report no percent complete.
+var clusterProgressDefinition = &QueryDefinition{
+	query: `SELECT -- CLUSTERPROGRESSQUERY
+			current_database() as database,
+			n.nspname as schema_name,
+			c.relname as table_name,
+			p.command,
+			p.phase,
+			i.relname as index_name,
+			p.pid::text as pid,
+			0 as is_autovacuum,
+			p.heap_blks_total as blocks_total,
+			p.heap_blks_scanned as blocks_done,
+			x.indexes as indexes_total,
+			p.index_rebuild_count as indexes_done,
+			CASE p.phase
+				WHEN 'seq scanning heap' THEN
+					CASE WHEN p.heap_blks_total > 0 THEN 100.0 * p.heap_blks_scanned / p.heap_blks_total ELSE 0 END
+				WHEN 'rebuilding index' THEN
+					CASE WHEN x.indexes > 0 THEN 100.0 * p.index_rebuild_count / x.indexes ELSE 0 END
+			END as percent_complete,
+			extract(epoch from now() - a.xact_start)::float as duration_seconds
+		FROM pg_stat_progress_cluster p
+		JOIN pg_class c ON c.oid = p.relid
+		JOIN pg_namespace n ON n.oid = c.relnamespace
+		LEFT JOIN pg_class i ON i.oid = p.cluster_index_relid
+		LEFT JOIN (SELECT indrelid, count(*) AS indexes FROM pg_index GROUP BY indrelid) x ON x.indrelid = p.relid
+		LEFT JOIN pg_stat_activity a ON a.pid = p.pid
+		WHERE p.datname = current_database() AND n.nspname::text || '.' || c.relname::text = ANY($1)`,
+
+	dataModels: []progressDataModel{},
+}
@@ -0,0 +1,44 @@
+package metrics
+
+import (
+	"testing"
+
+	"github.com/blang/semver/v4"
+	"github.com/lib/pq"
+	"github.com/newrelic/nri-postgresql/src/collection"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_generateProgressDefinitions(t *testing.T) {
+	schemaList := collection.SchemaList{"schema1": collection.TableList{"table1": []string{}}}
+
+	tests := []struct {
+		name          string
+		version       string
+		expectedCount int
+	}{
+		{name: "PostgreSQL 9.5", version: "9.5.0", expectedCount: 0},
+		{name: "PostgreSQL 9.6", version: "9.6.0", expectedCount: 1},
+		{name: "PostgreSQL 12.2", version: "12.2.0", expectedCount: 3},
+		{name: "PostgreSQL 13.0", version: "13.0.0", expectedCount: 4},
+		{name: "PostgreSQL 17.0", version: "17.0.0", expectedCount: 4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			version := semver.MustParse(tt.version)
+			queryDefinitions := generateProgressDefinitions(schemaList, &version)
+			assert.Equal(t, tt.expectedCount, len(queryDefinitions))
+			for _, def := range queryDefinitions {
+				assert.Equal(t, []interface{}{pq.Array([]string{"schema1.table1"})}, def.GetArgs())
+			}
+		})
+	}
+}
+
+func Test_generateProgressDefinitions_NoTables(t *testing.T) {
+	schemaList := collection.SchemaList{"schema1": collection.TableList{}}
+	version := semver.MustParse("13.0.0")
+
+	assert.Empty(t, generateProgressDefinitions(schemaList, &version))
+}
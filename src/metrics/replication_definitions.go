@@ -0,0 +1,146 @@
+package metrics
+
+import (
+	"github.com/blang/semver/v4"
+)
+
+var replicationVersionDefinitions = []VersionDefinition{
+	{
+		minVersion: semver.MustParse("10.0.0"),
+		queryDefinitions: []*QueryDefinition{
+			replicationDefinition100,
+			replicationStandbyDefinition100,
+		},
+	},
+	{
+		minVersion: semver.MustParse("9.6.0"),
+		queryDefinitions: []*QueryDefinition{
+			replicationDefinition92,
+			replicationStandbyDefinition96,
+		},
+	},
+	{
+		minVersion: semver.MustParse("9.2.0"),
+		queryDefinitions: []*QueryDefinition{
+			replicationDefinition92,
+		},
+	},
+}
+
+func generateReplicationDefinitions(version *semver.Version) []*QueryDefinition {
+	if queryDefinitions := findVersionDefinitions(replicationVersionDefinitions, version); queryDefinitions != nil {
+		return queryDefinitions
+	}
+
+	return []*QueryDefinition{}
+}
+
+// replicationDefinition100 reports one row per standby connected to a primary running PostgreSQL 10 or above.
+// Standbys often share their application_name, such as the default walreceiver, so each one is named after its
+// application_name and the address and port it connects from, or its pid when it connects over a Unix socket.
+// The lag intervals were added to pg_stat_replication in version 10, along with the xlog to wal renaming. A cascading
+// standby cannot call pg_current_wal_lsn(), so the lag of its standbys is measured from the WAL it received.
+var replicationDefinition100 = &QueryDefinition{
+	query: `SELECT -- REPLICATION_PRIMARY
+		COALESCE(NULLIF(R.application_name, ''), 'standby') || '@' || COALESCE(host(R.client_addr) || ':' || R.client_port, 'pid_' || R.pid) AS standby_name,
+		R.application_name AS application_name,
+		host(R.client_addr) AS client_address,
+		R.state AS state,
+		R.sync_state AS sync_state,
+		pg_wal_lsn_diff(P.wal_position, R.sent_lsn) AS sent_lag_bytes,
+		pg_wal_lsn_diff(P.wal_position, R.write_lsn) AS write_lag_bytes,
+		pg_wal_lsn_diff(P.wal_position, R.flush_lsn) AS flush_lag_bytes,
+		pg_wal_lsn_diff(P.wal_position, R.replay_lsn) AS replay_lag_bytes,
+		extract(epoch from R.write_lag)::float AS write_lag_seconds,
+		extract(epoch from R.flush_lag)::float AS flush_lag_seconds,
+		extract(epoch from R.replay_lag)::float AS replay_lag_seconds
+		FROM pg_stat_replication R,
+		(SELECT CASE WHEN pg_is_in_recovery() THEN pg_last_wal_receive_lsn() ELSE pg_current_wal_lsn() END AS wal_position) P;`,
+
+	dataModels: []struct {
+		standbyBase
+		ApplicationName  *string  `db:"application_name"   metric_name:"replication.applicationName"   source_type:"attribute"`
+		ClientAddress    *string  `db:"client_address"     metric_name:"replication.clientAddress"     source_type:"attribute"`
+		State            *string  `db:"state"              metric_name:"replication.state"             source_type:"attribute"`
+		SyncState        *string  `db:"sync_state"         metric_name:"replication.syncState"         source_type:"attribute"`
+		SentLagBytes     *float64 `db:"sent_lag_bytes"     metric_name:"replication.sentLagInBytes"    source_type:"gauge"`
+		WriteLagBytes    *float64 `db:"write_lag_bytes"    metric_name:"replication.writeLagInBytes"   source_type:"gauge"`
+		FlushLagBytes    *float64 `db:"flush_lag_bytes"    metric_name:"replication.flushLagInBytes"   source_type:"gauge"`
+		ReplayLagBytes   *float64 `db:"replay_lag_bytes"   metric_name:"replication.replayLagInBytes"  source_type:"gauge"`
+		WriteLagSeconds  *float64 `db:"write_lag_seconds"  metric_name:"replication.writeLagInSeconds"  source_type:"gauge"`
+		FlushLagSeconds  *float64 `db:"flush_lag_seconds"  metric_name:"replication.flushLagInSeconds"  source_type:"gauge"`
+		ReplayLagSeconds *float64 `db:"replay_lag_seconds" metric_name:"replication.replayLagInSeconds" source_type:"gauge"`
+	}{},
+}
+
+// replicationDefinition92 reports one row per standby connected to a primary running PostgreSQL 9.2 to 9.6.
+// Those versions only expose lag as xlog locations, so no lag in seconds is reported. As in replicationDefinition100,
+// standbys are named after their application_name and connection, and the lag of the standbys of a cascading standby is measured from the WAL it received.
+var replicationDefinition92 = &QueryDefinition{
+	query: `SELECT -- REPLICATION_PRIMARY
+		COALESCE(NULLIF(R.application_name, ''), 'standby') || '@' || COALESCE(host(R.client_addr) || ':' || R.client_port, 'pid_' || R.pid) AS standby_name,
+		R.application_name AS application_name,
+		host(R.client_addr) AS client_address,
+		R.state AS state,
+		R.sync_state AS sync_state,
+		pg_xlog_location_diff(P.wal_position, R.sent_location) AS sent_lag_bytes,
+		pg_xlog_location_diff(P.wal_position, R.write_location) AS write_lag_bytes,
+		pg_xlog_location_diff(P.wal_position, R.flush_location) AS flush_lag_bytes,
+		pg_xlog_location_diff(P.wal_position, R.replay_location) AS replay_lag_bytes
+		FROM pg_stat_replication R,
+		(SELECT CASE WHEN pg_is_in_recovery() THEN pg_last_xlog_receive_location() ELSE pg_current_xlog_location() END AS wal_position) P;`,
+
+	dataModels: []struct {
+		standbyBase
+		ApplicationName *string  `db:"application_name" metric_name:"replication.applicationName"  source_type:"attribute"`
+		ClientAddress   *string  `db:"client_address"   metric_name:"replication.clientAddress"    source_type:"attribute"`
+		State           *string  `db:"state"            metric_name:"replication.state"            source_type:"attribute"`
+		SyncState       *string  `db:"sync_state"       metric_name:"replication.syncState"        source_type:"attribute"`
+		SentLagBytes    *float64 `db:"sent_lag_bytes"   metric_name:"replication.sentLagInBytes"   source_type:"gauge"`
+		WriteLagBytes   *float64 `db:"write_lag_bytes"  metric_name:"replication.writeLagInBytes"  source_type:"gauge"`
+		FlushLagBytes   *float64 `db:"flush_lag_bytes"  metric_name:"replication.flushLagInBytes"  source_type:"gauge"`
+		ReplayLagBytes  *float64 `db:"replay_lag_bytes" metric_name:"replication.replayLagInBytes" source_type:"gauge"`
+	}{},
+}
+
+// replicationStandbyDefinition100 reports the state of the WAL receiver when the instance is a standby running
+// PostgreSQL 10 or above. It returns no rows on a primary.
+var replicationStandbyDefinition100 = &QueryDefinition{
+	query: `SELECT -- REPLICATION_STANDBY
+		WR.status AS receiver_status,
+		WR.slot_name AS slot_name,
+		pg_wal_lsn_diff(pg_last_wal_receive_lsn(), pg_last_wal_replay_lsn()) AS replay_lag_bytes,
+		extract(epoch from now() - pg_last_xact_replay_timestamp())::float AS replay_lag_seconds,
+		extract(epoch from WR.last_msg_receipt_time - WR.last_msg_send_time)::float AS last_message_lag_seconds
+		FROM pg_stat_wal_receiver WR
+		WHERE pg_is_in_recovery();`,
+
+	dataModels: []struct {
+		ReceiverStatus        *string  `db:"receiver_status"          metric_name:"replication.receiverStatus"          source_type:"attribute"`
+		SlotName              *string  `db:"slot_name"                metric_name:"replication.slotName"                source_type:"attribute"`
+		ReplayLagBytes        *float64 `db:"replay_lag_bytes"         metric_name:"replication.replayLagInBytes"        source_type:"gauge"`
+		ReplayLagSeconds      *float64 `db:"replay_lag_seconds"       metric_name:"replication.replayLagInSeconds"      source_type:"gauge"`
+		LastMessageLagSeconds *float64 `db:"last_message_lag_seconds" metric_name:"replication.lastMessageLagInSeconds" source_type:"gauge"`
+	}{},
+}
+
+// replicationStandbyDefinition96 is the PostgreSQL 9.6 equivalent of replicationStandbyDefinition100,
+// using the xlog function names.
+var replicationStandbyDefinition96 = &QueryDefinition{
+	query: `SELECT -- REPLICATION_STANDBY
+		WR.status AS receiver_status,
+		WR.slot_name AS slot_name,
+		pg_xlog_location_diff(pg_last_xlog_receive_location(), pg_last_xlog_replay_location()) AS replay_lag_bytes,
+		extract(epoch from now() - pg_last_xact_replay_timestamp())::float AS replay_lag_seconds,
+		extract(epoch from WR.last_msg_receipt_time - WR.last_msg_send_time)::float AS last_message_lag_seconds
+		FROM pg_stat_wal_receiver WR
+		WHERE pg_is_in_recovery();`,
+
+	dataModels: []struct {
+		ReceiverStatus        *string  `db:"receiver_status"          metric_name:"replication.receiverStatus"          source_type:"attribute"`
+		SlotName              *string  `db:"slot_name"                metric_name:"replication.slotName"                source_type:"attribute"`
+		ReplayLagBytes        *float64 `db:"replay_lag_bytes"         metric_name:"replication.replayLagInBytes"        source_type:"gauge"`
+		ReplayLagSeconds      *float64 `db:"replay_lag_seconds"       metric_name:"replication.replayLagInSeconds"      source_type:"gauge"`
+		LastMessageLagSeconds *float64 `db:"last_message_lag_seconds" metric_name:"replication.lastMessageLagInSeconds" source_type:"gauge"`
+	}{},
+}
@@ -0,0 +1,63 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/blang/semver/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_generateReplicationDefinitions(t *testing.T) {
+	tests := []struct {
+		name            string
+		version         string
+		expectedQueries []*QueryDefinition
+	}{
+		{
+			name:            "PostgreSQL 9.1",
+			version:         "9.1.0",
+			expectedQueries: []*QueryDefinition{},
+		},
+		{
+			name:            "PostgreSQL 9.2",
+			version:         "9.2.0",
+			expectedQueries: []*QueryDefinition{replicationDefinition92},
+		},
+		{
+			name:            "PostgreSQL 9.6",
+			version:         "9.6.3",
+			expectedQueries: []*QueryDefinition{replicationDefinition92, replicationStandbyDefinition96},
+		},
+		{
+			name:            "PostgreSQL 10.0",
+			version:         "10.0.0",
+			expectedQueries: []*QueryDefinition{replicationDefinition100, replicationStandbyDefinition100},
+		},
+		{
+			name:            "PostgreSQL 17.2",
+			version:         "17.2.0",
+			expectedQueries: []*QueryDefinition{replicationDefinition100, replicationStandbyDefinition100},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			version := semver.MustParse(tt.version)
+			queryDefinitions := generateReplicationDefinitions(&version)
+			assert.Equal(t, tt.expectedQueries, queryDefinitions)
+		})
+	}
+}
+
+func TestReplicationPrimaryDefinitionsOnCascadingStandby(t *testing.T) {
+	// pg_current_wal_lsn() and pg_current_xlog_location() raise an error during recovery, so they must only be called
+	// when the instance is not a standby
+	for definition, position := range map[*QueryDefinition]string{
+		replicationDefinition100: "CASE WHEN pg_is_in_recovery() THEN pg_last_wal_receive_lsn() ELSE pg_current_wal_lsn() END",
+		replicationDefinition92:  "CASE WHEN pg_is_in_recovery() THEN pg_last_xlog_receive_location() ELSE pg_current_xlog_location() END",
+	} {
+		assert.Contains(t, definition.query, position)
+		assert.Equal(t, 1, strings.Count(definition.query, "pg_current_"))
+	}
+}
@@ -0,0 +1,153 @@
+package metrics
+
+import (
+	"github.com/blang/semver/v4"
+)
+
+var replicationSlotVersionDefinitions = []VersionDefinition{
+	{
+		minVersion: semver.MustParse("14.0.0"),
+		queryDefinitions: []*QueryDefinition{
+			replicationSlotDefinition140,
+		},
+	},
+	{
+		minVersion: semver.MustParse("13.0.0"),
+		queryDefinitions: []*QueryDefinition{
+			replicationSlotDefinition130,
+		},
+	},
+	{
+		minVersion: semver.MustParse("10.0.0"),
+		queryDefinitions: []*QueryDefinition{
+			replicationSlotDefinition100,
+		},
+	},
+	{
+		minVersion: semver.MustParse("9.4.0"),
+		queryDefinitions: []*QueryDefinition{
+			replicationSlotDefinition94,
+		},
+	},
+}
+
+func generateReplicationSlotDefinitions(version *semver.Version) []*QueryDefinition {
+	if queryDefinitions := findVersionDefinitions(replicationSlotVersionDefinitions, version); queryDefinitions != nil {
+		return queryDefinitions
+	}
+
+	return []*QueryDefinition{}
+}
+
+// replicationSlotDefinition140 is the query used to fetch replication slots from Postgres version 14 and above,
+// along with the logical decoding statistics of pg_stat_replication_slots, which are null for physical slots.
+var replicationSlotDefinition140 = &QueryDefinition{
+	query: `SELECT -- REPLICATION_SLOTS
+		S.slot_name,
+		S.slot_type,
+		S.plugin,
+		S.database,
+		S.active::int AS active,
+		pg_wal_lsn_diff(CASE WHEN pg_is_in_recovery() THEN pg_last_wal_receive_lsn() ELSE pg_current_wal_lsn() END, S.restart_lsn) AS retained_wal_bytes,
+		S.wal_status,
+		S.safe_wal_size,
+		SS.spill_txns,
+		SS.spill_count,
+		SS.spill_bytes,
+		SS.stream_txns,
+		SS.stream_count,
+		SS.stream_bytes,
+		SS.total_txns,
+		SS.total_bytes
+		FROM pg_replication_slots S
+		LEFT JOIN pg_stat_replication_slots SS ON SS.slot_name = S.slot_name;`,
+
+	dataModels: []struct {
+		slotBase
+		SlotType         *string  `db:"slot_type"          metric_name:"replicationSlot.slotType"                    source_type:"attribute"`
+		Plugin           *string  `db:"plugin"             metric_name:"replicationSlot.plugin"                      source_type:"attribute"`
+		Database         *string  `db:"database"           metric_name:"replicationSlot.database"                    source_type:"attribute"`
+		Active           *int64   `db:"active"             metric_name:"replicationSlot.active"                      source_type:"gauge"`
+		RetainedWalBytes *float64 `db:"retained_wal_bytes" metric_name:"replicationSlot.retainedWalInBytes"          source_type:"gauge"`
+		WalStatus        *string  `db:"wal_status"         metric_name:"replicationSlot.walStatus"                   source_type:"attribute"`
+		SafeWalSize      *int64   `db:"safe_wal_size"      metric_name:"replicationSlot.safeWalSizeInBytes"          source_type:"gauge"`
+		SpillTxns        *int64   `db:"spill_txns"         metric_name:"replicationSlot.spillTransactionsPerSecond"  source_type:"rate"`
+		SpillCount       *int64   `db:"spill_count"        metric_name:"replicationSlot.spillsPerSecond"             source_type:"rate"`
+		SpillBytes       *int64   `db:"spill_bytes"        metric_name:"replicationSlot.spillBytesPerSecond"         source_type:"rate"`
+		StreamTxns       *int64   `db:"stream_txns"        metric_name:"replicationSlot.streamTransactionsPerSecond" source_type:"rate"`
+		StreamCount      *int64   `db:"stream_count"       metric_name:"replicationSlot.streamsPerSecond"            source_type:"rate"`
+		StreamBytes      *int64   `db:"stream_bytes"       metric_name:"replicationSlot.streamBytesPerSecond"        source_type:"rate"`
+		TotalTxns        *int64   `db:"total_txns"         metric_name:"replicationSlot.totalTransactionsPerSecond"  source_type:"rate"`
+		TotalBytes       *int64   `db:"total_bytes"        metric_name:"replicationSlot.totalBytesPerSecond"         source_type:"rate"`
+	}{},
+}
+
+// replicationSlotDefinition130 is the query used to fetch replication slots from Postgres version 13,
+// which report how much WAL can still be written before the slot is invalidated.
+// Retained WAL is measured against the last received location when the instance is a standby.
+var replicationSlotDefinition130 = &QueryDefinition{
+	query: `SELECT -- REPLICATION_SLOTS
+		S.slot_name,
+		S.slot_type,
+		S.plugin,
+		S.database,
+		S.active::int AS active,
+		pg_wal_lsn_diff(CASE WHEN pg_is_in_recovery() THEN pg_last_wal_receive_lsn() ELSE pg_current_wal_lsn() END, S.restart_lsn) AS retained_wal_bytes,
+		S.wal_status,
+		S.safe_wal_size
+		FROM pg_replication_slots S;`,
+
+	dataModels: []struct {
+		slotBase
+		SlotType         *string  `db:"slot_type"          metric_name:"replicationSlot.slotType"           source_type:"attribute"`
+		Plugin           *string  `db:"plugin"             metric_name:"replicationSlot.plugin"             source_type:"attribute"`
+		Database         *string  `db:"database"           metric_name:"replicationSlot.database"           source_type:"attribute"`
+		Active           *int64   `db:"active"             metric_name:"replicationSlot.active"             source_type:"gauge"`
+		RetainedWalBytes *float64 `db:"retained_wal_bytes" metric_name:"replicationSlot.retainedWalInBytes" source_type:"gauge"`
+		WalStatus        *string  `db:"wal_status"         metric_name:"replicationSlot.walStatus"          source_type:"attribute"`
+		SafeWalSize      *int64   `db:"safe_wal_size"      metric_name:"replicationSlot.safeWalSizeInBytes" source_type:"gauge"`
+	}{},
+}
+
+// replicationSlotDefinition100 is the query used to fetch replication slots from Postgres version 10 to 12.
+var replicationSlotDefinition100 = &QueryDefinition{
+	query: `SELECT -- REPLICATION_SLOTS
+		S.slot_name,
+		S.slot_type,
+		S.plugin,
+		S.database,
+		S.active::int AS active,
+		pg_wal_lsn_diff(CASE WHEN pg_is_in_recovery() THEN pg_last_wal_receive_lsn() ELSE pg_current_wal_lsn() END, S.restart_lsn) AS retained_wal_bytes
+		FROM pg_replication_slots S;`,
+
+	dataModels: []struct {
+		slotBase
+		SlotType         *string  `db:"slot_type"          metric_name:"replicationSlot.slotType"           source_type:"attribute"`
+		Plugin           *string  `db:"plugin"             metric_name:"replicationSlot.plugin"             source_type:"attribute"`
+		Database         *string  `db:"database"           metric_name:"replicationSlot.database"           source_type:"attribute"`
+		Active           *int64   `db:"active"             metric_name:"replicationSlot.active"             source_type:"gauge"`
+		RetainedWalBytes *float64 `db:"retained_wal_bytes" metric_name:"replicationSlot.retainedWalInBytes" source_type:"gauge"`
+	}{},
+}
+
+// replicationSlotDefinition94 is the query used to fetch replication slots from Postgres version 9.4 to 9.6,
+// using the xlog function names.
+var replicationSlotDefinition94 = &QueryDefinition{
+	query: `SELECT -- REPLICATION_SLOTS
+		S.slot_name,
+		S.slot_type,
+		S.plugin,
+		S.database,
+		S.active::int AS active,
+		pg_xlog_location_diff(CASE WHEN pg_is_in_recovery() THEN pg_last_xlog_receive_location() ELSE pg_current_xlog_location() END, S.restart_lsn) AS retained_wal_bytes
+		FROM pg_replication_slots S;`,
+
+	dataModels: []struct {
+		slotBase
+		SlotType         *string  `db:"slot_type"          metric_name:"replicationSlot.slotType"           source_type:"attribute"`
+		Plugin           *string  `db:"plugin"             metric_name:"replicationSlot.plugin"             source_type:"attribute"`
+		Database         *string  `db:"database"           metric_name:"replicationSlot.database"           source_type:"attribute"`
+		Active           *int64   `db:"active"             metric_name:"replicationSlot.active"             source_type:"gauge"`
+		RetainedWalBytes *float64 `db:"retained_wal_bytes" metric_name:"replicationSlot.retainedWalInBytes" source_type:"gauge"`
+	}{},
+}
@@ -0,0 +1,50 @@
+package metrics
+
+import (
+	"testing"
+
+	"github.com/blang/semver/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_generateReplicationSlotDefinitions(t *testing.T) {
+	tests := []struct {
+		name            string
+		version         string
+		expectedQueries []*QueryDefinition
+	}{
+		{
+			name:            "PostgreSQL 9.3",
+			version:         "9.3.0",
+			expectedQueries: []*QueryDefinition{},
+		},
+		{
+			name:            "PostgreSQL 9.4",
+			version:         "9.4.0",
+			expectedQueries: []*QueryDefinition{replicationSlotDefinition94},
+		},
+		{
+			name:            "PostgreSQL 12.4",
+			version:         "12.4.0",
+			expectedQueries: []*QueryDefinition{replicationSlotDefinition100},
+		},
+		{
+			name:            "PostgreSQL 13.0",
+			version:         "13.0.0",
+			expectedQueries: []*QueryDefinition{replicationSlotDefinition130},
+		},
+		{
+			name:            "PostgreSQL 16.1",
+			version:         "16.1.0",
+			expectedQueries: []*QueryDefinition{replicationSlotDefinition140},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			version := semver.MustParse(tt.version)
+			queryDefinitions := generateReplicationSlotDefinitions(&version)
+			assert.Equal(t, tt.expectedQueries, queryDefinitions)
+		})
+	}
+}
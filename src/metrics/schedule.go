@@ -0,0 +1,87 @@
+package metrics
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Schedule decides which collectors run in each cycle of the daemon mode. Collectors given an interval run
+// once it has elapsed since their last run, and the others run every cycle.
+type Schedule struct {
+	cycle     time.Duration
+	intervals map[string]time.Duration
+
+	lock    sync.Mutex
+	now     time.Time
+	lastRun map[string]time.Time
+}
+
+type scheduleKey struct{}
+
+// NewSchedule returns the schedule of collectors run every cycle, except those with an interval
+func NewSchedule(cycle time.Duration, intervals map[string]time.Duration) *Schedule {
+	return &Schedule{
+		cycle:     cycle,
+		intervals: intervals,
+		lastRun:   make(map[string]time.Time),
+	}
+}
+
+// WithSchedule returns a copy of ctx carrying schedule, which decides whether the collectors run with it are due
+func WithSchedule(ctx context.Context, schedule *Schedule) context.Context {
+	return context.WithValue(ctx, scheduleKey{}, schedule)
+}
+
+func scheduleFromContext(ctx context.Context) *Schedule {
+	schedule, _ := ctx.Value(scheduleKey{}).(*Schedule)
+	return schedule
+}
+
+// StartCycle starts a collection cycle at now, the time compared to the last run of each collector
+func (s *Schedule) StartCycle(now time.Time) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.now = now
+}
+
+// due returns whether the named collector runs in the current cycle. As cycles do not start exactly one cycle
+// apart, an interval is considered elapsed up to half a cycle early.
+func (s *Schedule) due(name string) bool {
+	interval, ok := s.intervals[name]
+	if !ok {
+		return true
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	last, ok := s.lastRun[name]
+	return !ok || s.now.Sub(last) >= interval-s.cycle/2
+}
+
+// ran records that the named collector ran in the current cycle
+func (s *Schedule) ran(name string) {
+	if _, ok := s.intervals[name]; !ok {
+		return
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.lastRun[name] = s.now
+}
+
+// collectorDue returns whether the named collector runs with ctx. Without a schedule every collector runs.
+func collectorDue(ctx context.Context, name string) bool {
+	if schedule := scheduleFromContext(ctx); schedule != nil {
+		return schedule.due(name)
+	}
+	return true
+}
+
+// recordCollectorRun records in the schedule carried by ctx, if any, that the named collector ran, so that
+// it is not due again before its interval elapses
+func recordCollectorRun(ctx context.Context, name string) {
+	if schedule := scheduleFromContext(ctx); schedule != nil {
+		schedule.ran(name)
+	}
+}
@@ -0,0 +1,74 @@
+package metrics
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSchedule_Due(t *testing.T) {
+	schedule := NewSchedule(15*time.Second, map[string]time.Duration{"bloat": time.Minute})
+	start := time.Now()
+
+	var runs []string
+	for cycle := 0; cycle < 9; cycle++ {
+		// cycles start a little late, which must not delay the collectors with an interval
+		schedule.StartCycle(start.Add(time.Duration(cycle)*15*time.Second - time.Duration(cycle%2)*time.Second))
+		for _, name := range []string{"bloat", "instance"} {
+			if schedule.due(name) {
+				schedule.ran(name)
+				runs = append(runs, name)
+			}
+		}
+	}
+
+	bloatRuns, instanceRuns := 0, 0
+	for _, run := range runs {
+		if run == "bloat" {
+			bloatRuns++
+		} else {
+			instanceRuns++
+		}
+	}
+	assert.Equal(t, 3, bloatRuns)
+	assert.Equal(t, 9, instanceRuns)
+}
+
+func TestRunCollector_Schedule(t *testing.T) {
+	telemetry := NewCollectionTelemetry()
+	schedule := NewSchedule(time.Second, map[string]time.Duration{"table": time.Hour})
+	schedule.StartCycle(time.Now())
+	ctx := WithSchedule(WithTelemetry(context.Background(), telemetry), schedule)
+
+	runs := 0
+	RunCollector(ctx, "table", func(context.Context) { runs++ })
+	RunCollector(ctx, "table", func(context.Context) { runs++ })
+	RunCollector(ctx, "index", func(context.Context) { runs++ })
+	RunCollector(ctx, "index", func(context.Context) { runs++ })
+
+	assert.Equal(t, 3, runs)
+	// collectors that are not due are not reported
+	require.Len(t, telemetry.collectors, 3)
+	assert.True(t, collectorDue(context.Background(), "table"))
+}
+
+func TestRunCollector_ScheduleSkipped(t *testing.T) {
+	schedule := NewSchedule(time.Second, map[string]time.Duration{"table": time.Hour})
+	schedule.StartCycle(time.Now())
+	ctx := WithSchedule(context.Background(), schedule)
+
+	// a collector skipped by the collection deadline runs in the next cycle
+	expired, cancel := context.WithCancel(ctx)
+	cancel()
+	runs := 0
+	RunCollector(expired, "table", func(context.Context) { runs++ })
+	assert.Equal(t, 0, runs)
+
+	schedule.StartCycle(time.Now().Add(time.Second))
+	RunCollector(ctx, "table", func(context.Context) { runs++ })
+	RunCollector(ctx, "table", func(context.Context) { runs++ })
+	assert.Equal(t, 1, runs)
+}
@@ -0,0 +1,99 @@
+package metrics
+
+import (
+	"reflect"
+
+	"github.com/newrelic/infra-integrations-sdk/v3/data/metric"
+	"github.com/newrelic/infra-integrations-sdk/v3/log"
+)
+
+// statsResetAttribute is set on samples whose rate metrics were dropped because the underlying counters were reset
+const statsResetAttribute = "statsReset"
+
+// marshalCounterMetrics marshals row into metricSet like MarshalMetrics, and then drops the rate metrics of the row
+// when its counters were reset since the previous run. A reset is detected from a change of the stats_reset time
+// reported by the row, or from a rate going negative after a restart. The stored values are already re-baselined
+// by the marshalling, so rates are reported again from the next run on.
+func marshalCounterMetrics(metricSet *metric.Set, row interface{}) error {
+	if err := metricSet.MarshalMetrics(row); err != nil {
+		return err
+	}
+
+	rateNames := rateMetricNames(reflect.TypeOf(row))
+	if len(rateNames) == 0 {
+		return nil
+	}
+
+	reset := false
+	if statsReset, ok := getStatsReset(row); ok {
+		// the previous stats_reset is kept in the metric store, under a name unique to the row within the sample
+		trackingName := rateNames[0] + ".statsReset"
+		if err := metricSet.SetMetric(trackingName, statsReset, metric.DELTA); err != nil {
+			log.Debug("Could not compare stats_reset for %s: %s", trackingName, err.Error())
+		} else if delta, ok := metricSet.Metrics[trackingName].(float64); ok && delta != 0 {
+			reset = true
+		}
+		delete(metricSet.Metrics, trackingName)
+	}
+
+	for _, name := range rateNames {
+		if value, ok := metricSet.Metrics[name].(float64); ok && value < 0 {
+			reset = true
+		}
+	}
+
+	if !reset {
+		return nil
+	}
+
+	for _, name := range rateNames {
+		delete(metricSet.Metrics, name)
+	}
+	return metricSet.SetMetric(statsResetAttribute, "true", metric.ATTRIBUTE)
+}
+
+// rateMetricNames returns the names of the rate metrics of a data model type, including embedded structs
+func rateMetricNames(t reflect.Type) []string {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil
+	}
+
+	names := make([]string, 0)
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.Anonymous {
+			names = append(names, rateMetricNames(field.Type)...)
+			continue
+		}
+		if field.Tag.Get("source_type") == "rate" {
+			names = append(names, field.Tag.Get("metric_name"))
+		}
+	}
+
+	return names
+}
+
+// getStatsReset returns the stats_reset time of a row implementing StatsResetModeler, or of a data model built
+// at run time with a stats_reset column
+func getStatsReset(row interface{}) (float64, bool) {
+	if modeler, ok := row.(StatsResetModeler); ok {
+		return modeler.GetStatsReset()
+	}
+
+	v := reflect.Indirect(reflect.ValueOf(row))
+	if v.Kind() != reflect.Struct {
+		return 0, false
+	}
+	for i := 0; i < v.NumField(); i++ {
+		if v.Type().Field(i).Tag.Get("db") != "stats_reset" {
+			continue
+		}
+		if value, ok := v.Field(i).Interface().(*float64); ok && value != nil {
+			return *value, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,78 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/newrelic/infra-integrations-sdk/v3/data/attribute"
+	"github.com/newrelic/infra-integrations-sdk/v3/integration"
+	"github.com/newrelic/infra-integrations-sdk/v3/persist"
+	"github.com/stretchr/testify/assert"
+)
+
+type statsResetTestModel struct {
+	statsResetBase
+	Commits *int64 `db:"commits" metric_name:"db.commitsPerSecond" source_type:"rate"`
+	Size    *int64 `db:"size"    metric_name:"db.sizeInBytes"       source_type:"gauge"`
+}
+
+func newStatsResetTestModel(statsReset float64, commits, size int64) statsResetTestModel {
+	return statsResetTestModel{
+		statsResetBase: statsResetBase{StatsReset: &statsReset},
+		Commits:        &commits,
+		Size:           &size,
+	}
+}
+
+func Test_marshalCounterMetrics(t *testing.T) {
+	testCases := []struct {
+		name          string
+		second        statsResetTestModel
+		expectedReset bool
+	}{
+		{
+			name:          "counters increasing",
+			second:        newStatsResetTestModel(1000, 200, 20),
+			expectedReset: false,
+		},
+		{
+			name:          "stats_reset changed",
+			second:        newStatsResetTestModel(2000, 150, 20),
+			expectedReset: true,
+		},
+		{
+			name:          "counters went backwards without stats_reset change",
+			second:        newStatsResetTestModel(1000, 50, 20),
+			expectedReset: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			now := int64(100)
+			persist.SetNow(func() time.Time { return time.Unix(now, 0) })
+			defer persist.SetNow(time.Now)
+
+			testIntegration, _ := integration.New("test", "test", integration.InMemoryStore())
+			entity, _ := testIntegration.Entity("testDB", "pg-database")
+
+			first := entity.NewMetricSet("PostgresqlDatabaseSample", attribute.Attribute{Key: "displayName", Value: "testDB"})
+			assert.NoError(t, marshalCounterMetrics(first, newStatsResetTestModel(1000, 100, 10)))
+			assert.Equal(t, float64(0), first.Metrics["db.commitsPerSecond"])
+
+			now = 110
+			second := entity.NewMetricSet("PostgresqlDatabaseSample", attribute.Attribute{Key: "displayName", Value: "testDB"})
+			assert.NoError(t, marshalCounterMetrics(second, tc.second))
+
+			assert.Equal(t, float64(20), second.Metrics["db.sizeInBytes"])
+			assert.NotContains(t, second.Metrics, "db.commitsPerSecond.statsReset")
+			if tc.expectedReset {
+				assert.NotContains(t, second.Metrics, "db.commitsPerSecond")
+				assert.Equal(t, "true", second.Metrics[statsResetAttribute])
+			} else {
+				assert.Equal(t, float64(10), second.Metrics["db.commitsPerSecond"])
+				assert.NotContains(t, second.Metrics, statsResetAttribute)
+			}
+		})
+	}
+}
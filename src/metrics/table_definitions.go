@@ -5,29 +5,43 @@ import (
 	"github.com/newrelic/nri-postgresql/src/collection"
 )
 
-func generateTableDefinitions(schemaList collection.SchemaList, version *semver.Version, collectBloat bool) []*QueryDefinition {
+func generateTableDefinitions(schemaList collection.SchemaList, version *semver.Version) []*QueryDefinition {
 	queryDefinitions := make([]*QueryDefinition, 0)
 
-	if collectBloat {
-		v12 := semver.MustParse("12.0.0")
-		if version.GTE(v12) {
-			if def := tableBloatDefinitionPostV12.insertSchemaTables(schemaList); def != nil {
-				queryDefinitions = append(queryDefinitions, def)
-			}
-		} else {
-			if def := tableBloatDefinition.insertSchemaTables(schemaList); def != nil {
-				queryDefinitions = append(queryDefinitions, def)
-			}
+	if def := tableDefinition.bindSchemaTables(schemaList); def != nil {
+		queryDefinitions = append(queryDefinitions, def)
+	}
+
+	v95 := semver.MustParse("9.5.0")
+	if version.GTE(v95) {
+		if def := tableMultixactDefinition.bindSchemaTables(schemaList); def != nil {
+			queryDefinitions = append(queryDefinitions, def)
 		}
 	}
 
-	if def := tableDefinition.insertSchemaTables(schemaList); def != nil {
-		queryDefinitions = append(queryDefinitions, def)
+	return queryDefinitions
+}
+
+// generateTableBloatDefinitions returns the bloat definitions of the tables, collected by the bloat collector
+func generateTableBloatDefinitions(schemaList collection.SchemaList, version *semver.Version) []*QueryDefinition {
+	queryDefinitions := make([]*QueryDefinition, 0)
+
+	v12 := semver.MustParse("12.0.0")
+	if version.GTE(v12) {
+		if def := tableBloatDefinitionPostV12.bindSchemaTables(schemaList); def != nil {
+			queryDefinitions = append(queryDefinitions, def)
+		}
+	} else {
+		if def := tableBloatDefinition.bindSchemaTables(schemaList); def != nil {
+			queryDefinitions = append(queryDefinitions, def)
+		}
 	}
 
 	return queryDefinitions
 }
 
+// tableDefinition reports the XID age of a table from the oldest of its heap and its TOAST relation, which
+// autovacuum freezes separately.
 var tableDefinition = &QueryDefinition{
 	query: `SELECT -- TABLEQUERY
 			current_database() as database,
@@ -51,7 +65,10 @@ var tableDefinition = &QueryDefinition{
 			n_tup_upd, -- table.rowsUpdatedPerSecond
 			n_tup_del, -- table.rowsDeletedPerSecond
 			n_live_tup, -- table.liveRows
-			n_dead_tup -- table.deadRows
+			n_dead_tup, -- table.deadRows
+			GREATEST(age(c.relfrozenxid), age(toast.relfrozenxid)) as xid_age, -- table.wraparound.xidAge
+			GREATEST(age(c.relfrozenxid), age(toast.relfrozenxid))::float * 100 / current_setting('autovacuum_freeze_max_age')::float as xid_percent_towards_freeze_max_age, -- table.wraparound.xidPercentTowardsFreezeMaxAge
+			GREATEST(age(c.relfrozenxid), age(toast.relfrozenxid))::float * 100 / 2147483648 as xid_percent_towards_wraparound -- table.wraparound.xidPercentTowardsWraparound
 		FROM pg_statio_user_tables as statio
 		JOIN pg_stat_user_tables as stat
 			ON stat.relid=statio.relid
@@ -59,31 +76,59 @@ var tableDefinition = &QueryDefinition{
 			ON c.relname=stat.relname
 		JOIN pg_namespace n
     		ON c.relnamespace = n.oid
-		WHERE n.nspname = stat.schemaname AND stat.schemaname::text || '.' || stat.relname::text in (%SCHEMA_TABLES%)`,
+		LEFT JOIN pg_class toast
+			ON toast.oid = c.reltoastrelid
+		WHERE n.nspname = stat.schemaname AND stat.schemaname::text || '.' || stat.relname::text = ANY($1)`,
+
+	dataModels: []struct {
+		databaseBase
+		schemaBase
+		tableBase
+		TotalSize                *int64   `db:"pg_total_relation_size"             metric_name:"table.totalSizeInBytes"                         source_type:"gauge"`
+		IndexSize                *int64   `db:"pg_indexes_size"                    metric_name:"table.indexSizeInBytes"                         source_type:"gauge"`
+		LiveRows                 *int64   `db:"n_live_tup"                         metric_name:"table.liveRows"                                 source_type:"gauge"`
+		DeadRows                 *int64   `db:"n_dead_tup"                         metric_name:"table.deadRows"                                 source_type:"gauge"`
+		IndexBlocksReadPerSecond *float32 `db:"idx_blks_read"                      metric_name:"table.indexBlocksReadPerSecond"                 source_type:"rate"`
+		IndexBlocksHitPerSecond  *float32 `db:"idx_blks_hit"                       metric_name:"table.indexBlocksHitPerSecond"                  source_type:"rate"`
+		ToastBlocksReadPerSecond *float32 `db:"toast_blks_read"                    metric_name:"table.indexToastBlocksReadPerSecond"            source_type:"rate"`
+		ToastBlocksHitPerSecond  *float32 `db:"toast_blks_hit"                     metric_name:"table.indexToastBlocksHitPerSecond"             source_type:"rate"`
+		LastVacuum               *int64   `db:"last_vacuum"                        metric_name:"table.lastVacuum"                               source_type:"gauge"`
+		LastAutoVacuum           *int64   `db:"last_autovacuum"                    metric_name:"table.lastAutoVacuum"                           source_type:"gauge"`
+		LastAnalyze              *int64   `db:"last_analyze"                       metric_name:"table.lastAnalyze"                              source_type:"gauge"`
+		LastAutoAnalyze          *int64   `db:"last_autoanalyze"                   metric_name:"table.lastAutoAnalyze"                          source_type:"gauge"`
+		SeqScans                 *float32 `db:"seq_scan"                           metric_name:"table.sequentialScansPerSecond"                 source_type:"rate"`
+		SeqReads                 *float32 `db:"seq_tup_read"                       metric_name:"table.sequentialScanRowsFetchedPerSecond"       source_type:"rate"`
+		IndexScans               *float32 `db:"idx_scan"                           metric_name:"table.indexScansPerSecond"                      source_type:"rate"`
+		IndexReads               *float32 `db:"idx_tup_fetch"                      metric_name:"table.indexScanRowsFetchedPerSecond"            source_type:"rate"`
+		RowsInserted             *float32 `db:"n_tup_ins"                          metric_name:"table.rowsInsertedPerSecond"                    source_type:"rate"`
+		RowsUpdated              *float32 `db:"n_tup_upd"                          metric_name:"table.rowsUpdatedPerSecond"                     source_type:"rate"`
+		RowsDeleted              *float32 `db:"n_tup_del"                          metric_name:"table.rowsDeletedPerSecond"                     source_type:"rate"`
+		XidAge                   *int64   `db:"xid_age"                            metric_name:"table.wraparound.xidAge"                        source_type:"gauge"`
+		XidPercentFreezeMaxAge   *float64 `db:"xid_percent_towards_freeze_max_age" metric_name:"table.wraparound.xidPercentTowardsFreezeMaxAge" source_type:"gauge"`
+		XidPercentWraparound     *float64 `db:"xid_percent_towards_wraparound"     metric_name:"table.wraparound.xidPercentTowardsWraparound"   source_type:"gauge"`
+	}{},
+}
+
+// tableMultixactDefinition is the query used to fetch per-table multixact wraparound metrics from Postgres
+// version 9.5 and above, where mxid_age was introduced.
+var tableMultixactDefinition = &QueryDefinition{
+	query: `SELECT -- TABLEMULTIXACTQUERY
+			current_database() as database,
+			n.nspname as schema_name,
+			c.relname as table_name,
+			mxid_age(c.relminmxid) as multixact_age,
+			mxid_age(c.relminmxid)::float * 100 / current_setting('autovacuum_multixact_freeze_max_age')::float as multixact_percent_towards_freeze_max_age
+		FROM pg_class c
+		JOIN pg_namespace n
+			ON c.relnamespace = n.oid
+		WHERE c.relkind = 'r' AND n.nspname::text || '.' || c.relname::text = ANY($1)`,
 
 	dataModels: []struct {
 		databaseBase
 		schemaBase
 		tableBase
-		TotalSize                *int64   `db:"pg_total_relation_size" metric_name:"table.totalSizeInBytes"                   source_type:"gauge"`
-		IndexSize                *int64   `db:"pg_indexes_size"        metric_name:"table.indexSizeInBytes"                   source_type:"gauge"`
-		LiveRows                 *int64   `db:"n_live_tup"             metric_name:"table.liveRows"                           source_type:"gauge"`
-		DeadRows                 *int64   `db:"n_dead_tup"             metric_name:"table.deadRows"                           source_type:"gauge"`
-		IndexBlocksReadPerSecond *float32 `db:"idx_blks_read"          metric_name:"table.indexBlocksReadPerSecond"           source_type:"rate"`
-		IndexBlocksHitPerSecond  *float32 `db:"idx_blks_hit"           metric_name:"table.indexBlocksHitPerSecond"            source_type:"rate"`
-		ToastBlocksReadPerSecond *float32 `db:"toast_blks_read"        metric_name:"table.indexToastBlocksReadPerSecond"      source_type:"rate"`
-		ToastBlocksHitPerSecond  *float32 `db:"toast_blks_hit"         metric_name:"table.indexToastBlocksHitPerSecond"       source_type:"rate"`
-		LastVacuum               *int64   `db:"last_vacuum"            metric_name:"table.lastVacuum"                         source_type:"gauge"`
-		LastAutoVacuum           *int64   `db:"last_autovacuum"        metric_name:"table.lastAutoVacuum"                     source_type:"gauge"`
-		LastAnalyze              *int64   `db:"last_analyze"           metric_name:"table.lastAnalyze"                        source_type:"gauge"`
-		LastAutoAnalyze          *int64   `db:"last_autoanalyze"       metric_name:"table.lastAutoAnalyze"                    source_type:"gauge"`
-		SeqScans                 *float32 `db:"seq_scan"               metric_name:"table.sequentialScansPerSecond"           source_type:"rate"`
-		SeqReads                 *float32 `db:"seq_tup_read"           metric_name:"table.sequentialScanRowsFetchedPerSecond" source_type:"rate"`
-		IndexScans               *float32 `db:"idx_scan"               metric_name:"table.indexScansPerSecond"                source_type:"rate"`
-		IndexReads               *float32 `db:"idx_tup_fetch"          metric_name:"table.indexScanRowsFetchedPerSecond"      source_type:"rate"`
-		RowsInserted             *float32 `db:"n_tup_ins"              metric_name:"table.rowsInsertedPerSecond"              source_type:"rate"`
-		RowsUpdated              *float32 `db:"n_tup_upd"              metric_name:"table.rowsUpdatedPerSecond"               source_type:"rate"`
-		RowsDeleted              *float32 `db:"n_tup_del"              metric_name:"table.rowsDeletedPerSecond"               source_type:"rate"`
+		MultixactAge                 *int64   `db:"multixact_age"                            metric_name:"table.wraparound.multixactAge"                        source_type:"gauge"`
+		MultixactPercentFreezeMaxAge *float64 `db:"multixact_percent_towards_freeze_max_age" metric_name:"table.wraparound.multixactPercentTowardsFreezeMaxAge" source_type:"gauge"`
 	}{},
 }
 
@@ -140,7 +185,7 @@ var tableBloatDefinition = &QueryDefinition{
 			) AS s2
 		) AS s3
 		where not is_na
-		and schemaname || '.' || tblname in (%SCHEMA_TABLES%)`,
+		and schemaname || '.' || tblname = ANY($1)`,
 
 	dataModels: []struct {
 		databaseBase
@@ -205,7 +250,7 @@ var tableBloatDefinitionPostV12 = &QueryDefinition{
 			) AS s2
 		) AS s3
 		where not is_na
-		and schemaname || '.' || tblname in (%SCHEMA_TABLES%)`,
+		and schemaname || '.' || tblname = ANY($1)`,
 
 	dataModels: []struct {
 		databaseBase
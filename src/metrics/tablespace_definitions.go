@@ -0,0 +1,86 @@
+package metrics
+
+import (
+	"github.com/blang/semver/v4"
+)
+
+var tablespaceVersionDefinitions = []VersionDefinition{
+	{
+		minVersion: semver.MustParse("10.0.0"),
+		queryDefinitions: []*QueryDefinition{
+			tablespaceDefinition100,
+		},
+	},
+	{
+		minVersion: semver.MustParse("9.2.0"),
+		queryDefinitions: []*QueryDefinition{
+			tablespaceDefinition92,
+		},
+	},
+	{
+		minVersion: semver.MustParse("0.0.0"),
+		queryDefinitions: []*QueryDefinition{
+			tablespaceDefinitionUnder92,
+		},
+	},
+}
+
+func generateTablespaceDefinitions(version *semver.Version) []*QueryDefinition {
+	if queryDefinitions := findVersionDefinitions(tablespaceVersionDefinitions, version); queryDefinitions != nil {
+		return queryDefinitions
+	}
+
+	return []*QueryDefinition{}
+}
+
+// tablespaceDefinition100 reports the size and location of each tablespace on PostgreSQL 10 and above.
+// pg_tablespace_size raises an error without CREATE privilege on the tablespace or membership in
+// pg_read_all_stats, so the size is left empty in that case instead of failing the whole query.
+var tablespaceDefinition100 = &QueryDefinition{
+	query: `SELECT -- TABLESPACES
+		T.spcname AS tablespace_name,
+		pg_tablespace_location(T.oid) AS location,
+		pg_get_userbyid(T.spcowner) AS owner,
+		CASE WHEN has_tablespace_privilege(T.oid, 'CREATE') OR pg_has_role('pg_read_all_stats', 'MEMBER')
+			THEN pg_tablespace_size(T.oid) END AS size_in_bytes,
+		(SELECT count(*) FROM pg_database D WHERE D.dattablespace = T.oid) AS database_count
+		FROM pg_tablespace T;`,
+
+	dataModels: []tablespaceDataModel{},
+}
+
+// tablespaceDefinition92 reports the size and location of each tablespace on PostgreSQL 9.2 to 9.6,
+// where only CREATE privilege on the tablespace allows reading its size.
+var tablespaceDefinition92 = &QueryDefinition{
+	query: `SELECT -- TABLESPACES
+		T.spcname AS tablespace_name,
+		pg_tablespace_location(T.oid) AS location,
+		pg_get_userbyid(T.spcowner) AS owner,
+		CASE WHEN has_tablespace_privilege(T.oid, 'CREATE') THEN pg_tablespace_size(T.oid) END AS size_in_bytes,
+		(SELECT count(*) FROM pg_database D WHERE D.dattablespace = T.oid) AS database_count
+		FROM pg_tablespace T;`,
+
+	dataModels: []tablespaceDataModel{},
+}
+
+// tablespaceDefinitionUnder92 reads the location from the spclocation column, which was replaced
+// by pg_tablespace_location in version 9.2.
+var tablespaceDefinitionUnder92 = &QueryDefinition{
+	query: `SELECT -- TABLESPACES
+		T.spcname AS tablespace_name,
+		T.spclocation AS location,
+		pg_get_userbyid(T.spcowner) AS owner,
+		CASE WHEN has_tablespace_privilege(T.oid, 'CREATE') THEN pg_tablespace_size(T.oid) END AS size_in_bytes,
+		(SELECT count(*) FROM pg_database D WHERE D.dattablespace = T.oid) AS database_count
+		FROM pg_tablespace T;`,
+
+	dataModels: []tablespaceDataModel{},
+}
+
+type tablespaceDataModel struct {
+	tablespaceBase
+	Location      *string `db:"location"       metric_name:"tablespace.location"    source_type:"attribute"`
+	Owner         *string `db:"owner"          metric_name:"tablespace.owner"       source_type:"attribute"`
+	SizeInBytes   *int64  `db:"size_in_bytes"  metric_name:"tablespace.sizeInBytes" source_type:"gauge"`
+	DatabaseCount *int64  `db:"database_count" metric_name:"tablespace.databases"   source_type:"gauge"`
+}
@@ -0,0 +1,40 @@
+package metrics
+
+import (
+	"testing"
+
+	"github.com/blang/semver/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_generateTablespaceDefinitions(t *testing.T) {
+	tests := []struct {
+		name            string
+		version         string
+		expectedQueries []*QueryDefinition
+	}{
+		{
+			name:            "PostgreSQL 9.1",
+			version:         "9.1.0",
+			expectedQueries: []*QueryDefinition{tablespaceDefinitionUnder92},
+		},
+		{
+			name:            "PostgreSQL 9.6",
+			version:         "9.6.3",
+			expectedQueries: []*QueryDefinition{tablespaceDefinition92},
+		},
+		{
+			name:            "PostgreSQL 17.2",
+			version:         "17.2.0",
+			expectedQueries: []*QueryDefinition{tablespaceDefinition100},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			version := semver.MustParse(tt.version)
+			queryDefinitions := generateTablespaceDefinitions(&version)
+			assert.Equal(t, tt.expectedQueries, queryDefinitions)
+		})
+	}
+}
@@ -0,0 +1,230 @@
+package metrics
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/newrelic/infra-integrations-sdk/v3/data/attribute"
+	"github.com/newrelic/infra-integrations-sdk/v3/data/metric"
+	"github.com/newrelic/infra-integrations-sdk/v3/integration"
+	"github.com/newrelic/infra-integrations-sdk/v3/log"
+	"github.com/newrelic/nri-postgresql/src/connection"
+)
+
+// Collector statuses reported by PostgresqlIntegrationSample
+const (
+	collectorStatusOK      = "ok"
+	collectorStatusFailed  = "failed"
+	collectorStatusSkipped = "skipped"
+)
+
+// CollectionTelemetry records how each collector of a run went, so that it can be reported as
+// PostgresqlIntegrationSample and broken monitoring can be told apart from a healthy database
+type CollectionTelemetry struct {
+	start      time.Time
+	lock       sync.Mutex
+	collectors []*collectorTelemetry
+}
+
+// collectorTelemetry records a collector run. It observes the queries run with its context, and may be
+// updated concurrently by collectors working on several databases at once.
+type collectorTelemetry struct {
+	name       string
+	lock       sync.Mutex
+	duration   time.Duration
+	rows       int
+	errors     int
+	lastError  string
+	skipReason string
+}
+
+type telemetryKey struct{}
+
+type statementTimeoutsKey struct{}
+
+// NewCollectionTelemetry starts the telemetry of a collection run
+func NewCollectionTelemetry() *CollectionTelemetry {
+	return &CollectionTelemetry{start: time.Now()}
+}
+
+// WithTelemetry returns a copy of ctx carrying telemetry, which records the collectors run with it
+func WithTelemetry(ctx context.Context, telemetry *CollectionTelemetry) context.Context {
+	return context.WithValue(ctx, telemetryKey{}, telemetry)
+}
+
+func telemetryFromContext(ctx context.Context) *CollectionTelemetry {
+	telemetry, _ := ctx.Value(telemetryKey{}).(*CollectionTelemetry)
+	return telemetry
+}
+
+// WithStatementTimeouts returns a copy of ctx carrying the statement timeouts of the collectors run with it, which
+// override the statement_timeout of their queries. Collectors without one keep that of the collector running them.
+func WithStatementTimeouts(ctx context.Context, timeouts map[string]time.Duration) context.Context {
+	return context.WithValue(ctx, statementTimeoutsKey{}, timeouts)
+}
+
+func statementTimeoutsFromContext(ctx context.Context) map[string]time.Duration {
+	timeouts, _ := ctx.Value(statementTimeoutsKey{}).(map[string]time.Duration)
+	return timeouts
+}
+
+func (t *CollectionTelemetry) newCollector(name string) *collectorTelemetry {
+	c := &collectorTelemetry{name: name}
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.collectors = append(t.collectors, c)
+	return c
+}
+
+// ObserveQuery records the rows returned and the error of a query run by the collector
+func (c *collectorTelemetry) ObserveQuery(rows int, err error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.rows += rows
+	if err != nil {
+		c.errors++
+		c.lastError = err.Error()
+	}
+}
+
+func (c *collectorTelemetry) skip(reason string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.skipReason = reason
+}
+
+func (c *collectorTelemetry) status() string {
+	switch {
+	case c.skipReason != "":
+		return collectorStatusSkipped
+	case c.errors > 0:
+		return collectorStatusFailed
+	default:
+		return collectorStatusOK
+	}
+}
+
+func collectorFromContext(ctx context.Context) *collectorTelemetry {
+	c, _ := connection.QueryObserverFromContext(ctx).(*collectorTelemetry)
+	return c
+}
+
+// RunCollector runs collect as the named collector with a context recording its duration, the rows returned
+// and the errors of its queries in the telemetry carried by ctx. The collector is skipped if ctx is already done,
+// and does not run at all when the schedule carried by ctx says it is not due. Only a collector that runs counts
+// as a run of the schedule. Its queries run with the statement
+// timeout of the collector carried by ctx, if any.
+func RunCollector(ctx context.Context, name string, collect func(ctx context.Context)) {
+	if !collectorDue(ctx, name) {
+		return
+	}
+	if timeout, ok := statementTimeoutsFromContext(ctx)[name]; ok {
+		ctx = connection.WithStatementTimeout(ctx, timeout)
+	}
+
+	var c *collectorTelemetry
+	if telemetry := telemetryFromContext(ctx); telemetry != nil {
+		c = telemetry.newCollector(name)
+		ctx = connection.WithQueryObserver(ctx, c)
+	}
+
+	if err := ctx.Err(); err != nil {
+		log.Warn("Skipping %s metrics collection: %s", name, err.Error())
+		SkipCollector(ctx, err.Error())
+		return
+	}
+
+	start := time.Now()
+	collect(ctx)
+	recordCollectorRun(ctx, name)
+	if c != nil {
+		c.lock.Lock()
+		c.duration = time.Since(start)
+		c.lock.Unlock()
+	}
+}
+
+// SkipCollector records why the collector running with ctx did not collect anything,
+// such as a missing extension or an unsupported version
+func SkipCollector(ctx context.Context, reason string) {
+	if c := collectorFromContext(ctx); c != nil {
+		c.skip(reason)
+	}
+}
+
+// recordCollectorError records an error of the collector running with ctx that did not come from a query,
+// such as a failure to connect or to marshal a row
+func recordCollectorError(ctx context.Context, err error) {
+	if c := collectorFromContext(ctx); c != nil {
+		c.ObserveQuery(0, err)
+	}
+}
+
+// Populate reports a PostgresqlIntegrationSample on the instance entity for every collector run,
+// along with a sample for the whole run whose collector attribute is "run"
+func (t *CollectionTelemetry) Populate(instanceEntity *integration.Entity) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	var rows, errors, failed, skipped int
+	for _, c := range t.collectors {
+		c.lock.Lock()
+		metricSet := newIntegrationMetricSet(instanceEntity, c.name)
+		status := c.status()
+		setIntegrationMetrics(metricSet, map[string]interface{}{
+			"collector.status":                 status,
+			"collector.durationInMilliseconds": float64(c.duration) / float64(time.Millisecond),
+			"collector.rowsReturned":           c.rows,
+			"collector.errors":                 c.errors,
+			"collector.lastError":              c.lastError,
+			"collector.skipReason":             c.skipReason,
+		})
+
+		rows += c.rows
+		errors += c.errors
+		switch status {
+		case collectorStatusFailed:
+			failed++
+		case collectorStatusSkipped:
+			skipped++
+		}
+		c.lock.Unlock()
+	}
+
+	setIntegrationMetrics(newIntegrationMetricSet(instanceEntity, "run"), map[string]interface{}{
+		"collector.durationInMilliseconds": float64(time.Since(t.start)) / float64(time.Millisecond),
+		"collector.rowsReturned":           rows,
+		"collector.errors":                 errors,
+		"run.collectors":                   len(t.collectors),
+		"run.failedCollectors":             failed,
+		"run.skippedCollectors":            skipped,
+	})
+}
+
+func newIntegrationMetricSet(instanceEntity *integration.Entity, collector string) *metric.Set {
+	return instanceEntity.NewMetricSet("PostgresqlIntegrationSample",
+		attribute.Attribute{Key: "displayName", Value: instanceEntity.Metadata.Name},
+		attribute.Attribute{Key: "entityName", Value: instanceEntity.Metadata.Namespace + ":" + instanceEntity.Metadata.Name},
+		attribute.Attribute{Key: "collector", Value: collector},
+	)
+}
+
+// setIntegrationMetrics sets numeric values as gauges and non-empty strings as attributes
+func setIntegrationMetrics(metricSet *metric.Set, values map[string]interface{}) {
+	for name, value := range values {
+		sourceType := metric.GAUGE
+		if s, ok := value.(string); ok {
+			if s == "" {
+				continue
+			}
+			sourceType = metric.ATTRIBUTE
+		}
+
+		if err := metricSet.SetMetric(name, value, sourceType); err != nil {
+			log.Error("Failed to set integration metric %s: %s", name, err.Error())
+		}
+	}
+}
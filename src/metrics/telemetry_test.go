@@ -0,0 +1,156 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/newrelic/infra-integrations-sdk/v3/data/metric"
+	"github.com/newrelic/infra-integrations-sdk/v3/integration"
+	"github.com/newrelic/nri-postgresql/src/connection"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/DATA-DOG/go-sqlmock.v1"
+)
+
+func integrationSamples(t *testing.T, entity *integration.Entity) map[string]*metric.Set {
+	samples := make(map[string]*metric.Set)
+	for _, metricSet := range entity.Metrics {
+		if metricSet.Metrics["event_type"] != "PostgresqlIntegrationSample" {
+			continue
+		}
+		collector, ok := metricSet.Metrics["collector"].(string)
+		require.True(t, ok)
+		samples[collector] = metricSet
+	}
+	return samples
+}
+
+func TestCollectionTelemetry_Populate(t *testing.T) {
+	testIntegration, _ := integration.New("test", "1.0.0")
+	instance, _ := testIntegration.Entity("testhost:1234", "pg-instance")
+
+	con, mock := connection.CreateMockSQL(t)
+	mock.ExpectQuery(".*").WillReturnRows(sqlmock.NewRows([]string{"name"}).AddRow("a").AddRow("b"))
+	mock.ExpectQuery(".*").WillReturnError(errors.New("permission denied"))
+
+	telemetry := NewCollectionTelemetry()
+	ctx := WithTelemetry(context.Background(), telemetry)
+
+	RunCollector(ctx, "ok", func(ctx context.Context) {
+		var rows []struct {
+			Name string `db:"name"`
+		}
+		assert.NoError(t, con.WithContext(ctx).Query(&rows, "SELECT name"))
+	})
+	RunCollector(ctx, "failed", func(ctx context.Context) {
+		var rows []struct{}
+		assert.Error(t, con.WithContext(ctx).Query(&rows, "SELECT name"))
+	})
+	RunCollector(ctx, "skipped", func(ctx context.Context) {
+		SkipCollector(ctx, "extension is required")
+	})
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	telemetry.Populate(instance)
+	samples := integrationSamples(t, instance)
+	require.Len(t, samples, 4)
+
+	assert.Equal(t, "ok", samples["ok"].Metrics["collector.status"])
+	assert.Equal(t, float64(2), samples["ok"].Metrics["collector.rowsReturned"])
+	assert.Equal(t, float64(0), samples["ok"].Metrics["collector.errors"])
+	assert.NotContains(t, samples["ok"].Metrics, "collector.lastError")
+
+	assert.Equal(t, "failed", samples["failed"].Metrics["collector.status"])
+	assert.Equal(t, float64(1), samples["failed"].Metrics["collector.errors"])
+	assert.Equal(t, "permission denied", samples["failed"].Metrics["collector.lastError"])
+
+	assert.Equal(t, "skipped", samples["skipped"].Metrics["collector.status"])
+	assert.Equal(t, "extension is required", samples["skipped"].Metrics["collector.skipReason"])
+
+	run := samples["run"].Metrics
+	assert.Equal(t, float64(3), run["run.collectors"])
+	assert.Equal(t, float64(1), run["run.failedCollectors"])
+	assert.Equal(t, float64(1), run["run.skippedCollectors"])
+	assert.Equal(t, float64(2), run["collector.rowsReturned"])
+	assert.Equal(t, "testhost:1234", run["displayName"])
+}
+
+func TestCollectionTelemetry_QueryxRows(t *testing.T) {
+	con, mock := connection.CreateMockSQL(t)
+	mock.ExpectQuery(".*").WillReturnRows(sqlmock.NewRows([]string{"name"}).AddRow("a").AddRow("b").AddRow("c"))
+	mock.ExpectQuery(".*").WillReturnRows(sqlmock.NewRows([]string{"name"}).AddRow("a").AddRow("b").AddRow("c"))
+	mock.ExpectQuery(".*").WillReturnRows(sqlmock.NewRows([]string{"name"}).AddRow("a").AddRow("b").
+		RowError(1, errors.New("canceling statement due to statement timeout")))
+
+	telemetry := NewCollectionTelemetry()
+	ctx := WithTelemetry(context.Background(), telemetry)
+
+	readRows := func(ctx context.Context, limit int) {
+		rows, err := con.WithContext(ctx).Queryx("SELECT name")
+		require.NoError(t, err)
+		defer rows.Close()
+		for read := 0; read < limit && rows.Next(); {
+			read++
+		}
+	}
+	RunCollector(ctx, "all", func(ctx context.Context) { readRows(ctx, 10) })
+	// rows left unread when closed are not counted
+	RunCollector(ctx, "first", func(ctx context.Context) { readRows(ctx, 1) })
+	RunCollector(ctx, "failed", func(ctx context.Context) { readRows(ctx, 10) })
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	require.Len(t, telemetry.collectors, 3)
+	assert.Equal(t, 3, telemetry.collectors[0].rows)
+	assert.Equal(t, collectorStatusOK, telemetry.collectors[0].status())
+	assert.Equal(t, 1, telemetry.collectors[1].rows)
+	assert.Equal(t, 1, telemetry.collectors[2].rows)
+	assert.Equal(t, collectorStatusFailed, telemetry.collectors[2].status())
+	assert.Equal(t, "canceling statement due to statement timeout", telemetry.collectors[2].lastError)
+}
+
+func TestRunCollector_DeadlineExceeded(t *testing.T) {
+	telemetry := NewCollectionTelemetry()
+	ctx, cancel := context.WithCancel(WithTelemetry(context.Background(), telemetry))
+	cancel()
+
+	called := false
+	RunCollector(ctx, "test", func(context.Context) { called = true })
+	assert.False(t, called)
+
+	require.Len(t, telemetry.collectors, 1)
+	assert.Equal(t, collectorStatusSkipped, telemetry.collectors[0].status())
+	assert.Equal(t, context.Canceled.Error(), telemetry.collectors[0].skipReason)
+}
+
+func TestRunCollector_WithoutTelemetry(t *testing.T) {
+	called := false
+	RunCollector(context.Background(), "test", func(ctx context.Context) {
+		called = true
+		SkipCollector(ctx, "no telemetry")
+		recordCollectorError(ctx, errors.New("no telemetry"))
+	})
+	assert.True(t, called)
+}
+
+func TestRunCollector_StatementTimeouts(t *testing.T) {
+	ctx := WithStatementTimeouts(context.Background(), map[string]time.Duration{"queryMonitoring": time.Minute, "planChanges": 0})
+
+	timeouts := map[string]time.Duration{}
+	RunCollector(ctx, "queryMonitoring", func(ctx context.Context) {
+		for _, name := range []string{"slowQueries", "planChanges"} {
+			RunCollector(ctx, name, func(ctx context.Context) {
+				timeout, ok := connection.StatementTimeoutFromContext(ctx)
+				assert.True(t, ok)
+				timeouts[name] = timeout
+			})
+		}
+	})
+	assert.Equal(t, map[string]time.Duration{"slowQueries": time.Minute, "planChanges": 0}, timeouts)
+
+	RunCollector(ctx, "instance", func(ctx context.Context) {
+		_, ok := connection.StatementTimeoutFromContext(ctx)
+		assert.False(t, ok)
+	})
+}
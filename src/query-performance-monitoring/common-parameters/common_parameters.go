@@ -1,7 +1,10 @@
 package commonparameters
 
 import (
+	"time"
+
 	"github.com/newrelic/infra-integrations-sdk/v3/log"
+	"github.com/newrelic/infra-integrations-sdk/v3/persist"
 	"github.com/newrelic/nri-postgresql/src/args"
 )
 
@@ -14,26 +17,55 @@ const DefaultQueryMonitoringCountThreshold = 20
 // DefaultQueryResponseTimeThreshold is the default threshold for the response time of a query.
 const DefaultQueryResponseTimeThreshold = 1
 
+// DefaultPlanChangeThreshold is the default percentage by which the execution time of a query must grow with a new plan.
+const DefaultPlanChangeThreshold = 50
+
+// MinStatementSnapshotMaxAge is the minimum age after which a pg_stat_statements snapshot is not compared anymore,
+// which covers the runs scheduled by the agent up to every half an hour
+const MinStatementSnapshotMaxAge = time.Hour
+
 type CommonParameters struct {
 	Version                              uint64
-	Databases                            string
+	Databases                            []string
 	QueryMonitoringCountThreshold        int
 	QueryMonitoringResponseTimeThreshold int
+	PlanChangeThreshold                  int
 	Host                                 string
 	Port                                 string
 	IsRds                                bool
+	// StatementSnapshots keeps the pg_stat_statements counters of the previous run, which slow queries are compared with,
+	// and the plans seen for each query
+	StatementSnapshots persist.Storer
+	// StatementSnapshotMaxAge is the age after which the pg_stat_statements counters of the previous run have expired
+	StatementSnapshotMaxAge time.Duration
 }
 
-func SetCommonParameters(args args.ArgumentList, version uint64, databases string) *CommonParameters {
+func SetCommonParameters(args args.ArgumentList, version uint64, databases []string) *CommonParameters {
 	return &CommonParameters{
 		Version:                              version,
-		Databases:                            databases, // comma separated database names
+		Databases:                            databases, // database names, bound as a text array
 		QueryMonitoringCountThreshold:        validateAndGetQueryMonitoringCountThreshold(args),
 		QueryMonitoringResponseTimeThreshold: validateAndGetQueryMonitoringResponseTimeThreshold(args),
+		PlanChangeThreshold:                  validateAndGetPlanChangeThreshold(args),
 		Host:                                 args.Hostname,
 		Port:                                 args.Port,
 		IsRds:                                args.IsRds,
+		StatementSnapshots:                   persist.NewInMemoryStore(),
+		StatementSnapshotMaxAge:              statementSnapshotMaxAge(args),
+	}
+}
+
+// statementSnapshotMaxAge returns the age after which a pg_stat_statements snapshot has expired: twice the interval
+// between query monitoring runs, so that a late run still compares with the previous one, and at least the cache TTL
+func statementSnapshotMaxAge(args args.ArgumentList) time.Duration {
+	maxAge := MinStatementSnapshotMaxAge
+	if args.CacheTTL > maxAge {
+		maxAge = args.CacheTTL
+	}
+	if interval := 2 * args.QueryMonitoringInterval(); interval > maxAge {
+		maxAge = interval
 	}
+	return maxAge
 }
 
 func validateAndGetQueryMonitoringResponseTimeThreshold(args args.ArgumentList) int {
@@ -44,6 +76,14 @@ func validateAndGetQueryMonitoringResponseTimeThreshold(args args.ArgumentList)
 	return args.QueryMonitoringResponseTimeThreshold
 }
 
+func validateAndGetPlanChangeThreshold(args args.ArgumentList) int {
+	if args.QueryMonitoringPlanChangeThreshold < 0 {
+		log.Warn("PlanChangeThreshold should be greater than or equal to 0 but the input is %d, setting value to default which is %d", args.QueryMonitoringPlanChangeThreshold, DefaultPlanChangeThreshold)
+		return DefaultPlanChangeThreshold
+	}
+	return args.QueryMonitoringPlanChangeThreshold
+}
+
 func validateAndGetQueryMonitoringCountThreshold(args args.ArgumentList) int {
 	if args.QueryMonitoringCountThreshold < 0 {
 		log.Warn("QueryCountThreshold should be greater than 0 but the input is %d, setting value to default which is %d", args.QueryMonitoringCountThreshold, DefaultQueryMonitoringCountThreshold)
@@ -1,65 +1,202 @@
 package commonutils
 
 import (
-	"crypto/rand"
 	"fmt"
-	"math/big"
-	"regexp"
+	"hash/fnv"
+	"io"
+	"sort"
+	"strconv"
 	"strings"
-	"time"
 
 	"github.com/newrelic/nri-postgresql/src/collection"
 )
 
-// re is a regular expression that matches single-quoted strings, numbers, or double-quoted strings
-var re = regexp.MustCompile(`'[^']*'|\d+|".*?"`)
-
-func GetDatabaseListInString(dbMap collection.DatabaseList) string {
-	if len(dbMap) == 0 {
-		return ""
-	}
-	var quotedNames = make([]string, 0)
+// GetDatabaseList returns the sorted names of the databases in dbMap, to be bound as a query parameter
+func GetDatabaseList(dbMap collection.DatabaseList) []string {
+	names := make([]string, 0, len(dbMap))
 	for dbName := range dbMap {
-		quotedNames = append(quotedNames, fmt.Sprintf("'%s'", dbName))
+		names = append(names, dbName)
 	}
-	return strings.Join(quotedNames, ",")
+	sort.Strings(names)
+	return names
 }
 
+// AnonymizeQueryText replaces the constants of query with $n parameters the way pg_stat_statements does, leaving its
+// identifiers, comments and layout untouched
 func AnonymizeQueryText(query string) string {
-	anonymizedQuery := re.ReplaceAllString(query, "?")
-	return anonymizedQuery
+	var anonymizedQuery strings.Builder
+	for _, token := range replaceConstants(tokenizeSQL(query)) {
+		anonymizedQuery.WriteString(token.text)
+	}
+	return anonymizedQuery.String()
+}
+
+// planStructure lists the EXPLAIN fields identifying the shape of a plan node. Costs, row estimates and conditions,
+// which change with the statistics and the parameters of the query, are left out.
+var planStructure = []string{
+	"Node Type", "Strategy", "Partial Mode", "Operation", "Parent Relationship", "Subplan Name", "Join Type",
+	"Parallel Aware", "Scan Direction", "Relation Name", "Schema", "Index Name", "CTE Name", "Function Name",
+}
+
+// GeneratePlanID returns the identifier of an EXPLAIN (FORMAT JSON) plan, a hash of its node types, relations,
+// indexes and join structure, so that a query keeps the same plan ID for as long as its plan does not change
+func GeneratePlanID(plan map[string]interface{}) string {
+	hash := fnv.New64a()
+	writePlanStructure(hash, plan)
+	return strconv.FormatUint(hash.Sum64(), 10)
 }
 
-// This function is used to generate a unique plan ID for a query
-func GeneratePlanID() (string, error) {
-	randomInt, err := rand.Int(rand.Reader, big.NewInt(RandomIntRange))
-	if err != nil {
-		return "", ErrUnExpectedError
+// DescribePlan returns a one line description of an EXPLAIN (FORMAT JSON) plan, such as
+// "Hash Join (Inner) [Seq Scan on orders, Index Scan using users_pkey on users]", truncated to 4095 characters
+func DescribePlan(plan map[string]interface{}) string {
+	var description strings.Builder
+	writePlanDescription(&description, plan)
+	if description.Len() > MaxAttributeLength {
+		return description.String()[:MaxAttributeLength]
 	}
-	currentTime := time.Now().Format(TimeFormat)
-	result := fmt.Sprintf("%d-%s", randomInt.Int64(), currentTime)
-	return result, nil
+	return description.String()
 }
 
-func AnonymizeAndNormalize(query string) string {
-	reNumbers := regexp.MustCompile(`\d+`)
-	cleanedQuery := reNumbers.ReplaceAllString(query, "?")
+func writePlanDescription(w *strings.Builder, plan map[string]interface{}) {
+	fmt.Fprint(w, plan["Node Type"])
+	if joinType, ok := plan["Join Type"]; ok {
+		fmt.Fprintf(w, " (%v)", joinType)
+	}
+	if indexName, ok := plan["Index Name"]; ok {
+		fmt.Fprintf(w, " using %v", indexName)
+	}
+	if relationName, ok := plan["Relation Name"]; ok {
+		fmt.Fprintf(w, " on %v", relationName)
+	}
+	children, _ := plan["Plans"].([]interface{})
+	written := 0
+	for _, child := range children {
+		childPlan, ok := child.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if written == 0 {
+			w.WriteString(" [")
+		} else {
+			w.WriteString(", ")
+		}
+		writePlanDescription(w, childPlan)
+		written++
+	}
+	if written > 0 {
+		w.WriteString("]")
+	}
+}
 
-	reSingleQuotes := regexp.MustCompile(`'[^']*'`)
-	cleanedQuery = reSingleQuotes.ReplaceAllString(cleanedQuery, "?")
+func writePlanStructure(w io.Writer, plan map[string]interface{}) {
+	fmt.Fprint(w, "(")
+	for _, field := range planStructure {
+		if value, ok := plan[field]; ok {
+			fmt.Fprintf(w, "%s=%v;", field, value)
+		}
+	}
+	if children, ok := plan["Plans"].([]interface{}); ok {
+		for _, child := range children {
+			if childPlan, ok := child.(map[string]interface{}); ok {
+				writePlanStructure(w, childPlan)
+			}
+		}
+	}
+	fmt.Fprint(w, ")")
+}
 
-	reDoubleQuotes := regexp.MustCompile(`"[^"]*"`)
-	cleanedQuery = reDoubleQuotes.ReplaceAllString(cleanedQuery, "?")
+// AnonymizeAndNormalize returns query with its constants replaced the way pg_stat_statements does, without its
+// comments and trailing semicolons, keywords and unquoted identifiers lowercased and tokens separated by one space, so
+// that the text of a running query can be matched with its pg_stat_statements text. Lists of parameters are collapsed
+// as PostgreSQL 18 squashes them, see collapseParameterLists.
+func AnonymizeAndNormalize(query string) string {
+	tokens := replaceConstants(tokenizeSQL(query))
+	normalized := make([]sqlToken, 0, len(tokens))
+	for _, token := range tokens {
+		switch token.kind {
+		case tokenWhitespace, tokenComment:
+		case tokenIdentifier:
+			normalized = append(normalized, sqlToken{token.kind, lowerASCII(token.text)})
+		default:
+			normalized = append(normalized, token)
+		}
+	}
+	for len(normalized) > 0 && normalized[len(normalized)-1].text == ";" {
+		normalized = normalized[:len(normalized)-1]
+	}
 
-	cleanedQuery = strings.ReplaceAll(cleanedQuery, "$", "")
+	texts := make([]string, 0, len(normalized))
+	for _, token := range collapseParameterLists(normalized) {
+		texts = append(texts, token.text)
+	}
+	return strings.Join(texts, " ")
+}
 
-	cleanedQuery = strings.ToLower(cleanedQuery)
+// collapseParameterLists replaces the lists of parameters of IN (...) and ARRAY[...] with their first parameter, as
+// pg_stat_statements shows them as "IN ($1 /*, ... */)" since PostgreSQL 18, and renumbers the parameters in the order
+// they appear, so that the texts with and without squashed lists match. tokens have no whitespace nor comments.
+func collapseParameterLists(tokens []sqlToken) []sqlToken {
+	numbers := make(map[string]string)
+	renumber := func(parameter sqlToken) sqlToken {
+		number, ok := numbers[parameter.text]
+		if !ok {
+			number = "$" + strconv.Itoa(len(numbers)+1)
+			numbers[parameter.text] = number
+		}
+		return sqlToken{tokenParameter, number}
+	}
 
-	cleanedQuery = strings.ReplaceAll(cleanedQuery, ";", "")
+	collapsed := make([]sqlToken, 0, len(tokens))
+	for i := 0; i < len(tokens); i++ {
+		switch end := parameterListEnd(tokens, i); {
+		case end > 0:
+			collapsed = append(collapsed, tokens[i], tokens[i+1], renumber(tokens[i+2]), tokens[end])
+			i = end
+		case tokens[i].kind == tokenParameter:
+			collapsed = append(collapsed, renumber(tokens[i]))
+		default:
+			collapsed = append(collapsed, tokens[i])
+		}
+	}
+	return collapsed
+}
 
-	cleanedQuery = strings.TrimSpace(cleanedQuery)
+// parameterListEnd returns the index of the closing bracket of the list of parameters following the IN or ARRAY
+// keyword at i, or 0 if there is none
+func parameterListEnd(tokens []sqlToken, i int) int {
+	closing := ""
+	switch {
+	case i+2 >= len(tokens) || tokens[i].kind != tokenIdentifier:
+		return 0
+	case tokens[i].text == "in" && tokens[i+1].text == "(":
+		closing = ")"
+	case tokens[i].text == "array" && tokens[i+1].text == "[":
+		closing = "]"
+	default:
+		return 0
+	}
 
-	cleanedQuery = strings.Join(strings.Fields(cleanedQuery), " ")
+	for j := i + 2; j < len(tokens); j += 2 {
+		if tokens[j].kind != tokenParameter || j+1 >= len(tokens) {
+			return 0
+		}
+		switch tokens[j+1].text {
+		case closing:
+			return j + 1
+		case ",":
+		default:
+			return 0
+		}
+	}
+	return 0
+}
 
-	return cleanedQuery
+// lowerASCII lowercases the ASCII letters of an identifier, as PostgreSQL does with unquoted identifiers
+func lowerASCII(identifier string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'A' && r <= 'Z' {
+			return r + 'a' - 'A'
+		}
+		return r
+	}, identifier)
 }
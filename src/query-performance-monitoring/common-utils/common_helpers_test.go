@@ -1,40 +1,107 @@
 package commonutils
 
 import (
-	"sort"
 	"testing"
 
 	"github.com/newrelic/nri-postgresql/src/collection"
 	"github.com/stretchr/testify/assert"
 )
 
-func TestGetDatabaseListInString(t *testing.T) {
-	dbListKeys := []string{"db1"}
-	sort.Strings(dbListKeys) // Sort the keys to ensure consistent order
-	dbList := collection.DatabaseList{}
-	for _, key := range dbListKeys {
-		dbList[key] = collection.SchemaList{}
+func TestGetDatabaseList(t *testing.T) {
+	dbList := collection.DatabaseList{
+		"db2":        collection.SchemaList{},
+		"db1":        collection.SchemaList{},
+		"o'reilly's": collection.SchemaList{},
 	}
-	expected := "'db1'"
-	result := GetDatabaseListInString(dbList)
-	assert.Equal(t, expected, result)
+	assert.Equal(t, []string{"db1", "db2", "o'reilly's"}, GetDatabaseList(dbList))
 
 	// Test with empty database list
-	dbList = collection.DatabaseList{}
-	expected = ""
-	result = GetDatabaseListInString(dbList)
-	assert.Equal(t, expected, result)
+	assert.Empty(t, GetDatabaseList(collection.DatabaseList{}))
 }
 
 func TestAnonymizeQueryText(t *testing.T) {
-	query := "SELECT * FROM users WHERE id = 1 AND name = 'John'"
-	expected := "SELECT * FROM users WHERE id = ? AND name = ?"
-	result := AnonymizeQueryText(query)
-	assert.Equal(t, expected, result)
-	query = "SELECT * FROM employees WHERE id = 10 OR name <> 'John Doe'   OR name != 'John Doe'   OR age < 30 OR age <= 30   OR salary > 50000OR salary >= 50000  OR department LIKE 'Sales%' OR department ILIKE 'sales%'OR join_date BETWEEN '2023-01-01' AND '2023-12-31' OR department IN ('HR', 'Engineering', 'Marketing') OR department IS NOT NULL OR department IS NULL;"
-	expected = "SELECT * FROM employees WHERE id = ? OR name <> ?   OR name != ?   OR age < ? OR age <= ?   OR salary > ?OR salary >= ?  OR department LIKE ? OR department ILIKE ?OR join_date BETWEEN ? AND ? OR department IN (?, ?, ?) OR department IS NOT NULL OR department IS NULL;"
-	result = AnonymizeQueryText(query)
-	assert.Equal(t, expected, result)
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name:     "integers and strings",
+			input:    "SELECT * FROM users WHERE id = 1 AND name = 'John'",
+			expected: "SELECT * FROM users WHERE id = $1 AND name = $2",
+		},
+		{
+			name:     "comparison operators",
+			input:    "SELECT * FROM employees WHERE id = 10 OR name <> 'John Doe'   OR name != 'John Doe'   OR age < 30 OR age <= 30   OR salary > 50000OR salary >= 50000  OR department LIKE 'Sales%' OR department ILIKE 'sales%'OR join_date BETWEEN '2023-01-01' AND '2023-12-31' OR department IN ('HR', 'Engineering', 'Marketing') OR department IS NOT NULL OR department IS NULL;",
+			expected: "SELECT * FROM employees WHERE id = $1 OR name <> $2   OR name != $3   OR age < $4 OR age <= $5   OR salary > $6OR salary >= $7  OR department LIKE $8 OR department ILIKE $9OR join_date BETWEEN $10 AND $11 OR department IN ($12, $13, $14) OR department IS NOT NULL OR department IS NULL;",
+		},
+		{name: "no constants", input: "SELECT * FROM users", expected: "SELECT * FROM users"},
+		{name: "empty", input: "", expected: ""},
+		{name: "digits in identifiers", input: "SELECT col1 FROM table1 t2 WHERE t2.x_3 = 4", expected: "SELECT col1 FROM table1 t2 WHERE t2.x_3 = $1"},
+		{name: "dollar in identifiers", input: "SELECT a$1 FROM t WHERE b$c = 2", expected: "SELECT a$1 FROM t WHERE b$c = $1"},
+		{name: "quoted identifiers", input: `SELECT "Col 1" FROM "public"."Users2" WHERE "id" = 5`, expected: `SELECT "Col 1" FROM "public"."Users2" WHERE "id" = $1`},
+		{name: "quoted identifier with doubled quote", input: `SELECT "a""b" FROM t WHERE c = 'x'`, expected: `SELECT "a""b" FROM t WHERE c = $1`},
+		{name: "unicode quoted identifier", input: `SELECT U&"d\0061t" FROM t WHERE a = 1`, expected: `SELECT U&"d\0061t" FROM t WHERE a = $1`},
+		{name: "doubled quotes in strings", input: "SELECT * FROM t WHERE name = 'O''Reilly' AND x = 1", expected: "SELECT * FROM t WHERE name = $1 AND x = $2"},
+		{name: "escape string", input: `SELECT E'it\'s 42' , 7`, expected: "SELECT $1 , $2"},
+		{name: "escape string with backslash before quote", input: `SELECT e'a\\', 'b'`, expected: "SELECT $1, $2"},
+		{name: "standard string ends at backslash quote", input: `SELECT 'a\', 'b'`, expected: "SELECT $1, $2"},
+		{name: "bit and hex strings", input: "SELECT B'1010', x'1F', b'0'", expected: "SELECT $1, $2, $3"},
+		{name: "unicode string", input: `SELECT U&'d\0061t\+000061'`, expected: "SELECT $1"},
+		{name: "unicode string escape character", input: `SELECT U&'d!0061t' UESCAPE '!'`, expected: "SELECT $1 UESCAPE '!'"},
+		{name: "national string", input: "SELECT N'abc'", expected: "SELECT N$1"},
+		{name: "dollar-quoted string", input: "SELECT $$it's 'quoted' 123$$ AS a", expected: "SELECT $1 AS a"},
+		{name: "tagged dollar-quoted string", input: "SELECT $body$ a $$ b 42 $body$, $x1$ $x$ $x1$", expected: "SELECT $1, $2"},
+		{name: "dollar-quoted function body", input: "DO $$BEGIN PERFORM 1; END$$", expected: "DO $1"},
+		{name: "string continued on the next line", input: "SELECT 'foo'\n    'bar' AS a, 'baz' 'qux'", expected: "SELECT $1 AS a, $2 $3"},
+		{name: "string continued after a comment", input: "SELECT 'foo' -- first part\n'bar'", expected: "SELECT $1"},
+		{name: "decimals", input: "SELECT 1.5, .5, 5., 1.5e10, 2E-3, 3e+2", expected: "SELECT $1, $2, $3, $4, $5, $6"},
+		{name: "hexadecimal, octal and binary integers", input: "SELECT 0x1F, 0o17, 0b101, 1_000_000", expected: "SELECT $1, $2, $3, $4"},
+		{name: "integers around dots", input: "SELECT a[1:2], 1..10 FROM t", expected: "SELECT a[$1:$2], $3..$4 FROM t"},
+		{name: "negative numbers", input: "SELECT -1, - 2.5 FROM t WHERE a = -3 AND b IN (-4, 5)", expected: "SELECT $1, $2 FROM t WHERE a = $3 AND b IN ($4, $5)"},
+		{name: "negative number after an operator without spaces", input: "SELECT * FROM t WHERE a=-1 AND b<>-2", expected: "SELECT * FROM t WHERE a=$1 AND b<>$2"},
+		{name: "negative number after a keyword", input: "SELECT * FROM t WHERE a BETWEEN -1 AND -2 LIMIT -0", expected: "SELECT * FROM t WHERE a BETWEEN $1 AND $2 LIMIT $3"},
+		{name: "subtraction", input: "SELECT a - 1, a-1, (a) - 1, count(*) -1 FROM t", expected: "SELECT a - $1, a-$2, (a) - $3, count(*) -$4 FROM t"},
+		{name: "case expression", input: "SELECT CASE WHEN a > 0 THEN -1 ELSE 'x' END - 1 FROM t", expected: "SELECT CASE WHEN a > $1 THEN $2 ELSE $3 END - $4 FROM t"},
+		{name: "unary plus is kept", input: "SELECT +1", expected: "SELECT +$1"},
+		{name: "operators ending in a minus sign", input: "SELECT a @- 1, b *-1 FROM t", expected: "SELECT a @- $1, b *$2 FROM t"},
+		{name: "booleans", input: "UPDATE t SET active = TRUE WHERE deleted = false", expected: "UPDATE t SET active = $1 WHERE deleted = $2"},
+		{name: "null", input: "INSERT INTO t (a, b) VALUES (1, NULL)", expected: "INSERT INTO t (a, b) VALUES ($1, $2)"},
+		{name: "is null and is true", input: "SELECT * FROM t WHERE a IS NULL AND b IS NOT TRUE AND c IS FALSE AND d = null", expected: "SELECT * FROM t WHERE a IS NULL AND b IS NOT TRUE AND c IS FALSE AND d = $1"},
+		{name: "is distinct from", input: "SELECT * FROM t WHERE a IS DISTINCT FROM NULL", expected: "SELECT * FROM t WHERE a IS DISTINCT FROM $1"},
+		{name: "existing parameters", input: "SELECT * FROM t WHERE a = $1 AND b = 'x' AND c = $2", expected: "SELECT * FROM t WHERE a = $1 AND b = $3 AND c = $2"},
+		{name: "parameters out of order", input: "SELECT $3, 1, $1", expected: "SELECT $3, $4, $1"},
+		{name: "typecasts", input: "SELECT '2024-01-01'::date, 1::text, CAST('5' AS integer), '{1,2}'::int[]", expected: "SELECT $1::date, $2::text, CAST($3 AS integer), $4::int[]"},
+		{name: "typed literals", input: "SELECT DATE '2024-01-01', interval '1 day', timestamp with time zone '2024-01-01 00:00'", expected: "SELECT DATE $1, interval $2, timestamp with time zone $3"},
+		{name: "type modifiers", input: "SELECT a::varchar(10), CAST(b AS numeric(10, 2)), c::character varying(5), d::timestamp(3), 'x'::char(1)", expected: "SELECT a::varchar(10), CAST(b AS numeric(10, 2)), c::character varying(5), d::timestamp(3), $1::char(1)"},
+		{name: "function arguments", input: "SELECT round(a, 2), left(b, 3), coalesce(c, 0) FROM t", expected: "SELECT round(a, $1), left(b, $2), coalesce(c, $3) FROM t"},
+		{name: "array constructor and subscripts", input: "SELECT ARRAY[1, 2, -3], a[4] FROM t", expected: "SELECT ARRAY[$1, $2, $3], a[$4] FROM t"},
+		{name: "order by positions", input: "SELECT a, b FROM t ORDER BY 1, 2 DESC NULLS LAST LIMIT 10 OFFSET 5", expected: "SELECT a, b FROM t ORDER BY 1, 2 DESC NULLS LAST LIMIT $1 OFFSET $2"},
+		{name: "group by positions", input: "SELECT a, count(*) FROM t GROUP BY 1 HAVING count(*) > 1 ORDER BY 2", expected: "SELECT a, count(*) FROM t GROUP BY 1 HAVING count(*) > $1 ORDER BY 2"},
+		{name: "order by expressions", input: "SELECT a FROM t ORDER BY a + 1, 1.5, -1", expected: "SELECT a FROM t ORDER BY a + $1, $2, $3"},
+		{name: "order by in a subquery", input: "SELECT * FROM (SELECT a FROM t ORDER BY 1 LIMIT 3) s WHERE a > 2", expected: "SELECT * FROM (SELECT a FROM t ORDER BY 1 LIMIT $1) s WHERE a > $2"},
+		{name: "order by of an aggregate or a window", input: "SELECT string_agg(a, ',' ORDER BY 1), rank() OVER (ORDER BY 1) FROM t ORDER BY 1", expected: "SELECT string_agg(a, $1 ORDER BY $2), rank() OVER (ORDER BY $3) FROM t ORDER BY 1"},
+		{name: "values after a group by", input: "SELECT a FROM t GROUP BY 1 UNION SELECT 1, 2", expected: "SELECT a FROM t GROUP BY 1 UNION SELECT $1, $2"},
+		{name: "line comments", input: "SELECT 1 -- id = 5, 'x'\nFROM t", expected: "SELECT $1 -- id = 5, 'x'\nFROM t"},
+		{name: "block comments", input: "/* app: web, id: 7 */ SELECT * FROM t WHERE a = 2 /* x = 'y' */", expected: "/* app: web, id: 7 */ SELECT * FROM t WHERE a = $1 /* x = 'y' */"},
+		{name: "nested block comments", input: "SELECT /* a /* 1 */ 2 */ 3", expected: "SELECT /* a /* 1 */ 2 */ $1"},
+		{name: "comment right after an operator", input: "SELECT a =/* 1 */-2 FROM t", expected: "SELECT a =/* 1 */$1 FROM t"},
+		{name: "json operators", input: "SELECT data->>'name', data->'tags'->0, data#>>'{a,b}' FROM t WHERE data @> '{\"a\": 1}'", expected: "SELECT data->>$1, data->$2->$3, data#>>$4 FROM t WHERE data @> $5"},
+		{name: "multiple statements", input: "SELECT 1; SELECT 'a' ORDER BY 1;", expected: "SELECT $1; SELECT $2 ORDER BY 1;"},
+		{name: "insert with several rows", input: "INSERT INTO t VALUES (1, 'a'), (2, 'b') ON CONFLICT (id) DO UPDATE SET v = 3 RETURNING id", expected: "INSERT INTO t VALUES ($1, $2), ($3, $4) ON CONFLICT (id) DO UPDATE SET v = $5 RETURNING id"},
+		{name: "alter statement", input: "ALTER TABLE t ALTER COLUMN c SET DEFAULT 'secret'", expected: "ALTER TABLE t ALTER COLUMN c SET DEFAULT $1"},
+		{name: "multibyte identifiers and strings", input: "SELECT naïve1 FROM tablé WHERE nom = 'café'", expected: "SELECT naïve1 FROM tablé WHERE nom = $1"},
+		{name: "unterminated string", input: "SELECT * FROM t WHERE secret = 'abc", expected: "SELECT * FROM t WHERE secret = $1"},
+		{name: "unterminated dollar quote", input: "SELECT $$secret", expected: "SELECT $1"},
+		{name: "unterminated comment", input: "SELECT 1 /* 2", expected: "SELECT $1 /* 2"},
+		{name: "lone dollar sign", input: "SELECT a $ 1", expected: "SELECT a $ $1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, AnonymizeQueryText(tt.input))
+		})
+	}
 }
 
 func TestAnonymizeAndNormalize(t *testing.T) {
@@ -44,24 +111,24 @@ func TestAnonymizeAndNormalize(t *testing.T) {
 		expected string
 	}{
 		{
-			name:     "Replace numbers with placeholders",
+			name:     "Replace numbers with parameters",
 			input:    "SELECT * FROM users WHERE id = 123",
-			expected: "select * from users where id = ?",
+			expected: "select * from users where id = $1",
 		},
 		{
-			name:     "Replace single-quoted strings with placeholders",
+			name:     "Replace single-quoted strings with parameters",
 			input:    "SELECT * FROM users WHERE name = 'John'",
-			expected: "select * from users where name = ?",
+			expected: "select * from users where name = $1",
 		},
 		{
-			name:     "Replace double-quoted strings with placeholders",
-			input:    `SELECT * FROM "users" WHERE "name" = "John"`,
-			expected: "select * from ? where ? = ?",
+			name:     "Keep quoted identifiers",
+			input:    `SELECT * FROM "Users" WHERE "Name" = 'John'`,
+			expected: `select * from "Users" where "Name" = $1`,
 		},
 		{
-			name:     "Remove dollar signs",
+			name:     "Keep parameters",
 			input:    "SELECT $1 FROM users WHERE id = $2",
-			expected: "select ? from users where id = ?",
+			expected: "select $1 from users where id = $2",
 		},
 		{
 			name:     "Convert to lowercase",
@@ -69,19 +136,54 @@ func TestAnonymizeAndNormalize(t *testing.T) {
 			expected: "select * from users",
 		},
 		{
-			name:     "Remove semicolons",
+			name:     "Remove trailing semicolons",
 			input:    "SELECT * FROM users;",
 			expected: "select * from users",
 		},
 		{
 			name:     "Trim and normalize spaces",
-			input:    "  SELECT   *   FROM   users   ",
+			input:    "  SELECT   *\n\tFROM   users   ",
+			expected: "select * from users",
+		},
+		{
+			name:     "Separate tokens",
+			input:    "SELECT count(*),max(a)FROM users WHERE id=1",
+			expected: "select count ( * ) , max ( a ) from users where id = $1",
+		},
+		{
+			name:     "Remove comments",
+			input:    "/* controller: users */ SELECT * FROM users -- list\n",
 			expected: "select * from users",
 		},
 		{
 			name:     "Complex query",
 			input:    "SELECT * FROM employees WHERE id = 10 OR name = 'John Doe';",
-			expected: "select * from employees where id = ? or name = ?",
+			expected: "select * from employees where id = $1 or name = $2",
+		},
+		{
+			name:     "Collapse lists of constants",
+			input:    "SELECT * FROM users WHERE id IN (1, 2, 3) AND status = 'active'",
+			expected: "select * from users where id in ( $1 ) and status = $2",
+		},
+		{
+			name:     "Collapse PostgreSQL 18 squashed lists",
+			input:    "SELECT * FROM users WHERE id IN ($1 /*, ... */) AND status = $2",
+			expected: "select * from users where id in ( $1 ) and status = $2",
+		},
+		{
+			name:     "Collapse arrays of constants",
+			input:    "SELECT * FROM users WHERE id = ANY(ARRAY[$1 /*, ... */]) OR id = ANY(ARRAY[7, 8])",
+			expected: "select * from users where id = any ( array [ $1 ] ) or id = any ( array [ $2 ] )",
+		},
+		{
+			name:     "Renumber parameters in order",
+			input:    "SELECT * FROM users WHERE id IN ($3, $4) AND name = $1 OR alias = $1",
+			expected: "select * from users where id in ( $1 ) and name = $2 or alias = $2",
+		},
+		{
+			name:     "Keep lists that are not only constants",
+			input:    "SELECT coalesce($1, $2) FROM users WHERE id IN (1, user_id) AND team IN (SELECT 1)",
+			expected: "select coalesce ( $1 , $2 ) from users where id in ( $3 , user_id ) and team in ( select $4 )",
 		},
 	}
 
@@ -91,4 +193,181 @@ func TestAnonymizeAndNormalize(t *testing.T) {
 			assert.Equal(t, tt.expected, result)
 		})
 	}
+
+	// a running query matches its pg_stat_statements text
+	assert.Equal(t,
+		AnonymizeAndNormalize("SELECT o.id, -o.total FROM orders o WHERE o.status = $1 AND o.created_at > $2::date ORDER BY 1 LIMIT $3"),
+		AnonymizeAndNormalize("select o.id,  -o.total\nfrom orders o\nwhere o.status = 'shipped' and o.created_at > '2024-01-01'::date\norder by 1 limit 50;"))
+
+	// including the PostgreSQL 18 pg_stat_statements texts, whose lists of constants are squashed
+	pg18Text := "SELECT * FROM orders WHERE status IN ($1 /*, ... */) AND user_id = ANY(ARRAY[$2 /*, ... */]) LIMIT $3"
+	assert.Equal(t,
+		AnonymizeAndNormalize(pg18Text),
+		AnonymizeAndNormalize("SELECT * FROM orders WHERE status IN ('new', 'paid', 'shipped') AND user_id = ANY(ARRAY[1, 2]) LIMIT 10"))
+	assert.Equal(t,
+		AnonymizeAndNormalize(pg18Text),
+		AnonymizeAndNormalize("SELECT * FROM orders WHERE status IN ($1, $2, $3) AND user_id = ANY(ARRAY[$4, $5]) LIMIT $6"))
+}
+
+func TestAnonymizeAndNormalizePgStatStatements(t *testing.T) {
+	// the text of a running query, as in pg_stat_activity, and the text pg_stat_statements shows for it
+	tests := []struct {
+		name      string
+		running   string
+		statement string
+	}{
+		{
+			name:      "dollar quotes with tags",
+			running:   "SELECT $fn$it's $$ not the end$fn$, $$plain$$ FROM t",
+			statement: "SELECT $1, $2 FROM t",
+		},
+		{
+			name:      "escape strings",
+			running:   `SELECT * FROM t WHERE a = E'it\'s' AND b = e'C:\\dir\n' AND c LIKE E'50\\%' ESCAPE E'\\'`,
+			statement: "SELECT * FROM t WHERE a = $1 AND b = $2 AND c LIKE $3 ESCAPE $4",
+		},
+		{
+			name:      "unicode strings",
+			running:   `SELECT * FROM t WHERE a = U&'d\0061t\+000061' OR b = U&'d!0061t' UESCAPE '!'`,
+			statement: "SELECT * FROM t WHERE a = $1 OR b = $2 UESCAPE '!'",
+		},
+		{
+			name:      "bit and hexadecimal strings",
+			running:   "UPDATE flags SET mask = B'1010' | b'0001', raw = X'1F' WHERE id = x'0A'::int",
+			statement: "UPDATE flags SET mask = $1 | $2, raw = $3 WHERE id = $4::int",
+		},
+		{
+			name:      "strings continued after a newline",
+			running:   "SELECT * FROM t WHERE a = 'foo'\n  'bar' AND b = 'baz'",
+			statement: "SELECT * FROM t WHERE a = $1 AND b = $2",
+		},
+		{
+			name:      "nested comments",
+			running:   "/* app /* job 42 */ 'x' */ SELECT 1 /* a /* b */ c */ FROM t -- 'x'\n",
+			statement: "/* app /* job 42 */ 'x' */ SELECT $1 /* a /* b */ c */ FROM t -- 'x'\n",
+		},
+		{
+			name:      "digits inside identifiers",
+			running:   "SELECT t1.c2, col_3, a$4 FROM t1 JOIN t2 ON t2.id9 = t1.id9 WHERE t1.c2 = 10",
+			statement: "SELECT t1.c2, col_3, a$4 FROM t1 JOIN t2 ON t2.id9 = t1.id9 WHERE t1.c2 = $1",
+		},
+		{
+			name:      "quoted identifiers",
+			running:   `SELECT "Col 1", "a""b", "2024" FROM "Orders" WHERE "Status" = 'new' AND "n" = 5`,
+			statement: `SELECT "Col 1", "a""b", "2024" FROM "Orders" WHERE "Status" = $1 AND "n" = $2`,
+		},
+		{
+			name:      "negative numbers",
+			running:   "SELECT a - 1, -2, b*-3.5, abs(-4) FROM t WHERE c = -5 AND d BETWEEN -6 AND -7 AND e IN (SELECT -8)",
+			statement: "SELECT a - $1, $2, b*$3, abs($4) FROM t WHERE c = $5 AND d BETWEEN $6 AND $7 AND e IN (SELECT $8)",
+		},
+		{
+			name:      "type modifiers",
+			running:   "SELECT 1.5::numeric(10, 2), CAST(a AS varchar(20)), b::timestamp(3), c::bit varying(8) FROM t WHERE d = 'x'::char(1)",
+			statement: "SELECT $1::numeric(10, 2), CAST(a AS varchar(20)), b::timestamp(3), c::bit varying(8) FROM t WHERE d = $2::char(1)",
+		},
+		{
+			name:      "intervals",
+			running:   "SELECT * FROM t WHERE created > now() - interval '1 day' AND age < interval(0) '2 hours' AND d < '3 weeks'::interval",
+			statement: "SELECT * FROM t WHERE created > now() - interval $1 AND age < interval(0) $2 AND d < $3::interval",
+		},
+		{
+			name:      "multi-row VALUES",
+			running:   "INSERT INTO t (a, b, c) VALUES (1, 'x', NULL), (2, 'y', true), (-3, E'z', 4.5) RETURNING id",
+			statement: "INSERT INTO t (a, b, c) VALUES ($1, $2, $3), ($4, $5, $6), ($7, $8, $9) RETURNING id",
+		},
+		{
+			name:      "IN lists",
+			running:   "SELECT * FROM t WHERE id IN (1, 2, 3) AND kind IN ('a', 'b') AND x = 9",
+			statement: "SELECT * FROM t WHERE id IN ($1, $2, $3) AND kind IN ($4, $5) AND x = $6",
+		},
+		{
+			name:      "IN lists squashed by PostgreSQL 18",
+			running:   "SELECT * FROM t WHERE id IN (1, 2, 3, 4) AND kind IN ('a', 'b') AND x = 9",
+			statement: "SELECT * FROM t WHERE id IN ($1 /*, ... */) AND kind IN ($2 /*, ... */) AND x = $3",
+		},
+		{
+			name:      "ARRAY lists",
+			running:   "SELECT * FROM t WHERE id = ANY(ARRAY[1, 2, 3]) AND tags && ARRAY['a', 'b']",
+			statement: "SELECT * FROM t WHERE id = ANY(ARRAY[$1, $2, $3]) AND tags && ARRAY[$4, $5]",
+		},
+		{
+			name:      "ARRAY lists squashed by PostgreSQL 18",
+			running:   "SELECT * FROM t WHERE id = ANY(ARRAY[1, 2, 3]) AND tags && ARRAY['a', 'b']",
+			statement: "SELECT * FROM t WHERE id = ANY(ARRAY[$1 /*, ... */]) AND tags && ARRAY[$2 /*, ... */]",
+		},
+		{
+			name:      "constants with parameters of the extended protocol",
+			running:   "SELECT * FROM t WHERE a = $1 AND b = 'x' AND c IN ($2, 3)",
+			statement: "SELECT * FROM t WHERE a = $1 AND b = $3 AND c IN ($2, $4)",
+		},
+		{
+			name:      "column positions and booleans",
+			running:   "SELECT a, count(*) FROM t WHERE b IS NOT NULL AND c = false GROUP BY 1 ORDER BY 2 DESC LIMIT 10",
+			statement: "SELECT a, count(*) FROM t WHERE b IS NOT NULL AND c = $1 GROUP BY 1 ORDER BY 2 DESC LIMIT $2",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, AnonymizeAndNormalize(tt.statement), AnonymizeAndNormalize(tt.running))
+		})
+	}
+
+	// the constants that are part of the query text do not make different queries match
+	different := [][2]string{
+		{`SELECT * FROM "Users"`, "SELECT * FROM users"},
+		{"SELECT a::varchar(10) FROM t", "SELECT a::varchar(20) FROM t"},
+		{"SELECT a, b FROM t ORDER BY 1", "SELECT a, b FROM t ORDER BY 2"},
+		{"SELECT * FROM t WHERE a IS NULL", "SELECT * FROM t WHERE a IS NOT NULL"},
+		{"SELECT U&'x' UESCAPE '!'", "SELECT U&'x' UESCAPE '#'"},
+		{"SELECT t1.c2 FROM t1", "SELECT t1.c3 FROM t1"},
+	}
+	for _, queries := range different {
+		assert.NotEqual(t, AnonymizeAndNormalize(queries[0]), AnonymizeAndNormalize(queries[1]), queries[0])
+	}
+}
+
+func TestGeneratePlanID(t *testing.T) {
+	plan := func(indexName string, cost float64, filter string) map[string]interface{} {
+		return map[string]interface{}{
+			"Node Type": "Hash Join", "Join Type": "Inner", "Total Cost": cost, "Plan Rows": cost * 10,
+			"Hash Cond": "(o.user_id = u.id)",
+			"Plans": []interface{}{
+				map[string]interface{}{"Node Type": "Seq Scan", "Relation Name": "orders", "Alias": "o", "Parent Relationship": "Outer", "Filter": filter},
+				map[string]interface{}{"Node Type": "Index Scan", "Relation Name": "users", "Index Name": indexName, "Alias": "u", "Parent Relationship": "Inner"},
+			},
+		}
+	}
+
+	planID := GeneratePlanID(plan("users_pkey", 100, "(amount > 10)"))
+	assert.Equal(t, planID, GeneratePlanID(plan("users_pkey", 100, "(amount > 10)")))
+	// costs, estimates, aliases and conditions do not identify a plan
+	assert.Equal(t, planID, GeneratePlanID(plan("users_pkey", 2500, "(amount > 99)")))
+	assert.NotEqual(t, planID, GeneratePlanID(plan("users_email_idx", 100, "(amount > 10)")))
+
+	flipped := plan("users_pkey", 100, "(amount > 10)")
+	children := flipped["Plans"].([]interface{})
+	flipped["Plans"] = []interface{}{children[1], children[0]}
+	assert.NotEqual(t, planID, GeneratePlanID(flipped))
+	assert.NotEqual(t, planID, GeneratePlanID(map[string]interface{}{"Node Type": "Hash Join", "Join Type": "Inner"}))
+}
+
+func TestDescribePlan(t *testing.T) {
+	plan := map[string]interface{}{
+		"Node Type": "Hash Join", "Join Type": "Inner", "Total Cost": 100.5,
+		"Plans": []interface{}{
+			map[string]interface{}{"Node Type": "Seq Scan", "Relation Name": "orders", "Alias": "o"},
+			map[string]interface{}{"Node Type": "Hash", "Plans": []interface{}{
+				map[string]interface{}{"Node Type": "Index Scan", "Relation Name": "users", "Index Name": "users_pkey"},
+			}},
+		},
+	}
+	assert.Equal(t, "Hash Join (Inner) [Seq Scan on orders, Hash [Index Scan using users_pkey on users]]", DescribePlan(plan))
+
+	children := make([]interface{}, 0, 1000)
+	for i := 0; i < 1000; i++ {
+		children = append(children, map[string]interface{}{"Node Type": "Seq Scan", "Relation Name": "orders"})
+	}
+	assert.Len(t, DescribePlan(map[string]interface{}{"Node Type": "Append", "Plans": children}), MaxAttributeLength)
 }
@@ -4,8 +4,12 @@ import "errors"
 
 // The maximum number of metrics to be published in a single batch
 const PublishThreshold = 600
-const RandomIntRange = 1000000
-const TimeFormat = "20060102150405"
+
+// The maximum length of the text attributes, as query texts are truncated by the queries
+const MaxAttributeLength = 4095
+
+// The minimum growth in milliseconds of the average execution time of a query with a new plan to report the plan change
+const MinPlanChangeDeltaMs = 1.0
 
 // The maximum number of individual queries that can be fetched in a single metrics, the value was chosen as the queries samples were with same query statements but with different parameters so 10 samples would be enough to check the execution plan
 const MaxIndividualQueryCountThreshold = 10
@@ -29,7 +29,7 @@ func TestIngestMetric(t *testing.T) {
 		Hostname: "localhost",
 		Port:     "5432",
 	}
-	cp := common_parameters.SetCommonParameters(args, uint64(14), "testdb")
+	cp := common_parameters.SetCommonParameters(args, uint64(14), []string{"testdb"})
 	metricList := []interface{}{
 		struct {
 			TestField int `metric_name:"testField" source_type:"gauge"`
@@ -49,7 +49,7 @@ func TestCreateEntity(t *testing.T) {
 		Hostname: "localhost",
 		Port:     "5432",
 	}
-	cp := common_parameters.SetCommonParameters(args, uint64(14), "testdb")
+	cp := common_parameters.SetCommonParameters(args, uint64(14), []string{"testdb"})
 
 	entity, err := commonutils.CreateEntity(pgIntegration, cp)
 	assert.NoError(t, err)
@@ -78,7 +78,7 @@ func TestPublishMetrics(t *testing.T) {
 		Hostname: "localhost",
 		Port:     "5432",
 	}
-	cp := common_parameters.SetCommonParameters(args, uint64(14), "testdb")
+	cp := common_parameters.SetCommonParameters(args, uint64(14), []string{"testdb"})
 	entity, _ := commonutils.CreateEntity(pgIntegration, cp)
 
 	err := commonutils.PublishMetrics(pgIntegration, &entity, cp)
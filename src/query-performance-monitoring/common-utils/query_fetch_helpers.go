@@ -7,9 +7,9 @@ import (
 func FetchVersionSpecificSlowQuery(version uint64) (string, error) {
 	switch {
 	case version == PostgresVersion12:
-		return queries.SlowQueriesForV12, nil
+		return queries.SlowQueryCountersForV12, nil
 	case version >= PostgresVersion13:
-		return queries.SlowQueriesForV13AndAbove, nil
+		return queries.SlowQueryCountersForV13AndAbove, nil
 	default:
 		return "", ErrUnsupportedVersion
 	}
@@ -31,8 +31,8 @@ func TestFetchVersionSpecificSlowQueries(t *testing.T) {
 		expected  string
 		expectErr bool
 	}{
-		{commonutils.PostgresVersion12, queries.SlowQueriesForV12, false},
-		{commonutils.PostgresVersion13, queries.SlowQueriesForV13AndAbove, false},
+		{commonutils.PostgresVersion12, queries.SlowQueryCountersForV12, false},
+		{commonutils.PostgresVersion13, queries.SlowQueryCountersForV13AndAbove, false},
 		{commonutils.PostgresVersion11, "", true},
 	}
 
@@ -0,0 +1,511 @@
+package commonutils
+
+import (
+	"strconv"
+	"strings"
+)
+
+// sqlTokenKind is the kind of a token of a query, as read by the PostgreSQL lexer
+type sqlTokenKind int
+
+const (
+	tokenWhitespace sqlTokenKind = iota
+	tokenComment
+	// tokenIdentifier is a keyword or an unquoted identifier
+	tokenIdentifier
+	// tokenQuotedIdentifier is a "quoted" or U&"quoted" identifier
+	tokenQuotedIdentifier
+	// tokenString is a '...', E'...', B'...', X'...', U&'...' or dollar-quoted string
+	tokenString
+	tokenNumber
+	// tokenParameter is a $n parameter
+	tokenParameter
+	tokenOperator
+	// tokenPunctuation is one of , ( ) [ ] ; . : :: .. or a character the lexer does not know
+	tokenPunctuation
+)
+
+// sqlToken is a token of a query. Concatenating the tokens of a query gives back the query.
+type sqlToken struct {
+	kind sqlTokenKind
+	text string
+}
+
+// operatorChars are the characters operators are made of
+const operatorChars = "~!@#^&|`?+-*/%<>="
+
+// expressionKeywords are the keywords followed by an expression or a subquery, after which a minus sign negates the
+// number that follows it instead of subtracting it
+var expressionKeywords = map[string]bool{
+	"all": true, "and": true, "any": true, "array": true, "as": true, "between": true, "by": true, "case": true,
+	"default": true, "distinct": true, "do": true, "else": true, "escape": true, "except": true, "exists": true,
+	"fetch": true, "for": true, "from": true, "having": true, "ilike": true, "in": true, "intersect": true, "into": true,
+	"is": true, "join": true, "lateral": true, "like": true, "limit": true, "not": true, "offset": true, "on": true,
+	"or": true, "return": true, "returning": true, "select": true, "set": true, "similar": true, "some": true,
+	"symmetric": true, "then": true, "to": true, "union": true, "using": true, "values": true, "when": true,
+	"where": true, "with": true,
+}
+
+// typeModifierKeywords are the types whose parenthesized modifiers, as in varchar(10) or numeric(10, 2), are not
+// constants of the query
+var typeModifierKeywords = map[string]bool{
+	"bit": true, "char": true, "character": true, "dec": true, "decimal": true, "float": true, "interval": true,
+	"nchar": true, "numeric": true, "time": true, "timestamp": true, "varchar": true, "varying": true,
+}
+
+// sortListFollowers are the keywords that can follow a column position of an ORDER BY or GROUP BY list
+var sortListFollowers = map[string]bool{
+	"asc": true, "desc": true, "except": true, "fetch": true, "for": true, "group": true, "having": true,
+	"intersect": true, "limit": true, "nulls": true, "offset": true, "order": true, "union": true, "using": true,
+	"window": true,
+}
+
+// sortListEnds are the keywords ending an ORDER BY or GROUP BY list
+var sortListEnds = map[string]bool{
+	"except": true, "fetch": true, "for": true, "from": true, "having": true, "intersect": true, "into": true,
+	"limit": true, "offset": true, "returning": true, "select": true, "set": true, "union": true, "values": true,
+	"where": true, "window": true,
+}
+
+// tokenizeSQL splits query into tokens the way the PostgreSQL lexer does. Unterminated strings, quoted identifiers and
+// comments, as in query texts truncated by track_activity_query_size, extend to the end of the query.
+func tokenizeSQL(query string) []sqlToken {
+	var tokens []sqlToken
+	for pos := 0; pos < len(query); {
+		kind, end := scanToken(query, pos)
+		tokens = append(tokens, sqlToken{kind: kind, text: query[pos:end]})
+		pos = end
+	}
+	return tokens
+}
+
+// scanToken returns the kind and the end of the token starting at pos
+func scanToken(query string, pos int) (sqlTokenKind, int) {
+	c := query[pos]
+	switch {
+	case isSQLSpace(c):
+		end := pos + 1
+		for end < len(query) && isSQLSpace(query[end]) {
+			end++
+		}
+		return tokenWhitespace, end
+	case strings.HasPrefix(query[pos:], "--"):
+		return tokenComment, scanLineComment(query, pos)
+	case strings.HasPrefix(query[pos:], "/*"):
+		return tokenComment, scanBlockComment(query, pos)
+	case c == '\'':
+		return tokenString, scanString(query, pos, false)
+	case c == '"':
+		return tokenQuotedIdentifier, scanQuoted(query, pos+1, '"', false)
+	case c == '$':
+		if pos+1 < len(query) && isDigit(query[pos+1]) {
+			end := pos + 1
+			for end < len(query) && isDigit(query[end]) {
+				end++
+			}
+			return tokenParameter, end
+		}
+		if end, ok := scanDollarQuote(query, pos); ok {
+			return tokenString, end
+		}
+		return tokenPunctuation, pos + 1
+	case isDigit(c) || (c == '.' && pos+1 < len(query) && isDigit(query[pos+1])):
+		return tokenNumber, scanNumber(query, pos)
+	case isIdentifierStart(c):
+		return scanIdentifier(query, pos)
+	case strings.IndexByte(operatorChars, c) >= 0:
+		return tokenOperator, scanOperator(query, pos)
+	case strings.HasPrefix(query[pos:], "::") || strings.HasPrefix(query[pos:], ".."):
+		return tokenPunctuation, pos + 2
+	default:
+		return tokenPunctuation, pos + 1
+	}
+}
+
+func scanLineComment(query string, pos int) int {
+	end := strings.IndexAny(query[pos:], "\r\n")
+	if end < 0 {
+		return len(query)
+	}
+	return pos + end
+}
+
+// scanBlockComment returns the end of the /* comment */ starting at pos, comments being nested in PostgreSQL
+func scanBlockComment(query string, pos int) int {
+	depth := 0
+	for end := pos; end < len(query); {
+		switch {
+		case strings.HasPrefix(query[end:], "/*"):
+			depth++
+			end += 2
+		case strings.HasPrefix(query[end:], "*/"):
+			depth--
+			end += 2
+			if depth == 0 {
+				return end
+			}
+		default:
+			end++
+		}
+	}
+	return len(query)
+}
+
+// scanIdentifier returns the kind and the end of the identifier starting at pos, or of the E'...', B'...', X'...' or
+// U&'...' string or U&"..." identifier it prefixes. N'...' is read as the NCHAR keyword followed by a string, as
+// PostgreSQL does.
+func scanIdentifier(query string, pos int) (sqlTokenKind, int) {
+	if pos+1 < len(query) && query[pos+1] == '\'' {
+		switch query[pos] {
+		case 'e', 'E':
+			return tokenString, scanString(query, pos+1, true)
+		case 'b', 'B', 'x', 'X':
+			return tokenString, scanString(query, pos+1, false)
+		}
+	}
+	if (query[pos] == 'u' || query[pos] == 'U') && pos+2 < len(query) && query[pos+1] == '&' {
+		switch query[pos+2] {
+		case '\'':
+			return tokenString, scanString(query, pos+2, false)
+		case '"':
+			return tokenQuotedIdentifier, scanQuoted(query, pos+3, '"', false)
+		}
+	}
+	end := pos + 1
+	for end < len(query) && (isIdentifierStart(query[end]) || isDigit(query[end]) || query[end] == '$') {
+		end++
+	}
+	return tokenIdentifier, end
+}
+
+// scanString returns the end of the string whose opening quote is at pos, including the strings continuing it after a
+// newline, which PostgreSQL concatenates. Backslashes escape the next character when escapes is set.
+func scanString(query string, pos int, escapes bool) int {
+	end := scanQuoted(query, pos+1, '\'', escapes)
+	for {
+		next := stringContinuation(query, end)
+		if next < 0 {
+			return end
+		}
+		end = scanQuoted(query, next+1, '\'', escapes)
+	}
+}
+
+// stringContinuation returns the position of the quote continuing the string ending at pos, or -1
+func stringContinuation(query string, pos int) int {
+	newline := false
+	for pos < len(query) {
+		switch {
+		case query[pos] == '\n' || query[pos] == '\r':
+			newline = true
+			pos++
+		case isSQLSpace(query[pos]):
+			pos++
+		case strings.HasPrefix(query[pos:], "--"):
+			pos = scanLineComment(query, pos)
+		case query[pos] == '\'' && newline:
+			return pos
+		default:
+			return -1
+		}
+	}
+	return -1
+}
+
+// scanQuoted returns the end of the text quoted by quote starting at pos, a doubled quote standing for the quote itself
+func scanQuoted(query string, pos int, quote byte, escapes bool) int {
+	for pos < len(query) {
+		switch {
+		case escapes && query[pos] == '\\':
+			pos += 2
+		case query[pos] != quote:
+			pos++
+		case pos+1 < len(query) && query[pos+1] == quote:
+			pos += 2
+		default:
+			return pos + 1
+		}
+	}
+	return len(query)
+}
+
+// scanDollarQuote returns the end of the $tag$ quoted string starting at pos, if there is a dollar quote at pos
+func scanDollarQuote(query string, pos int) (int, bool) {
+	end := pos + 1
+	if end < len(query) && isIdentifierStart(query[end]) {
+		for end < len(query) && (isIdentifierStart(query[end]) || isDigit(query[end])) {
+			end++
+		}
+	}
+	if end >= len(query) || query[end] != '$' {
+		return 0, false
+	}
+	tag := query[pos : end+1]
+	closing := strings.Index(query[end+1:], tag)
+	if closing < 0 {
+		return len(query), true
+	}
+	return end + 1 + closing + len(tag), true
+}
+
+// scanNumber returns the end of the integer, decimal, exponent, hexadecimal, octal or binary number starting at pos
+func scanNumber(query string, pos int) int {
+	if query[pos] == '0' && pos+2 < len(query) {
+		var digit func(byte) bool
+		switch query[pos+1] {
+		case 'x', 'X':
+			digit = isHexDigit
+		case 'o', 'O':
+			digit = func(c byte) bool { return c >= '0' && c <= '7' }
+		case 'b', 'B':
+			digit = func(c byte) bool { return c == '0' || c == '1' }
+		}
+		if digit != nil && digit(query[pos+2]) {
+			return scanDigits(query, pos+2, digit)
+		}
+	}
+	end := scanDigits(query, pos, isDigit)
+	// "1..10" is the integer 1 followed by ".."
+	if end < len(query) && query[end] == '.' && !strings.HasPrefix(query[end:], "..") {
+		end = scanDigits(query, end+1, isDigit)
+	}
+	if end < len(query) && (query[end] == 'e' || query[end] == 'E') {
+		exponent := end + 1
+		if exponent < len(query) && (query[exponent] == '+' || query[exponent] == '-') {
+			exponent++
+		}
+		if exponent < len(query) && isDigit(query[exponent]) {
+			end = scanDigits(query, exponent, isDigit)
+		}
+	}
+	return end
+}
+
+// scanDigits returns the end of the digits starting at pos, which underscores may separate
+func scanDigits(query string, pos int, digit func(byte) bool) int {
+	end := pos
+	for end < len(query) {
+		switch {
+		case digit(query[end]):
+			end++
+		case query[end] == '_' && end > pos && end+1 < len(query) && digit(query[end+1]):
+			end += 2
+		default:
+			return end
+		}
+	}
+	return end
+}
+
+// scanOperator returns the end of the operator starting at pos. As in PostgreSQL, an operator stops before a comment,
+// and a trailing + or - starts the next token unless the operator contains one of ~!@#^&|`?%, so that "x=-1" reads as
+// x = -1.
+func scanOperator(query string, pos int) int {
+	end := pos
+	for end < len(query) && strings.IndexByte(operatorChars, query[end]) >= 0 {
+		if end > pos && (strings.HasPrefix(query[end:], "--") || strings.HasPrefix(query[end:], "/*")) {
+			break
+		}
+		end++
+	}
+	if end-pos > 1 && !strings.ContainsAny(query[pos:end], "~!@#^&|`?%") {
+		for end-pos > 1 && (query[end-1] == '+' || query[end-1] == '-') {
+			end--
+		}
+	}
+	return end
+}
+
+// constantSpan are the tokens of a constant, from first to last, which are more than one token for negative numbers
+type constantSpan struct {
+	first, last int
+}
+
+// parenContext is what a parenthesis of a query holds
+type parenContext struct {
+	// call is set for the arguments of a function or a window, whose ORDER BY does not refer to column positions
+	call bool
+	// typeModifiers is set for the modifiers of a type, which are not constants
+	typeModifiers bool
+	// sortList is set while reading the ORDER BY or GROUP BY list of a query, whose integers are column positions
+	sortList bool
+}
+
+// findConstants returns the tokens that pg_stat_statements replaces with parameters: the strings, numbers, negative
+// numbers, booleans and nulls of the query, except for the modifiers of types, the column positions of ORDER BY and
+// GROUP BY, the operands of IS and the escape character of U&'...' strings.
+func findConstants(tokens []sqlToken) []constantSpan {
+	var constants []constantSpan
+	contexts := []parenContext{{}}
+	// the indexes of the previous two significant tokens, or -1
+	prev, prev2 := -1, -1
+	word := func(i int) string {
+		if i < 0 || tokens[i].kind != tokenIdentifier {
+			return ""
+		}
+		return strings.ToLower(tokens[i].text)
+	}
+	text := func(i int) string {
+		if i < 0 {
+			return ""
+		}
+		return tokens[i].text
+	}
+
+	for i, token := range tokens {
+		if token.kind == tokenWhitespace || token.kind == tokenComment {
+			continue
+		}
+		context := &contexts[len(contexts)-1]
+
+		switch token.kind {
+		case tokenString:
+			if word(prev) != "uescape" {
+				constants = append(constants, constantSpan{i, i})
+			}
+		case tokenNumber:
+			switch {
+			case context.typeModifiers || (context.sortList && isColumnPosition(tokens, prev, i)):
+			case text(prev) == "-" && tokens[prev].kind == tokenOperator && !endsOperand(tokens, prev2):
+				constants = append(constants, constantSpan{prev, i})
+			default:
+				constants = append(constants, constantSpan{i, i})
+			}
+		case tokenIdentifier:
+			switch lower := word(i); {
+			case lower == "true" || lower == "false" || lower == "null":
+				if word(prev) != "is" && (word(prev) != "not" || word(prev2) != "is") {
+					constants = append(constants, constantSpan{i, i})
+				}
+			case lower == "by" && (word(prev) == "order" || word(prev) == "group"):
+				context.sortList = !context.call
+			case sortListEnds[lower]:
+				context.sortList = false
+			}
+		case tokenPunctuation:
+			switch token.text {
+			case "(":
+				contexts = append(contexts, parenContext{
+					call:          isFunctionName(tokens, prev) || word(prev) == "over",
+					typeModifiers: typeModifierKeywords[word(prev)],
+				})
+			case ")":
+				if len(contexts) > 1 {
+					contexts = contexts[:len(contexts)-1]
+				}
+			case ";":
+				contexts = []parenContext{{}}
+			}
+		}
+		prev, prev2 = i, prev
+	}
+	return constants
+}
+
+// isColumnPosition returns whether the number at i, in an ORDER BY or GROUP BY list, is a whole item of the list
+func isColumnPosition(tokens []sqlToken, prev, i int) bool {
+	if !isInteger(tokens[i].text) {
+		return false
+	}
+	if prev < 0 || (tokens[prev].text != "," && !strings.EqualFold(tokens[prev].text, "by")) {
+		return false
+	}
+	next := nextSignificant(tokens, i)
+	if next < 0 {
+		return true
+	}
+	switch tokens[next].kind {
+	case tokenPunctuation:
+		return tokens[next].text == "," || tokens[next].text == ")" || tokens[next].text == ";"
+	case tokenIdentifier:
+		return sortListFollowers[strings.ToLower(tokens[next].text)]
+	default:
+		return false
+	}
+}
+
+func isInteger(number string) bool {
+	if len(number) > 1 && number[0] == '0' && strings.ContainsAny(number[1:2], "xXoObB") {
+		return true
+	}
+	return !strings.ContainsAny(number, ".eE")
+}
+
+// isFunctionName returns whether the token at i, followed by a parenthesis, names a function
+func isFunctionName(tokens []sqlToken, i int) bool {
+	return i >= 0 && (tokens[i].kind == tokenQuotedIdentifier || (tokens[i].kind == tokenIdentifier && endsOperand(tokens, i)))
+}
+
+func nextSignificant(tokens []sqlToken, i int) int {
+	for next := i + 1; next < len(tokens); next++ {
+		if tokens[next].kind != tokenWhitespace && tokens[next].kind != tokenComment {
+			return next
+		}
+	}
+	return -1
+}
+
+// endsOperand returns whether the token at i can end an operand, making a minus sign after it a subtraction
+func endsOperand(tokens []sqlToken, i int) bool {
+	if i < 0 {
+		return false
+	}
+	switch tokens[i].kind {
+	case tokenNumber, tokenString, tokenParameter, tokenQuotedIdentifier:
+		return true
+	case tokenIdentifier:
+		return !expressionKeywords[strings.ToLower(tokens[i].text)]
+	case tokenPunctuation:
+		return tokens[i].text == ")" || tokens[i].text == "]"
+	default:
+		return false
+	}
+}
+
+// replaceConstants returns tokens with their constants replaced by $n parameters the way pg_stat_statements does,
+// numbered in order of appearance after the highest parameter of the query
+func replaceConstants(tokens []sqlToken) []sqlToken {
+	constants := findConstants(tokens)
+	if len(constants) == 0 {
+		return tokens
+	}
+	parameter := 1
+	for _, token := range tokens {
+		if token.kind != tokenParameter {
+			continue
+		}
+		if n, err := strconv.Atoi(token.text[1:]); err == nil && n >= parameter {
+			parameter = n + 1
+		}
+	}
+
+	replaced := make([]sqlToken, 0, len(tokens))
+	for i := 0; i < len(tokens); i++ {
+		if len(constants) > 0 && constants[0].first == i {
+			replaced = append(replaced, sqlToken{kind: tokenParameter, text: "$" + strconv.Itoa(parameter)})
+			parameter++
+			i = constants[0].last
+			constants = constants[1:]
+			continue
+		}
+		replaced = append(replaced, tokens[i])
+	}
+	return replaced
+}
+
+func isSQLSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f' || c == '\v'
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isHexDigit(c byte) bool {
+	return isDigit(c) || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+}
+
+// isIdentifierStart returns whether c starts an identifier, bytes of multibyte characters included
+func isIdentifierStart(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || c == '_' || c >= 0x80
+}
@@ -0,0 +1,153 @@
+package commonutils
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTokenizeSQL(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected []sqlToken
+	}{
+		{
+			name:  "keywords, identifiers and numbers",
+			input: "SELECT t1.col_2 FROM t1",
+			expected: []sqlToken{
+				{tokenIdentifier, "SELECT"}, {tokenWhitespace, " "}, {tokenIdentifier, "t1"}, {tokenPunctuation, "."},
+				{tokenIdentifier, "col_2"}, {tokenWhitespace, " "}, {tokenIdentifier, "FROM"}, {tokenWhitespace, " "},
+				{tokenIdentifier, "t1"},
+			},
+		},
+		{
+			name:  "strings and quoted identifiers",
+			input: `"a""b"E'\''$x$'$x$U&'c'`,
+			expected: []sqlToken{
+				{tokenQuotedIdentifier, `"a""b"`}, {tokenString, `E'\''`}, {tokenString, "$x$'$x$"}, {tokenString, "U&'c'"},
+			},
+		},
+		{
+			name:  "parameters and typecasts",
+			input: "$12::int",
+			expected: []sqlToken{
+				{tokenParameter, "$12"}, {tokenPunctuation, "::"}, {tokenIdentifier, "int"},
+			},
+		},
+		{
+			name:  "operators",
+			input: "a<>-1 OR b->>'c'",
+			expected: []sqlToken{
+				{tokenIdentifier, "a"}, {tokenOperator, "<>"}, {tokenOperator, "-"}, {tokenNumber, "1"},
+				{tokenWhitespace, " "}, {tokenIdentifier, "OR"}, {tokenWhitespace, " "}, {tokenIdentifier, "b"},
+				{tokenOperator, "->>"}, {tokenString, "'c'"},
+			},
+		},
+		{
+			name:  "comments",
+			input: "1--x\n/* /* */ */2",
+			expected: []sqlToken{
+				{tokenNumber, "1"}, {tokenComment, "--x"}, {tokenWhitespace, "\n"}, {tokenComment, "/* /* */ */"},
+				{tokenNumber, "2"},
+			},
+		},
+		{
+			name:  "deeply nested comments",
+			input: "/* a /* b /* c */ */ d */x",
+			expected: []sqlToken{
+				{tokenComment, "/* a /* b /* c */ */ d */"}, {tokenIdentifier, "x"},
+			},
+		},
+		{
+			name:  "dollar quotes with tags",
+			input: "$fn$ $body$ ' $x $fn$$$ $$ $a1$",
+			expected: []sqlToken{
+				{tokenString, "$fn$ $body$ ' $x $fn$"}, {tokenString, "$$ $$"}, {tokenWhitespace, " "}, {tokenString, "$a1$"},
+			},
+		},
+		{
+			name:  "escape strings",
+			input: `E'a\'b\\' e'\n''c' 'a\'`,
+			expected: []sqlToken{
+				{tokenString, `E'a\'b\\'`}, {tokenWhitespace, " "}, {tokenString, `e'\n''c'`}, {tokenWhitespace, " "},
+				{tokenString, `'a\'`},
+			},
+		},
+		{
+			name:  "unicode strings and identifiers",
+			input: `U&'d\0061t' UESCAPE '!' u&"c\0061"`,
+			expected: []sqlToken{
+				{tokenString, `U&'d\0061t'`}, {tokenWhitespace, " "}, {tokenIdentifier, "UESCAPE"}, {tokenWhitespace, " "},
+				{tokenString, "'!'"}, {tokenWhitespace, " "}, {tokenQuotedIdentifier, `u&"c\0061"`},
+			},
+		},
+		{
+			name:  "bit and hexadecimal strings",
+			input: "B'1010'X'1f'b'0'x''",
+			expected: []sqlToken{
+				{tokenString, "B'1010'"}, {tokenString, "X'1f'"}, {tokenString, "b'0'"}, {tokenString, "x''"},
+			},
+		},
+		{
+			name:  "strings continued after a newline",
+			input: "'a'\n  'b' 'c'",
+			expected: []sqlToken{
+				{tokenString, "'a'\n  'b'"}, {tokenWhitespace, " "}, {tokenString, "'c'"},
+			},
+		},
+		{
+			name:  "digits and dollars inside identifiers",
+			input: "t2.c3_4 a$1 _9",
+			expected: []sqlToken{
+				{tokenIdentifier, "t2"}, {tokenPunctuation, "."}, {tokenIdentifier, "c3_4"}, {tokenWhitespace, " "},
+				{tokenIdentifier, "a$1"}, {tokenWhitespace, " "}, {tokenIdentifier, "_9"},
+			},
+		},
+		{
+			name:  "numbers",
+			input: "1.5e-3 .5 0x1F 0o17 0b101 1_000 1..10",
+			expected: []sqlToken{
+				{tokenNumber, "1.5e-3"}, {tokenWhitespace, " "}, {tokenNumber, ".5"}, {tokenWhitespace, " "},
+				{tokenNumber, "0x1F"}, {tokenWhitespace, " "}, {tokenNumber, "0o17"}, {tokenWhitespace, " "},
+				{tokenNumber, "0b101"}, {tokenWhitespace, " "}, {tokenNumber, "1_000"}, {tokenWhitespace, " "},
+				{tokenNumber, "1"}, {tokenPunctuation, ".."}, {tokenNumber, "10"},
+			},
+		},
+		{
+			name:  "signs after operators",
+			input: "x=-1 y*-2 z@-3",
+			expected: []sqlToken{
+				{tokenIdentifier, "x"}, {tokenOperator, "="}, {tokenOperator, "-"}, {tokenNumber, "1"},
+				{tokenWhitespace, " "}, {tokenIdentifier, "y"}, {tokenOperator, "*"}, {tokenOperator, "-"},
+				{tokenNumber, "2"}, {tokenWhitespace, " "}, {tokenIdentifier, "z"}, {tokenOperator, "@-"},
+				{tokenNumber, "3"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, tokenizeSQL(tt.input))
+		})
+	}
+}
+
+func TestTokenizeSQLKeepsText(t *testing.T) {
+	queries := []string{
+		"SELECT * FROM t WHERE a = 'unterminated",
+		"SELECT $tag$ never closed",
+		`SELECT "unterminated`,
+		"SELECT E'\\",
+		"SELECT 1e, 0x, 1_, $, U&, x & y /* open",
+	}
+	for _, query := range queries {
+		var text strings.Builder
+		for _, token := range tokenizeSQL(query) {
+			assert.NotEmpty(t, token.text)
+			text.WriteString(token.text)
+		}
+		assert.Equal(t, query, text.String())
+	}
+}
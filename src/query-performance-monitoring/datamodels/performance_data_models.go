@@ -10,11 +10,27 @@ type SlowRunningQueryMetrics struct {
 	AvgElapsedTimeMs    *float64 `db:"avg_elapsed_time_ms"   metric_name:"avg_elapsed_time_ms"        source_type:"gauge"`
 	AvgDiskReads        *float64 `db:"avg_disk_reads"        metric_name:"avg_disk_reads"             source_type:"gauge"`
 	AvgDiskWrites       *float64 `db:"avg_disk_writes"       metric_name:"avg_disk_writes"            source_type:"gauge"`
+	TotalElapsedTimeMs  *float64 `db:"total_elapsed_time_ms" metric_name:"total_elapsed_time_ms"      source_type:"gauge"`
+	TotalDiskReads      *int64   `db:"total_disk_reads"      metric_name:"total_disk_reads"           source_type:"gauge"`
+	TotalDiskWrites     *int64   `db:"total_disk_writes"     metric_name:"total_disk_writes"          source_type:"gauge"`
+	IntervalSeconds     *int64   `db:"interval_seconds"      metric_name:"interval_seconds"           source_type:"gauge"`
 	StatementType       *string  `db:"statement_type"        metric_name:"statement_type"             source_type:"attribute"`
 	CollectionTimestamp *string  `db:"collection_timestamp"  metric_name:"collection_timestamp"       source_type:"attribute"`
 	IndividualQuery     *string  `db:"individual_query"      metric_name:"individual_query"           source_type:"attribute" ingest_data:"false"`
 }
 
+// SlowQueryCounters are the cumulative pg_stat_statements counters of a statement
+type SlowQueryCounters struct {
+	Newrelic          *string `db:"newrelic"`
+	QueryID           int64   `db:"query_id"`
+	DBID              int64   `db:"dbid"`
+	UserID            int64   `db:"userid"`
+	Calls             int64   `db:"calls"`
+	TotalTimeMs       float64 `db:"total_time_ms"`
+	SharedBlksRead    int64   `db:"shared_blks_read"`
+	SharedBlksWritten int64   `db:"shared_blks_written"`
+}
+
 type WaitEventMetrics struct {
 	WaitEventName       *string  `db:"wait_event_name"       metric_name:"wait_event_name"            source_type:"attribute"`
 	WaitCategory        *string  `db:"wait_category"         metric_name:"wait_category"              source_type:"attribute"`
@@ -47,6 +63,8 @@ type IndividualQueryMetrics struct {
 	ExecTimeInMs    *float64 `json:"exec_time_ms" db:"exec_time_ms" metric_name:"exec_time_ms" source_type:"gauge"`
 	AvgExecTimeInMs *float64 `json:"avg_exec_time_ms" metric_name:"avg_exec_time_ms" source_type:"gauge"`
 	Newrelic        *string  `db:"newrelic"              metric_name:"newrelic"            source_type:"attribute"  ingest_data:"false"`
+	// ExecutionPlan is the EXPLAIN plan of RealQueryText once it was explained
+	ExecutionPlan map[string]interface{} `ingest_data:"false"`
 }
 
 type QueryExecutionPlanMetrics struct {
@@ -67,3 +85,21 @@ type QueryExecutionPlanMetrics struct {
 	PlanID              string  `mapstructure:"Plan Id"             json:"Plan Id"             metric_name:"plan_id"              source_type:"attribute"`
 	Level               int     `mapstructure:"Level"               json:"Level"               metric_name:"level_id"             source_type:"gauge"`
 }
+
+// PlanChangeEvent reports a query whose execution time got worse after its plan changed
+type PlanChangeEvent struct {
+	QueryID               string   `metric_name:"query_id"                 source_type:"attribute"`
+	QueryText             string   `metric_name:"query_text"               source_type:"attribute"`
+	DatabaseName          string   `metric_name:"database_name"            source_type:"attribute"`
+	OldPlanID             string   `metric_name:"old_plan_id"              source_type:"attribute"`
+	NewPlanID             string   `metric_name:"new_plan_id"              source_type:"attribute"`
+	OldPlan               *string  `metric_name:"old_plan"                 source_type:"attribute"`
+	NewPlan               *string  `metric_name:"new_plan"                 source_type:"attribute"`
+	OldAvgExecTimeMs      float64  `metric_name:"old_avg_exec_time_ms"     source_type:"gauge"`
+	NewAvgExecTimeMs      float64  `metric_name:"new_avg_exec_time_ms"     source_type:"gauge"`
+	ExecTimeDeltaMs       float64  `metric_name:"exec_time_delta_ms"       source_type:"gauge"`
+	ExecTimeChangePercent float64  `metric_name:"exec_time_change_percent" source_type:"gauge"`
+	OldTotalCost          *float64 `metric_name:"old_total_cost"           source_type:"gauge"`
+	NewTotalCost          *float64 `metric_name:"new_total_cost"           source_type:"gauge"`
+	CollectionTimestamp   string   `metric_name:"collection_timestamp"     source_type:"attribute"`
+}
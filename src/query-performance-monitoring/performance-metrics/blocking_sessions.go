@@ -1,17 +1,18 @@
 package performancemetrics
 
 import (
-	"fmt"
 	"github.com/newrelic/nri-postgresql/src/query-performance-monitoring/queries"
 
 	commonparameters "github.com/newrelic/nri-postgresql/src/query-performance-monitoring/common-parameters"
 
+	"github.com/lib/pq"
 	"github.com/newrelic/infra-integrations-sdk/v3/integration"
 	commonutils "github.com/newrelic/nri-postgresql/src/query-performance-monitoring/common-utils"
 	"github.com/newrelic/nri-postgresql/src/query-performance-monitoring/validations"
 
 	"github.com/newrelic/infra-integrations-sdk/v3/log"
 	performancedbconnection "github.com/newrelic/nri-postgresql/src/connection"
+	"github.com/newrelic/nri-postgresql/src/metrics"
 	"github.com/newrelic/nri-postgresql/src/query-performance-monitoring/datamodels"
 )
 
@@ -19,6 +20,7 @@ func PopulateBlockingMetrics(conn *performancedbconnection.PGSQLConnection, pgIn
 	isEligible := validations.CheckBlockingSessionMetricsFetchEligibility(enabledExtensions, cp.Version)
 	if !isEligible {
 		log.Debug("Extension 'pg_stat_statements' is not enabled or unsupported version.")
+		metrics.SkipCollector(conn.Context(), "pg_stat_statements extension is required")
 		return
 	}
 	blockingQueriesMetricsList, blockQueryFetchErr := getBlockingMetrics(conn, cp)
@@ -44,8 +46,7 @@ func getBlockingMetrics(conn *performancedbconnection.PGSQLConnection, cp *commo
 		log.Error("Unsupported postgres version: %v", err)
 		return nil, err
 	}
-	var query = fmt.Sprintf(versionSpecificBlockingQuery, cp.Databases, cp.QueryMonitoringCountThreshold)
-	rows, err := conn.Queryx(query)
+	rows, err := conn.Queryx(versionSpecificBlockingQuery, pq.Array(cp.Databases), cp.QueryMonitoringCountThreshold)
 	if err != nil {
 		log.Error("Failed to execute query: %v", err)
 		return nil, commonutils.ErrUnExpectedError
@@ -71,6 +72,7 @@ func PopulateBlockingMetricsPgStat(conn *performancedbconnection.PGSQLConnection
 	isEligible := validations.CheckBlockingSessionMetricsFetchEligibility(enabledExtensions, cp.Version)
 	if !isEligible {
 		log.Debug("Extension 'pg_stat_statements' is not enabled or unsupported version.")
+		metrics.SkipCollector(conn.Context(), "pg_stat_statements extension is required")
 		return
 	}
 	blockingQueriesMetricsList, blockQueryFetchErr := getBlockingMetricsPgStat(conn, cp)
@@ -92,8 +94,7 @@ func PopulateBlockingMetricsPgStat(conn *performancedbconnection.PGSQLConnection
 
 func getBlockingMetricsPgStat(conn *performancedbconnection.PGSQLConnection, cp *commonparameters.CommonParameters) ([]datamodels.BlockingSessionMetrics, error) {
 	var blockingQueriesMetricsList []datamodels.BlockingSessionMetrics
-	var query = fmt.Sprintf(queries.RDSPostgresBlockingQuery, cp.Databases, cp.QueryMonitoringCountThreshold)
-	rows, err := conn.Queryx(query)
+	rows, err := conn.Queryx(queries.RDSPostgresBlockingQuery, pq.Array(cp.Databases), cp.QueryMonitoringCountThreshold)
 	if err != nil {
 		log.Error("Failed to execute query: %v", err)
 		return nil, commonutils.ErrUnExpectedError
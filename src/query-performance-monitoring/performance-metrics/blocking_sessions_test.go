@@ -2,13 +2,13 @@ package performancemetrics
 
 import (
 	"database/sql/driver"
-	"fmt"
 	"regexp"
 	"testing"
 
 	commonutils "github.com/newrelic/nri-postgresql/src/query-performance-monitoring/common-utils"
 	"github.com/newrelic/nri-postgresql/src/query-performance-monitoring/datamodels"
 
+	"github.com/lib/pq"
 	"github.com/newrelic/nri-postgresql/src/args"
 	"github.com/newrelic/nri-postgresql/src/connection"
 	common_parameters "github.com/newrelic/nri-postgresql/src/query-performance-monitoring/common-parameters"
@@ -22,9 +22,9 @@ func TestGetBlockingMetrics(t *testing.T) {
 	args := args.ArgumentList{QueryMonitoringCountThreshold: 10}
 	databaseName := "testdb"
 	version := uint64(13)
-	cp := common_parameters.SetCommonParameters(args, version, databaseName)
+	cp := common_parameters.SetCommonParameters(args, version, []string{databaseName})
 	expectedQuery := queries.BlockingQueriesForV12AndV13
-	query := fmt.Sprintf(expectedQuery, databaseName, args.QueryMonitoringCountThreshold)
+	query := expectedQuery
 	rowData := []driver.Value{
 		"newrelic_value", int64(123), "SELECT 1", "1233444", "2023-01-01 00:00:00", "testdb",
 		int64(456), "SELECT 2", "4566", "2023-01-01 00:00:00",
@@ -36,7 +36,7 @@ func TestGetBlockingMetrics(t *testing.T) {
 		"newrelic", "blocked_pid", "blocked_query", "blocked_query_id", "blocked_query_start", "database_name",
 		"blocking_pid", "blocking_query", "blocking_query_id", "blocking_query_start",
 	}).AddRow(rowData...).AddRow(rowData...)
-	mock.ExpectQuery(regexp.QuoteMeta(query)).WillReturnRows(mockRows)
+	mock.ExpectQuery(regexp.QuoteMeta(query)).WithArgs(pq.Array([]string{databaseName}), args.QueryMonitoringCountThreshold).WillReturnRows(mockRows)
 	blockingQueriesMetricsList, err := getBlockingMetrics(conn, cp)
 	compareMockRowsWithMetrics(t, expectedRows, blockingQueriesMetricsList)
 	assert.NoError(t, err)
@@ -67,7 +67,7 @@ func TestGetBlockingMetricsErr(t *testing.T) {
 	args := args.ArgumentList{QueryMonitoringCountThreshold: 10}
 	databaseName := "testdb"
 	version := uint64(13)
-	cp := common_parameters.SetCommonParameters(args, version, databaseName)
+	cp := common_parameters.SetCommonParameters(args, version, []string{databaseName})
 	_, err := getBlockingMetrics(conn, cp)
 	assert.EqualError(t, err, commonutils.ErrUnExpectedError.Error())
 	assert.NoError(t, mock.ExpectationsWereMet())
@@ -76,11 +76,11 @@ func TestGetBlockingMetricsErr(t *testing.T) {
 func TestGetBlockingMetricsPgStat_Success(t *testing.T) {
 	conn, mock := connection.CreateMockSQL(t)
 	cp := &common_parameters.CommonParameters{
-		Databases:                     "testdb",
+		Databases:                     []string{"testdb"},
 		QueryMonitoringCountThreshold: 10,
 		Version:                       14,
 	}
-	query := fmt.Sprintf(queries.RDSPostgresBlockingQuery, cp.Databases, cp.QueryMonitoringCountThreshold)
+	query := queries.RDSPostgresBlockingQuery
 	mockRows := sqlmock.NewRows([]string{
 		"newrelic", "blocked_pid", "blocked_query", "blocked_query_start", "database_name",
 		"blocking_pid", "blocking_query", "blocking_query_start",
@@ -91,7 +91,7 @@ func TestGetBlockingMetricsPgStat_Success(t *testing.T) {
 		"newrelic_value", 789, "SELECT 3", "2023-01-02 00:00:00", "testdb",
 		101, "SELECT 4", "2023-01-02 00:00:00",
 	)
-	mock.ExpectQuery(regexp.QuoteMeta(query)).WillReturnRows(mockRows)
+	mock.ExpectQuery(regexp.QuoteMeta(query)).WithArgs(pq.Array(cp.Databases), cp.QueryMonitoringCountThreshold).WillReturnRows(mockRows)
 
 	blockingMetrics, err := getBlockingMetricsPgStat(conn, cp)
 
@@ -105,12 +105,12 @@ func TestGetBlockingMetricsPgStat_Success(t *testing.T) {
 func TestGetBlockingMetricsPgStat_Error(t *testing.T) {
 	conn, mock := connection.CreateMockSQL(t)
 	cp := &common_parameters.CommonParameters{
-		Databases:                     "testdb",
+		Databases:                     []string{"testdb"},
 		QueryMonitoringCountThreshold: 10,
 		Version:                       14,
 	}
-	query := fmt.Sprintf(queries.RDSPostgresBlockingQuery, cp.Databases, cp.QueryMonitoringCountThreshold)
-	mock.ExpectQuery(regexp.QuoteMeta(query)).WillReturnError(commonutils.ErrUnExpectedError)
+	query := queries.RDSPostgresBlockingQuery
+	mock.ExpectQuery(regexp.QuoteMeta(query)).WithArgs(pq.Array(cp.Databases), cp.QueryMonitoringCountThreshold).WillReturnError(commonutils.ErrUnExpectedError)
 
 	blockingMetrics, err := getBlockingMetricsPgStat(conn, cp)
 
@@ -1,7 +1,10 @@
 package performancemetrics
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 
 	"github.com/go-viper/mapstructure/v2"
 	"github.com/newrelic/infra-integrations-sdk/v3/integration"
@@ -12,12 +15,12 @@ import (
 	"github.com/newrelic/nri-postgresql/src/query-performance-monitoring/datamodels"
 )
 
-func PopulateExecutionPlanMetrics(results []datamodels.IndividualQueryMetrics, pgIntegration *integration.Integration, cp *commonparameters.CommonParameters, connectionInfo performancedbconnection.Info) {
+func PopulateExecutionPlanMetrics(ctx context.Context, results []datamodels.IndividualQueryMetrics, pgIntegration *integration.Integration, cp *commonparameters.CommonParameters, connectionInfo performancedbconnection.Info) {
 	if len(results) == 0 {
 		log.Debug("No individual queries found.")
 		return
 	}
-	executionDetailsList := getExecutionPlanMetrics(results, connectionInfo)
+	executionDetailsList := getExecutionPlanMetrics(ctx, results, connectionInfo)
 	err := commonutils.IngestMetric(executionDetailsList, "PostgresExecutionPlanMetrics", pgIntegration, cp)
 	if err != nil {
 		log.Error("Error ingesting Execution Plan metrics: %v", err)
@@ -25,78 +28,86 @@ func PopulateExecutionPlanMetrics(results []datamodels.IndividualQueryMetrics, p
 	}
 }
 
-func getExecutionPlanMetrics(results []datamodels.IndividualQueryMetrics, connectionInfo performancedbconnection.Info) []interface{} {
+func getExecutionPlanMetrics(ctx context.Context, results []datamodels.IndividualQueryMetrics, connectionInfo performancedbconnection.Info) []interface{} {
 	var executionPlanMetricsList []interface{}
-	var groupIndividualQueriesByDatabase = groupQueriesByDatabase(results)
-	for dbName, individualQueriesList := range groupIndividualQueriesByDatabase {
-		dbConn, err := connectionInfo.NewConnection(dbName)
-		if err != nil {
-			log.Error("Error opening database connection: %v", err)
+	explainQueries(ctx, results, connectionInfo, func(individualQuery datamodels.IndividualQueryMetrics) bool {
+		return individualQuery.ExecutionPlan == nil
+	})
+	for _, individualQuery := range results {
+		if individualQuery.ExecutionPlan == nil || individualQuery.QueryID == nil || individualQuery.PlanID == nil {
 			continue
 		}
-		processExecutionPlanOfQueries(individualQueriesList, dbConn, &executionPlanMetricsList)
-		dbConn.Close()
+		level := 0
+		fetchNestedExecutionPlanDetails(individualQuery, &level, individualQuery.ExecutionPlan, &executionPlanMetricsList)
 	}
-
 	return executionPlanMetricsList
 }
 
-func processExecutionPlanOfQueries(individualQueriesList []datamodels.IndividualQueryMetrics, dbConn *performancedbconnection.PGSQLConnection, executionPlanMetricsList *[]interface{}) {
-	for _, individualQuery := range individualQueriesList {
-		if individualQuery.RealQueryText == nil || individualQuery.QueryID == nil || individualQuery.DatabaseName == nil {
-			log.Error("QueryText, QueryID or Database Name is nil")
-			continue
-		}
-		query := "EXPLAIN (FORMAT JSON) " + *individualQuery.RealQueryText
-		rows, err := dbConn.Queryx(query)
-		if err != nil {
-			log.Debug("Error executing query: %v", err)
-			continue
-		}
-		defer rows.Close()
-		if !rows.Next() {
-			log.Debug("Execution plan not found for queryId", *individualQuery.QueryID)
-			continue
-		}
-		var execPlanJSON string
-		if scanErr := rows.Scan(&execPlanJSON); scanErr != nil {
-			log.Error("Error scanning row: ", scanErr.Error())
+// explainQueries explains the queries selected by needed, one database at a time, keeping the plan of each query.
+// Queries that pg_stat_monitor did not identify the plan of are identified by the hash of their plan.
+// The queries are explained with ctx.
+func explainQueries(ctx context.Context, results []datamodels.IndividualQueryMetrics, connectionInfo performancedbconnection.Info, needed func(datamodels.IndividualQueryMetrics) bool) {
+	queriesByDatabase := make(map[string][]int)
+	for i, individualQuery := range results {
+		if individualQuery.DatabaseName == nil || !needed(individualQuery) {
 			continue
 		}
+		dbName := *individualQuery.DatabaseName
+		queriesByDatabase[dbName] = append(queriesByDatabase[dbName], i)
+	}
 
-		var execPlan []map[string]interface{}
-		err = json.Unmarshal([]byte(execPlanJSON), &execPlan)
+	for dbName, indexes := range queriesByDatabase {
+		dbConn, err := connectionInfo.NewConnection(dbName)
 		if err != nil {
-			log.Error("Failed to unmarshal execution plan: %v", err)
+			log.Error("Error opening database connection: %v", err)
 			continue
 		}
-		validateAndFetchNestedExecPlan(execPlan, individualQuery, executionPlanMetricsList)
+		for _, i := range indexes {
+			execPlan, err := explainQuery(dbConn.WithContext(ctx), results[i])
+			if err != nil {
+				log.Debug("Execution plan not found: %v", err)
+				continue
+			}
+			results[i].ExecutionPlan = execPlan
+			if results[i].PlanID == nil {
+				planID := commonutils.GeneratePlanID(execPlan)
+				results[i].PlanID = &planID
+			}
+		}
+		dbConn.Close()
 	}
 }
 
-func validateAndFetchNestedExecPlan(execPlan []map[string]interface{}, individualQuery datamodels.IndividualQueryMetrics, executionPlanMetricsList *[]interface{}) {
-	level := 0
-	if len(execPlan) > 0 {
-		if plan, ok := execPlan[0]["Plan"].(map[string]interface{}); ok {
-			fetchNestedExecutionPlanDetails(individualQuery, &level, plan, executionPlanMetricsList)
-		} else {
-			log.Debug("execPlan is not in correct datatype")
-		}
-	} else {
-		log.Debug("execPlan is empty")
+// explainQuery returns the top node of the EXPLAIN plan of the text of individualQuery
+func explainQuery(dbConn *performancedbconnection.PGSQLConnection, individualQuery datamodels.IndividualQueryMetrics) (map[string]interface{}, error) {
+	if individualQuery.RealQueryText == nil || individualQuery.QueryID == nil {
+		return nil, errors.New("query text or query ID is nil")
+	}
+	rows, err := dbConn.Queryx("EXPLAIN (FORMAT JSON) " + *individualQuery.RealQueryText)
+	if err != nil {
+		return nil, fmt.Errorf("error explaining queryId %s: %w", *individualQuery.QueryID, err)
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		return nil, fmt.Errorf("no execution plan for queryId %s", *individualQuery.QueryID)
+	}
+	var execPlanJSON string
+	if err := rows.Scan(&execPlanJSON); err != nil {
+		return nil, fmt.Errorf("error scanning the execution plan of queryId %s: %w", *individualQuery.QueryID, err)
 	}
-}
 
-func groupQueriesByDatabase(results []datamodels.IndividualQueryMetrics) map[string][]datamodels.IndividualQueryMetrics {
-	databaseMap := make(map[string][]datamodels.IndividualQueryMetrics)
-	for _, individualQueryMetric := range results {
-		if individualQueryMetric.DatabaseName == nil {
-			continue
-		}
-		dbName := *individualQueryMetric.DatabaseName
-		databaseMap[dbName] = append(databaseMap[dbName], individualQueryMetric)
+	var execPlan []map[string]interface{}
+	if err := json.Unmarshal([]byte(execPlanJSON), &execPlan); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal the execution plan of queryId %s: %w", *individualQuery.QueryID, err)
+	}
+	if len(execPlan) == 0 {
+		return nil, fmt.Errorf("empty execution plan for queryId %s", *individualQuery.QueryID)
+	}
+	plan, ok := execPlan[0]["Plan"].(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("execution plan of queryId %s is not in correct datatype", *individualQuery.QueryID)
 	}
-	return databaseMap
+	return plan, nil
 }
 
 func fetchNestedExecutionPlanDetails(individualQuery datamodels.IndividualQueryMetrics, level *int, execPlan map[string]interface{}, executionPlanMetricsList *[]interface{}) {
@@ -1,6 +1,9 @@
 package performancemetrics
 
 import (
+	"context"
+	"errors"
+	"regexp"
 	"testing"
 
 	performancedbconnection "github.com/newrelic/nri-postgresql/src/connection"
@@ -9,36 +12,83 @@ import (
 
 	"github.com/newrelic/infra-integrations-sdk/v3/integration"
 	"github.com/newrelic/nri-postgresql/src/args"
+	commonutils "github.com/newrelic/nri-postgresql/src/query-performance-monitoring/common-utils"
 	"github.com/newrelic/nri-postgresql/src/query-performance-monitoring/datamodels"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/DATA-DOG/go-sqlmock.v1"
 )
 
 func TestPopulateExecutionPlanMetrics(t *testing.T) {
 	pgIntegration, _ := integration.New("test", "1.0.0")
 	args := args.ArgumentList{}
 	results := []datamodels.IndividualQueryMetrics{}
-	cp := common_parameters.SetCommonParameters(args, uint64(13), "testdb")
+	cp := common_parameters.SetCommonParameters(args, uint64(13), []string{"testdb"})
 	connectionInfo := performancedbconnection.DefaultConnectionInfo(&args)
-	PopulateExecutionPlanMetrics(results, pgIntegration, cp, connectionInfo)
+	PopulateExecutionPlanMetrics(context.Background(), results, pgIntegration, cp, connectionInfo)
 	assert.Empty(t, pgIntegration.Entities)
 }
 
-func TestGroupQueriesByDatabase(t *testing.T) {
-	databaseName := "testdb"
-	queryID := "queryid1"
-	queryText := "SELECT 1"
+const testPlanJSON = `[{"Plan": {"Node Type": "Index Scan", "Relation Name": "users", "Index Name": "users_pkey", "Total Cost": 8.3}}]`
+
+func TestExplainQueries(t *testing.T) {
+	conn, mock := performancedbconnection.CreateMockSQL(t)
+	connectionInfo := &performancedbconnection.MockInfo{}
+	connectionInfo.On("NewConnection", "testdb").Return(conn, nil)
+
+	monitorPlanID := "4242"
 	results := []datamodels.IndividualQueryMetrics{
-		{
-			QueryID:      &queryID,
-			QueryText:    &queryText,
-			DatabaseName: &databaseName,
-		},
+		{QueryID: stringPtr("1"), DatabaseName: stringPtr("testdb"), RealQueryText: stringPtr("SELECT * FROM users WHERE id = 1")},
+		{QueryID: stringPtr("2"), DatabaseName: stringPtr("testdb"), RealQueryText: stringPtr("SELECT * FROM orders"), PlanID: &monitorPlanID},
+		{QueryID: stringPtr("3"), DatabaseName: stringPtr("testdb"), RealQueryText: stringPtr("SELECT $1")},
 	}
+	mock.ExpectQuery(regexp.QuoteMeta("EXPLAIN (FORMAT JSON) SELECT * FROM users WHERE id = 1")).
+		WillReturnRows(sqlmock.NewRows([]string{"QUERY PLAN"}).AddRow(testPlanJSON))
+	mock.ExpectQuery(regexp.QuoteMeta("EXPLAIN (FORMAT JSON) SELECT $1")).WillReturnError(errors.New("there is no parameter $1"))
+
+	identifyPlans(context.Background(), results, connectionInfo)
+
+	assert.Equal(t, commonutils.GeneratePlanID(results[0].ExecutionPlan), *results[0].PlanID)
+	assert.Equal(t, "users_pkey", results[0].ExecutionPlan["Index Name"])
+	// pg_stat_monitor plan IDs are kept and the plan is only explained for the execution plan metrics
+	assert.Equal(t, "4242", *results[1].PlanID)
+	assert.Nil(t, results[1].ExecutionPlan)
+	assert.Nil(t, results[2].PlanID)
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	// the execution plan metrics only explain the queries that were not explained yet
+	conn, mock = performancedbconnection.CreateMockSQL(t)
+	connectionInfo = &performancedbconnection.MockInfo{}
+	connectionInfo.On("NewConnection", "testdb").Return(conn, nil)
+	mock.ExpectQuery(regexp.QuoteMeta("EXPLAIN (FORMAT JSON) SELECT * FROM orders")).
+		WillReturnRows(sqlmock.NewRows([]string{"QUERY PLAN"}).AddRow(testPlanJSON))
+	mock.ExpectQuery(regexp.QuoteMeta("EXPLAIN (FORMAT JSON) SELECT $1")).WillReturnError(errors.New("there is no parameter $1"))
+
+	executionPlanMetrics := getExecutionPlanMetrics(context.Background(), results, connectionInfo)
+	require.Len(t, executionPlanMetrics, 2)
+	assert.Equal(t, *results[0].PlanID, executionPlanMetrics[0].(datamodels.QueryExecutionPlanMetrics).PlanID)
+	assert.Equal(t, "4242", executionPlanMetrics[1].(datamodels.QueryExecutionPlanMetrics).PlanID)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestExplainQueriesContext(t *testing.T) {
+	conn, mock := performancedbconnection.CreateMockSQL(t)
+	connectionInfo := &performancedbconnection.MockInfo{}
+	connectionInfo.On("NewConnection", "testdb").Return(conn, nil)
+
+	results := []datamodels.IndividualQueryMetrics{
+		{QueryID: stringPtr("1"), DatabaseName: stringPtr("testdb"), RealQueryText: stringPtr("SELECT * FROM users WHERE id = 1")},
+	}
+	mock.ExpectQuery(regexp.QuoteMeta("EXPLAIN (FORMAT JSON) SELECT * FROM users WHERE id = 1")).
+		WillReturnRows(sqlmock.NewRows([]string{"QUERY PLAN"}).AddRow(testPlanJSON))
+
+	// the queries are not explained once the collection deadline is exceeded
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	identifyPlans(ctx, results, connectionInfo)
 
-	groupedQueries := groupQueriesByDatabase(results)
-	assert.Len(t, groupedQueries, 1)
-	assert.Contains(t, groupedQueries, databaseName)
-	assert.Len(t, groupedQueries[databaseName], 1)
+	assert.Nil(t, results[0].PlanID)
+	assert.Nil(t, results[0].ExecutionPlan)
 }
 
 func TestFetchNestedExecutionPlanDetails(t *testing.T) {
@@ -1,14 +1,15 @@
 package performancemetrics
 
 import (
-	"fmt"
-	"github.com/newrelic/nri-postgresql/src/query-performance-monitoring/queries"
+	"context"
 
-	"github.com/jmoiron/sqlx"
+	"github.com/newrelic/nri-postgresql/src/query-performance-monitoring/queries"
 
+	"github.com/lib/pq"
 	"github.com/newrelic/infra-integrations-sdk/v3/integration"
 	"github.com/newrelic/infra-integrations-sdk/v3/log"
 	performancedbconnection "github.com/newrelic/nri-postgresql/src/connection"
+	"github.com/newrelic/nri-postgresql/src/metrics"
 	commonparameters "github.com/newrelic/nri-postgresql/src/query-performance-monitoring/common-parameters"
 	commonutils "github.com/newrelic/nri-postgresql/src/query-performance-monitoring/common-utils"
 	"github.com/newrelic/nri-postgresql/src/query-performance-monitoring/datamodels"
@@ -18,14 +19,15 @@ import (
 type queryInfoMap map[string]string
 type databaseQueryInfoMap map[string]queryInfoMap
 
-func PopulateIndividualQueryMetrics(conn *performancedbconnection.PGSQLConnection, slowRunningQueries []datamodels.SlowRunningQueryMetrics, pgIntegration *integration.Integration, cp *commonparameters.CommonParameters, enabledExtensions map[string]bool) []datamodels.IndividualQueryMetrics {
+func PopulateIndividualQueryMetrics(conn *performancedbconnection.PGSQLConnection, slowRunningQueries []datamodels.SlowRunningQueryMetrics, pgIntegration *integration.Integration, cp *commonparameters.CommonParameters, enabledExtensions map[string]bool, connectionInfo performancedbconnection.Info) []datamodels.IndividualQueryMetrics {
 	isEligible := validations.CheckIndividualQueryMetricsFetchEligibility(enabledExtensions)
 	if !isEligible {
 		log.Debug("Extension 'pg_stat_monitor' is not enabled or unsupported version.")
+		metrics.SkipCollector(conn.Context(), "pg_stat_monitor extension is required")
 		return nil
 	}
 	log.Debug("Extension 'pg_stat_monitor' enabled.")
-	individualQueryMetricsInterface, individualQueriesList := getIndividualQueryMetrics(conn, slowRunningQueries, cp)
+	individualQueryMetricsInterface, individualQueriesList := getIndividualQueryMetrics(conn, slowRunningQueries, cp, connectionInfo)
 	if len(individualQueryMetricsInterface) == 0 {
 		log.Debug("No individual queries found.")
 		return nil
@@ -38,7 +40,7 @@ func PopulateIndividualQueryMetrics(conn *performancedbconnection.PGSQLConnectio
 	return individualQueriesList
 }
 
-func getIndividualQueryMetrics(conn *performancedbconnection.PGSQLConnection, slowRunningQueries []datamodels.SlowRunningQueryMetrics, cp *commonparameters.CommonParameters) ([]interface{}, []datamodels.IndividualQueryMetrics) {
+func getIndividualQueryMetrics(conn *performancedbconnection.PGSQLConnection, slowRunningQueries []datamodels.SlowRunningQueryMetrics, cp *commonparameters.CommonParameters, connectionInfo performancedbconnection.Info) ([]interface{}, []datamodels.IndividualQueryMetrics) {
 	if len(slowRunningQueries) == 0 {
 		log.Debug("No slow running queries found.")
 		return nil, nil
@@ -55,8 +57,8 @@ func getIndividualQueryMetrics(conn *performancedbconnection.PGSQLConnection, sl
 		if slowRunningMetric.QueryID == nil {
 			continue
 		}
-		query := fmt.Sprintf(versionSpecificIndividualQuery, *slowRunningMetric.QueryID, cp.Databases, cp.QueryMonitoringResponseTimeThreshold, min(cp.QueryMonitoringCountThreshold, commonutils.MaxIndividualQueryCountThreshold))
-		rows, err := conn.Queryx(query)
+		rows, err := conn.Queryx(versionSpecificIndividualQuery, *slowRunningMetric.QueryID, pq.Array(cp.Databases),
+			cp.QueryMonitoringResponseTimeThreshold, min(cp.QueryMonitoringCountThreshold, commonutils.MaxIndividualQueryCountThreshold))
 		if err != nil {
 			log.Debug("Error executing query in individual query: %v", err)
 			return nil, nil
@@ -66,13 +68,17 @@ func getIndividualQueryMetrics(conn *performancedbconnection.PGSQLConnection, sl
 		for _, individualQuery := range individualQuerySamplesList {
 			individualQuery.AvgExecTimeInMs = slowRunningMetric.AvgElapsedTimeMs
 			individualQueryMetricsList = append(individualQueryMetricsList, individualQuery)
-			individualQueryMetricsListInterface = append(individualQueryMetricsListInterface, individualQuery)
 		}
 	}
+
+	identifyPlans(conn.Context(), individualQueryMetricsList, connectionInfo)
+	for _, individualQuery := range individualQueryMetricsList {
+		individualQueryMetricsListInterface = append(individualQueryMetricsListInterface, individualQuery)
+	}
 	return individualQueryMetricsListInterface, individualQueryMetricsList
 }
 
-func processRows(rows *sqlx.Rows, anonymizedQueriesByDB databaseQueryInfoMap) []datamodels.IndividualQueryMetrics {
+func processRows(rows *performancedbconnection.Rows, anonymizedQueriesByDB databaseQueryInfoMap) []datamodels.IndividualQueryMetrics {
 	var individualQueryMetricsList []datamodels.IndividualQueryMetrics
 	for rows.Next() {
 		var model datamodels.IndividualQueryMetrics
@@ -89,12 +95,10 @@ func processRows(rows *sqlx.Rows, anonymizedQueriesByDB databaseQueryInfoMap) []
 		queryText := *model.QueryText
 		individualQueryMetric.RealQueryText = &queryText
 		individualQueryMetric.QueryText = &anonymizedQueryText
-		generatedPlanID, err := commonutils.GeneratePlanID()
-		if err != nil {
-			log.Error("Error generating plan ID: %v", err)
-			continue
+		// pg_stat_monitor reports a zero plan ID unless pgsm_enable_query_plan is on
+		if individualQueryMetric.PlanID != nil && (*individualQueryMetric.PlanID == "" || *individualQueryMetric.PlanID == "0") {
+			individualQueryMetric.PlanID = nil
 		}
-		individualQueryMetric.PlanID = &generatedPlanID
 		individualQueryMetricsList = append(individualQueryMetricsList, individualQueryMetric)
 	}
 	return individualQueryMetricsList
@@ -118,7 +122,7 @@ func processForAnonymizeQueryMap(slowRunningMetricList []datamodels.SlowRunningQ
 	return anonymizeQueryMapByDB
 }
 
-func PopulateIndividualQueryMetricsPgStat(slowQueries []datamodels.SlowRunningQueryMetrics, pgIntegration *integration.Integration, cp *commonparameters.CommonParameters) []datamodels.IndividualQueryMetrics {
+func PopulateIndividualQueryMetricsPgStat(ctx context.Context, slowQueries []datamodels.SlowRunningQueryMetrics, pgIntegration *integration.Integration, cp *commonparameters.CommonParameters, connectionInfo performancedbconnection.Info) []datamodels.IndividualQueryMetrics {
 	var individualQueriesMetricsList = make([]datamodels.IndividualQueryMetrics, 0)
 	var individualQueriesMetricsListInterface = make([]interface{}, 0)
 	for _, slowRunningMetric := range slowQueries {
@@ -128,13 +132,11 @@ func PopulateIndividualQueryMetricsPgStat(slowQueries []datamodels.SlowRunningQu
 		individualQueryMetric.QueryText = slowRunningMetric.QueryText
 		individualQueryMetric.RealQueryText = slowRunningMetric.IndividualQuery
 		individualQueryMetric.AvgExecTimeInMs = slowRunningMetric.AvgElapsedTimeMs
-		generatedPlanID, err := commonutils.GeneratePlanID()
-		if err != nil {
-			log.Error("Error generating plan ID: %v", err)
-			continue
-		}
-		individualQueryMetric.PlanID = &generatedPlanID
 		individualQueriesMetricsList = append(individualQueriesMetricsList, individualQueryMetric)
+	}
+
+	identifyPlans(ctx, individualQueriesMetricsList, connectionInfo)
+	for _, individualQueryMetric := range individualQueriesMetricsList {
 		individualQueriesMetricsListInterface = append(individualQueriesMetricsListInterface, individualQueryMetric)
 	}
 	err := commonutils.IngestMetric(individualQueriesMetricsListInterface, "PostgresIndividualQueries", pgIntegration, cp)
@@ -145,10 +147,17 @@ func PopulateIndividualQueryMetricsPgStat(slowQueries []datamodels.SlowRunningQu
 	return individualQueriesMetricsList
 }
 
+// identifyPlans explains the queries that pg_stat_monitor did not identify the plan of, to identify them by their
+// plan. The plans are kept for the execution plan metrics.
+func identifyPlans(ctx context.Context, individualQueries []datamodels.IndividualQueryMetrics, connectionInfo performancedbconnection.Info) {
+	explainQueries(ctx, individualQueries, connectionInfo, func(individualQuery datamodels.IndividualQueryMetrics) bool {
+		return individualQuery.PlanID == nil
+	})
+}
+
 func getIndividualQueriesFromPgStat(conn *performancedbconnection.PGSQLConnection) []string {
 	var individualQueryMetricsList []string
-	query := fmt.Sprintf(queries.IndividualQueryFromPgStat)
-	rows, err := conn.Queryx(query)
+	rows, err := conn.Queryx(queries.IndividualQueryFromPgStat)
 	if err != nil {
 		log.Error("Error executing query: %v", err)
 		return nil
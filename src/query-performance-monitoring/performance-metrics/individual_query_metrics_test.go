@@ -1,12 +1,13 @@
 package performancemetrics
 
 import (
-	"fmt"
+	"context"
 	"regexp"
 	"testing"
 
 	"github.com/newrelic/infra-integrations-sdk/v3/integration"
 
+	"github.com/lib/pq"
 	"github.com/newrelic/nri-postgresql/src/args"
 	"github.com/newrelic/nri-postgresql/src/connection"
 	common_parameters "github.com/newrelic/nri-postgresql/src/query-performance-monitoring/common-parameters"
@@ -23,11 +24,11 @@ func TestGetIndividualQueryMetrics(t *testing.T) {
 	version := uint64(13)
 	mockQueryID := "-123"
 	mockQueryText := "SELECT 1"
-	cp := common_parameters.SetCommonParameters(args, version, databaseName)
+	cp := common_parameters.SetCommonParameters(args, version, []string{databaseName})
 
 	// Mock the individual query
-	query := fmt.Sprintf(queries.IndividualQuerySearchV13AndAbove, mockQueryID, databaseName, args.QueryMonitoringResponseTimeThreshold, args.QueryMonitoringCountThreshold)
-	mock.ExpectQuery(regexp.QuoteMeta(query)).WillReturnRows(sqlmock.NewRows([]string{
+	query := queries.IndividualQuerySearchV13AndAbove
+	mock.ExpectQuery(regexp.QuoteMeta(query)).WithArgs(mockQueryID, pq.Array([]string{databaseName}), args.QueryMonitoringResponseTimeThreshold, args.QueryMonitoringCountThreshold).WillReturnRows(sqlmock.NewRows([]string{
 		"newrelic", "query", "queryid", "datname", "planid", "cpu_time_ms", "exec_time_ms",
 	}).AddRow(
 		"newrelic_value", "SELECT 1", "queryid1", "testdb", "planid1", 10.0, 20.0,
@@ -41,10 +42,11 @@ func TestGetIndividualQueryMetrics(t *testing.T) {
 		},
 	}
 
-	individualQueryMetricsInterface, individualQueryMetrics := getIndividualQueryMetrics(conn, slowRunningQueries, cp)
+	individualQueryMetricsInterface, individualQueryMetrics := getIndividualQueryMetrics(conn, slowRunningQueries, cp, &connection.MockInfo{})
 
 	assert.Len(t, individualQueryMetricsInterface, 1)
 	assert.Len(t, individualQueryMetrics, 1)
+	assert.Equal(t, "planid1", *individualQueryMetrics[0].PlanID)
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
@@ -70,10 +72,19 @@ func TestPopulateIndividualQueryMetricsPgStat(t *testing.T) {
 	args := args.ArgumentList{QueryMonitoringCountThreshold: 10}
 	version := uint64(13)
 	databaseName := "testdb"
-	cp := common_parameters.SetCommonParameters(args, version, databaseName)
-	result := PopulateIndividualQueryMetricsPgStat(slowQueries, pgIntegration, cp)
+	cp := common_parameters.SetCommonParameters(args, version, []string{databaseName})
+	conn, mock := connection.CreateMockSQL(t)
+	connectionInfo := &connection.MockInfo{}
+	connectionInfo.On("NewConnection", databaseName).Return(conn, nil)
+	mock.ExpectQuery(regexp.QuoteMeta("EXPLAIN (FORMAT JSON) SELECT * FROM test where id = 1")).
+		WillReturnRows(sqlmock.NewRows([]string{"QUERY PLAN"}).AddRow(`[{"Plan": {"Node Type": "Seq Scan", "Relation Name": "test"}}]`))
+	mock.ExpectQuery(regexp.QuoteMeta("EXPLAIN (FORMAT JSON) SELECT * FROM users where id = 1")).
+		WillReturnRows(sqlmock.NewRows([]string{"QUERY PLAN"}).AddRow(`[{"Plan": {"Node Type": "Index Scan", "Relation Name": "users", "Index Name": "users_pkey"}}]`))
+	result := PopulateIndividualQueryMetricsPgStat(context.Background(), slowQueries, pgIntegration, cp, connectionInfo)
 	assert.NotEmpty(t, pgIntegration.Entities)
 	assert.Len(t, result, 2)
+	assert.NotEqual(t, *result[0].PlanID, *result[1].PlanID)
+	assert.NoError(t, mock.ExpectationsWereMet())
 	assert.Equal(t, "query1", *result[0].QueryID)
 	assert.Equal(t, "testdb", *result[0].DatabaseName)
 	assert.Equal(t, "SELECT * FROM test where id = $1", *result[0].QueryText)
@@ -86,8 +97,8 @@ func TestPopulateIndividualQueryMetricsPgStatEmpty(t *testing.T) {
 	args := args.ArgumentList{QueryMonitoringCountThreshold: 10}
 	version := uint64(13)
 	databaseName := "testdb"
-	cp := common_parameters.SetCommonParameters(args, version, databaseName)
-	result := PopulateIndividualQueryMetricsPgStat(nil, pgIntegration, cp)
+	cp := common_parameters.SetCommonParameters(args, version, []string{databaseName})
+	result := PopulateIndividualQueryMetricsPgStat(context.Background(), nil, pgIntegration, cp, &connection.MockInfo{})
 	assert.NotEmpty(t, pgIntegration.Entities)
 	assert.Len(t, result, 0)
 }
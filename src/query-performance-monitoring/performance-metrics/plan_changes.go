@@ -0,0 +1,246 @@
+package performancemetrics
+
+import (
+	"sort"
+	"time"
+
+	"github.com/newrelic/infra-integrations-sdk/v3/integration"
+	"github.com/newrelic/infra-integrations-sdk/v3/log"
+	commonparameters "github.com/newrelic/nri-postgresql/src/query-performance-monitoring/common-parameters"
+	commonutils "github.com/newrelic/nri-postgresql/src/query-performance-monitoring/common-utils"
+	"github.com/newrelic/nri-postgresql/src/query-performance-monitoring/datamodels"
+)
+
+// planHistoryKey stores the plans seen for each query by the previous runs
+const planHistoryKey = "plans"
+
+// PlanHistoryRetention is how long the plans of a query are remembered once the query is no longer reported. The
+// store of the plan history must keep its entries at least as long.
+const PlanHistoryRetention = 24 * time.Hour
+
+// maxPlansPerQuery is the number of plans remembered for each query, the most recently seen ones
+const maxPlansPerQuery = 5
+
+// planRecord is a plan seen for a query, with the cost and latency it had when it was last seen
+type planRecord struct {
+	AvgExecTimeMs float64  `json:"avg_exec_time_ms"`
+	TotalCost     *float64 `json:"total_cost,omitempty"`
+	Plan          *string  `json:"plan,omitempty"`
+	LastSeen      int64    `json:"last_seen"`
+}
+
+// queryPlanHistory are the plans seen for a query, CurrentPlanID being the one it used when it was last seen
+type queryPlanHistory struct {
+	CurrentPlanID string                `json:"current_plan_id"`
+	Plans         map[string]planRecord `json:"plans"`
+	LastSeen      int64                 `json:"last_seen"`
+}
+
+// observedPlan is a plan used by the samples of a query in the current run
+type observedPlan struct {
+	planID      string
+	samples     int
+	execTimeMs  float64
+	totalCost   *float64
+	description *string
+}
+
+// observedQuery are the plans used by a query in the current run
+type observedQuery struct {
+	queryID      string
+	databaseName string
+	queryText    string
+	plans        map[string]*observedPlan
+}
+
+// PopulatePlanChangeEvents compares the plans of the individual queries with the plans seen by the previous runs, and
+// reports a PostgresPlanChangeEvent for every query whose plan changed and whose average execution time grew by more
+// than the plan change threshold. The history is saved even without individual queries, so that it is only forgotten
+// once its queries have not been reported for PlanHistoryRetention.
+func PopulatePlanChangeEvents(individualQueries []datamodels.IndividualQueryMetrics, pgIntegration *integration.Integration, cp *commonparameters.CommonParameters) {
+	if len(individualQueries) == 0 {
+		log.Debug("No individual queries found.")
+	}
+
+	history := make(map[string]queryPlanHistory)
+	if _, err := cp.StatementSnapshots.Get(planHistoryKey, &history); err != nil {
+		log.Debug("No plan history: %v", err)
+	}
+	events := detectPlanChanges(individualQueries, history, cp.PlanChangeThreshold, time.Now())
+	cp.StatementSnapshots.Set(planHistoryKey, history)
+	if err := cp.StatementSnapshots.Save(); err != nil {
+		log.Error("Error saving the plan history: %v", err)
+	}
+
+	if len(events) == 0 {
+		return
+	}
+	eventsInterface := make([]interface{}, 0, len(events))
+	for _, event := range events {
+		eventsInterface = append(eventsInterface, event)
+	}
+	if err := commonutils.IngestMetric(eventsInterface, "PostgresPlanChangeEvent", pgIntegration, cp); err != nil {
+		log.Error("Error ingesting plan changes: %v", err)
+	}
+}
+
+// detectPlanChanges returns the plan changes of the individual queries that are regressions, and records the plans
+// of the queries in history. The plan of a query is the one used by most of its samples.
+func detectPlanChanges(individualQueries []datamodels.IndividualQueryMetrics, history map[string]queryPlanHistory, threshold int, now time.Time) []datamodels.PlanChangeEvent {
+	var events []datamodels.PlanChangeEvent
+	observed := observeQueries(individualQueries)
+	keys := make([]string, 0, len(observed))
+	for key := range observed {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		query := observed[key]
+		current := query.currentPlan()
+		queryHistory, known := history[key]
+		if known && queryHistory.CurrentPlanID != current.planID {
+			if seen, ok := queryHistory.Plans[current.planID]; ok && current.description == nil {
+				current.description, current.totalCost = seen.Plan, seen.TotalCost
+			}
+			if previous, ok := queryHistory.Plans[queryHistory.CurrentPlanID]; ok && regressed(previous.AvgExecTimeMs, current.avgExecTimeMs(), threshold) {
+				events = append(events, newPlanChangeEvent(query, queryHistory.CurrentPlanID, previous, current, now))
+			}
+		}
+
+		if !known {
+			queryHistory = queryPlanHistory{Plans: make(map[string]planRecord)}
+		}
+		for planID, plan := range query.plans {
+			record := queryHistory.Plans[planID]
+			record.AvgExecTimeMs = plan.avgExecTimeMs()
+			record.LastSeen = now.Unix()
+			// plans that were not explained in this run keep their previous description and cost
+			if plan.description != nil {
+				record.Plan = plan.description
+				record.TotalCost = plan.totalCost
+			}
+			queryHistory.Plans[planID] = record
+		}
+		queryHistory.CurrentPlanID = current.planID
+		queryHistory.LastSeen = now.Unix()
+		prunePlans(&queryHistory)
+		history[key] = queryHistory
+	}
+
+	for key, queryHistory := range history {
+		if now.Sub(time.Unix(queryHistory.LastSeen, 0)) > PlanHistoryRetention {
+			delete(history, key)
+		}
+	}
+	return events
+}
+
+func observeQueries(individualQueries []datamodels.IndividualQueryMetrics) map[string]*observedQuery {
+	observed := make(map[string]*observedQuery)
+	for _, individualQuery := range individualQueries {
+		if individualQuery.QueryID == nil || individualQuery.DatabaseName == nil || individualQuery.PlanID == nil {
+			continue
+		}
+		execTimeMs := individualQuery.ExecTimeInMs
+		if execTimeMs == nil {
+			execTimeMs = individualQuery.AvgExecTimeInMs
+		}
+		if execTimeMs == nil {
+			continue
+		}
+
+		key := *individualQuery.DatabaseName + ":" + *individualQuery.QueryID
+		query, ok := observed[key]
+		if !ok {
+			query = &observedQuery{
+				queryID:      *individualQuery.QueryID,
+				databaseName: *individualQuery.DatabaseName,
+				plans:        make(map[string]*observedPlan),
+			}
+			if individualQuery.QueryText != nil {
+				query.queryText = *individualQuery.QueryText
+			}
+			observed[key] = query
+		}
+		plan, ok := query.plans[*individualQuery.PlanID]
+		if !ok {
+			plan = &observedPlan{planID: *individualQuery.PlanID}
+			query.plans[plan.planID] = plan
+		}
+		plan.samples++
+		plan.execTimeMs += *execTimeMs
+		if plan.description == nil && individualQuery.ExecutionPlan != nil {
+			description := commonutils.DescribePlan(individualQuery.ExecutionPlan)
+			plan.description = &description
+			if totalCost, ok := individualQuery.ExecutionPlan["Total Cost"].(float64); ok {
+				plan.totalCost = &totalCost
+			}
+		}
+	}
+	return observed
+}
+
+// currentPlan returns the plan used by most samples of the query
+func (q *observedQuery) currentPlan() *observedPlan {
+	var current *observedPlan
+	for _, plan := range q.plans {
+		if current == nil || plan.samples > current.samples || (plan.samples == current.samples && plan.planID < current.planID) {
+			current = plan
+		}
+	}
+	return current
+}
+
+func (p *observedPlan) avgExecTimeMs() float64 {
+	return p.execTimeMs / float64(p.samples)
+}
+
+// regressed returns whether the execution time grew by more than threshold percent, and at least MinPlanChangeDeltaMs
+func regressed(previousMs, currentMs float64, threshold int) bool {
+	return currentMs-previousMs >= commonutils.MinPlanChangeDeltaMs && currentMs > previousMs*(1+float64(threshold)/100)
+}
+
+func newPlanChangeEvent(query *observedQuery, previousPlanID string, previous planRecord, current *observedPlan, now time.Time) datamodels.PlanChangeEvent {
+	currentMs := current.avgExecTimeMs()
+	event := datamodels.PlanChangeEvent{
+		QueryID:             query.queryID,
+		QueryText:           query.queryText,
+		DatabaseName:        query.databaseName,
+		OldPlanID:           previousPlanID,
+		NewPlanID:           current.planID,
+		OldPlan:             previous.Plan,
+		NewPlan:             current.description,
+		OldAvgExecTimeMs:    round(previous.AvgExecTimeMs),
+		NewAvgExecTimeMs:    round(currentMs),
+		ExecTimeDeltaMs:     round(currentMs - previous.AvgExecTimeMs),
+		OldTotalCost:        previous.TotalCost,
+		NewTotalCost:        current.totalCost,
+		CollectionTimestamp: now.UTC().Format(time.RFC3339),
+	}
+	if previous.AvgExecTimeMs > 0 {
+		event.ExecTimeChangePercent = round((currentMs - previous.AvgExecTimeMs) / previous.AvgExecTimeMs * 100)
+	}
+	return event
+}
+
+// prunePlans keeps the maxPlansPerQuery most recently seen plans of the query, including its current plan
+func prunePlans(queryHistory *queryPlanHistory) {
+	if len(queryHistory.Plans) <= maxPlansPerQuery {
+		return
+	}
+	planIDs := make([]string, 0, len(queryHistory.Plans))
+	for planID := range queryHistory.Plans {
+		planIDs = append(planIDs, planID)
+	}
+	sort.Slice(planIDs, func(i, j int) bool {
+		iCurrent, jCurrent := planIDs[i] == queryHistory.CurrentPlanID, planIDs[j] == queryHistory.CurrentPlanID
+		if iCurrent != jCurrent {
+			return iCurrent
+		}
+		return queryHistory.Plans[planIDs[i]].LastSeen > queryHistory.Plans[planIDs[j]].LastSeen
+	})
+	for _, planID := range planIDs[maxPlansPerQuery:] {
+		delete(queryHistory.Plans, planID)
+	}
+}
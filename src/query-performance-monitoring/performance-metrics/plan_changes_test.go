@@ -0,0 +1,134 @@
+package performancemetrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/newrelic/infra-integrations-sdk/v3/integration"
+	"github.com/newrelic/nri-postgresql/src/args"
+	common_parameters "github.com/newrelic/nri-postgresql/src/query-performance-monitoring/common-parameters"
+	"github.com/newrelic/nri-postgresql/src/query-performance-monitoring/datamodels"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func planSample(queryID, planID string, execTimeMs float64, plan map[string]interface{}) datamodels.IndividualQueryMetrics {
+	return datamodels.IndividualQueryMetrics{
+		QueryID:       stringPtr(queryID),
+		DatabaseName:  stringPtr("testdb"),
+		QueryText:     stringPtr("SELECT * FROM users WHERE id = $1"),
+		PlanID:        stringPtr(planID),
+		ExecTimeInMs:  floatPtr(execTimeMs),
+		ExecutionPlan: plan,
+	}
+}
+
+func TestDetectPlanChanges(t *testing.T) {
+	indexScan := map[string]interface{}{"Node Type": "Index Scan", "Relation Name": "users", "Index Name": "users_pkey", "Total Cost": 8.3}
+	seqScan := map[string]interface{}{"Node Type": "Seq Scan", "Relation Name": "users", "Total Cost": 1834.0}
+	history := make(map[string]queryPlanHistory)
+	start := time.Now()
+
+	// the first plan of a query is only recorded
+	events := detectPlanChanges([]datamodels.IndividualQueryMetrics{
+		planSample("1", "100", 2, indexScan),
+		planSample("1", "100", 4, indexScan),
+		planSample("2", "300", 5, nil),
+	}, history, 50, start)
+	assert.Empty(t, events)
+	require.Contains(t, history, "testdb:1")
+	assert.Equal(t, "100", history["testdb:1"].CurrentPlanID)
+	assert.Equal(t, 3.0, history["testdb:1"].Plans["100"].AvgExecTimeMs)
+
+	// the plan used by most samples is the plan of the query
+	events = detectPlanChanges([]datamodels.IndividualQueryMetrics{
+		planSample("1", "200", 30, seqScan),
+		planSample("1", "200", 34, seqScan),
+		planSample("1", "100", 3, indexScan),
+		planSample("2", "400", 6, nil),
+	}, history, 50, start.Add(time.Minute))
+	require.Len(t, events, 1)
+	assert.Equal(t, datamodels.PlanChangeEvent{
+		QueryID:               "1",
+		QueryText:             "SELECT * FROM users WHERE id = $1",
+		DatabaseName:          "testdb",
+		OldPlanID:             "100",
+		NewPlanID:             "200",
+		OldPlan:               stringPtr("Index Scan using users_pkey on users"),
+		NewPlan:               stringPtr("Seq Scan on users"),
+		OldAvgExecTimeMs:      3,
+		NewAvgExecTimeMs:      32,
+		ExecTimeDeltaMs:       29,
+		ExecTimeChangePercent: 966.667,
+		OldTotalCost:          floatPtr(8.3),
+		NewTotalCost:          floatPtr(1834),
+		CollectionTimestamp:   start.Add(time.Minute).UTC().Format(time.RFC3339),
+	}, events[0])
+	// a plan change within the threshold is not a regression
+	assert.Equal(t, "400", history["testdb:2"].CurrentPlanID)
+
+	// an unchanged plan, or a plan change that improves the execution time, is not reported
+	events = detectPlanChanges([]datamodels.IndividualQueryMetrics{
+		planSample("1", "200", 40, nil),
+	}, history, 50, start.Add(2*time.Minute))
+	assert.Empty(t, events)
+	events = detectPlanChanges([]datamodels.IndividualQueryMetrics{
+		planSample("1", "100", 2, nil),
+	}, history, 50, start.Add(3*time.Minute))
+	assert.Empty(t, events)
+	assert.Equal(t, stringPtr("Seq Scan on users"), history["testdb:1"].Plans["200"].Plan)
+
+	// queries that are no longer reported are forgotten
+	detectPlanChanges([]datamodels.IndividualQueryMetrics{
+		planSample("1", "100", 2, nil),
+	}, history, 50, start.Add(25*time.Hour))
+	assert.NotContains(t, history, "testdb:2")
+	assert.Contains(t, history, "testdb:1")
+}
+
+func TestPrunePlans(t *testing.T) {
+	queryHistory := queryPlanHistory{CurrentPlanID: "1", Plans: map[string]planRecord{}}
+	for i, planID := range []string{"1", "2", "3", "4", "5", "6", "7"} {
+		queryHistory.Plans[planID] = planRecord{LastSeen: int64(i)}
+	}
+	prunePlans(&queryHistory)
+	assert.Len(t, queryHistory.Plans, maxPlansPerQuery)
+	assert.Contains(t, queryHistory.Plans, "1")
+	assert.NotContains(t, queryHistory.Plans, "2")
+	assert.NotContains(t, queryHistory.Plans, "3")
+}
+
+func TestPopulatePlanChangeEvents(t *testing.T) {
+	pgIntegration, _ := integration.New("test", "1.0.0")
+	cp := common_parameters.SetCommonParameters(args.ArgumentList{QueryMonitoringPlanChangeThreshold: 50}, uint64(13), []string{"testdb"})
+
+	PopulatePlanChangeEvents([]datamodels.IndividualQueryMetrics{planSample("1", "100", 2, nil)}, pgIntegration, cp)
+	assert.Empty(t, pgIntegration.Entities)
+
+	PopulatePlanChangeEvents([]datamodels.IndividualQueryMetrics{planSample("1", "200", 20, nil)}, pgIntegration, cp)
+	// the event is published, which leaves the instance entity empty
+	assert.Len(t, pgIntegration.Entities, 1)
+
+	history := make(map[string]queryPlanHistory)
+	_, err := cp.StatementSnapshots.Get(planHistoryKey, &history)
+	require.NoError(t, err)
+	assert.Equal(t, "200", history["testdb:1"].CurrentPlanID)
+	assert.Len(t, history["testdb:1"].Plans, 2)
+}
+
+func TestPopulatePlanChangeEventsWithoutQueries(t *testing.T) {
+	pgIntegration, _ := integration.New("test", "1.0.0")
+	cp := common_parameters.SetCommonParameters(args.ArgumentList{QueryMonitoringPlanChangeThreshold: 50}, uint64(13), []string{"testdb"})
+	cp.StatementSnapshots.Set(planHistoryKey, map[string]queryPlanHistory{
+		"testdb:1": {CurrentPlanID: "100", LastSeen: time.Now().Unix()},
+		"testdb:2": {CurrentPlanID: "200", LastSeen: time.Now().Add(-PlanHistoryRetention - time.Hour).Unix()},
+	})
+
+	// runs without individual queries keep the history, and forget the queries past their retention
+	PopulatePlanChangeEvents(nil, pgIntegration, cp)
+	history := make(map[string]queryPlanHistory)
+	_, err := cp.StatementSnapshots.Get(planHistoryKey, &history)
+	require.NoError(t, err)
+	assert.Contains(t, history, "testdb:1")
+	assert.NotContains(t, history, "testdb:2")
+}
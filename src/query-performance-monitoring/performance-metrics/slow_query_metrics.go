@@ -1,18 +1,38 @@
 package performancemetrics
 
 import (
-	"fmt"
+	"errors"
+	"strconv"
 	"strings"
+	"time"
 
+	"github.com/lib/pq"
 	"github.com/newrelic/infra-integrations-sdk/v3/integration"
 	"github.com/newrelic/infra-integrations-sdk/v3/log"
 	performancedbconnection "github.com/newrelic/nri-postgresql/src/connection"
+	"github.com/newrelic/nri-postgresql/src/metrics"
 	commonparameters "github.com/newrelic/nri-postgresql/src/query-performance-monitoring/common-parameters"
 	commonutils "github.com/newrelic/nri-postgresql/src/query-performance-monitoring/common-utils"
 	"github.com/newrelic/nri-postgresql/src/query-performance-monitoring/datamodels"
+	"github.com/newrelic/nri-postgresql/src/query-performance-monitoring/queries"
 	"github.com/newrelic/nri-postgresql/src/query-performance-monitoring/validations"
 )
 
+// slowQuerySnapshotKey stores the pg_stat_statements counters of the previous run
+const slowQuerySnapshotKey = "pg_stat_statements"
+
+var errNoPreviousSnapshot = errors.New("no previous pg_stat_statements snapshot")
+
+// slowQueryDetails are the attributes of a statement, with the database and user identifying it along its query ID
+type slowQueryDetails struct {
+	datamodels.SlowRunningQueryMetrics
+	DBID   int64 `db:"dbid"`
+	UserID int64 `db:"userid"`
+}
+
+// getSlowRunningMetrics returns the statements that took the most time since the previous run, from the difference
+// between the current pg_stat_statements counters and the ones stored by the previous run. It returns
+// errNoPreviousSnapshot when there is nothing to compare with yet, or the previous snapshot has expired.
 func getSlowRunningMetrics(conn *performancedbconnection.PGSQLConnection, cp *commonparameters.CommonParameters) ([]datamodels.SlowRunningQueryMetrics, []interface{}, error) {
 	var slowQueryMetricsList []datamodels.SlowRunningQueryMetrics
 	var slowQueryMetricsListInterface []interface{}
@@ -21,17 +41,44 @@ func getSlowRunningMetrics(conn *performancedbconnection.PGSQLConnection, cp *co
 		log.Error("Unsupported postgres version: %v", err)
 		return nil, nil, err
 	}
-	var query = fmt.Sprintf(versionSpecificSlowQuery, cp.Databases, cp.QueryMonitoringCountThreshold)
-	rows, err := conn.Queryx(query)
+	var counters []datamodels.SlowQueryCounters
+	if err = conn.Query(&counters, versionSpecificSlowQuery, pq.Array(cp.Databases)); err != nil {
+		return nil, nil, err
+	}
+
+	var previous map[string]statementCounters
+	storedAt, previousErr := cp.StatementSnapshots.Get(slowQuerySnapshotKey, &previous)
+	now := cp.StatementSnapshots.Set(slowQuerySnapshotKey, newStatementSnapshot(counters))
+	if saveErr := cp.StatementSnapshots.Save(); saveErr != nil {
+		log.Error("Error saving the pg_stat_statements snapshot: %v", saveErr)
+	}
+	if previousErr != nil {
+		log.Debug("No previous pg_stat_statements snapshot: %v", previousErr)
+		return nil, nil, errNoPreviousSnapshot
+	}
+	if age := time.Duration(now-storedAt) * time.Second; cp.StatementSnapshotMaxAge > 0 && age > cp.StatementSnapshotMaxAge {
+		log.Warn("Discarding the pg_stat_statements snapshot taken %s ago, older than %s: slow queries are reported from the next run", age, cp.StatementSnapshotMaxAge)
+		return nil, nil, errNoPreviousSnapshot
+	}
+
+	deltas := statementDeltas(counters, previous, cp.QueryMonitoringCountThreshold)
+	if len(deltas) == 0 {
+		return nil, nil, nil
+	}
+	details, err := getSlowQueryDetails(conn, deltas)
 	if err != nil {
 		return nil, nil, err
 	}
-	defer rows.Close()
-	for rows.Next() {
-		var slowQuery datamodels.SlowRunningQueryMetrics
-		if scanErr := rows.StructScan(&slowQuery); scanErr != nil {
-			return nil, nil, err
+
+	interval := now - storedAt
+	for _, delta := range deltas {
+		detail, ok := details[delta.key]
+		if !ok {
+			// the statement was evicted from pg_stat_statements since its counters were fetched
+			continue
 		}
+		slowQuery := detail.SlowRunningQueryMetrics
+		delta.populate(&slowQuery, interval)
 		if slowQuery.QueryText != nil && strings.Contains(strings.ToLower(*slowQuery.QueryText), "alter") {
 			anonymizedQuery := commonutils.AnonymizeQueryText(*slowQuery.QueryText)
 			slowQuery.QueryText = &anonymizedQuery
@@ -42,14 +89,53 @@ func getSlowRunningMetrics(conn *performancedbconnection.PGSQLConnection, cp *co
 	return slowQueryMetricsList, slowQueryMetricsListInterface, nil
 }
 
+// getSlowQueryDetails returns the text and attributes of the statements of deltas
+func getSlowQueryDetails(conn *performancedbconnection.PGSQLConnection, deltas []statementDelta) (map[statementKey]slowQueryDetails, error) {
+	queryIDs := make([]int64, 0, len(deltas))
+	dbIDs := make([]int64, 0, len(deltas))
+	userIDs := make([]int64, 0, len(deltas))
+	for _, delta := range deltas {
+		queryIDs = append(queryIDs, delta.key.queryID)
+		dbIDs = append(dbIDs, delta.key.dbID)
+		userIDs = append(userIDs, delta.key.userID)
+	}
+
+	rows, err := conn.Queryx(queries.SlowQueryDetails, pq.Array(queryIDs), pq.Array(dbIDs), pq.Array(userIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	details := make(map[statementKey]slowQueryDetails, len(deltas))
+	for rows.Next() {
+		var detail slowQueryDetails
+		if err := rows.StructScan(&detail); err != nil {
+			return nil, err
+		}
+		if detail.QueryID == nil {
+			continue
+		}
+		queryID, err := strconv.ParseInt(*detail.QueryID, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		details[statementKey{queryID: queryID, dbID: detail.DBID, userID: detail.UserID}] = detail
+	}
+	return details, rows.Err()
+}
+
 func PopulateSlowRunningMetrics(conn *performancedbconnection.PGSQLConnection, pgIntegration *integration.Integration, cp *commonparameters.CommonParameters, enabledExtensions map[string]bool) []datamodels.SlowRunningQueryMetrics {
 	isEligible := validations.CheckSlowQueryMetricsFetchEligibility(enabledExtensions)
 	if !isEligible {
 		log.Debug("Extension 'pg_stat_statements' is not enabled or unsupported version.")
+		metrics.SkipCollector(conn.Context(), "pg_stat_statements extension is required")
 		return nil
 	}
 
 	slowQueryMetricsList, slowQueryMetricsListInterface, err := getSlowRunningMetrics(conn, cp)
+	if errors.Is(err, errNoPreviousSnapshot) {
+		metrics.SkipCollector(conn.Context(), "slow queries are reported from the next run, once pg_stat_statements counters can be compared")
+		return nil
+	}
 	if err != nil {
 		log.Error("Error fetching slow-running queries: %v", err)
 		return nil
@@ -72,10 +158,15 @@ func PopulateSlowRunningMetricsPgStat(conn *performancedbconnection.PGSQLConnect
 	isEligible := validations.CheckSlowQueryMetricsFetchEligibility(enabledExtensions)
 	if !isEligible {
 		log.Debug("Extension 'pg_stat_statements' is not enabled or unsupported version.")
+		metrics.SkipCollector(conn.Context(), "pg_stat_statements extension is required")
 		return nil
 	}
 	individualQueries := getIndividualQueriesFromPgStat(conn)
 	slowQueryMetricsList, _, err := getSlowRunningMetrics(conn, cp)
+	if errors.Is(err, errNoPreviousSnapshot) {
+		metrics.SkipCollector(conn.Context(), "slow queries are reported from the next run, once pg_stat_statements counters can be compared")
+		return nil
+	}
 	filteredSlowQueryMetrics, filteredSlowQueryMetricsInterface := getFilteredSlowMetrics(individualQueries, slowQueryMetricsList)
 	if err != nil {
 		log.Error("Error fetching slow-running queries: %v", err)
@@ -1,12 +1,14 @@
 package performancemetrics
 
 import (
-	"fmt"
 	"regexp"
 	"testing"
+	"time"
 
 	"github.com/newrelic/nri-postgresql/src/query-performance-monitoring/datamodels"
 
+	"github.com/lib/pq"
+	"github.com/newrelic/infra-integrations-sdk/v3/persist"
 	"github.com/newrelic/nri-postgresql/src/args"
 	"github.com/newrelic/nri-postgresql/src/connection"
 	common_parameters "github.com/newrelic/nri-postgresql/src/query-performance-monitoring/common-parameters"
@@ -16,32 +18,50 @@ import (
 	"gopkg.in/DATA-DOG/go-sqlmock.v1"
 )
 
+var slowQueryCounterColumns = []string{
+	"newrelic", "query_id", "dbid", "userid", "calls", "total_time_ms", "shared_blks_read", "shared_blks_written",
+}
+
 func runSlowQueryTest(t *testing.T, query string, version uint64, expectedLength int) {
 	conn, mock := connection.CreateMockSQL(t)
 	args := args.ArgumentList{QueryMonitoringCountThreshold: 10}
 	databaseName := "testdb"
-	cp := common_parameters.SetCommonParameters(args, version, databaseName)
+	cp := common_parameters.SetCommonParameters(args, version, []string{databaseName})
 
-	query = fmt.Sprintf(query, "testdb", args.QueryMonitoringCountThreshold)
-	mock.ExpectQuery(regexp.QuoteMeta(query)).WillReturnRows(sqlmock.NewRows([]string{
-		"newrelic", "query_id", "query_text", "database_name", "schema_name", "execution_count",
-		"avg_elapsed_time_ms", "avg_disk_reads", "avg_disk_writes", "statement_type", "collection_timestamp",
+	// the first run stores the counters to compare the next one with
+	mock.ExpectQuery(regexp.QuoteMeta(query)).WithArgs(pq.Array([]string{"testdb"})).WillReturnRows(sqlmock.NewRows(slowQueryCounterColumns).
+		AddRow("newrelic", 1, 5, 10, 100, 1000.0, 50, 20).
+		AddRow("newrelic", 2, 5, 10, 10, 10.0, 0, 0))
+	slowQueryList, _, err := getSlowRunningMetrics(conn, cp)
+	assert.ErrorIs(t, err, errNoPreviousSnapshot)
+	assert.Empty(t, slowQueryList)
+
+	mock.ExpectQuery(regexp.QuoteMeta(query)).WithArgs(pq.Array([]string{"testdb"})).WillReturnRows(sqlmock.NewRows(slowQueryCounterColumns).
+		AddRow("newrelic", 1, 5, 10, 110, 1150.0, 60, 20).
+		AddRow("newrelic", 2, 5, 10, 10, 10.0, 0, 0))
+	mock.ExpectQuery(regexp.QuoteMeta(queries.SlowQueryDetails)).WithArgs(pq.Array([]int64{1}), pq.Array([]int64{5}), pq.Array([]int64{10})).WillReturnRows(sqlmock.NewRows([]string{
+		"newrelic", "query_id", "dbid", "userid", "query_text", "database_name", "schema_name", "statement_type", "collection_timestamp",
 	}).AddRow(
-		"newrelic_value", "queryid1", "SELECT 1", "testdb", "public", 10,
-		15.0, 5, 2, "SELECT", "2023-01-01T00:00:00Z",
+		"newrelic", "1", 5, 10, "SELECT 1", "testdb", "public", "SELECT", "2023-01-01T00:00:00Z",
 	))
-	slowQueryList, _, err := getSlowRunningMetrics(conn, cp)
+	slowQueryList, _, err = getSlowRunningMetrics(conn, cp)
 	assert.NoError(t, err)
 	assert.Len(t, slowQueryList, expectedLength)
+	assert.Equal(t, int64(10), *slowQueryList[0].ExecutionCount)
+	assert.Equal(t, 150.0, *slowQueryList[0].TotalElapsedTimeMs)
+	assert.Equal(t, 15.0, *slowQueryList[0].AvgElapsedTimeMs)
+	assert.Equal(t, int64(10), *slowQueryList[0].TotalDiskReads)
+	assert.Equal(t, 1.0, *slowQueryList[0].AvgDiskReads)
+	assert.Equal(t, 0.0, *slowQueryList[0].AvgDiskWrites)
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
 func TestGetSlowRunningMetrics(t *testing.T) {
-	runSlowQueryTest(t, queries.SlowQueriesForV13AndAbove, 13, 1)
+	runSlowQueryTest(t, queries.SlowQueryCountersForV13AndAbove, 13, 1)
 }
 
 func TestGetSlowRunningMetricsV12(t *testing.T) {
-	runSlowQueryTest(t, queries.SlowQueriesForV12, 12, 1)
+	runSlowQueryTest(t, queries.SlowQueryCountersForV12, 12, 1)
 }
 
 func TestGetSlowRunningEmptyMetrics(t *testing.T) {
@@ -49,13 +69,10 @@ func TestGetSlowRunningEmptyMetrics(t *testing.T) {
 	args := args.ArgumentList{QueryMonitoringCountThreshold: 10}
 	databaseName := "testdb"
 	version := uint64(13)
-	cp := common_parameters.SetCommonParameters(args, version, databaseName)
-	expectedQuery := queries.SlowQueriesForV13AndAbove
-	query := fmt.Sprintf(expectedQuery, "testdb", args.QueryMonitoringCountThreshold)
-	mock.ExpectQuery(regexp.QuoteMeta(query)).WillReturnRows(sqlmock.NewRows([]string{
-		"newrelic", "query_id", "query_text", "database_name", "schema_name", "execution_count",
-		"avg_elapsed_time_ms", "avg_disk_reads", "avg_disk_writes", "statement_type", "collection_timestamp",
-	}))
+	cp := common_parameters.SetCommonParameters(args, version, []string{databaseName})
+	cp.StatementSnapshots.Set(slowQuerySnapshotKey, map[string]statementCounters{})
+	query := queries.SlowQueryCountersForV13AndAbove
+	mock.ExpectQuery(regexp.QuoteMeta(query)).WithArgs(pq.Array([]string{"testdb"})).WillReturnRows(sqlmock.NewRows(slowQueryCounterColumns))
 	slowQueryList, _, err := getSlowRunningMetrics(conn, cp)
 
 	assert.NoError(t, err)
@@ -63,12 +80,39 @@ func TestGetSlowRunningEmptyMetrics(t *testing.T) {
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
+func TestGetSlowRunningMetricsExpiredSnapshot(t *testing.T) {
+	conn, mock := connection.CreateMockSQL(t)
+	instanceArgs := args.ArgumentList{QueryMonitoringCountThreshold: 10}
+	instanceArgs.Daemon = true
+	instanceArgs.CollectorIntervals = `{"queryMonitoring": 3600}`
+	cp := common_parameters.SetCommonParameters(instanceArgs, 13, []string{"testdb"})
+	assert.Equal(t, 2*time.Hour, cp.StatementSnapshotMaxAge)
+
+	// a snapshot taken by the previous run is compared even an interval later
+	persist.SetNow(func() time.Time { return time.Now().Add(-time.Hour) })
+	cp.StatementSnapshots.Set(slowQuerySnapshotKey, map[string]statementCounters{})
+	persist.SetNow(time.Now)
+	query := queries.SlowQueryCountersForV13AndAbove
+	mock.ExpectQuery(regexp.QuoteMeta(query)).WithArgs(pq.Array([]string{"testdb"})).WillReturnRows(sqlmock.NewRows(slowQueryCounterColumns))
+	_, _, err := getSlowRunningMetrics(conn, cp)
+	assert.NoError(t, err)
+
+	// but not once it has expired
+	persist.SetNow(func() time.Time { return time.Now().Add(-3 * time.Hour) })
+	cp.StatementSnapshots.Set(slowQuerySnapshotKey, map[string]statementCounters{})
+	persist.SetNow(time.Now)
+	mock.ExpectQuery(regexp.QuoteMeta(query)).WithArgs(pq.Array([]string{"testdb"})).WillReturnRows(sqlmock.NewRows(slowQueryCounterColumns))
+	_, _, err = getSlowRunningMetrics(conn, cp)
+	assert.ErrorIs(t, err, errNoPreviousSnapshot)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
 func TestGetSlowRunningMetricsUnsupportedVersion(t *testing.T) {
 	conn, mock := connection.CreateMockSQL(t)
 	args := args.ArgumentList{QueryMonitoringCountThreshold: 10}
 	databaseName := "testdb"
 	version := uint64(11)
-	cp := common_parameters.SetCommonParameters(args, version, databaseName)
+	cp := common_parameters.SetCommonParameters(args, version, []string{databaseName})
 	slowQueryList, _, err := getSlowRunningMetrics(conn, cp)
 	assert.EqualError(t, err, commonutils.ErrUnsupportedVersion.Error())
 	assert.Len(t, slowQueryList, 0)
@@ -0,0 +1,117 @@
+package performancemetrics
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/newrelic/nri-postgresql/src/query-performance-monitoring/datamodels"
+)
+
+// statementKey identifies a pg_stat_statements entry
+type statementKey struct {
+	queryID int64
+	dbID    int64
+	userID  int64
+}
+
+func (k statementKey) String() string {
+	return fmt.Sprintf("%d:%d:%d", k.queryID, k.dbID, k.userID)
+}
+
+// statementCounters are the cumulative counters of a statement kept between runs
+type statementCounters struct {
+	Calls             int64   `json:"calls"`
+	TotalTimeMs       float64 `json:"total_time_ms"`
+	SharedBlksRead    int64   `json:"shared_blks_read"`
+	SharedBlksWritten int64   `json:"shared_blks_written"`
+}
+
+// statementDelta is what a statement did between two snapshots
+type statementDelta struct {
+	key statementKey
+	statementCounters
+}
+
+func newStatementSnapshot(counters []datamodels.SlowQueryCounters) map[string]statementCounters {
+	snapshot := make(map[string]statementCounters, len(counters))
+	for _, c := range counters {
+		snapshot[counterKey(c).String()] = countersOf(c)
+	}
+	return snapshot
+}
+
+// statementDeltas returns the statements executed since the previous snapshot, the most time consuming first, and at
+// most limit of them. A statement missing from the previous snapshot, or whose counters went backwards because
+// pg_stat_statements was reset or evicted it in between, is counted from zero.
+func statementDeltas(counters []datamodels.SlowQueryCounters, previous map[string]statementCounters, limit int) []statementDelta {
+	deltas := make([]statementDelta, 0, len(counters))
+	for _, c := range counters {
+		key := counterKey(c)
+		delta := countersOf(c)
+		if before, ok := previous[key.String()]; ok && before.Calls <= delta.Calls && before.TotalTimeMs <= delta.TotalTimeMs {
+			delta.Calls -= before.Calls
+			delta.TotalTimeMs -= before.TotalTimeMs
+			delta.SharedBlksRead = nonNegative(delta.SharedBlksRead - before.SharedBlksRead)
+			delta.SharedBlksWritten = nonNegative(delta.SharedBlksWritten - before.SharedBlksWritten)
+		}
+		if delta.Calls <= 0 {
+			continue
+		}
+		deltas = append(deltas, statementDelta{key: key, statementCounters: delta})
+	}
+
+	sort.Slice(deltas, func(i, j int) bool {
+		if deltas[i].TotalTimeMs != deltas[j].TotalTimeMs {
+			return deltas[i].TotalTimeMs > deltas[j].TotalTimeMs
+		}
+		return deltas[i].key.String() < deltas[j].key.String()
+	})
+	if len(deltas) > limit {
+		deltas = deltas[:limit]
+	}
+	return deltas
+}
+
+// populate sets the interval metrics of slowQuery, interval being the number of seconds between the snapshots
+func (d statementDelta) populate(slowQuery *datamodels.SlowRunningQueryMetrics, interval int64) {
+	calls := float64(d.Calls)
+	totalTime := round(d.TotalTimeMs)
+	avgTime := round(d.TotalTimeMs / calls)
+	avgReads := round(float64(d.SharedBlksRead) / calls)
+	avgWrites := round(float64(d.SharedBlksWritten) / calls)
+
+	slowQuery.ExecutionCount = &d.Calls
+	slowQuery.TotalElapsedTimeMs = &totalTime
+	slowQuery.AvgElapsedTimeMs = &avgTime
+	slowQuery.TotalDiskReads = &d.SharedBlksRead
+	slowQuery.TotalDiskWrites = &d.SharedBlksWritten
+	slowQuery.AvgDiskReads = &avgReads
+	slowQuery.AvgDiskWrites = &avgWrites
+	slowQuery.IntervalSeconds = &interval
+}
+
+func counterKey(c datamodels.SlowQueryCounters) statementKey {
+	return statementKey{queryID: c.QueryID, dbID: c.DBID, userID: c.UserID}
+}
+
+func countersOf(c datamodels.SlowQueryCounters) statementCounters {
+	return statementCounters{
+		Calls:             c.Calls,
+		TotalTimeMs:       c.TotalTimeMs,
+		SharedBlksRead:    c.SharedBlksRead,
+		SharedBlksWritten: c.SharedBlksWritten,
+	}
+}
+
+func nonNegative(n int64) int64 {
+	if n < 0 {
+		return 0
+	}
+	return n
+}
+
+// round rounds v to three decimals
+func round(v float64) float64 {
+	return math.Round(v*1000) / 1000
+}
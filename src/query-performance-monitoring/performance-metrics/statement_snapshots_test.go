@@ -0,0 +1,85 @@
+package performancemetrics
+
+import (
+	"testing"
+
+	"github.com/newrelic/nri-postgresql/src/query-performance-monitoring/datamodels"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStatementDeltas(t *testing.T) {
+	counters := []datamodels.SlowQueryCounters{
+		{QueryID: 1, DBID: 5, UserID: 10, Calls: 110, TotalTimeMs: 1100, SharedBlksRead: 60, SharedBlksWritten: 20},
+		{QueryID: 2, DBID: 5, UserID: 10, Calls: 4, TotalTimeMs: 400, SharedBlksRead: 8},
+		{QueryID: 1, DBID: 6, UserID: 10, Calls: 50, TotalTimeMs: 50},
+	}
+
+	tests := []struct {
+		name     string
+		previous map[string]statementCounters
+		limit    int
+		expected []statementDelta
+	}{
+		{
+			name: "ranked by interval time",
+			previous: map[string]statementCounters{
+				"1:5:10": {Calls: 100, TotalTimeMs: 1000, SharedBlksRead: 50, SharedBlksWritten: 20},
+				"2:5:10": {Calls: 2, TotalTimeMs: 200, SharedBlksRead: 4},
+				"1:6:10": {Calls: 50, TotalTimeMs: 50},
+			},
+			limit: 10,
+			expected: []statementDelta{
+				{key: statementKey{2, 5, 10}, statementCounters: statementCounters{Calls: 2, TotalTimeMs: 200, SharedBlksRead: 4}},
+				{key: statementKey{1, 5, 10}, statementCounters: statementCounters{Calls: 10, TotalTimeMs: 100, SharedBlksRead: 10}},
+			},
+		},
+		{
+			name: "new statements are counted from zero",
+			previous: map[string]statementCounters{
+				"1:5:10": {Calls: 100, TotalTimeMs: 1000, SharedBlksRead: 50, SharedBlksWritten: 20},
+				"2:5:10": {Calls: 2, TotalTimeMs: 200, SharedBlksRead: 4},
+			},
+			limit: 10,
+			expected: []statementDelta{
+				{key: statementKey{2, 5, 10}, statementCounters: statementCounters{Calls: 2, TotalTimeMs: 200, SharedBlksRead: 4}},
+				{key: statementKey{1, 5, 10}, statementCounters: statementCounters{Calls: 10, TotalTimeMs: 100, SharedBlksRead: 10}},
+				{key: statementKey{1, 6, 10}, statementCounters: statementCounters{Calls: 50, TotalTimeMs: 50}},
+			},
+		},
+		{
+			name: "reset counters are counted from zero",
+			previous: map[string]statementCounters{
+				"1:5:10": {Calls: 500, TotalTimeMs: 9000, SharedBlksRead: 70},
+				"2:5:10": {Calls: 4, TotalTimeMs: 400, SharedBlksRead: 8},
+				"1:6:10": {Calls: 50, TotalTimeMs: 50},
+			},
+			limit: 10,
+			expected: []statementDelta{
+				{key: statementKey{1, 5, 10}, statementCounters: statementCounters{Calls: 110, TotalTimeMs: 1100, SharedBlksRead: 60, SharedBlksWritten: 20}},
+			},
+		},
+		{
+			name:     "limited",
+			previous: map[string]statementCounters{},
+			limit:    1,
+			expected: []statementDelta{
+				{key: statementKey{1, 5, 10}, statementCounters: statementCounters{Calls: 110, TotalTimeMs: 1100, SharedBlksRead: 60, SharedBlksWritten: 20}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, statementDeltas(counters, tt.previous, tt.limit))
+		})
+	}
+}
+
+func TestNewStatementSnapshot(t *testing.T) {
+	snapshot := newStatementSnapshot([]datamodels.SlowQueryCounters{
+		{QueryID: -42, DBID: 5, UserID: 10, Calls: 3, TotalTimeMs: 1.5, SharedBlksRead: 2, SharedBlksWritten: 1},
+	})
+	assert.Equal(t, map[string]statementCounters{
+		"-42:5:10": {Calls: 3, TotalTimeMs: 1.5, SharedBlksRead: 2, SharedBlksWritten: 1},
+	}, snapshot)
+}
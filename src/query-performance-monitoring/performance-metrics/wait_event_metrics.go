@@ -1,12 +1,13 @@
 package performancemetrics
 
 import (
-	"fmt"
 	"github.com/newrelic/nri-postgresql/src/query-performance-monitoring/queries"
 
+	"github.com/lib/pq"
 	"github.com/newrelic/infra-integrations-sdk/v3/integration"
 	"github.com/newrelic/infra-integrations-sdk/v3/log"
 	performancedbconnection "github.com/newrelic/nri-postgresql/src/connection"
+	"github.com/newrelic/nri-postgresql/src/metrics"
 	commonparameters "github.com/newrelic/nri-postgresql/src/query-performance-monitoring/common-parameters"
 	commonutils "github.com/newrelic/nri-postgresql/src/query-performance-monitoring/common-utils"
 	"github.com/newrelic/nri-postgresql/src/query-performance-monitoring/datamodels"
@@ -17,6 +18,7 @@ func PopulateWaitEventMetrics(conn *performancedbconnection.PGSQLConnection, pgI
 	var isEligible = validations.CheckWaitEventMetricsFetchEligibility(enabledExtensions)
 	if !isEligible {
 		log.Debug("Extension 'pg_wait_sampling' or 'pg_stat_statement' is not enabled or unsupported version.")
+		metrics.SkipCollector(conn.Context(), "pg_wait_sampling and pg_stat_statements extensions are required")
 		return commonutils.ErrNotEligible
 	}
 	waitEventMetricsList, waitEventErr := getWaitEventMetrics(conn, cp)
@@ -38,8 +40,7 @@ func PopulateWaitEventMetrics(conn *performancedbconnection.PGSQLConnection, pgI
 
 func getWaitEventMetrics(conn *performancedbconnection.PGSQLConnection, cp *commonparameters.CommonParameters) ([]interface{}, error) {
 	var waitEventMetricsList []interface{}
-	var query = fmt.Sprintf(queries.WaitEvents, cp.Databases, cp.QueryMonitoringCountThreshold)
-	rows, err := conn.Queryx(query)
+	rows, err := conn.Queryx(queries.WaitEvents, pq.Array(cp.Databases), cp.QueryMonitoringCountThreshold)
 	if err != nil {
 		return nil, err
 	}
@@ -76,8 +77,7 @@ func PopulateWaitEventMetricsPgStat(conn *performancedbconnection.PGSQLConnectio
 
 func getWaitEventMetricsPgStat(conn *performancedbconnection.PGSQLConnection, cp *commonparameters.CommonParameters) ([]datamodels.WaitEventMetrics, error) {
 	var waitEventMetricsList []datamodels.WaitEventMetrics
-	var query = fmt.Sprintf(queries.WaitEventsFromPgStatActivity, cp.Databases, cp.QueryMonitoringCountThreshold)
-	rows, err := conn.Queryx(query)
+	rows, err := conn.Queryx(queries.WaitEventsFromPgStatActivity, pq.Array(cp.Databases), cp.QueryMonitoringCountThreshold)
 	if err != nil {
 		return nil, err
 	}
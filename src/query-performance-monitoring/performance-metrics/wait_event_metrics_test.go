@@ -1,10 +1,10 @@
 package performancemetrics
 
 import (
-	"fmt"
 	"regexp"
 	"testing"
 
+	"github.com/lib/pq"
 	"github.com/newrelic/nri-postgresql/src/args"
 	"github.com/newrelic/nri-postgresql/src/connection"
 	common_parameters "github.com/newrelic/nri-postgresql/src/query-performance-monitoring/common-parameters"
@@ -18,10 +18,10 @@ func TestGetWaitEventMetrics(t *testing.T) {
 	conn, mock := connection.CreateMockSQL(t)
 	args := args.ArgumentList{QueryMonitoringCountThreshold: 10}
 	databaseName := "testdb"
-	cp := common_parameters.SetCommonParameters(args, uint64(14), databaseName)
+	cp := common_parameters.SetCommonParameters(args, uint64(14), []string{databaseName})
 
-	var query = fmt.Sprintf(queries.WaitEvents, databaseName, args.QueryMonitoringCountThreshold)
-	mock.ExpectQuery(regexp.QuoteMeta(query)).WillReturnRows(sqlmock.NewRows([]string{
+	var query = queries.WaitEvents
+	mock.ExpectQuery(regexp.QuoteMeta(query)).WithArgs(pq.Array([]string{databaseName}), args.QueryMonitoringCountThreshold).WillReturnRows(sqlmock.NewRows([]string{
 		"wait_event_name", "wait_category", "total_wait_time_ms", "collection_timestamp", "query_id", "query_text", "database_name",
 	}).AddRow(
 		"Locks:Lock", "Locks", 1000.0, "2023-01-01T00:00:00Z", "queryid1", "SELECT 1", "testdb",
@@ -37,10 +37,10 @@ func TestGetWaitEventEmptyMetrics(t *testing.T) {
 	conn, mock := connection.CreateMockSQL(t)
 	args := args.ArgumentList{QueryMonitoringCountThreshold: 10}
 	databaseName := "testdb"
-	cp := common_parameters.SetCommonParameters(args, uint64(14), databaseName)
+	cp := common_parameters.SetCommonParameters(args, uint64(14), []string{databaseName})
 
-	var query = fmt.Sprintf(queries.WaitEvents, databaseName, args.QueryMonitoringCountThreshold)
-	mock.ExpectQuery(regexp.QuoteMeta(query)).WillReturnRows(sqlmock.NewRows([]string{
+	var query = queries.WaitEvents
+	mock.ExpectQuery(regexp.QuoteMeta(query)).WithArgs(pq.Array([]string{databaseName}), args.QueryMonitoringCountThreshold).WillReturnRows(sqlmock.NewRows([]string{
 		"wait_event_name", "wait_category", "total_wait_time_ms", "collection_timestamp", "query_id", "query_text", "database_name",
 	}))
 	waitEventsList, err := getWaitEventMetrics(conn, cp)
@@ -54,9 +54,9 @@ func TestGetWaitEventMetricsFromPgStatActivity(t *testing.T) {
 	args := args.ArgumentList{QueryMonitoringCountThreshold: 10, Hostname: "testhost.rds.amazonaws.com"}
 	databaseName := "testdb"
 
-	cp := common_parameters.SetCommonParameters(args, uint64(14), databaseName)
-	query := fmt.Sprintf(queries.WaitEventsFromPgStatActivity, databaseName, args.QueryMonitoringCountThreshold)
-	mock.ExpectQuery(regexp.QuoteMeta(query)).WillReturnRows(sqlmock.NewRows([]string{
+	cp := common_parameters.SetCommonParameters(args, uint64(14), []string{databaseName})
+	query := queries.WaitEventsFromPgStatActivity
+	mock.ExpectQuery(regexp.QuoteMeta(query)).WithArgs(pq.Array([]string{databaseName}), args.QueryMonitoringCountThreshold).WillReturnRows(sqlmock.NewRows([]string{
 		"wait_event_name", "wait_category", "total_wait_time_ms", "collection_timestamp", "query_id", "query_text", "database_name",
 	}).AddRow(
 		"Locks:Lock", "Locks", 500.0, "2023-01-01T00:00:00Z", "queryid2", "SELECT 2", "testdb",
@@ -72,16 +72,16 @@ func TestGetWaitEventMetricsFromPgStatActivity(t *testing.T) {
 func TestGetWaitEventMetricsPgStat_Success(t *testing.T) {
 	conn, mock := connection.CreateMockSQL(t)
 	cp := &common_parameters.CommonParameters{
-		Databases:                     "testdb",
+		Databases:                     []string{"testdb"},
 		QueryMonitoringCountThreshold: 10,
 	}
-	query := fmt.Sprintf(queries.WaitEventsFromPgStatActivity, cp.Databases, cp.QueryMonitoringCountThreshold)
+	query := queries.WaitEventsFromPgStatActivity
 	mockRows := sqlmock.NewRows([]string{
 		"wait_event_name", "wait_category", "total_wait_time_ms", "collection_timestamp", "query_id", "query_text", "database_name",
 	}).AddRow(
 		"Locks:Lock", "Locks", 500.0, "2023-01-01T00:00:00Z", "queryid1", "SELECT 1", "testdb",
 	)
-	mock.ExpectQuery(regexp.QuoteMeta(query)).WillReturnRows(mockRows)
+	mock.ExpectQuery(regexp.QuoteMeta(query)).WithArgs(pq.Array(cp.Databases), cp.QueryMonitoringCountThreshold).WillReturnRows(mockRows)
 
 	waitEventMetrics, err := getWaitEventMetricsPgStat(conn, cp)
 
@@ -99,7 +99,7 @@ func TestGetFilteredWaitEvents(t *testing.T) {
 	}
 	slowQueryMetrics := []datamodels.SlowRunningQueryMetrics{
 		{
-			QueryText: stringPointer("SELECT ?"),
+			QueryText: stringPointer("SELECT $1"),
 			QueryID:   stringPointer("queryid1"),
 		},
 	}
@@ -108,7 +108,7 @@ func TestGetFilteredWaitEvents(t *testing.T) {
 
 	assert.Len(t, filteredMetrics, 1)
 	filteredMetric := filteredMetrics[0].(datamodels.WaitEventMetrics)
-	assert.Equal(t, "SELECT ?", *filteredMetric.QueryText)
+	assert.Equal(t, "SELECT $1", *filteredMetric.QueryText)
 	assert.Equal(t, "queryid1", *filteredMetric.QueryID)
 }
 
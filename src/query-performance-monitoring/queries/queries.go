@@ -2,76 +2,82 @@
 package queries
 
 const (
-	SlowQueriesForV13AndAbove = `SELECT 'newrelic' as newrelic, -- Common value to filter with like operator in slow query metrics
+	// SlowQueryCountersForV13AndAbove retrieves the cumulative counters of the statements for PostgreSQL version 13 and
+	// above. The slow queries of an interval are ranked from the difference with the counters of the previous run.
+	SlowQueryCountersForV13AndAbove = `SELECT 'newrelic' as newrelic, -- Common value to filter with like operator in slow query metrics
 		pss.queryid AS query_id, -- Unique identifier for the query
-		LEFT(pss.query, 4095) AS query_text, -- Query text truncated to 4095 characters
-		pd.datname AS database_name, -- Name of the database
-		current_schema() AS schema_name, -- Name of the current schema
-		pss.calls AS execution_count, -- Number of times the query was executed
-		ROUND((pss.total_exec_time / pss.calls)::numeric, 3) AS avg_elapsed_time_ms, -- Average execution time in milliseconds
-		pss.shared_blks_read / pss.calls AS avg_disk_reads, -- Average number of disk reads per execution
-		pss.shared_blks_written / pss.calls AS avg_disk_writes, -- Average number of disk writes per execution
-		CASE
-			WHEN pss.query ILIKE 'SELECT%%' THEN 'SELECT' -- Query type is SELECT
-			WHEN pss.query ILIKE 'INSERT%%' THEN 'INSERT' -- Query type is INSERT
-			WHEN pss.query ILIKE 'UPDATE%%' THEN 'UPDATE' -- Query type is UPDATE
-			WHEN pss.query ILIKE 'DELETE%%' THEN 'DELETE' -- Query type is DELETE
-			ELSE 'OTHER' -- Query type is OTHER
-		END AS statement_type, -- Type of SQL statement
-		to_char(NOW() AT TIME ZONE 'UTC', 'YYYY-MM-DD"T"HH24:MI:SS"Z"') AS collection_timestamp -- Timestamp of data collection
+		pss.dbid AS dbid, -- OID of the database
+		pss.userid AS userid, -- OID of the user who executed the query
+		SUM(pss.calls)::bigint AS calls, -- Number of times the query was executed
+		SUM(pss.total_exec_time) AS total_time_ms, -- Total execution time in milliseconds
+		SUM(pss.shared_blks_read)::bigint AS shared_blks_read, -- Total number of disk reads
+		SUM(pss.shared_blks_written)::bigint AS shared_blks_written -- Total number of disk writes
 	FROM
 		pg_stat_statements pss
 	JOIN
 		pg_database pd ON pss.dbid = pd.oid
-	WHERE 
-		pd.datname in (%s) -- List of database names
-		AND pss.query NOT ILIKE 'EXPLAIN (FORMAT JSON)%%' -- Exclude EXPLAIN queries
-		AND pss.query NOT ILIKE 'SELECT $1 as newrelic%%' -- Exclude specific New Relic queries
-		AND pss.query NOT ILIKE 'WITH wait_history AS%%' -- Exclude specific WITH queries
-		AND pss.query NOT ILIKE 'select -- BLOATQUERY%%' -- Exclude BLOATQUERY
-		AND pss.query NOT ILIKE 'select -- INDEXQUERY%%' -- Exclude INDEXQUERY
-		AND pss.query NOT ILIKE 'SELECT -- TABLEQUERY%%' -- Exclude TABLEQUERY
-		AND pss.query NOT ILIKE 'SELECT table_schema%%' -- Exclude table_schema queries
-	ORDER BY
-		avg_elapsed_time_ms DESC -- Order by the average elapsed time in descending order
-	LIMIT %d;`
+	WHERE
+		pd.datname = ANY($1) -- List of database names
+		AND pss.queryid IS NOT NULL -- Exclude statements without a query identifier
+		AND pss.query NOT ILIKE 'EXPLAIN (FORMAT JSON)%' -- Exclude EXPLAIN queries
+		AND pss.query NOT ILIKE 'SELECT $1 as newrelic%' -- Exclude specific New Relic queries
+		AND pss.query NOT ILIKE 'WITH wait_history AS%' -- Exclude specific WITH queries
+		AND pss.query NOT ILIKE 'select -- BLOATQUERY%' -- Exclude BLOATQUERY
+		AND pss.query NOT ILIKE 'select -- INDEXQUERY%' -- Exclude INDEXQUERY
+		AND pss.query NOT ILIKE 'SELECT -- TABLEQUERY%' -- Exclude TABLEQUERY
+		AND pss.query NOT ILIKE 'SELECT table_schema%' -- Exclude table_schema queries
+	GROUP BY
+		pss.queryid, pss.dbid, pss.userid; -- Top level and nested executions of a statement are counted together`
 
-	// SlowQueriesForV12 retrieves slow queries and their statistics for PostgreSQL version 12
-	SlowQueriesForV12 = `SELECT 'newrelic' as newrelic, -- Common value to filter with like operator in slow query metrics
+	// SlowQueryCountersForV12 retrieves the cumulative counters of the statements for PostgreSQL version 12
+	SlowQueryCountersForV12 = `SELECT 'newrelic' as newrelic, -- Common value to filter with like operator in slow query metrics
 		pss.queryid AS query_id, -- Unique identifier for the query
+		pss.dbid AS dbid, -- OID of the database
+		pss.userid AS userid, -- OID of the user who executed the query
+		SUM(pss.calls)::bigint AS calls, -- Number of times the query was executed
+		SUM(pss.total_time) AS total_time_ms, -- Total execution time in milliseconds
+		SUM(pss.shared_blks_read)::bigint AS shared_blks_read, -- Total number of disk reads
+		SUM(pss.shared_blks_written)::bigint AS shared_blks_written -- Total number of disk writes
+	FROM
+		pg_stat_statements pss
+	JOIN
+		pg_database pd ON pss.dbid = pd.oid
+	WHERE
+		pd.datname = ANY($1) -- List of database names
+		AND pss.queryid IS NOT NULL -- Exclude statements without a query identifier
+		AND pss.query NOT ILIKE 'EXPLAIN (FORMAT JSON) %' -- Exclude EXPLAIN queries
+		AND pss.query NOT ILIKE 'SELECT $1 as newrelic%' -- Exclude specific New Relic queries
+		AND pss.query NOT ILIKE 'WITH wait_history AS%' -- Exclude specific WITH queries
+		AND pss.query NOT ILIKE 'select -- BLOATQUERY%' -- Exclude BLOATQUERY
+		AND pss.query NOT ILIKE 'select -- INDEXQUERY%' -- Exclude INDEXQUERY
+		AND pss.query NOT ILIKE 'SELECT -- TABLEQUERY%' -- Exclude TABLEQUERY
+		AND pss.query NOT ILIKE 'SELECT table_schema%' -- Exclude table_schema queries
+		AND pss.query NOT ILIKE 'SELECT D.datname%' -- Exclude specific datname queries
+	GROUP BY
+		pss.queryid, pss.dbid, pss.userid; -- Top level and nested executions of a statement are counted together`
+
+	// SlowQueryDetails retrieves the text and attributes of the statements identified by their query, database and user
+	SlowQueryDetails = `SELECT DISTINCT ON (pss.queryid, pss.dbid, pss.userid) 'newrelic' as newrelic, -- Common value to filter with like operator in slow query metrics
+		pss.queryid AS query_id, -- Unique identifier for the query
+		pss.dbid AS dbid, -- OID of the database
+		pss.userid AS userid, -- OID of the user who executed the query
 		LEFT(pss.query, 4095) AS query_text, -- Query text truncated to 4095 characters
 		pd.datname AS database_name, -- Name of the database
 		current_schema() AS schema_name, -- Name of the current schema
-		pss.calls AS execution_count, -- Number of times the query was executed
-		ROUND((pss.total_time / pss.calls)::numeric, 3) AS avg_elapsed_time_ms, -- Average execution time in milliseconds
-		pss.shared_blks_read / pss.calls AS avg_disk_reads, -- Average number of disk reads per execution
-		pss.shared_blks_written / pss.calls AS avg_disk_writes, -- Average number of disk writes per execution
 		CASE
-		  WHEN pss.query ILIKE 'SELECT%%' THEN 'SELECT' -- Query type is SELECT
-		  WHEN pss.query ILIKE 'INSERT%%' THEN 'INSERT' -- Query type is INSERT
-		  WHEN pss.query ILIKE 'UPDATE%%' THEN 'UPDATE' -- Query type is UPDATE
-		  WHEN pss.query ILIKE 'DELETE%%' THEN 'DELETE' -- Query type is DELETE
-		  ELSE 'OTHER' -- Query type is OTHER
+			WHEN pss.query ILIKE 'SELECT%' THEN 'SELECT' -- Query type is SELECT
+			WHEN pss.query ILIKE 'INSERT%' THEN 'INSERT' -- Query type is INSERT
+			WHEN pss.query ILIKE 'UPDATE%' THEN 'UPDATE' -- Query type is UPDATE
+			WHEN pss.query ILIKE 'DELETE%' THEN 'DELETE' -- Query type is DELETE
+			ELSE 'OTHER' -- Query type is OTHER
 		END AS statement_type, -- Type of SQL statement
 		to_char(NOW() AT TIME ZONE 'UTC', 'YYYY-MM-DD"T"HH24:MI:SS"Z"') AS collection_timestamp -- Timestamp of data collection
 	FROM
 		pg_stat_statements pss
 	JOIN
 		pg_database pd ON pss.dbid = pd.oid
-		WHERE 
-		pd.datname in (%s) -- List of database names
-		AND pss.query NOT ILIKE 'EXPLAIN (FORMAT JSON) %%' -- Exclude EXPLAIN queries
-		AND pss.query NOT ILIKE 'SELECT $1 as newrelic%%' -- Exclude specific New Relic queries
-		AND pss.query NOT ILIKE 'WITH wait_history AS%%' -- Exclude specific WITH queries
-		AND pss.query NOT ILIKE 'select -- BLOATQUERY%%' -- Exclude BLOATQUERY
-		AND pss.query NOT ILIKE 'select -- INDEXQUERY%%' -- Exclude INDEXQUERY
-		AND pss.query NOT ILIKE 'SELECT -- TABLEQUERY%%' -- Exclude TABLEQUERY
-		AND pss.query NOT ILIKE 'SELECT table_schema%%' -- Exclude table_schema queries
-		AND pss.query NOT ILIKE 'SELECT D.datname%%' -- Exclude specific datname queries
-	ORDER BY
-		avg_elapsed_time_ms DESC -- Order by the average elapsed time in descending order
-	LIMIT
-		 %d; -- Limit the number of results`
+	WHERE
+		(pss.queryid, pss.dbid, pss.userid) IN (SELECT * FROM unnest($1::bigint[], $2::oid[], $3::oid[])); -- Statements to describe`
 
 	// WaitEvents retrieves wait events and their statistics from pg_wait_sampling_history
 	WaitEvents = `WITH wait_history AS (
@@ -90,7 +96,7 @@ const (
 			pg_stat_statements sa ON wh.queryid = sa.queryid
 		LEFT JOIN
 			pg_database ON pg_database.oid = sa.dbid
-		WHERE pg_database.datname in (%s) -- List of database names
+		WHERE pg_database.datname = ANY($1) -- List of database names
 	)
 	SELECT
 		event_type || ':' || event AS wait_event_name, -- Concatenated wait event name
@@ -106,10 +112,10 @@ const (
 		query_text, -- Query text
 		database_name -- Name of the database
 	FROM wait_history
-	WHERE query_text NOT LIKE 'EXPLAIN (FORMAT JSON) %%' AND query_id IS NOT NULL AND event_type IS NOT NULL
+	WHERE query_text NOT LIKE 'EXPLAIN (FORMAT JSON) %' AND query_id IS NOT NULL AND event_type IS NOT NULL
 	GROUP BY event_type, event, query_id, query_text, database_name
 	ORDER BY total_wait_time_ms DESC -- Order by the total wait time in descending order
-	LIMIT %d; -- Limit the number of results`
+	LIMIT $2; -- Limit the number of results`
 
 	// WaitEvents retrieves wait events and their statistics from pg_stat_activity and doesnt involve joining pg_stat_staments as query id is missing in pg_stat_activity
 	WaitEventsFromPgStatActivity = `WITH wait_history AS (
@@ -124,7 +130,7 @@ const (
             pg_stat_activity sa
         LEFT JOIN
             pg_database ON pg_database.oid = sa.datid
-        WHERE pg_database.datname in (%s) -- List of database names 
+        WHERE pg_database.datname = ANY($1) -- List of database names 
 			AND sa.state = 'active' -- Only consider active sessions
       )
     SELECT
@@ -140,10 +146,10 @@ const (
         to_char(NOW() AT TIME ZONE 'UTC', 'YYYY-MM-DD"T"HH24:MI:SS"Z"') AS collection_timestamp, -- Timestamp of data collection
         database_name -- Name of the database
     FROM wait_history
-    WHERE query_text NOT LIKE 'EXPLAIN (FORMAT JSON) %%' AND event_type IS NOT NULL 
+    WHERE query_text NOT LIKE 'EXPLAIN (FORMAT JSON) %' AND event_type IS NOT NULL 
     GROUP BY event_type, event, database_name,total_wait_time_ms,query_text
     ORDER BY total_wait_time_ms DESC -- Order by the total wait time in descending order
-    LIMIT %d;  -- Limit the number of results`
+    LIMIT $2;  -- Limit the number of results`
 
 	// BlockingQueriesForV14AndAbove retrieves information about blocking and blocked queries for PostgreSQL version 14 and above
 	BlockingQueriesForV14AndAbove = `SELECT 'newrelic' as newrelic, -- Common value to filter with like operator in slow query metrics
@@ -172,11 +178,11 @@ const (
 		JOIN pg_stat_activity AS blocking_activity ON blocking_locks.pid = blocking_activity.pid
 		JOIN pg_stat_statements AS blocking_statements ON blocking_activity.query_id = blocking_statements.queryid
 		WHERE NOT blocked_locks.granted
-		  AND blocked_activity.datname IN (%s) -- List of database names
-		  AND blocked_statements.query NOT LIKE 'EXPLAIN (FORMAT JSON) %%' -- Exclude EXPLAIN queries
-		  AND blocking_statements.query NOT LIKE 'EXPLAIN (FORMAT JSON) %%' -- Exclude EXPLAIN queries
+		  AND blocked_activity.datname = ANY($1) -- List of database names
+		  AND blocked_statements.query NOT LIKE 'EXPLAIN (FORMAT JSON) %' -- Exclude EXPLAIN queries
+		  AND blocking_statements.query NOT LIKE 'EXPLAIN (FORMAT JSON) %' -- Exclude EXPLAIN queries
 		ORDER BY blocked_activity.query_start ASC -- Order by the start time of the blocked query in ascending order
-		LIMIT %d; -- Limit the number of results`
+		LIMIT $2; -- Limit the number of results`
 
 	// RDSPostgresBlockingQuery retrieves blocking session events and their statistics from pg_stat_activity and doesnt involve joining pg_stat_staments as query id is missing in pg_stat_activity
 	RDSPostgresBlockingQuery = `SELECT 'newrelic' as newrelic, -- Common value to filter with like operator in slow query metrics
@@ -201,11 +207,11 @@ const (
 		  AND blocked_locks.pid <> blocking_locks.pid
 		JOIN pg_stat_activity AS blocking_activity ON blocking_locks.pid = blocking_activity.pid
 		WHERE NOT blocked_locks.granted
-          AND blocked_activity.datname IN (%s) -- List of database names
-		  AND blocked_activity.query NOT LIKE 'EXPLAIN (FORMAT JSON) %%' -- Exclude EXPLAIN queries
-		  AND blocking_activity.query NOT LIKE 'EXPLAIN (FORMAT JSON) %%' -- Exclude EXPLAIN queries
+          AND blocked_activity.datname = ANY($1) -- List of database names
+		  AND blocked_activity.query NOT LIKE 'EXPLAIN (FORMAT JSON) %' -- Exclude EXPLAIN queries
+		  AND blocking_activity.query NOT LIKE 'EXPLAIN (FORMAT JSON) %' -- Exclude EXPLAIN queries
 		ORDER BY blocked_activity.query_start ASC -- Order by the start time of the blocked query in ascending order
-		LIMIT %d; -- Limit the number of results`
+		LIMIT $2; -- Limit the number of results`
 
 	// BlockingQueriesForV12AndV13 retrieves information about blocking and blocked queries for PostgreSQL versions 12 and 13
 	BlockingQueriesForV12AndV13 = `SELECT 'newrelic' as newrelic, -- Common value to filter with like operator in slow query metrics
@@ -230,11 +236,11 @@ const (
 		AND blocked_locks.pid <> blocking_locks.pid
 	JOIN pg_stat_activity AS blocking_activity ON blocking_locks.pid = blocking_activity.pid
 	WHERE NOT blocked_locks.granted
-		AND blocked_activity.datname IN (%s) -- List of database names
-		AND blocked_activity.query NOT LIKE 'EXPLAIN (FORMAT JSON) %%' -- Exclude EXPLAIN queries
-		AND blocking_activity.query NOT LIKE 'EXPLAIN (FORMAT JSON) %%' -- Exclude EXPLAIN queries
+		AND blocked_activity.datname = ANY($1) -- List of database names
+		AND blocked_activity.query NOT LIKE 'EXPLAIN (FORMAT JSON) %' -- Exclude EXPLAIN queries
+		AND blocking_activity.query NOT LIKE 'EXPLAIN (FORMAT JSON) %' -- Exclude EXPLAIN queries
 		ORDER BY blocked_activity.query_start ASC -- Order by the start time of the blocked query in ascending order
-		LIMIT %d; -- Limit the number of results`
+		LIMIT $2; -- Limit the number of results`
 
 	// IndividualQuerySearchV13AndAbove retrieves individual query statistics for PostgreSQL version 13 and above
 	IndividualQuerySearchV13AndAbove = `SELECT 'newrelic' as newrelic, -- Common value to filter with like operator in slow query metrics
@@ -247,15 +253,15 @@ const (
 		FROM
 		 pg_stat_monitor
 		WHERE
-		 queryid = %s -- Query identifier
-		 AND datname IN (%s) -- List of database names
-		 AND (total_exec_time / NULLIF(calls, 0)) > %d -- Minimum average execution time
+		 queryid = $1 -- Query identifier
+		 AND datname = ANY($2) -- List of database names
+		 AND (total_exec_time / NULLIF(calls, 0)) > $3 -- Minimum average execution time
 		 AND bucket_start_time >= NOW() - INTERVAL '60 seconds' -- Time interval
 		GROUP BY
 		 query, queryid, datname, planid, cpu_user_time, cpu_sys_time, calls, total_exec_time
 		ORDER BY
 		 exec_time_ms DESC -- Order by average execution time in descending order
-		LIMIT %d; -- Limit the number of results`
+		LIMIT $4; -- Limit the number of results`
 
 	// IndividualQueryFromPgStat retrieves currently running or last executed query of  DB connections
 	IndividualQueryFromPgStat = "select query  from pg_stat_activity where query is not null and query !='';"
@@ -271,13 +277,13 @@ const (
 		FROM
 		 pg_stat_monitor
 		WHERE
-		 queryid = %s -- Query identifier
-		 AND datname IN (%s) -- List of database names
-		 AND (total_time / NULLIF(calls, 0)) > %d -- Minimum average execution time
+		 queryid = $1 -- Query identifier
+		 AND datname = ANY($2) -- List of database names
+		 AND (total_time / NULLIF(calls, 0)) > $3 -- Minimum average execution time
 		 AND bucket_start_time >= NOW() - INTERVAL '60 seconds' -- Time interval
 		GROUP BY
 		 query, queryid, datname, planid, cpu_user_time, cpu_sys_time, calls, total_time
 		ORDER BY
 		 exec_time_ms DESC -- Order by average execution time in descending order
-		LIMIT %d; -- Limit the number of results`
+		LIMIT $4; -- Limit the number of results`
 )
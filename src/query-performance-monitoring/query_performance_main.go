@@ -2,6 +2,7 @@ package queryperformancemonitoring
 
 // this is the main go file for the query_monitoring package
 import (
+	"context"
 	"time"
 
 	"github.com/newrelic/nri-postgresql/src/query-performance-monitoring/validations"
@@ -10,40 +11,48 @@ import (
 
 	"github.com/newrelic/infra-integrations-sdk/v3/integration"
 	"github.com/newrelic/infra-integrations-sdk/v3/log"
+	"github.com/newrelic/infra-integrations-sdk/v3/persist"
 	"github.com/newrelic/nri-postgresql/src/args"
 	"github.com/newrelic/nri-postgresql/src/collection"
 	performancedbconnection "github.com/newrelic/nri-postgresql/src/connection"
 	"github.com/newrelic/nri-postgresql/src/metrics"
 	commonutils "github.com/newrelic/nri-postgresql/src/query-performance-monitoring/common-utils"
+	"github.com/newrelic/nri-postgresql/src/query-performance-monitoring/datamodels"
 	performancemetrics "github.com/newrelic/nri-postgresql/src/query-performance-monitoring/performance-metrics"
 )
 
-func QueryPerformanceMain(args args.ArgumentList, pgIntegration *integration.Integration, databaseMap collection.DatabaseList) {
-	connectionInfo := performancedbconnection.DefaultConnectionInfo(&args)
+// QueryPerformanceMain collects the query performance metrics of the instance. statementSnapshots keeps the
+// pg_stat_statements counters between runs, which slow queries are computed from.
+func QueryPerformanceMain(ctx context.Context, args args.ArgumentList, pgIntegration *integration.Integration, databaseMap collection.DatabaseList, connectionInfo performancedbconnection.Info, statementSnapshots persist.Storer) {
 	if len(databaseMap) == 0 {
 		log.Debug("No databases found")
 		return
 	}
-	newConnection, err := connectionInfo.NewConnection(connectionInfo.DatabaseName())
-	if err != nil {
-		log.Error("Error creating connection: ", err)
-		return
-	}
-	defer newConnection.Close()
-
-	version, versionErr := metrics.CollectVersion(newConnection)
-	if versionErr != nil {
-		log.Error("Error fetching version: ", versionErr)
-		return
-	}
-	versionInt := version.Major
-	if !validations.CheckPostgresVersionSupportForQueryMonitoring(versionInt) {
-		log.Debug("Postgres version: %d is not supported for query monitoring", versionInt)
-		return
-	}
-	cp := common_parameters.SetCommonParameters(args, versionInt, commonutils.GetDatabaseListInString(databaseMap))
-
-	populateQueryPerformanceMetrics(newConnection, pgIntegration, cp, connectionInfo)
+	metrics.RunCollector(ctx, "queryMonitoring", func(ctx context.Context) {
+		newConnection, err := connectionInfo.NewConnection(connectionInfo.DatabaseName())
+		if err != nil {
+			log.Error("Error creating connection: ", err)
+			return
+		}
+		defer newConnection.Close()
+		newConnection = newConnection.WithContext(ctx)
+
+		version, versionErr := metrics.CollectVersion(newConnection)
+		if versionErr != nil {
+			log.Error("Error fetching version: ", versionErr)
+			return
+		}
+		versionInt := version.Major
+		if !validations.CheckPostgresVersionSupportForQueryMonitoring(versionInt) {
+			log.Debug("Postgres version: %d is not supported for query monitoring", versionInt)
+			metrics.SkipCollector(ctx, "query monitoring requires PostgreSQL 12 or later")
+			return
+		}
+		cp := common_parameters.SetCommonParameters(args, versionInt, commonutils.GetDatabaseList(databaseMap))
+		cp.StatementSnapshots = statementSnapshots
+
+		populateQueryPerformanceMetrics(newConnection, pgIntegration, cp, connectionInfo)
+	})
 }
 
 func populateQueryPerformanceMetrics(newConnection *performancedbconnection.PGSQLConnection, pgIntegration *integration.Integration, cp *common_parameters.CommonParameters, connectionInfo performancedbconnection.Info) {
@@ -54,30 +63,49 @@ func populateQueryPerformanceMetrics(newConnection *performancedbconnection.PGSQ
 	}
 
 	if !cp.IsRds {
-		start := time.Now()
-		log.Debug("Starting PopulateWaitEventMetrics at ", start)
-		_ = performancemetrics.PopulateWaitEventMetrics(newConnection, pgIntegration, cp, enabledExtensions)
-		log.Debug("PopulateWaitEventMetrics completed in ", time.Since(start))
-
-		start = time.Now()
-		log.Debug("Starting PopulateBlockingMetrics at ", start)
-		performancemetrics.PopulateBlockingMetrics(newConnection, pgIntegration, cp, enabledExtensions)
-		log.Debug("PopulateBlockingMetrics completed in ", time.Since(start))
-
-		start = time.Now()
-		log.Debug("Starting PopulateSlowRunningMetrics at ", start)
-		slowRunningQueries := performancemetrics.PopulateSlowRunningMetrics(newConnection, pgIntegration, cp, enabledExtensions)
-		log.Debug("PopulateSlowRunningMetrics completed in ", time.Since(start))
-
-		start = time.Now()
-		log.Debug("Starting PopulateIndividualQueryMetrics at ", start)
-		individualQueries := performancemetrics.PopulateIndividualQueryMetrics(newConnection, slowRunningQueries, pgIntegration, cp, enabledExtensions)
-		log.Debug("PopulateIndividualQueryMetrics completed in ", time.Since(start))
-
-		start = time.Now()
-		log.Debug("Starting PopulateExecutionPlanMetrics at ", start)
-		performancemetrics.PopulateExecutionPlanMetrics(individualQueries, pgIntegration, cp, connectionInfo)
-		log.Debug("PopulateExecutionPlanMetrics completed in ", time.Since(start))
+		metrics.RunCollector(newConnection.Context(), "waitEvents", func(ctx context.Context) {
+			start := time.Now()
+			log.Debug("Starting PopulateWaitEventMetrics at ", start)
+			_ = performancemetrics.PopulateWaitEventMetrics(newConnection.WithContext(ctx), pgIntegration, cp, enabledExtensions)
+			log.Debug("PopulateWaitEventMetrics completed in ", time.Since(start))
+		})
+
+		metrics.RunCollector(newConnection.Context(), "blockingSessions", func(ctx context.Context) {
+			start := time.Now()
+			log.Debug("Starting PopulateBlockingMetrics at ", start)
+			performancemetrics.PopulateBlockingMetrics(newConnection.WithContext(ctx), pgIntegration, cp, enabledExtensions)
+			log.Debug("PopulateBlockingMetrics completed in ", time.Since(start))
+		})
+
+		var slowRunningQueries []datamodels.SlowRunningQueryMetrics
+		metrics.RunCollector(newConnection.Context(), "slowQueries", func(ctx context.Context) {
+			start := time.Now()
+			log.Debug("Starting PopulateSlowRunningMetrics at ", start)
+			slowRunningQueries = performancemetrics.PopulateSlowRunningMetrics(newConnection.WithContext(ctx), pgIntegration, cp, enabledExtensions)
+			log.Debug("PopulateSlowRunningMetrics completed in ", time.Since(start))
+		})
+
+		var individualQueries []datamodels.IndividualQueryMetrics
+		metrics.RunCollector(newConnection.Context(), "individualQueries", func(ctx context.Context) {
+			start := time.Now()
+			log.Debug("Starting PopulateIndividualQueryMetrics at ", start)
+			individualQueries = performancemetrics.PopulateIndividualQueryMetrics(newConnection.WithContext(ctx), slowRunningQueries, pgIntegration, cp, enabledExtensions, connectionInfo)
+			log.Debug("PopulateIndividualQueryMetrics completed in ", time.Since(start))
+		})
+
+		metrics.RunCollector(newConnection.Context(), "executionPlans", func(ctx context.Context) {
+			start := time.Now()
+			log.Debug("Starting PopulateExecutionPlanMetrics at ", start)
+			performancemetrics.PopulateExecutionPlanMetrics(ctx, individualQueries, pgIntegration, cp, connectionInfo)
+			log.Debug("PopulateExecutionPlanMetrics completed in ", time.Since(start))
+		})
+
+		metrics.RunCollector(newConnection.Context(), "planChanges", func(ctx context.Context) {
+			start := time.Now()
+			log.Debug("Starting PopulatePlanChangeEvents at ", start)
+			performancemetrics.PopulatePlanChangeEvents(individualQueries, pgIntegration, cp)
+			log.Debug("PopulatePlanChangeEvents completed in ", time.Since(start))
+		})
 	} else {
 		/*
 			Currently, there isn't an extension like pg_stat_monitor for RDS/Aurora that retrieves individual queries along with their CPU
@@ -87,29 +115,48 @@ func populateQueryPerformanceMetrics(newConnection *performancedbconnection.PGSQ
 			for each metric collection query, we can join pg_stat_statements through the query text. This process involves anonymizing and normalizing
 			both individual and slow queries for accurate correlation.
 		*/
-		start := time.Now()
-		log.Debug("Starting PopulateSlowQueriesPgStat at ", start)
-		slowQueries := performancemetrics.PopulateSlowRunningMetricsPgStat(newConnection, pgIntegration, cp, enabledExtensions)
-		log.Debug("PopulateSlowQueriesPgStat completed in ", time.Since(start))
-
-		start = time.Now()
-		log.Debug("Starting PopulateIndividualQueryMetricsPgStat at ", start)
-		individualQueries := performancemetrics.PopulateIndividualQueryMetricsPgStat(slowQueries, pgIntegration, cp)
-		log.Debug("PopulateIndividualQueryMetricsPgStat completed in ", time.Since(start))
-
-		start = time.Now()
-		log.Debug("Starting PopulateExecutionPlanMetrics at ", start)
-		performancemetrics.PopulateExecutionPlanMetrics(individualQueries, pgIntegration, cp, connectionInfo)
-		log.Debug("PopulateExecutionPlanMetrics completed in ", time.Since(start))
-
-		start = time.Now()
-		log.Debug("Starting PopulateWaitEventMetricsPgStat at ", start)
-		_ = performancemetrics.PopulateWaitEventMetricsPgStat(newConnection, pgIntegration, cp, enabledExtensions, slowQueries)
-		log.Debug("PopulateWaitEventMetrics completed in ", time.Since(start))
-
-		start = time.Now()
-		log.Debug("Starting PopulateBlockingMetricsPgStat at ", start)
-		performancemetrics.PopulateBlockingMetricsPgStat(newConnection, pgIntegration, cp, enabledExtensions, slowQueries)
-		log.Debug("PopulateBlockingMetrics completed in ", time.Since(start))
+		var slowQueries []datamodels.SlowRunningQueryMetrics
+		metrics.RunCollector(newConnection.Context(), "slowQueries", func(ctx context.Context) {
+			start := time.Now()
+			log.Debug("Starting PopulateSlowQueriesPgStat at ", start)
+			slowQueries = performancemetrics.PopulateSlowRunningMetricsPgStat(newConnection.WithContext(ctx), pgIntegration, cp, enabledExtensions)
+			log.Debug("PopulateSlowQueriesPgStat completed in ", time.Since(start))
+		})
+
+		var individualQueries []datamodels.IndividualQueryMetrics
+		metrics.RunCollector(newConnection.Context(), "individualQueries", func(ctx context.Context) {
+			start := time.Now()
+			log.Debug("Starting PopulateIndividualQueryMetricsPgStat at ", start)
+			individualQueries = performancemetrics.PopulateIndividualQueryMetricsPgStat(ctx, slowQueries, pgIntegration, cp, connectionInfo)
+			log.Debug("PopulateIndividualQueryMetricsPgStat completed in ", time.Since(start))
+		})
+
+		metrics.RunCollector(newConnection.Context(), "executionPlans", func(ctx context.Context) {
+			start := time.Now()
+			log.Debug("Starting PopulateExecutionPlanMetrics at ", start)
+			performancemetrics.PopulateExecutionPlanMetrics(ctx, individualQueries, pgIntegration, cp, connectionInfo)
+			log.Debug("PopulateExecutionPlanMetrics completed in ", time.Since(start))
+		})
+
+		metrics.RunCollector(newConnection.Context(), "planChanges", func(ctx context.Context) {
+			start := time.Now()
+			log.Debug("Starting PopulatePlanChangeEvents at ", start)
+			performancemetrics.PopulatePlanChangeEvents(individualQueries, pgIntegration, cp)
+			log.Debug("PopulatePlanChangeEvents completed in ", time.Since(start))
+		})
+
+		metrics.RunCollector(newConnection.Context(), "waitEvents", func(ctx context.Context) {
+			start := time.Now()
+			log.Debug("Starting PopulateWaitEventMetricsPgStat at ", start)
+			_ = performancemetrics.PopulateWaitEventMetricsPgStat(newConnection.WithContext(ctx), pgIntegration, cp, enabledExtensions, slowQueries)
+			log.Debug("PopulateWaitEventMetrics completed in ", time.Since(start))
+		})
+
+		metrics.RunCollector(newConnection.Context(), "blockingSessions", func(ctx context.Context) {
+			start := time.Now()
+			log.Debug("Starting PopulateBlockingMetricsPgStat at ", start)
+			performancemetrics.PopulateBlockingMetricsPgStat(newConnection.WithContext(ctx), pgIntegration, cp, enabledExtensions, slowQueries)
+			log.Debug("PopulateBlockingMetrics completed in ", time.Since(start))
+		})
 	}
 }
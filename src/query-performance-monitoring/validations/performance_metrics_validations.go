@@ -3,10 +3,18 @@ package validations
 import (
 	"github.com/newrelic/infra-integrations-sdk/v3/log"
 	performancedbconnection "github.com/newrelic/nri-postgresql/src/connection"
+	"github.com/newrelic/nri-postgresql/src/metrics"
 	commonutils "github.com/newrelic/nri-postgresql/src/query-performance-monitoring/common-utils"
 )
 
+// FetchAllExtensions returns the extensions installed in the database, cached by the capabilities carried by the connection context
 func FetchAllExtensions(conn *performancedbconnection.PGSQLConnection) (map[string]bool, error) {
+	return metrics.CachedCapability(conn.Context(), "extensions", func() (map[string]bool, error) {
+		return fetchAllExtensions(conn)
+	})
+}
+
+func fetchAllExtensions(conn *performancedbconnection.PGSQLConnection) (map[string]bool, error) {
 	rows, err := conn.Queryx("SELECT extname FROM pg_extension")
 	if err != nil {
 		log.Error("Error executing query: ", err.Error())
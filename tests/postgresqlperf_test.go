@@ -102,6 +102,10 @@ func TestIntegrationWithDatabaseLoadPerfEnabled(t *testing.T) {
 					// Start all simulations
 					done := controller.StartAllSimulations(t)
 
+					// Slow queries are reported from the second run, which compares pg_stat_statements with the first one
+					_, _, err := simulation.RunIntegration(container, integrationContainer, *binaryPath, user, psw, database, tt.args...)
+					require.NoError(t, err, "Running Integration Failed")
+
 					time.Sleep(30 * time.Second)
 
 					stdout, stderr, err := simulation.RunIntegration(container, integrationContainer, *binaryPath, user, psw, database, tt.args...)